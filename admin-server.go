@@ -10,10 +10,13 @@ import (
 	"github.com/dgrijalva/jwt-go"
 	router "github.com/gorilla/mux"
 	"github.com/journeymidnight/yig/api"
+	. "github.com/journeymidnight/yig/error"
 	"github.com/journeymidnight/yig/helper"
 	"github.com/journeymidnight/yig/iam"
 	"github.com/journeymidnight/yig/iam/common"
 	"github.com/journeymidnight/yig/log"
+	bus "github.com/journeymidnight/yig/messagebus"
+	"github.com/journeymidnight/yig/messagebus/webhook"
 	meta "github.com/journeymidnight/yig/meta/types"
 	"github.com/journeymidnight/yig/storage"
 	"github.com/prometheus/client_golang/prometheus"
@@ -40,13 +43,29 @@ type objectJson struct {
 }
 
 type cacheJson struct {
-	HitRate float64
+	HitRate     float64
+	Hit         int64
+	Miss        int64
+	NegativeHit int64
+	Coalesced   int64
 }
 
 type usageJson struct {
 	Usage int64
 }
 
+type drainJson struct {
+	Drains []storage.DrainProgress
+}
+
+type batchJobJson struct {
+	Jobs []meta.BatchJob
+}
+
+type webhookJson struct {
+	Targets []webhook.TargetStats
+}
+
 var adminServer *adminServerConfig
 
 type handlerFunc func(http.Handler) http.Handler
@@ -125,11 +144,214 @@ func getCacheHitRatio(w http.ResponseWriter, r *http.Request) {
 	helper.Debugln("enter getCacheHitRatio")
 
 	rate := adminServer.Yig.MetaStorage.Cache.GetCacheHitRatio()
-	b, _ := json.Marshal(cacheJson{HitRate: rate})
+	stats := adminServer.Yig.MetaStorage.Cache.GetCacheStats()
+	b, _ := json.Marshal(cacheJson{
+		HitRate:     rate,
+		Hit:         stats.Hit,
+		Miss:        stats.Miss,
+		NegativeHit: stats.NegativeHit,
+		Coalesced:   stats.Coalesced,
+	})
 	w.Write(b)
 	return
 }
 
+// Numbers in claims are float64, returns `defaultValue` if absent
+func getIntClaim(claims jwt.MapClaims, key string, defaultValue int64) int64 {
+	if v, ok := claims[key].(float64); ok {
+		return int64(v)
+	}
+	return defaultValue
+}
+
+func writeDrainProgress(w http.ResponseWriter, progresses ...storage.DrainProgress) {
+	b, _ := json.Marshal(drainJson{Drains: progresses})
+	w.Write(b)
+}
+
+func startDrain(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(jwt.MapClaims)
+	fsid := claims["fsid"].(string)
+	rateLimit := getIntClaim(claims, "rate_limit", -1)
+	concurrency := getIntClaim(claims, "concurrency", 0)
+
+	progress, err := adminServer.Yig.StartDrain(fsid, rateLimit, int(concurrency))
+	if err != nil {
+		api.WriteErrorResponse(w, r, err)
+		return
+	}
+	writeDrainProgress(w, progress)
+}
+
+func getDrainProgress(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(jwt.MapClaims)
+	fsid, _ := claims["fsid"].(string)
+	if fsid == "" {
+		writeDrainProgress(w, adminServer.Yig.ListDrainProgress()...)
+		return
+	}
+	progress, err := adminServer.Yig.GetDrainProgress(fsid)
+	if err != nil {
+		api.WriteErrorResponse(w, r, err)
+		return
+	}
+	writeDrainProgress(w, progress)
+}
+
+func pauseDrain(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(jwt.MapClaims)
+	fsid := claims["fsid"].(string)
+	progress, err := adminServer.Yig.PauseDrain(fsid)
+	if err != nil {
+		api.WriteErrorResponse(w, r, err)
+		return
+	}
+	writeDrainProgress(w, progress)
+}
+
+func resumeDrain(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(jwt.MapClaims)
+	fsid := claims["fsid"].(string)
+	progress, err := adminServer.Yig.ResumeDrain(fsid)
+	if err != nil {
+		api.WriteErrorResponse(w, r, err)
+		return
+	}
+	writeDrainProgress(w, progress)
+}
+
+func setDrainRateLimit(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(jwt.MapClaims)
+	fsid := claims["fsid"].(string)
+	rateLimit := getIntClaim(claims, "rate_limit", 0)
+	progress, err := adminServer.Yig.SetDrainRateLimit(fsid, rateLimit)
+	if err != nil {
+		api.WriteErrorResponse(w, r, err)
+		return
+	}
+	writeDrainProgress(w, progress)
+}
+
+func setBucketCompression(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(jwt.MapClaims)
+	bucketName := claims["bucket"].(string)
+	compression, _ := claims["compression"].(string)
+
+	err := adminServer.Yig.SetBucketCompression(bucketName, compression)
+	if err != nil {
+		api.WriteErrorResponse(w, r, err)
+		return
+	}
+	getBucketInfo(w, r)
+}
+
+// Delivery metrics of webhook targets, empty if webhook is not configured
+func getWebhookStats(w http.ResponseWriter, r *http.Request) {
+	var stats []webhook.TargetStats
+	if bus.WebhookEnabled() {
+		sender, err := bus.GetWebhookSender()
+		if err != nil {
+			api.WriteErrorResponse(w, r, err)
+			return
+		}
+		if wh, ok := sender.(*webhook.Webhook); ok {
+			stats = wh.Stats()
+		}
+	}
+	b, _ := json.Marshal(webhookJson{Targets: stats})
+	w.Write(b)
+}
+
+// Replication backlog, tasks failing and statistics of workers on this instance
+func getReplicationProgress(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(jwt.MapClaims)
+	limit := getIntClaim(claims, "limit", 100)
+
+	progress, err := adminServer.Yig.GetReplicationProgress(int(limit))
+	if err != nil {
+		api.WriteErrorResponse(w, r, err)
+		return
+	}
+	b, _ := json.Marshal(progress)
+	w.Write(b)
+}
+
+func writeBatchJobs(w http.ResponseWriter, jobs ...meta.BatchJob) {
+	b, _ := json.Marshal(batchJobJson{Jobs: jobs})
+	w.Write(b)
+}
+
+// The job is in claim "job", as the JSON of meta.BatchJob without status
+// and progress
+func createBatchJob(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(jwt.MapClaims)
+	var job meta.BatchJob
+	b, _ := json.Marshal(claims["job"])
+	if err := json.Unmarshal(b, &job); err != nil {
+		api.WriteErrorResponse(w, r, ErrInvalidBatchJob)
+		return
+	}
+	job, err := adminServer.Yig.CreateBatchJob(job)
+	if err != nil {
+		api.WriteErrorResponse(w, r, err)
+		return
+	}
+	writeBatchJobs(w, job)
+}
+
+func getBatchJob(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(jwt.MapClaims)
+	id, _ := claims["id"].(string)
+	if id == "" {
+		jobs, err := adminServer.Yig.ListBatchJobs()
+		if err != nil {
+			api.WriteErrorResponse(w, r, err)
+			return
+		}
+		writeBatchJobs(w, jobs...)
+		return
+	}
+	job, err := adminServer.Yig.GetBatchJob(id)
+	if err != nil {
+		api.WriteErrorResponse(w, r, err)
+		return
+	}
+	writeBatchJobs(w, job)
+}
+
+func pauseBatchJob(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(jwt.MapClaims)
+	id, _ := claims["id"].(string)
+	job, err := adminServer.Yig.PauseBatchJob(id)
+	if err != nil {
+		api.WriteErrorResponse(w, r, err)
+		return
+	}
+	writeBatchJobs(w, job)
+}
+
+func resumeBatchJob(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(jwt.MapClaims)
+	id, _ := claims["id"].(string)
+	job, err := adminServer.Yig.ResumeBatchJob(id)
+	if err != nil {
+		api.WriteErrorResponse(w, r, err)
+		return
+	}
+	writeBatchJobs(w, job)
+}
+
+func cancelBatchJob(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value("claims").(jwt.MapClaims)
+	id, _ := claims["id"].(string)
+	job, err := adminServer.Yig.CancelBatchJob(id)
+	if err != nil {
+		api.WriteErrorResponse(w, r, err)
+		return
+	}
+	writeBatchJobs(w, job)
+}
+
 var handlerFns = []handlerFunc{
 	//	SetJwtMiddlewareHandler,
 }
@@ -150,8 +372,21 @@ func configureAdminHandler() http.Handler {
 	admin.Methods("GET").Path("/usage").HandlerFunc(SetJwtMiddlewareFunc(getUsage))
 	admin.Methods("GET").Path("/user").HandlerFunc(SetJwtMiddlewareFunc(getUserInfo))
 	admin.Methods("GET").Path("/bucket").HandlerFunc(SetJwtMiddlewareFunc(getBucketInfo))
+	admin.Methods("PUT").Path("/bucket/compression").HandlerFunc(SetJwtMiddlewareFunc(setBucketCompression))
 	admin.Methods("GET").Path("/object").HandlerFunc(SetJwtMiddlewareFunc(getObjectInfo))
 	admin.Methods("GET").Path("/cachehit").HandlerFunc(SetJwtMiddlewareFunc(getCacheHitRatio))
+	admin.Methods("GET").Path("/drain").HandlerFunc(SetJwtMiddlewareFunc(getDrainProgress))
+	admin.Methods("PUT").Path("/drain").HandlerFunc(SetJwtMiddlewareFunc(startDrain))
+	admin.Methods("PUT").Path("/drain/pause").HandlerFunc(SetJwtMiddlewareFunc(pauseDrain))
+	admin.Methods("PUT").Path("/drain/resume").HandlerFunc(SetJwtMiddlewareFunc(resumeDrain))
+	admin.Methods("PUT").Path("/drain/ratelimit").HandlerFunc(SetJwtMiddlewareFunc(setDrainRateLimit))
+	admin.Methods("GET").Path("/batchjob").HandlerFunc(SetJwtMiddlewareFunc(getBatchJob))
+	admin.Methods("PUT").Path("/batchjob").HandlerFunc(SetJwtMiddlewareFunc(createBatchJob))
+	admin.Methods("PUT").Path("/batchjob/pause").HandlerFunc(SetJwtMiddlewareFunc(pauseBatchJob))
+	admin.Methods("PUT").Path("/batchjob/resume").HandlerFunc(SetJwtMiddlewareFunc(resumeBatchJob))
+	admin.Methods("PUT").Path("/batchjob/cancel").HandlerFunc(SetJwtMiddlewareFunc(cancelBatchJob))
+	admin.Methods("GET").Path("/webhook").HandlerFunc(SetJwtMiddlewareFunc(getWebhookStats))
+	admin.Methods("GET").Path("/replication").HandlerFunc(SetJwtMiddlewareFunc(getReplicationProgress))
 
 	metrics := NewMetrics("yig")
 	registry := prometheus.NewRegistry()
@@ -73,7 +73,7 @@ func configureServerHandler(c *ServerConfig) http.Handler {
 
 		api.SetLogHandler,
 
-		api.NewAccessLogHandler,
+		api.NewAccessLogHandlerFunc(c.ObjectLayer),
 
 		// This handler must be last one.
 		api.SetGenerateContextHandler,
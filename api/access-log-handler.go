@@ -33,13 +33,16 @@ func NewResponseRecorder(w http.ResponseWriter) *ResponseRecorder {
 }
 
 func (r *ResponseRecorder) Flush() {
-	return
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
 }
 
 type AccessLogHandler struct {
 	handler          http.Handler
 	responseRecorder *ResponseRecorder
 	format           string
+	objectLayer      ObjectLayer
 }
 
 func (a AccessLogHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -49,6 +52,8 @@ func (a AccessLogHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	a.handler.ServeHTTP(a.responseRecorder, r)
 	finishTime := time.Now()
 	a.responseRecorder.requestTime = finishTime.Sub(startTime)
+	bucketInfo := r.Context().Value(RequestContextKey).(RequestContext).BucketInfo
+	a.responseRecorder.bucketLogging = bucketInfo != nil && bucketInfo.Logging.LoggingEnabled != nil
 
 	newReplacer := NewReplacer(r, a.responseRecorder, "-")
 	response := newReplacer.Replace(a.format)
@@ -57,10 +62,15 @@ func (a AccessLogHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// send the entrys in access logger to message bus.
 	elems := newReplacer.GetReplacedValues()
 	a.notify(elems)
+	if a.responseRecorder.bucketLogging {
+		a.objectLayer.AppendBucketLog(bucketInfo,
+			bucketLogRecord(r, a.responseRecorder, elems, startTime))
+	}
 }
 
 func (a AccessLogHandler) notify(elems map[string]string) {
-	if !helper.CONFIG.MsgBus.Enabled {
+	// topic could be empty if message bus is only for notifications
+	if !helper.CONFIG.MsgBus.Enabled || helper.CONFIG.MsgBus.Topic == "" {
 		return
 	}
 	if len(elems) == 0 {
@@ -95,9 +105,13 @@ func (a AccessLogHandler) notify(elems map[string]string) {
 	helper.Logger.Printf(20, "succeed to send message [%v] to message bus.", elems)
 }
 
-func NewAccessLogHandler(handler http.Handler, _ *meta.Meta) http.Handler {
-	return AccessLogHandler{
-		handler: handler,
-		format:  CombinedLogFormat,
+// Access records of buckets with logging enabled are also appended to `objectLayer`
+func NewAccessLogHandlerFunc(objectLayer ObjectLayer) HandlerFunc {
+	return func(handler http.Handler, _ *meta.Meta) http.Handler {
+		return AccessLogHandler{
+			handler:     handler,
+			format:      CombinedLogFormat,
+			objectLayer: objectLayer,
+		}
 	}
 }
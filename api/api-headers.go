@@ -39,6 +39,38 @@ func EncodeResponse(response interface{}) []byte {
 	return bytesBuffer.Bytes()
 }
 
+// CRC32C of the whole object, in hex
+func setChecksumHeader(w http.ResponseWriter, checksum string) {
+	if checksum != "" {
+		w.Header().Set("X-Yig-Checksum-Crc32c", checksum)
+	}
+}
+
+// Checksum requested by clients with x-amz-checksum-algorithm, base64 encoded
+func setRequestedChecksumHeaders(w http.ResponseWriter, algorithm, value, checksumType string) {
+	if algorithm == "" || value == "" {
+		return
+	}
+	w.Header().Set(ChecksumHeader(algorithm), value)
+	if checksumType != "" {
+		w.Header().Set("X-Amz-Checksum-Type", checksumType)
+	}
+}
+
+// Checksum of the whole object is returned only if asked by x-amz-checksum-mode
+func setObjectChecksumHeaders(w http.ResponseWriter, r *http.Request, object *meta.Object,
+	contentRange *HttpRange) {
+
+	if r.Header.Get("X-Amz-Checksum-Mode") != "ENABLED" {
+		return
+	}
+	if contentRange != nil && contentRange.OffsetBegin > -1 {
+		return
+	}
+	algorithm, value, checksumType := object.GetChecksum()
+	setRequestedChecksumHeaders(w, algorithm, value, checksumType)
+}
+
 // Write object header
 func SetObjectHeaders(w http.ResponseWriter, object *meta.Object, contentRange *HttpRange) {
 	// set object-related metadata headers
@@ -59,12 +91,27 @@ func SetObjectHeaders(w http.ResponseWriter, object *meta.Object, contentRange *
 		w.Header().Set("Cache-Control", "no-store")
 	}
 
+	setChecksumHeader(w, object.Checksum)
 	w.Header().Set("X-Amz-Object-Type", object.ObjectTypeToString())
 	w.Header().Set("X-Amz-Storage-Class", object.StorageClass.ToString())
 	w.Header().Set("Content-Length", strconv.FormatInt(object.Size, 10))
 	if object.Type == meta.ObjectTypeAppendable {
 		w.Header().Set("X-Amz-Next-Append-Position", strconv.FormatInt(object.Size, 10))
 	}
+	if len(object.Tagging.TagSet) > 0 {
+		w.Header().Set("X-Amz-Tagging-Count", strconv.Itoa(len(object.Tagging.TagSet)))
+	}
+	if object.ReplicationStatus != "" {
+		w.Header().Set("X-Amz-Replication-Status", object.ReplicationStatus)
+	}
+	if object.Restore != nil {
+		if object.Restore.Ongoing {
+			w.Header().Set("X-Amz-Restore", `ongoing-request="true"`)
+		} else {
+			w.Header().Set("X-Amz-Restore", `ongoing-request="false", expiry-date="`+
+				object.Restore.ExpiryDate.UTC().Format(http.TimeFormat)+`"`)
+		}
+	}
 
 	// for providing ranged content
 	if contentRange != nil && contentRange.OffsetBegin > -1 {
@@ -245,6 +245,11 @@ func WriteErrorResponseHeaders(w http.ResponseWriter, err error) {
 
 	//ResponseRecorder
 	w.(*ResponseRecorder).status = status
+	if ok {
+		w.(*ResponseRecorder).errorCode = apiErrorCode.AwsErrorCode()
+	} else {
+		w.(*ResponseRecorder).errorCode = "InternalError"
+	}
 
 	w.WriteHeader(status)
 }
@@ -31,6 +31,13 @@ func RegisterAPIRouter(mux *router.Router, api ObjectAPIHandlers) {
 	// API Router
 	apiRouter := mux.NewRoute().PathPrefix("/").Subrouter()
 
+	// Website router, matches bucket_name.website.domain/object_name, registered
+	// first since website domains may be subdomains of s3 domains
+	for _, domain := range helper.CONFIG.WebsiteDomain {
+		website := apiRouter.Host("{bucket:.+}." + domain).Subrouter()
+		website.Methods("GET", "HEAD").Path("/{object:.*}").HandlerFunc(api.WebsiteHandler)
+	}
+
 	var routers []*router.Router
 	for _, domain := range helper.CONFIG.S3Domain {
 		// Bucket router, matches domain.name/bucket_name/object_name
@@ -63,6 +70,9 @@ func RegisterAPIRouter(mux *router.Router, api ObjectAPIHandlers) {
 		// AbortMultipartUpload
 		bucket.Methods("DELETE").Path("/{object:.+}").HandlerFunc(api.AbortMultipartUploadHandler).
 			Queries("uploadId", "{uploadId:.*}")
+		// RenameObject
+		bucket.Methods("PUT").Path("/{object:.+}").HandlerFunc(api.RenameObjectHandler).
+			Queries("rename", "")
 		// CopyObject
 		bucket.Methods("PUT").Path("/{object:.+}").HeadersRegexp("X-Amz-Copy-Source", ".*?(/).*?").
 			HandlerFunc(api.CopyObjectHandler)
@@ -72,6 +82,24 @@ func RegisterAPIRouter(mux *router.Router, api ObjectAPIHandlers) {
 		// GetObjectAcl
 		bucket.Methods("GET").Path("/{object:.+}").HandlerFunc(api.GetObjectAclHandler).
 			Queries("acl", "")
+		// PutObjectTagging
+		bucket.Methods("PUT").Path("/{object:.+}").HandlerFunc(api.PutObjectTaggingHandler).
+			Queries("tagging", "")
+		// GetObjectTagging
+		bucket.Methods("GET").Path("/{object:.+}").HandlerFunc(api.GetObjectTaggingHandler).
+			Queries("tagging", "")
+		// DeleteObjectTagging
+		bucket.Methods("DELETE").Path("/{object:.+}").HandlerFunc(api.DeleteObjectTaggingHandler).
+			Queries("tagging", "")
+		// GetObjectAttributes
+		bucket.Methods("GET").Path("/{object:.+}").HandlerFunc(api.GetObjectAttributesHandler).
+			Queries("attributes", "")
+		// SelectObjectContent
+		bucket.Methods("POST").Path("/{object:.+}").HandlerFunc(api.SelectObjectContentHandler).
+			Queries("select", "")
+		// RestoreObject
+		bucket.Methods("POST").Path("/{object:.+}").HandlerFunc(api.RestoreObjectHandler).
+			Queries("restore", "")
 
 		// AppendObject
 		bucket.Methods("POST").Path("/{object:.+}").HandlerFunc(api.AppendObjectHandler).Queries("append", "")
@@ -107,6 +135,40 @@ func RegisterAPIRouter(mux *router.Router, api ObjectAPIHandlers) {
 		bucket.Methods("GET").HandlerFunc(api.GetBucketCorsHandler).Queries("cors", "")
 		// DeleteBucketCORS
 		bucket.Methods("DELETE").HandlerFunc(api.DeleteBucketCorsHandler).Queries("cors", "")
+		// PutBucketNotification
+		bucket.Methods("PUT").HandlerFunc(api.PutBucketNotificationHandler).Queries("notification", "")
+		// GetBucketNotification
+		bucket.Methods("GET").HandlerFunc(api.GetBucketNotificationHandler).Queries("notification", "")
+		// PutBucketLogging
+		bucket.Methods("PUT").HandlerFunc(api.PutBucketLoggingHandler).Queries("logging", "")
+		// GetBucketLogging
+		bucket.Methods("GET").HandlerFunc(api.GetBucketLoggingHandler).Queries("logging", "")
+		// PutBucketTagging
+		bucket.Methods("PUT").HandlerFunc(api.PutBucketTaggingHandler).Queries("tagging", "")
+		// GetBucketTagging
+		bucket.Methods("GET").HandlerFunc(api.GetBucketTaggingHandler).Queries("tagging", "")
+		// DeleteBucketTagging
+		bucket.Methods("DELETE").HandlerFunc(api.DeleteBucketTaggingHandler).Queries("tagging", "")
+		// PutBucketWebsite
+		bucket.Methods("PUT").HandlerFunc(api.PutBucketWebsiteHandler).Queries("website", "")
+		// GetBucketWebsite
+		bucket.Methods("GET").HandlerFunc(api.GetBucketWebsiteHandler).Queries("website", "")
+		// DeleteBucketWebsite
+		bucket.Methods("DELETE").HandlerFunc(api.DeleteBucketWebsiteHandler).Queries("website", "")
+		// PutBucketReplication
+		bucket.Methods("PUT").HandlerFunc(api.PutBucketReplicationHandler).Queries("replication", "")
+		// GetBucketReplication
+		bucket.Methods("GET").HandlerFunc(api.GetBucketReplicationHandler).Queries("replication", "")
+		// DeleteBucketReplication
+		bucket.Methods("DELETE").HandlerFunc(api.DeleteBucketReplicationHandler).Queries("replication", "")
+		// PutBucketInventory
+		bucket.Methods("PUT").HandlerFunc(api.PutBucketInventoryHandler).Queries("inventory", "")
+		// GetBucketInventory
+		bucket.Methods("GET").HandlerFunc(api.GetBucketInventoryHandler).Queries("inventory", "", "id", "{id:.*}")
+		// ListBucketInventory
+		bucket.Methods("GET").HandlerFunc(api.ListBucketInventoryHandler).Queries("inventory", "")
+		// DeleteBucketInventory
+		bucket.Methods("DELETE").HandlerFunc(api.DeleteBucketInventoryHandler).Queries("inventory", "")
 		// PutLifeCycleConfig
 		bucket.Methods("PUT").HandlerFunc(api.PutBucketLifeCycleHandler).Queries("lifecycle", "")
 		// GetLifeCycleConfig
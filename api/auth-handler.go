@@ -1,15 +1,21 @@
 package api
 
 import (
+	"bytes"
+	"io"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"regexp"
 	"strings"
 
+	"github.com/journeymidnight/yig/api/datatype"
 	"github.com/journeymidnight/yig/api/datatype/policy"
+	"github.com/journeymidnight/yig/api/datatype/policy/condition"
 	. "github.com/journeymidnight/yig/error"
 	"github.com/journeymidnight/yig/helper"
 	"github.com/journeymidnight/yig/iam/common"
+	meta "github.com/journeymidnight/yig/meta/types"
 	"github.com/journeymidnight/yig/signature"
 )
 
@@ -55,11 +61,15 @@ func IsBucketPolicyAllowed(c common.Credential, api ObjectAPIHandlers, r *http.R
 	}
 	helper.Logger.Println(5, "bucket.OwnerId:", bucket.OwnerId, "not equals c.UserId:", c.UserId)
 	helper.Debugln("GetBucketPolicy:", bucket.Policy)
+	conditionValues := getConditionValues(r, "")
+	if objectName != "" {
+		api.addObjectTagConditionValues(conditionValues, r, bucket, objectName)
+	}
 	policyResult := bucket.Policy.IsAllowed(policy.Args{
 		AccountName:     c.UserId,
 		Action:          action,
 		BucketName:      bucketName,
-		ConditionValues: getConditionValues(r, ""),
+		ConditionValues: conditionValues,
 		IsOwner:         false,
 		ObjectName:      objectName,
 	})
@@ -104,6 +114,44 @@ func getConditionValues(request *http.Request, locationConstraint string) map[st
 	return args
 }
 
+// Add values of "ExistingObjectTag/<key>" and "RequestObjectTag/<key>", only if
+// used by the bucket policy, since the object or request body has to be read.
+func (api ObjectAPIHandlers) addObjectTagConditionValues(values map[string][]string, r *http.Request,
+	bucket *meta.Bucket, objectName string) {
+
+	var requestTagging, existingTagging datatype.Tagging
+	var err error
+	if bucket.Policy.HasConditionKey(condition.S3RequestObjectTag) {
+		if _, ok := r.URL.Query()["tagging"]; ok && r.Method == http.MethodPut {
+			// body of PutObjectTagging, read and put back
+			buffer, _ := ioutil.ReadAll(io.LimitReader(r.Body, datatype.MAX_TAGGING_SIZE))
+			r.Body = ioutil.NopCloser(io.MultiReader(bytes.NewReader(buffer), r.Body))
+			requestTagging, err = datatype.TaggingFromXml(buffer, datatype.MaxObjectTagCount)
+		} else {
+			requestTagging, err = datatype.TaggingFromHeader(r.Header.Get("X-Amz-Tagging"))
+		}
+		if err != nil {
+			helper.Debugln("Invalid tags of request to", bucket.Name, objectName, "err:", err)
+		}
+	}
+	if bucket.Policy.HasConditionKey(condition.S3ExistingObjectTag) {
+		// only to read tags, ACL of the object does not matter
+		object, err := api.ObjectAPI.GetObjectInfo(bucket.Name, objectName, r.URL.Query().Get("versionId"),
+			common.Credential{AllowOtherUserAccess: true})
+		if err == nil {
+			existingTagging = object.Tagging
+		}
+	}
+	for _, tag := range requestTagging.TagSet {
+		key := condition.NewObjectTagKey(condition.S3RequestObjectTag, tag.Key).Name()
+		values[key] = []string{tag.Value}
+	}
+	for _, tag := range existingTagging.TagSet {
+		key := condition.NewObjectTagKey(condition.S3ExistingObjectTag, tag.Key).Name()
+		values[key] = []string{tag.Value}
+	}
+}
+
 var (
 	// De-facto standard header keys.
 	xForwardedFor = http.CanonicalHeaderKey("X-Forwarded-For")
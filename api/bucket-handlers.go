@@ -296,6 +296,7 @@ func (api ObjectAPIHandlers) DeleteMultipleObjectsHandler(w http.ResponseWriter,
 		result, err := api.ObjectAPI.DeleteObject(bucket, object.ObjectName,
 			object.VersionId, credential)
 		if err == nil {
+			api.sendDeleteEvent(r, credential, object.ObjectName, result)
 			deletedObjects = append(deletedObjects, ObjectIdentifier{
 				ObjectName:   object.ObjectName,
 				VersionId:    object.VersionId,
@@ -406,6 +407,10 @@ func (api ObjectAPIHandlers) PutBucketLifeCycleHandler(w http.ResponseWriter, r
 		WriteErrorResponse(w, r, ErrInternalError)
 		return
 	}
+	if err = lc.Validate(); err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
 
 	helper.Debugln("Set LC:", lc)
 	err = api.ObjectAPI.SetBucketLc(bucket, lc, credential)
@@ -651,6 +656,564 @@ func (api ObjectAPIHandlers) GetBucketCorsHandler(w http.ResponseWriter, r *http
 	WriteSuccessResponse(w, corsBuffer)
 }
 
+// PutBucketNotificationHandler - PUT Bucket notification
+// ----------
+// An empty NotificationConfiguration disables notifications of the bucket.
+func (api ObjectAPIHandlers) PutBucketNotificationHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucket"]
+
+	var credential common.Credential
+	var err error
+	if credential, err = signature.IsReqAuthenticated(r); err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+
+	// If Content-Length is unknown or zero, deny the request.
+	if !contains(r.TransferEncoding, "chunked") {
+		if r.ContentLength == -1 || r.ContentLength == 0 {
+			WriteErrorResponse(w, r, ErrMissingContentLength)
+			return
+		}
+		// If Content-Length is greater than maximum allowed notification size.
+		if r.ContentLength > MAX_NOTIFICATION_SIZE {
+			WriteErrorResponse(w, r, ErrEntityTooLarge)
+			return
+		}
+	}
+
+	notificationBuffer, err := ioutil.ReadAll(io.LimitReader(r.Body, MAX_NOTIFICATION_SIZE))
+	if err != nil {
+		helper.ErrorIf(err, "Unable to read notification body")
+		WriteErrorResponse(w, r, ErrInternalError)
+		return
+	}
+
+	notification, err := NotificationFromXml(notificationBuffer)
+	if err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+	err = checkNotificationTopics(notification)
+	if err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+	err = api.ObjectAPI.SetBucketNotification(bucketName, notification, credential)
+	if err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+	WriteSuccessResponse(w, nil)
+}
+
+func (api ObjectAPIHandlers) GetBucketNotificationHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucket"]
+
+	var credential common.Credential
+	var err error
+	if credential, err = signature.IsReqAuthenticated(r); err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+
+	notification, err := api.ObjectAPI.GetBucketNotification(bucketName, credential)
+	if err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+
+	notificationBuffer, err := xmlFormat(notification)
+	if err != nil {
+		helper.ErrorIf(err, "Failed to marshal notification XML for bucket %s", bucketName)
+		WriteErrorResponse(w, r, ErrInternalError)
+		return
+	}
+
+	setXmlHeader(w, notificationBuffer)
+	WriteSuccessResponse(w, notificationBuffer)
+}
+
+// PutBucketLoggingHandler - PUT Bucket logging
+// ----------
+// An empty BucketLoggingStatus disables logging of the bucket.
+func (api ObjectAPIHandlers) PutBucketLoggingHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucket"]
+
+	var credential common.Credential
+	var err error
+	if credential, err = signature.IsReqAuthenticated(r); err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+
+	// If Content-Length is unknown or zero, deny the request.
+	if !contains(r.TransferEncoding, "chunked") {
+		if r.ContentLength == -1 || r.ContentLength == 0 {
+			WriteErrorResponse(w, r, ErrMissingContentLength)
+			return
+		}
+		// If Content-Length is greater than maximum allowed logging size.
+		if r.ContentLength > MAX_LOGGING_SIZE {
+			WriteErrorResponse(w, r, ErrEntityTooLarge)
+			return
+		}
+	}
+
+	loggingBuffer, err := ioutil.ReadAll(io.LimitReader(r.Body, MAX_LOGGING_SIZE))
+	if err != nil {
+		helper.ErrorIf(err, "Unable to read logging body")
+		WriteErrorResponse(w, r, ErrInternalError)
+		return
+	}
+
+	logging, err := BucketLoggingFromXml(loggingBuffer)
+	if err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+	err = api.ObjectAPI.SetBucketLogging(bucketName, logging, credential)
+	if err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+	WriteSuccessResponse(w, nil)
+}
+
+func (api ObjectAPIHandlers) GetBucketLoggingHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucket"]
+
+	var credential common.Credential
+	var err error
+	if credential, err = signature.IsReqAuthenticated(r); err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+
+	logging, err := api.ObjectAPI.GetBucketLogging(bucketName, credential)
+	if err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+	logging.Xmlns = XMLNS
+
+	loggingBuffer, err := xmlFormat(logging)
+	if err != nil {
+		helper.ErrorIf(err, "Failed to marshal logging XML for bucket %s", bucketName)
+		WriteErrorResponse(w, r, ErrInternalError)
+		return
+	}
+
+	setXmlHeader(w, loggingBuffer)
+	WriteSuccessResponse(w, loggingBuffer)
+}
+
+func (api ObjectAPIHandlers) PutBucketTaggingHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucket"]
+
+	var credential common.Credential
+	var err error
+	if credential, err = signature.IsReqAuthenticated(r); err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+
+	// If Content-Length is unknown or zero, deny the request.
+	if !contains(r.TransferEncoding, "chunked") {
+		if r.ContentLength == -1 || r.ContentLength == 0 {
+			WriteErrorResponse(w, r, ErrMissingContentLength)
+			return
+		}
+		// If Content-Length is greater than maximum allowed tagging size.
+		if r.ContentLength > MAX_TAGGING_SIZE {
+			WriteErrorResponse(w, r, ErrEntityTooLarge)
+			return
+		}
+	}
+
+	taggingBuffer, err := ioutil.ReadAll(io.LimitReader(r.Body, MAX_TAGGING_SIZE))
+	if err != nil {
+		helper.ErrorIf(err, "Unable to read tagging body")
+		WriteErrorResponse(w, r, ErrInternalError)
+		return
+	}
+
+	tagging, err := TaggingFromXml(taggingBuffer, MaxBucketTagCount)
+	if err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+	err = api.ObjectAPI.SetBucketTagging(bucketName, tagging, credential)
+	if err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+	WriteSuccessNoContent(w)
+}
+
+func (api ObjectAPIHandlers) GetBucketTaggingHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucket"]
+
+	var credential common.Credential
+	var err error
+	if credential, err = signature.IsReqAuthenticated(r); err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+
+	tagging, err := api.ObjectAPI.GetBucketTagging(bucketName, credential)
+	if err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+	tagging.Xmlns = XMLNS
+
+	taggingBuffer, err := xmlFormat(tagging)
+	if err != nil {
+		helper.ErrorIf(err, "Failed to marshal tagging XML for bucket %s", bucketName)
+		WriteErrorResponse(w, r, ErrInternalError)
+		return
+	}
+
+	setXmlHeader(w, taggingBuffer)
+	WriteSuccessResponse(w, taggingBuffer)
+}
+
+func (api ObjectAPIHandlers) DeleteBucketTaggingHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucket"]
+
+	var credential common.Credential
+	var err error
+	if credential, err = signature.IsReqAuthenticated(r); err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+
+	err = api.ObjectAPI.SetBucketTagging(bucketName, Tagging{}, credential)
+	if err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+	WriteSuccessNoContent(w)
+}
+
+func (api ObjectAPIHandlers) PutBucketWebsiteHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucket"]
+
+	var credential common.Credential
+	var err error
+	if credential, err = signature.IsReqAuthenticated(r); err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+
+	// If Content-Length is unknown or zero, deny the request.
+	if !contains(r.TransferEncoding, "chunked") {
+		if r.ContentLength == -1 || r.ContentLength == 0 {
+			WriteErrorResponse(w, r, ErrMissingContentLength)
+			return
+		}
+		// If Content-Length is greater than maximum allowed website size.
+		if r.ContentLength > MAX_WEBSITE_SIZE {
+			WriteErrorResponse(w, r, ErrEntityTooLarge)
+			return
+		}
+	}
+
+	websiteBuffer, err := ioutil.ReadAll(io.LimitReader(r.Body, MAX_WEBSITE_SIZE))
+	if err != nil {
+		helper.ErrorIf(err, "Unable to read website body")
+		WriteErrorResponse(w, r, ErrInternalError)
+		return
+	}
+
+	website, err := WebsiteFromXml(websiteBuffer)
+	if err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+	err = api.ObjectAPI.SetBucketWebsite(bucketName, website, credential)
+	if err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+	WriteSuccessResponse(w, nil)
+}
+
+func (api ObjectAPIHandlers) GetBucketWebsiteHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucket"]
+
+	var credential common.Credential
+	var err error
+	if credential, err = signature.IsReqAuthenticated(r); err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+
+	website, err := api.ObjectAPI.GetBucketWebsite(bucketName, credential)
+	if err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+	website.Xmlns = XMLNS
+
+	websiteBuffer, err := xmlFormat(website)
+	if err != nil {
+		helper.ErrorIf(err, "Failed to marshal website XML for bucket %s", bucketName)
+		WriteErrorResponse(w, r, ErrInternalError)
+		return
+	}
+
+	setXmlHeader(w, websiteBuffer)
+	WriteSuccessResponse(w, websiteBuffer)
+}
+
+func (api ObjectAPIHandlers) DeleteBucketWebsiteHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucket"]
+
+	var credential common.Credential
+	var err error
+	if credential, err = signature.IsReqAuthenticated(r); err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+
+	err = api.ObjectAPI.SetBucketWebsite(bucketName, WebsiteConfiguration{}, credential)
+	if err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+	WriteSuccessNoContent(w)
+}
+
+func (api ObjectAPIHandlers) PutBucketReplicationHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucket"]
+
+	var credential common.Credential
+	var err error
+	if credential, err = signature.IsReqAuthenticated(r); err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+
+	// If Content-Length is unknown or zero, deny the request.
+	if !contains(r.TransferEncoding, "chunked") {
+		if r.ContentLength == -1 || r.ContentLength == 0 {
+			WriteErrorResponse(w, r, ErrMissingContentLength)
+			return
+		}
+		// If Content-Length is greater than maximum allowed replication size.
+		if r.ContentLength > MAX_REPLICATION_SIZE {
+			WriteErrorResponse(w, r, ErrEntityTooLarge)
+			return
+		}
+	}
+
+	replicationBuffer, err := ioutil.ReadAll(io.LimitReader(r.Body, MAX_REPLICATION_SIZE))
+	if err != nil {
+		helper.ErrorIf(err, "Unable to read replication body")
+		WriteErrorResponse(w, r, ErrInternalError)
+		return
+	}
+
+	replication, err := ReplicationFromXml(replicationBuffer)
+	if err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+	err = api.ObjectAPI.SetBucketReplication(bucketName, replication, credential)
+	if err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+	WriteSuccessResponse(w, nil)
+}
+
+func (api ObjectAPIHandlers) GetBucketReplicationHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucket"]
+
+	var credential common.Credential
+	var err error
+	if credential, err = signature.IsReqAuthenticated(r); err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+
+	replication, err := api.ObjectAPI.GetBucketReplication(bucketName, credential)
+	if err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+	replication.Xmlns = XMLNS
+
+	replicationBuffer, err := xmlFormat(replication)
+	if err != nil {
+		helper.ErrorIf(err, "Failed to marshal replication XML for bucket %s", bucketName)
+		WriteErrorResponse(w, r, ErrInternalError)
+		return
+	}
+
+	setXmlHeader(w, replicationBuffer)
+	WriteSuccessResponse(w, replicationBuffer)
+}
+
+func (api ObjectAPIHandlers) DeleteBucketReplicationHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucket"]
+
+	var credential common.Credential
+	var err error
+	if credential, err = signature.IsReqAuthenticated(r); err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+
+	err = api.ObjectAPI.SetBucketReplication(bucketName, ReplicationConfiguration{}, credential)
+	if err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+	WriteSuccessNoContent(w)
+}
+
+func (api ObjectAPIHandlers) PutBucketInventoryHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucket"]
+
+	var credential common.Credential
+	var err error
+	if credential, err = signature.IsReqAuthenticated(r); err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+
+	// If Content-Length is unknown or zero, deny the request.
+	if !contains(r.TransferEncoding, "chunked") {
+		if r.ContentLength == -1 || r.ContentLength == 0 {
+			WriteErrorResponse(w, r, ErrMissingContentLength)
+			return
+		}
+		// If Content-Length is greater than maximum allowed inventory size.
+		if r.ContentLength > MAX_INVENTORY_SIZE {
+			WriteErrorResponse(w, r, ErrEntityTooLarge)
+			return
+		}
+	}
+
+	inventoryBuffer, err := ioutil.ReadAll(io.LimitReader(r.Body, MAX_INVENTORY_SIZE))
+	if err != nil {
+		helper.ErrorIf(err, "Unable to read inventory body")
+		WriteErrorResponse(w, r, ErrInternalError)
+		return
+	}
+
+	inventory, err := InventoryFromXml(inventoryBuffer)
+	if err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+	if inventory.Id != r.URL.Query().Get("id") {
+		WriteErrorResponse(w, r, ErrInvalidInventoryConfiguration)
+		return
+	}
+	err = api.ObjectAPI.SetBucketInventory(bucketName, inventory, credential)
+	if err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+	WriteSuccessResponse(w, nil)
+}
+
+func (api ObjectAPIHandlers) GetBucketInventoryHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucket"]
+
+	var credential common.Credential
+	var err error
+	if credential, err = signature.IsReqAuthenticated(r); err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+
+	inventory, err := api.ObjectAPI.GetBucketInventory(bucketName, vars["id"], credential)
+	if err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+	inventory.Xmlns = XMLNS
+
+	inventoryBuffer, err := xmlFormat(inventory)
+	if err != nil {
+		helper.ErrorIf(err, "Failed to marshal inventory XML for bucket %s", bucketName)
+		WriteErrorResponse(w, r, ErrInternalError)
+		return
+	}
+
+	setXmlHeader(w, inventoryBuffer)
+	WriteSuccessResponse(w, inventoryBuffer)
+}
+
+func (api ObjectAPIHandlers) ListBucketInventoryHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucket"]
+
+	var credential common.Credential
+	var err error
+	if credential, err = signature.IsReqAuthenticated(r); err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+
+	result, err := api.ObjectAPI.ListBucketInventory(bucketName,
+		r.URL.Query().Get("continuation-token"), credential)
+	if err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+	result.Xmlns = XMLNS
+
+	resultBuffer, err := xmlFormat(result)
+	if err != nil {
+		helper.ErrorIf(err, "Failed to marshal inventory list XML for bucket %s", bucketName)
+		WriteErrorResponse(w, r, ErrInternalError)
+		return
+	}
+
+	setXmlHeader(w, resultBuffer)
+	WriteSuccessResponse(w, resultBuffer)
+}
+
+func (api ObjectAPIHandlers) DeleteBucketInventoryHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucket"]
+
+	var credential common.Credential
+	var err error
+	if credential, err = signature.IsReqAuthenticated(r); err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+
+	err = api.ObjectAPI.DeleteBucketInventory(bucketName, r.URL.Query().Get("id"), credential)
+	if err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+	WriteSuccessNoContent(w)
+}
+
 func (api ObjectAPIHandlers) GetBucketVersioningHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	bucketName := vars["bucket"]
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/journeymidnight/yig/helper"
+	"github.com/journeymidnight/yig/signature"
+)
+
+// Sub-resources named in operations of bucket access logs
+var bucketLogResources = []string{"acl", "cors", "inventory", "lifecycle", "logging", "notification", "policy",
+	"rename", "replication", "restore", "select", "tagging", "uploads", "versioning", "versions", "website"}
+
+// Format the access record of `r` in the format of S3 server access logs, see
+// https://docs.aws.amazon.com/AmazonS3/latest/dev/LogFormat.html
+// `elems` are values replaced in the access log of yig.
+func bucketLogRecord(r *http.Request, rr *ResponseRecorder, elems map[string]string,
+	startTime time.Time) string {
+
+	ctx := r.Context().Value(RequestContextKey).(RequestContext)
+	bucket := ctx.BucketInfo
+	_, objectName := GetBucketAndObjectInfoFromRequest(r)
+	var objectSize int64
+	if ctx.ObjectInfo != nil {
+		objectSize = ctx.ObjectInfo.Size
+	}
+	fields := []string{
+		bucket.OwnerId,
+		bucket.Name,
+		startTime.Format("[02/Jan/2006:15:04:05 -0700]"),
+		GetSourceIP(r),
+		orDash(elems["requester_id"]),
+		ctx.RequestId,
+		bucketLogOperation(r, objectName),
+		orDash(url.QueryEscape(objectName)),
+		"\"" + r.Method + " " + r.RequestURI + " " + r.Proto + "\"",
+		strconv.Itoa(rr.status),
+		orDash(rr.errorCode),
+		orDash(strconv.FormatInt(rr.size, 10)),
+		orDash(strconv.FormatInt(objectSize, 10)),
+		strconv.FormatInt(rr.requestTime.Nanoseconds()/1e6, 10),
+		"-", // turn around time
+		"\"" + orDash(r.Header.Get("Referer")) + "\"",
+		"\"" + orDash(r.Header.Get("User-Agent")) + "\"",
+		orDash(r.URL.Query().Get("versionId")),
+		helper.CONFIG.InstanceId,
+	}
+	signatureVersion, authType := "-", "-"
+	switch signature.GetRequestAuthType(r) {
+	case signature.AuthTypeSignedV4, signature.AuthTypeStreamingSigned:
+		signatureVersion, authType = "SigV4", "AuthHeader"
+	case signature.AuthTypePresignedV4:
+		signatureVersion, authType = "SigV4", "QueryString"
+	case signature.AuthTypeSignedV2:
+		signatureVersion, authType = "SigV2", "AuthHeader"
+	case signature.AuthTypePresignedV2:
+		signatureVersion, authType = "SigV2", "QueryString"
+	case signature.AuthTypePostPolicy:
+		authType = "PostPolicy"
+	}
+	fields = append(fields, signatureVersion, "-", authType, r.Host, tlsVersionName(r))
+	return strings.Join(fields, " ")
+}
+
+// Operation in the format of REST.<method>.<resource>, e.g. REST.PUT.OBJECT
+func bucketLogOperation(r *http.Request, objectName string) string {
+	resource := "BUCKET"
+	if objectName != "" {
+		resource = "OBJECT"
+	}
+	query := r.URL.Query()
+	if query.Get("uploadId") != "" {
+		if query.Get("partNumber") != "" {
+			resource = "PART"
+		} else {
+			resource = "UPLOAD"
+		}
+	} else {
+		for _, name := range bucketLogResources {
+			if _, ok := query[name]; ok {
+				resource = strings.ToUpper(name)
+				break
+			}
+		}
+	}
+	return "REST." + r.Method + "." + resource
+}
+
+func tlsVersionName(r *http.Request) string {
+	if r.TLS == nil {
+		return "-"
+	}
+	switch r.TLS.Version {
+	case tls.VersionTLS10:
+		return "TLSv1"
+	case tls.VersionTLS11:
+		return "TLSv1.1"
+	case tls.VersionTLS12:
+		return "TLSv1.2"
+	case tls.VersionTLS13:
+		return "TLSv1.3"
+	}
+	return "-"
+}
+
+func orDash(s string) string {
+	if s == "" || s == "0" {
+		return "-"
+	}
+	return s
+}
@@ -118,6 +118,7 @@ type Part struct {
 	ETag         string
 	LastModified string
 	Size         int64
+	Checksums
 }
 
 // ListPartsResponse - format for list parts response.
@@ -235,6 +236,12 @@ type CopyObjectPartResponse struct {
 	ETag         string
 }
 
+// RenameObjectResponse returns the number of keys renamed
+type RenameObjectResponse struct {
+	XMLName  xml.Name `xml:"RenameObjectResult"`
+	KeyCount int
+}
+
 // Initiator inherit from Owner struct, fields are same
 type Initiator Owner
 
@@ -261,6 +268,8 @@ type CompleteMultipartUploadResponse struct {
 	Bucket   string
 	Key      string
 	ETag     string
+	Checksums
+	ChecksumType string `xml:",omitempty"`
 }
 
 // PostResponse container for completed post upload response
@@ -306,9 +315,12 @@ type DeleteObjectsRequest struct {
 }
 
 type PutObjectResult struct {
-	Md5          string
-	VersionId    string
-	LastModified time.Time
+	Md5               string
+	Checksum          string
+	ChecksumAlgorithm string
+	ChecksumValue     string
+	VersionId         string
+	LastModified      time.Time
 }
 
 type AppendObjectResult struct {
@@ -323,6 +335,9 @@ type DeleteObjectResult struct {
 
 type PutObjectPartResult struct {
 	ETag                    string
+	Checksum                string
+	ChecksumAlgorithm       string
+	ChecksumValue           string
 	SseType                 string
 	SseAwsKmsKeyIdBase64    string
 	SseCustomerAlgorithm    string
@@ -331,6 +346,10 @@ type PutObjectPartResult struct {
 
 type CompleteMultipartResult struct {
 	ETag                    string
+	Checksum                string
+	ChecksumAlgorithm       string
+	ChecksumValue           string
+	ChecksumType            string
 	VersionId               string
 	SseType                 string
 	SseAwsKmsKeyIdBase64    string
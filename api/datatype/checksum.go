@@ -0,0 +1,116 @@
+package datatype
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"strings"
+)
+
+// Checksum algorithms of x-amz-checksum-algorithm
+const (
+	ChecksumAlgorithmCRC32  = "CRC32"
+	ChecksumAlgorithmCRC32C = "CRC32C"
+	ChecksumAlgorithmSHA1   = "SHA1"
+	ChecksumAlgorithmSHA256 = "SHA256"
+)
+
+// Checksum types of multipart uploads, COMPOSITE is the checksum of part
+// checksums, FULL_OBJECT is the checksum of whole data, only for CRCs.
+const (
+	ChecksumTypeComposite  = "COMPOSITE"
+	ChecksumTypeFullObject = "FULL_OBJECT"
+)
+
+var ChecksumAlgorithms = []string{
+	ChecksumAlgorithmCRC32,
+	ChecksumAlgorithmCRC32C,
+	ChecksumAlgorithmSHA1,
+	ChecksumAlgorithmSHA256,
+}
+
+var checksumSizes = map[string]int{
+	ChecksumAlgorithmCRC32:  4,
+	ChecksumAlgorithmCRC32C: 4,
+	ChecksumAlgorithmSHA1:   20,
+	ChecksumAlgorithmSHA256: 32,
+}
+
+// Checksum requested with x-amz-checksum-* headers
+type ChecksumRequest struct {
+	Algorithm string // "" if no checksum is requested
+	Type      string // only for multipart uploads
+	// base64 encoded, "" to calculate it only
+	Value string
+	// name of trailer carrying the value in aws-chunked body, "" if none
+	Trailer string
+}
+
+func IsValidChecksumAlgorithm(algorithm string) bool {
+	_, ok := checksumSizes[algorithm]
+	return ok
+}
+
+// Returns true if `value` is a base64 encoded checksum of `algorithm`
+func IsValidChecksumValue(algorithm, value string) bool {
+	decoded, err := base64.StdEncoding.DecodeString(value)
+	return err == nil && len(decoded) == checksumSizes[algorithm]
+}
+
+// Name of header carrying checksum of `algorithm`, e.g. "X-Amz-Checksum-Crc32c"
+func ChecksumHeader(algorithm string) string {
+	algorithm = strings.ToLower(algorithm)
+	return "X-Amz-Checksum-" + strings.ToUpper(algorithm[:1]) + algorithm[1:]
+}
+
+// Checksum elements of XML responses, at most one is set
+type Checksums struct {
+	ChecksumCRC32  string `xml:",omitempty"`
+	ChecksumCRC32C string `xml:",omitempty"`
+	ChecksumSHA1   string `xml:",omitempty"`
+	ChecksumSHA256 string `xml:",omitempty"`
+}
+
+func (c *Checksums) Set(algorithm, value string) {
+	switch algorithm {
+	case ChecksumAlgorithmCRC32:
+		c.ChecksumCRC32 = value
+	case ChecksumAlgorithmCRC32C:
+		c.ChecksumCRC32C = value
+	case ChecksumAlgorithmSHA1:
+		c.ChecksumSHA1 = value
+	case ChecksumAlgorithmSHA256:
+		c.ChecksumSHA256 = value
+	}
+}
+
+// GetObjectAttributesResponse - format for GET ?attributes response,
+// only attributes requested by x-amz-object-attributes are set.
+type GetObjectAttributesResponse struct {
+	XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ GetObjectAttributesResponse" json:"-"`
+
+	ETag         string                    `xml:",omitempty"`
+	Checksum     *ObjectAttributesChecksum `xml:",omitempty"`
+	ObjectParts  *ObjectAttributesParts    `xml:",omitempty"`
+	StorageClass string                    `xml:",omitempty"`
+	ObjectSize   *int64                    `xml:",omitempty"`
+}
+
+type ObjectAttributesChecksum struct {
+	Checksums
+	ChecksumType string `xml:",omitempty"`
+}
+
+type ObjectAttributesParts struct {
+	TotalPartsCount      int `xml:"PartsCount"`
+	PartNumberMarker     int
+	NextPartNumberMarker int
+	MaxParts             int
+	IsTruncated          bool
+	Parts                []ObjectAttributesPart `xml:"Part"`
+}
+
+type ObjectAttributesPart struct {
+	PartNumber int
+	Size       int64
+	Checksums
+}
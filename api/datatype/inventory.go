@@ -0,0 +1,184 @@
+package datatype
+
+import (
+	"encoding/xml"
+	"regexp"
+	"strings"
+	"time"
+
+	. "github.com/journeymidnight/yig/error"
+	"github.com/journeymidnight/yig/helper"
+)
+
+const (
+	MAX_INVENTORY_SIZE = 64 << 10 // 64 KB
+
+	MaxInventoryConfigurations = 1000
+	// configurations returned by each ListBucketInventoryConfigurations
+	MaxInventoryConfigurationsListed = 100
+
+	InventoryFormatCSV = "CSV"
+
+	InventoryFrequencyDaily  = "Daily"
+	InventoryFrequencyWeekly = "Weekly"
+
+	InventoryVersionsAll     = "All"
+	InventoryVersionsCurrent = "Current"
+
+	InventoryFieldSize                = "Size"
+	InventoryFieldLastModifiedDate    = "LastModifiedDate"
+	InventoryFieldStorageClass        = "StorageClass"
+	InventoryFieldETag                = "ETag"
+	InventoryFieldIsMultipartUploaded = "IsMultipartUploaded"
+	InventoryFieldReplicationStatus   = "ReplicationStatus"
+	InventoryFieldEncryptionStatus    = "EncryptionStatus"
+)
+
+// Optional fields supported, in the order of columns of reports
+var InventoryOptionalFields = []string{
+	InventoryFieldSize,
+	InventoryFieldLastModifiedDate,
+	InventoryFieldStorageClass,
+	InventoryFieldETag,
+	InventoryFieldIsMultipartUploaded,
+	InventoryFieldReplicationStatus,
+	InventoryFieldEncryptionStatus,
+}
+
+var inventoryIdPattern = regexp.MustCompile(`^[a-zA-Z0-9\-_.]{1,64}$`)
+
+type InventoryFilter struct {
+	Prefix string `xml:"Prefix"`
+}
+
+type InventorySSES3 struct{}
+
+type InventorySSEKMS struct {
+	KeyId string `xml:"KeyId"`
+}
+
+type InventoryEncryption struct {
+	SSES3  *InventorySSES3  `xml:"SSE-S3,omitempty"`
+	SSEKMS *InventorySSEKMS `xml:"SSE-KMS,omitempty"`
+}
+
+// Reports are written into Bucket, in ARN format "arn:aws:s3:::<bucket name>",
+// which should be owned by the owner of the bucket inventoried.
+type InventoryS3BucketDestination struct {
+	AccountId  string               `xml:"AccountId,omitempty"`
+	Bucket     string               `xml:"Bucket"`
+	Format     string               `xml:"Format"`
+	Prefix     string               `xml:"Prefix,omitempty"`
+	Encryption *InventoryEncryption `xml:"Encryption,omitempty"`
+}
+
+type InventoryDestination struct {
+	S3BucketDestination InventoryS3BucketDestination `xml:"S3BucketDestination"`
+}
+
+type InventorySchedule struct {
+	Frequency string `xml:"Frequency"`
+}
+
+type InventoryConfiguration struct {
+	XMLName                xml.Name             `xml:"InventoryConfiguration" json:"-"`
+	Xmlns                  string               `xml:"xmlns,attr,omitempty" json:"-"`
+	Id                     string               `xml:"Id"`
+	IsEnabled              bool                 `xml:"IsEnabled"`
+	Filter                 *InventoryFilter     `xml:"Filter,omitempty"`
+	Destination            InventoryDestination `xml:"Destination"`
+	Schedule               InventorySchedule    `xml:"Schedule"`
+	IncludedObjectVersions string               `xml:"IncludedObjectVersions"`
+	OptionalFields         []string             `xml:"OptionalFields>Field,omitempty"`
+}
+
+type ListInventoryConfigurationsResult struct {
+	XMLName                 xml.Name                 `xml:"ListInventoryConfigurationsResult"`
+	Xmlns                   string                   `xml:"xmlns,attr,omitempty"`
+	InventoryConfigurations []InventoryConfiguration `xml:"InventoryConfiguration"`
+	IsTruncated             bool                     `xml:"IsTruncated"`
+	ContinuationToken       string                   `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken   string                   `xml:"NextContinuationToken,omitempty"`
+}
+
+func InventoryFromXml(inventoryBuffer []byte) (inventory InventoryConfiguration, err error) {
+	helper.Debugln("Incoming inventory XML:", string(inventoryBuffer))
+	err = xml.Unmarshal(inventoryBuffer, &inventory)
+	if err != nil {
+		helper.ErrorIf(err, "Unable to unmarshal inventory XML")
+		return inventory, ErrMalformedXML
+	}
+	return inventory, inventory.Validate()
+}
+
+func IsValidInventoryId(id string) bool {
+	return inventoryIdPattern.MatchString(id)
+}
+
+// Only CSV reports into buckets of this YIG are supported, optionally
+// encrypted by SSE-S3.
+func (inventory InventoryConfiguration) Validate() error {
+	if !IsValidInventoryId(inventory.Id) {
+		return ErrInvalidInventoryConfiguration
+	}
+	destination := inventory.Destination.S3BucketDestination
+	if destination.DestinationBucket() == "" || destination.Format != InventoryFormatCSV {
+		return ErrInvalidInventoryConfiguration
+	}
+	if destination.Encryption != nil && destination.Encryption.SSEKMS != nil {
+		return ErrInvalidInventoryConfiguration
+	}
+	if inventory.Schedule.Frequency != InventoryFrequencyDaily &&
+		inventory.Schedule.Frequency != InventoryFrequencyWeekly {
+		return ErrInvalidInventoryConfiguration
+	}
+	if inventory.IncludedObjectVersions != InventoryVersionsAll &&
+		inventory.IncludedObjectVersions != InventoryVersionsCurrent {
+		return ErrInvalidInventoryConfiguration
+	}
+	fields := make(map[string]bool)
+	for _, field := range inventory.OptionalFields {
+		if fields[field] || !helper.StringInSlice(field, InventoryOptionalFields) {
+			return ErrInvalidInventoryConfiguration
+		}
+		fields[field] = true
+	}
+	return nil
+}
+
+// Name of the destination bucket, "" if the ARN is invalid
+func (destination InventoryS3BucketDestination) DestinationBucket() string {
+	const arnPrefix = "arn:aws:s3:::"
+	if !strings.HasPrefix(destination.Bucket, arnPrefix) {
+		return ""
+	}
+	return strings.TrimPrefix(destination.Bucket, arnPrefix)
+}
+
+func (inventory InventoryConfiguration) KeyPrefix() string {
+	if inventory.Filter == nil {
+		return ""
+	}
+	return inventory.Filter.Prefix
+}
+
+// Optional fields of the configuration, in the order of columns of reports
+func (inventory InventoryConfiguration) Fields() (fields []string) {
+	for _, field := range InventoryOptionalFields {
+		if helper.StringInSlice(field, inventory.OptionalFields) {
+			fields = append(fields, field)
+		}
+	}
+	return
+}
+
+// Time of the latest report scheduled before `now`, reports are generated
+// from 00:00 UTC every day, or every Sunday if weekly.
+func (inventory InventoryConfiguration) ReportTime(now time.Time) time.Time {
+	now = now.UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if inventory.Schedule.Frequency == InventoryFrequencyWeekly {
+		day = day.AddDate(0, 0, -int(day.Weekday()))
+	}
+	return day
+}
@@ -0,0 +1,71 @@
+package datatype
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/journeymidnight/yig/error"
+)
+
+const testInventory = `<InventoryConfiguration>
+  <Id>report1</Id>
+  <IsEnabled>true</IsEnabled>
+  <Filter><Prefix>logs/</Prefix></Filter>
+  <Destination>
+    <S3BucketDestination>
+      <Bucket>arn:aws:s3:::reports</Bucket>
+      <Format>CSV</Format>
+      <Prefix>inventory</Prefix>
+    </S3BucketDestination>
+  </Destination>
+  <Schedule><Frequency>Weekly</Frequency></Schedule>
+  <IncludedObjectVersions>All</IncludedObjectVersions>
+  <OptionalFields><Field>ETag</Field><Field>Size</Field></OptionalFields>
+</InventoryConfiguration>`
+
+func TestInventoryFromXml(t *testing.T) {
+	inventory, err := InventoryFromXml([]byte(testInventory))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bucket := inventory.Destination.S3BucketDestination.DestinationBucket(); bucket != "reports" {
+		t.Error("wrong destination bucket:", bucket)
+	}
+	if inventory.KeyPrefix() != "logs/" {
+		t.Error("wrong prefix:", inventory.KeyPrefix())
+	}
+	fields := inventory.Fields()
+	if len(fields) != 2 || fields[0] != InventoryFieldSize || fields[1] != InventoryFieldETag {
+		t.Error("fields should be in the order of columns:", fields)
+	}
+
+	// a Sunday
+	sunday := time.Date(2019, 6, 2, 0, 0, 0, 0, time.UTC)
+	if r := inventory.ReportTime(sunday.Add(3*24*time.Hour + time.Hour)); !r.Equal(sunday) {
+		t.Error("wrong weekly report time:", r)
+	}
+	inventory.Schedule.Frequency = InventoryFrequencyDaily
+	if r := inventory.ReportTime(sunday.Add(25 * time.Hour)); !r.Equal(sunday.AddDate(0, 0, 1)) {
+		t.Error("wrong daily report time:", r)
+	}
+
+	for _, invalid := range []func(*InventoryConfiguration){
+		func(i *InventoryConfiguration) { i.Id = "bad id" },
+		func(i *InventoryConfiguration) { i.Destination.S3BucketDestination.Bucket = "reports" },
+		func(i *InventoryConfiguration) { i.Destination.S3BucketDestination.Format = "ORC" },
+		func(i *InventoryConfiguration) { i.Schedule.Frequency = "Hourly" },
+		func(i *InventoryConfiguration) { i.IncludedObjectVersions = "None" },
+		func(i *InventoryConfiguration) { i.OptionalFields = []string{"Size", "Size"} },
+		func(i *InventoryConfiguration) { i.OptionalFields = []string{"ObjectLockMode"} },
+		func(i *InventoryConfiguration) {
+			i.Destination.S3BucketDestination.Encryption = &InventoryEncryption{SSEKMS: &InventorySSEKMS{}}
+		},
+	} {
+		c := inventory
+		c.OptionalFields = append([]string{}, inventory.OptionalFields...)
+		invalid(&c)
+		if err := c.Validate(); err != ErrInvalidInventoryConfiguration {
+			t.Error("invalid configuration accepted:", c)
+		}
+	}
+}
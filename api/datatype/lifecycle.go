@@ -2,18 +2,98 @@ package datatype
 
 import (
 	"encoding/xml"
+	"strings"
 	//	. "github.com/journeymidnight/yig/error"
 	//	"github.com/journeymidnight/yig/helper"
 )
 
+// Objects matching both the prefix and all of the tags
+type LcFilterAnd struct {
+	Prefix string `xml:"Prefix,omitempty"`
+	Tags   []Tag  `xml:"Tag"`
+}
+
+// Filter of a rule, only one of Prefix, Tag and And should be set
+type LcFilter struct {
+	Prefix string       `xml:"Prefix,omitempty"`
+	Tag    *Tag         `xml:"Tag,omitempty"`
+	And    *LcFilterAnd `xml:"And,omitempty"`
+}
+
 type LcRule struct {
-	ID         string `xml:"ID"`
-	Prefix     string `xml:"Prefix"`
-	Status     string `xml:"Status"`
-	Expiration string `xml:"Expiration>Days"`
+	ID         string    `xml:"ID"`
+	Prefix     string    `xml:"Prefix"`
+	Filter     *LcFilter `xml:"Filter,omitempty"`
+	Status     string    `xml:"Status"`
+	Expiration string    `xml:"Expiration>Days"`
 }
 
 type Lc struct {
 	XMLName xml.Name `xml:"LifecycleConfiguration"`
 	Rule    []LcRule `xml:"Rule"`
 }
+
+// Prefix of keys the rule applies to, from either Prefix or Filter
+func (rule LcRule) KeyPrefix() string {
+	return filterKeyPrefix(rule.Prefix, rule.Filter)
+}
+
+// Tags an object should have for the rule to apply
+func (rule LcRule) Tags() []Tag {
+	return filterTags(rule.Filter)
+}
+
+// A default rule applies to all objects not matched by other rules
+func (rule LcRule) IsDefault() bool {
+	return rule.KeyPrefix() == "" && len(rule.Tags()) == 0
+}
+
+func (rule LcRule) Matches(objectName string, tagging Tagging) bool {
+	return filterMatches(rule.Prefix, rule.Filter, objectName, tagging)
+}
+
+// Filters are also used by replication rules, along with the Prefix
+// element replaced by them.
+func filterKeyPrefix(prefix string, filter *LcFilter) string {
+	if filter == nil {
+		return prefix
+	}
+	if filter.And != nil {
+		return filter.And.Prefix
+	}
+	return filter.Prefix
+}
+
+func filterTags(filter *LcFilter) []Tag {
+	if filter == nil {
+		return nil
+	}
+	if filter.And != nil {
+		return filter.And.Tags
+	}
+	if filter.Tag != nil {
+		return []Tag{*filter.Tag}
+	}
+	return nil
+}
+
+func filterMatches(prefix string, filter *LcFilter, objectName string, tagging Tagging) bool {
+	if !strings.HasPrefix(objectName, filterKeyPrefix(prefix, filter)) {
+		return false
+	}
+	for _, tag := range filterTags(filter) {
+		if value, ok := tagging.Get(tag.Key); !ok || value != tag.Value {
+			return false
+		}
+	}
+	return true
+}
+
+func (lc Lc) Validate() error {
+	for _, rule := range lc.Rule {
+		if err := (Tagging{TagSet: rule.Tags()}).Validate(MaxObjectTagCount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package datatype
+
+import (
+	"encoding/xml"
+
+	. "github.com/journeymidnight/yig/error"
+	"github.com/journeymidnight/yig/helper"
+)
+
+const (
+	MAX_LOGGING_SIZE = 64 << 10 // 64 KB
+)
+
+// Access logs of the bucket are written into `TargetBucket` as objects
+// whose names start with `TargetPrefix`
+type LoggingEnabled struct {
+	TargetBucket string `xml:"TargetBucket"`
+	TargetPrefix string `xml:"TargetPrefix"`
+}
+
+// An empty BucketLoggingStatus disables logging
+type BucketLoggingStatus struct {
+	XMLName        xml.Name        `xml:"BucketLoggingStatus" json:"-"`
+	Xmlns          string          `xml:"xmlns,attr,omitempty" json:"-"`
+	LoggingEnabled *LoggingEnabled `xml:"LoggingEnabled,omitempty"`
+}
+
+func BucketLoggingFromXml(loggingBuffer []byte) (logging BucketLoggingStatus, err error) {
+	helper.Debugln("Incoming logging XML:", string(loggingBuffer))
+	err = xml.Unmarshal(loggingBuffer, &logging)
+	if err != nil {
+		helper.ErrorIf(err, "Unable to unmarshal logging XML")
+		return logging, ErrMalformedXML
+	}
+	if logging.LoggingEnabled != nil && logging.LoggingEnabled.TargetBucket == "" {
+		return logging, ErrInvalidTargetBucketForLogging
+	}
+	return logging, nil
+}
@@ -0,0 +1,140 @@
+package datatype
+
+import (
+	"encoding/xml"
+	"strings"
+
+	. "github.com/journeymidnight/yig/error"
+	"github.com/journeymidnight/yig/helper"
+)
+
+const (
+	MAX_NOTIFICATION_SIZE = 64 << 10 // 64 KB
+)
+
+// Event types could be subscribed, names ending with "*" match all types
+// with the prefix
+const (
+	EventObjectCreatedAll                     = "s3:ObjectCreated:*"
+	EventObjectCreatedPut                     = "s3:ObjectCreated:Put"
+	EventObjectCreatedPost                    = "s3:ObjectCreated:Post"
+	EventObjectCreatedCopy                    = "s3:ObjectCreated:Copy"
+	EventObjectCreatedCompleteMultipartUpload = "s3:ObjectCreated:CompleteMultipartUpload"
+	EventObjectRemovedAll                     = "s3:ObjectRemoved:*"
+	EventObjectRemovedDelete                  = "s3:ObjectRemoved:Delete"
+	EventObjectRemovedDeleteMarkerCreated     = "s3:ObjectRemoved:DeleteMarkerCreated"
+)
+
+var supportedEvents = map[string]bool{
+	EventObjectCreatedAll:                     true,
+	EventObjectCreatedPut:                     true,
+	EventObjectCreatedPost:                    true,
+	EventObjectCreatedCopy:                    true,
+	EventObjectCreatedCompleteMultipartUpload: true,
+	EventObjectRemovedAll:                     true,
+	EventObjectRemovedDelete:                  true,
+	EventObjectRemovedDeleteMarkerCreated:     true,
+}
+
+type FilterRule struct {
+	Name  string `xml:"Name"` // "prefix" or "suffix"
+	Value string `xml:"Value"`
+}
+
+type NotificationFilter struct {
+	FilterRules []FilterRule `xml:"S3Key>FilterRule"`
+}
+
+// Events are sent to message bus topics, `Topic` is either a topic name, an
+// ARN whose last field is the topic name, or an endpoint URL for webhook.
+// Events are signed with `Secret` if set, it is never returned by GET.
+type TopicConfiguration struct {
+	Id     string              `xml:"Id,omitempty"`
+	Topic  string              `xml:"Topic"`
+	Secret string              `xml:"Secret,omitempty"`
+	Events []string            `xml:"Event"`
+	Filter *NotificationFilter `xml:"Filter,omitempty"`
+}
+
+type Notification struct {
+	XMLName             xml.Name             `xml:"NotificationConfiguration" json:"-"`
+	TopicConfigurations []TopicConfiguration `xml:"TopicConfiguration"`
+	// not supported, only to reject them
+	QueueConfigurations         []struct{} `xml:"QueueConfiguration" json:"-"`
+	CloudFunctionConfigurations []struct{} `xml:"CloudFunctionConfiguration" json:"-"`
+}
+
+func NotificationFromXml(notificationBuffer []byte) (notification Notification, err error) {
+	helper.Debugln("Incoming notification XML:", string(notificationBuffer))
+	err = xml.Unmarshal(notificationBuffer, &notification)
+	if err != nil {
+		helper.ErrorIf(err, "Unable to unmarshal notification XML")
+		return notification, ErrInvalidNotificationDocument
+	}
+	if len(notification.QueueConfigurations) != 0 || len(notification.CloudFunctionConfigurations) != 0 {
+		return notification, ErrInvalidNotificationDocument
+	}
+	ids := make(map[string]bool)
+	for i := range notification.TopicConfigurations {
+		config := &notification.TopicConfigurations[i]
+		if config.Id == "" {
+			config.Id = string(helper.GenerateRandomId())
+		}
+		if ids[config.Id] || config.TopicName() == "" || len(config.Events) == 0 {
+			return notification, ErrInvalidNotificationDocument
+		}
+		ids[config.Id] = true
+		for _, event := range config.Events {
+			if !supportedEvents[event] {
+				return notification, ErrInvalidNotificationDocument
+			}
+		}
+		if config.Filter == nil {
+			continue
+		}
+		names := make(map[string]bool)
+		for j := range config.Filter.FilterRules {
+			rule := &config.Filter.FilterRules[j]
+			rule.Name = strings.ToLower(rule.Name)
+			if (rule.Name != "prefix" && rule.Name != "suffix") || names[rule.Name] {
+				return notification, ErrInvalidNotificationDocument
+			}
+			names[rule.Name] = true
+		}
+	}
+	return notification, nil
+}
+
+// Name of the message bus topic
+func (config TopicConfiguration) TopicName() string {
+	if strings.HasPrefix(config.Topic, "http://") || strings.HasPrefix(config.Topic, "https://") {
+		return config.Topic
+	}
+	return config.Topic[strings.LastIndex(config.Topic, ":")+1:]
+}
+
+// Returns true if `eventName` on object `key` should be sent
+func (config TopicConfiguration) Matches(eventName, key string) bool {
+	for _, rule := range config.filterRules() {
+		if rule.Name == "prefix" && !strings.HasPrefix(key, rule.Value) {
+			return false
+		}
+		if rule.Name == "suffix" && !strings.HasSuffix(key, rule.Value) {
+			return false
+		}
+	}
+	for _, event := range config.Events {
+		if event == eventName ||
+			(strings.HasSuffix(event, "*") && strings.HasPrefix(eventName, strings.TrimSuffix(event, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+func (config TopicConfiguration) filterRules() []FilterRule {
+	if config.Filter == nil {
+		return nil
+	}
+	return config.Filter.FilterRules
+}
@@ -0,0 +1,51 @@
+package datatype
+
+import "testing"
+
+func TestNotificationFromXml(t *testing.T) {
+	notification, err := NotificationFromXml([]byte(`<NotificationConfiguration>
+  <TopicConfiguration>
+    <Topic>arn:aws:sns:cn-bj-1:123:uploads</Topic>
+    <Event>s3:ObjectCreated:*</Event>
+    <Filter><S3Key>
+      <FilterRule><Name>Prefix</Name><Value>images/</Value></FilterRule>
+      <FilterRule><Name>suffix</Name><Value>.jpg</Value></FilterRule>
+    </S3Key></Filter>
+  </TopicConfiguration>
+</NotificationConfiguration>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := notification.TopicConfigurations[0]
+	if config.Id == "" || config.TopicName() != "uploads" {
+		t.Fatal("unexpected configuration:", config)
+	}
+	for _, c := range []struct {
+		event, key string
+		matched    bool
+	}{
+		{EventObjectCreatedPut, "images/a.jpg", true},
+		{EventObjectCreatedCompleteMultipartUpload, "images/b/c.jpg", true},
+		{EventObjectRemovedDelete, "images/a.jpg", false},
+		{EventObjectCreatedPut, "docs/a.jpg", false},
+		{EventObjectCreatedPut, "images/a.png", false},
+	} {
+		if config.Matches(c.event, c.key) != c.matched {
+			t.Error(c.event, c.key, "should match:", c.matched)
+		}
+	}
+
+	for _, invalid := range []string{
+		`<NotificationConfiguration><TopicConfiguration><Topic>t</Topic>` +
+			`<Event>s3:ObjectAccessed:Get</Event></TopicConfiguration></NotificationConfiguration>`,
+		`<NotificationConfiguration><TopicConfiguration><Topic>t</Topic></TopicConfiguration>` +
+			`</NotificationConfiguration>`,
+		`<NotificationConfiguration><QueueConfiguration><Queue>q</Queue>` +
+			`<Event>s3:ObjectCreated:*</Event></QueueConfiguration></NotificationConfiguration>`,
+	} {
+		_, err = NotificationFromXml([]byte(invalid))
+		if err == nil {
+			t.Error("invalid configuration accepted:", invalid)
+		}
+	}
+}
@@ -68,10 +68,40 @@ const (
 
 	// AWSSourceIP - key representing client's IP address (not intermittent proxies) of any API.
 	AWSSourceIP = "aws:SourceIp"
+
+	// S3ExistingObjectTag - prefix of keys representing tags of the object requested, in the form
+	// of "s3:ExistingObjectTag/<tag key>".
+	S3ExistingObjectTag = "s3:ExistingObjectTag"
+
+	// S3RequestObjectTag - prefix of keys representing tags to set by x-amz-tagging HTTP header of
+	// PutObject API or body of PutObjectTagging API, in the form of "s3:RequestObjectTag/<tag key>".
+	S3RequestObjectTag = "s3:RequestObjectTag"
 )
 
+// NewObjectTagKey - returns key of tag `tagKey` with prefix S3ExistingObjectTag or S3RequestObjectTag.
+func NewObjectTagKey(prefix Key, tagKey string) Key {
+	return prefix + "/" + Key(tagKey)
+}
+
+// Generic - returns the prefix of object tag keys, or the key itself for other keys.
+// Example:
+//     Key("s3:ExistingObjectTag/project").Generic() == S3ExistingObjectTag
+func (key Key) Generic() Key {
+	for _, prefix := range []Key{S3ExistingObjectTag, S3RequestObjectTag} {
+		if strings.HasPrefix(string(key), string(prefix)+"/") && len(key) > len(prefix)+1 {
+			return prefix
+		}
+	}
+
+	return key
+}
+
 // IsValid - checks if key is valid or not.
 func (key Key) IsValid() bool {
+	if key.Generic() != key {
+		return true
+	}
+
 	switch key {
 	case S3XAmzCopySource, S3XAmzServerSideEncryption, S3XAmzServerSideEncryptionAwsKMSKeyID:
 		fallthrough
@@ -20,6 +20,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+
+	"github.com/journeymidnight/yig/api/datatype/policy/condition"
 )
 
 // DefaultVersion - default policy version as per AWS S3 specification.
@@ -79,6 +81,20 @@ func (policy Policy) IsAllowed(args Args) IsPolicyAllowedResult {
 	return NoPolicy
 }
 
+// HasConditionKey - checks whether any statement has condition of `key`, keys of object
+// tags are matched by their prefixes.
+func (policy Policy) HasConditionKey(key condition.Key) bool {
+	for _, statement := range policy.Statements {
+		for k := range statement.Conditions.Keys() {
+			if k.Generic() == key {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
 // IsEmpty - returns whether policy is empty or not.
 func (policy Policy) IsEmpty() bool {
 	return len(policy.Statements) == 0
@@ -93,7 +93,11 @@ func (statement Statement) isValid() error {
 			}
 		}
 
-		keys := statement.Conditions.Keys()
+		// keys of object tags are checked by their prefixes
+		keys := make(condition.KeySet)
+		for key := range statement.Conditions.Keys() {
+			keys.Add(key.Generic())
+		}
 		keyDiff := keys.Difference(actionConditionKeyMap[action])
 		if !keyDiff.IsEmpty() {
 			return fmt.Errorf("unsupported condition keys '%v' used for action '%v'", keyDiff, action)
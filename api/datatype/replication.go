@@ -0,0 +1,139 @@
+package datatype
+
+import (
+	"encoding/xml"
+	"strings"
+
+	. "github.com/journeymidnight/yig/error"
+	"github.com/journeymidnight/yig/helper"
+)
+
+const (
+	MAX_REPLICATION_SIZE = 2 << 20 // 2 MB
+
+	MaxReplicationRules = 1000
+
+	ReplicationStatusEnabled  = "Enabled"
+	ReplicationStatusDisabled = "Disabled"
+)
+
+// Bucket of the destination is in ARN format
+//
+//	arn:aws:s3:<target>::<bucket name>
+//
+// where <target> names a remote endpoint in `replication_targets` of the
+// configuration, or is empty for buckets of this YIG.
+type ReplicationDestination struct {
+	Bucket       string `xml:"Bucket"`
+	StorageClass string `xml:"StorageClass,omitempty"`
+}
+
+type DeleteMarkerReplication struct {
+	Status string `xml:"Status"`
+}
+
+// Objects are matched by Filter or the legacy Prefix, in the same way as
+// lifecycle rules
+type ReplicationRule struct {
+	ID                      string                   `xml:"ID,omitempty"`
+	Priority                int                      `xml:"Priority,omitempty"`
+	Status                  string                   `xml:"Status"`
+	Prefix                  string                   `xml:"Prefix,omitempty"`
+	Filter                  *LcFilter                `xml:"Filter,omitempty"`
+	DeleteMarkerReplication *DeleteMarkerReplication `xml:"DeleteMarkerReplication,omitempty"`
+	Destination             ReplicationDestination   `xml:"Destination"`
+}
+
+type ReplicationConfiguration struct {
+	XMLName xml.Name          `xml:"ReplicationConfiguration" json:"-"`
+	Xmlns   string            `xml:"xmlns,attr,omitempty" json:"-"`
+	Role    string            `xml:"Role,omitempty"`
+	Rules   []ReplicationRule `xml:"Rule"`
+}
+
+func ReplicationFromXml(replicationBuffer []byte) (replication ReplicationConfiguration, err error) {
+	helper.Debugln("Incoming replication XML:", string(replicationBuffer))
+	err = xml.Unmarshal(replicationBuffer, &replication)
+	if err != nil {
+		helper.ErrorIf(err, "Unable to unmarshal replication XML")
+		return replication, ErrMalformedXML
+	}
+	return replication, replication.Validate()
+}
+
+// Split the destination bucket ARN into the target name and bucket name
+func ParseReplicationDestination(arn string) (target, bucket string, err error) {
+	splits := strings.Split(arn, ":")
+	if len(splits) != 6 || splits[0] != "arn" || splits[1] != "aws" || splits[2] != "s3" ||
+		splits[4] != "" || splits[5] == "" {
+		return "", "", ErrInvalidReplicationConfiguration
+	}
+	return splits[3], splits[5], nil
+}
+
+func (replication ReplicationConfiguration) Validate() error {
+	if len(replication.Rules) == 0 || len(replication.Rules) > MaxReplicationRules {
+		return ErrInvalidReplicationConfiguration
+	}
+	ids := make(map[string]bool)
+	for i := range replication.Rules {
+		rule := &replication.Rules[i]
+		if rule.ID == "" {
+			rule.ID = string(helper.GenerateRandomId())
+		}
+		if ids[rule.ID] {
+			return ErrInvalidReplicationConfiguration
+		}
+		ids[rule.ID] = true
+		if rule.Status != ReplicationStatusEnabled && rule.Status != ReplicationStatusDisabled {
+			return ErrInvalidReplicationConfiguration
+		}
+		if _, _, err := ParseReplicationDestination(rule.Destination.Bucket); err != nil {
+			return err
+		}
+		tags := filterTags(rule.Filter)
+		if err := (Tagging{TagSet: tags}).Validate(MaxObjectTagCount); err != nil {
+			return err
+		}
+		// tags of deleted objects are unknown
+		if rule.ReplicatesDeleteMarkers() && len(tags) != 0 {
+			return ErrInvalidReplicationConfiguration
+		}
+		if d := rule.DeleteMarkerReplication; d != nil &&
+			d.Status != ReplicationStatusEnabled && d.Status != ReplicationStatusDisabled {
+			return ErrInvalidReplicationConfiguration
+		}
+	}
+	return nil
+}
+
+func (rule ReplicationRule) KeyPrefix() string {
+	return filterKeyPrefix(rule.Prefix, rule.Filter)
+}
+
+func (rule ReplicationRule) ReplicatesDeleteMarkers() bool {
+	return rule.DeleteMarkerReplication != nil &&
+		rule.DeleteMarkerReplication.Status == ReplicationStatusEnabled
+}
+
+// Enabled rule of the highest priority matching the object, nil if none.
+// Only rules without tags match if `tagging` is nil, i.e. for deleted objects.
+func (replication ReplicationConfiguration) RuleFor(objectName string, tagging *Tagging) *ReplicationRule {
+	var matched *ReplicationRule
+	for i, rule := range replication.Rules {
+		if rule.Status != ReplicationStatusEnabled {
+			continue
+		}
+		if tagging == nil {
+			if len(filterTags(rule.Filter)) != 0 || !strings.HasPrefix(objectName, rule.KeyPrefix()) {
+				continue
+			}
+		} else if !filterMatches(rule.Prefix, rule.Filter, objectName, *tagging) {
+			continue
+		}
+		if matched == nil || rule.Priority > matched.Priority {
+			matched = &replication.Rules[i]
+		}
+	}
+	return matched
+}
@@ -0,0 +1,80 @@
+package datatype
+
+import (
+	"testing"
+
+	. "github.com/journeymidnight/yig/error"
+)
+
+func TestReplicationRuleFor(t *testing.T) {
+	replication, err := ReplicationFromXml([]byte(`<ReplicationConfiguration>
+  <Rule>
+    <Status>Enabled</Status>
+    <Priority>1</Priority>
+    <Filter><Prefix>logs/</Prefix></Filter>
+    <DeleteMarkerReplication><Status>Enabled</Status></DeleteMarkerReplication>
+    <Destination><Bucket>arn:aws:s3:::backup</Bucket></Destination>
+  </Rule>
+  <Rule>
+    <ID>important</ID>
+    <Status>Enabled</Status>
+    <Priority>2</Priority>
+    <Filter><And><Prefix>logs/</Prefix><Tag><Key>level</Key><Value>error</Value></Tag></And></Filter>
+    <Destination><Bucket>arn:aws:s3:dc2::remote</Bucket><StorageClass>STANDARD_IA</StorageClass></Destination>
+  </Rule>
+  <Rule>
+    <Status>Disabled</Status>
+    <Priority>3</Priority>
+    <Destination><Bucket>arn:aws:s3:::disabled</Bucket></Destination>
+  </Rule>
+</ReplicationConfiguration>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replication.Rules[0].ID == "" {
+		t.Error("ID should be generated")
+	}
+	errorTags := Tagging{TagSet: []Tag{{Key: "level", Value: "error"}}}
+	for _, c := range []struct {
+		key     string
+		tagging *Tagging
+		bucket  string
+	}{
+		{"logs/a", &Tagging{}, "arn:aws:s3:::backup"},
+		{"logs/a", &errorTags, "arn:aws:s3:dc2::remote"},
+		{"logs/a", nil, "arn:aws:s3:::backup"},
+		{"data/a", &errorTags, ""},
+	} {
+		rule := replication.RuleFor(c.key, c.tagging)
+		if rule == nil {
+			if c.bucket != "" {
+				t.Error("no rule for", c.key, c.tagging)
+			}
+			continue
+		}
+		if rule.Destination.Bucket != c.bucket {
+			t.Error("unexpected rule for", c.key, c.tagging, ":", rule.Destination.Bucket)
+		}
+	}
+
+	target, bucket, err := ParseReplicationDestination("arn:aws:s3:dc2::remote")
+	if err != nil || target != "dc2" || bucket != "remote" {
+		t.Error("unexpected destination:", target, bucket, err)
+	}
+
+	for _, invalid := range []string{
+		`<ReplicationConfiguration></ReplicationConfiguration>`,
+		`<ReplicationConfiguration><Rule><Status>On</Status>` +
+			`<Destination><Bucket>arn:aws:s3:::backup</Bucket></Destination></Rule></ReplicationConfiguration>`,
+		`<ReplicationConfiguration><Rule><Status>Enabled</Status>` +
+			`<Destination><Bucket>backup</Bucket></Destination></Rule></ReplicationConfiguration>`,
+		`<ReplicationConfiguration><Rule><Status>Enabled</Status>` +
+			`<Filter><Tag><Key>k</Key><Value>v</Value></Tag></Filter>` +
+			`<DeleteMarkerReplication><Status>Enabled</Status></DeleteMarkerReplication>` +
+			`<Destination><Bucket>arn:aws:s3:::backup</Bucket></Destination></Rule></ReplicationConfiguration>`,
+	} {
+		if _, err := ReplicationFromXml([]byte(invalid)); err != ErrInvalidReplicationConfiguration {
+			t.Error(invalid, "should be invalid, but:", err)
+		}
+	}
+}
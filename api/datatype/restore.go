@@ -0,0 +1,50 @@
+package datatype
+
+import (
+	"encoding/xml"
+
+	. "github.com/journeymidnight/yig/error"
+)
+
+const MAX_RESTORE_REQUEST_SIZE = 64 << 10 // 64 KB
+
+// Retrieval tiers of archived objects, all restored by the same workers
+const (
+	RestoreTierStandard  = "Standard"
+	RestoreTierBulk      = "Bulk"
+	RestoreTierExpedited = "Expedited"
+)
+
+type GlacierJobParameters struct {
+	Tier string `xml:"Tier"`
+}
+
+type RestoreRequest struct {
+	XMLName              xml.Name              `xml:"RestoreRequest"`
+	Xmlns                string                `xml:"xmlns,attr,omitempty"`
+	Days                 int                   `xml:"Days"` // days the restored copy is kept
+	GlacierJobParameters *GlacierJobParameters `xml:"GlacierJobParameters,omitempty"`
+}
+
+// Parse the body of POST ?restore, the tier is Standard if not specified
+func RestoreRequestFromXml(restoreBuffer []byte) (request RestoreRequest, err error) {
+	err = xml.Unmarshal(restoreBuffer, &request)
+	if err != nil {
+		return request, ErrMalformedXML
+	}
+	if request.Days <= 0 {
+		return request, ErrMalformedRestoreRequest
+	}
+	if request.GlacierJobParameters == nil {
+		request.GlacierJobParameters = &GlacierJobParameters{Tier: RestoreTierStandard}
+	}
+	switch request.GlacierJobParameters.Tier {
+	case "":
+		request.GlacierJobParameters.Tier = RestoreTierStandard
+	case RestoreTierStandard, RestoreTierBulk, RestoreTierExpedited:
+		break
+	default:
+		return request, ErrMalformedRestoreRequest
+	}
+	return request, nil
+}
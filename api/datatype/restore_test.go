@@ -0,0 +1,39 @@
+package datatype
+
+import (
+	"testing"
+
+	. "github.com/journeymidnight/yig/error"
+)
+
+func TestRestoreRequestFromXml(t *testing.T) {
+	request, err := RestoreRequestFromXml([]byte(`<RestoreRequest xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <Days>2</Days>
+  <GlacierJobParameters><Tier>Bulk</Tier></GlacierJobParameters>
+</RestoreRequest>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request.Days != 2 || request.GlacierJobParameters.Tier != RestoreTierBulk {
+		t.Fatal("unexpected request:", request)
+	}
+	request, err = RestoreRequestFromXml([]byte(`<RestoreRequest><Days>1</Days></RestoreRequest>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request.GlacierJobParameters.Tier != RestoreTierStandard {
+		t.Fatal("unexpected tier:", request.GlacierJobParameters.Tier)
+	}
+
+	for body, expected := range map[string]error{
+		`<RestoreRequest>`:                                 ErrMalformedXML,
+		`<RestoreRequest></RestoreRequest>`:                ErrMalformedRestoreRequest,
+		`<RestoreRequest><Days>-1</Days></RestoreRequest>`: ErrMalformedRestoreRequest,
+		`<RestoreRequest><Days>1</Days><GlacierJobParameters><Tier>Fast</Tier>` +
+			`</GlacierJobParameters></RestoreRequest>`: ErrMalformedRestoreRequest,
+	} {
+		if _, err := RestoreRequestFromXml([]byte(body)); err != expected {
+			t.Error(body, "should fail with", expected, "but:", err)
+		}
+	}
+}
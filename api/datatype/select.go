@@ -0,0 +1,186 @@
+package datatype
+
+import (
+	"encoding/xml"
+	"strings"
+
+	. "github.com/journeymidnight/yig/error"
+	"github.com/journeymidnight/yig/helper"
+)
+
+const (
+	MAX_SELECT_REQUEST_SIZE = 256 << 10 // 256 KB
+
+	SelectExpressionTypeSQL = "SQL"
+
+	SelectCompressionNone  = "NONE"
+	SelectCompressionGzip  = "GZIP"
+	SelectCompressionBzip2 = "BZIP2"
+
+	SelectFileHeaderUse    = "USE"
+	SelectFileHeaderIgnore = "IGNORE"
+	SelectFileHeaderNone   = "NONE"
+
+	SelectJsonDocument = "DOCUMENT"
+	SelectJsonLines    = "LINES"
+
+	SelectQuoteFieldsAlways   = "ALWAYS"
+	SelectQuoteFieldsAsNeeded = "ASNEEDED"
+)
+
+// Absent characters are nil, so they could be distinguished from the empty
+// ones, which are not allowed.
+type CSVInput struct {
+	FileHeaderInfo             string  `xml:"FileHeaderInfo,omitempty"`
+	Comments                   *string `xml:"Comments"`
+	QuoteEscapeCharacter       *string `xml:"QuoteEscapeCharacter"`
+	RecordDelimiter            *string `xml:"RecordDelimiter"`
+	FieldDelimiter             *string `xml:"FieldDelimiter"`
+	QuoteCharacter             *string `xml:"QuoteCharacter"`
+	AllowQuotedRecordDelimiter bool    `xml:"AllowQuotedRecordDelimiter,omitempty"`
+}
+
+type JSONInput struct {
+	Type string `xml:"Type"`
+}
+
+type InputSerialization struct {
+	CompressionType string     `xml:"CompressionType,omitempty"`
+	CSV             *CSVInput  `xml:"CSV"`
+	JSON            *JSONInput `xml:"JSON"`
+}
+
+type CSVOutput struct {
+	QuoteFields          string  `xml:"QuoteFields,omitempty"`
+	QuoteEscapeCharacter *string `xml:"QuoteEscapeCharacter"`
+	RecordDelimiter      *string `xml:"RecordDelimiter"`
+	FieldDelimiter       *string `xml:"FieldDelimiter"`
+	QuoteCharacter       *string `xml:"QuoteCharacter"`
+}
+
+type JSONOutput struct {
+	RecordDelimiter *string `xml:"RecordDelimiter"`
+}
+
+type OutputSerialization struct {
+	CSV  *CSVOutput  `xml:"CSV"`
+	JSON *JSONOutput `xml:"JSON"`
+}
+
+type RequestProgress struct {
+	Enabled bool `xml:"Enabled"`
+}
+
+// Records starting in the range are processed, an absent Start means the
+// last End bytes of the object.
+type ScanRange struct {
+	Start *int64 `xml:"Start"`
+	End   *int64 `xml:"End"`
+}
+
+type SelectObjectContentRequest struct {
+	XMLName             xml.Name            `xml:"SelectObjectContentRequest"`
+	Expression          string              `xml:"Expression"`
+	ExpressionType      string              `xml:"ExpressionType"`
+	RequestProgress     RequestProgress     `xml:"RequestProgress"`
+	InputSerialization  InputSerialization  `xml:"InputSerialization"`
+	OutputSerialization OutputSerialization `xml:"OutputSerialization"`
+	ScanRange           *ScanRange          `xml:"ScanRange"`
+}
+
+func SelectObjectContentFromXml(requestBuffer []byte) (request SelectObjectContentRequest, err error) {
+	helper.Debugln("Incoming select request XML:", string(requestBuffer))
+	err = xml.Unmarshal(requestBuffer, &request)
+	if err != nil {
+		helper.ErrorIf(err, "Unable to unmarshal select request XML")
+		return request, ErrMalformedXML
+	}
+	return request, request.Validate()
+}
+
+// Checks the request is well formed, the expression and characters of
+// serializations are checked when the query is compiled.
+func (request *SelectObjectContentRequest) Validate() error {
+	if strings.TrimSpace(request.Expression) == "" {
+		return ErrMissingFields
+	}
+	if !strings.EqualFold(request.ExpressionType, SelectExpressionTypeSQL) {
+		return ErrInvalidExpressionType
+	}
+	input := &request.InputSerialization
+	input.CompressionType = strings.ToUpper(input.CompressionType)
+	switch input.CompressionType {
+	case "":
+		input.CompressionType = SelectCompressionNone
+	case SelectCompressionNone, SelectCompressionGzip, SelectCompressionBzip2:
+	default:
+		return ErrInvalidCompressionFormat
+	}
+	if (input.CSV == nil) == (input.JSON == nil) {
+		return ErrInvalidSelectParameter
+	}
+	if input.CSV != nil {
+		input.CSV.FileHeaderInfo = strings.ToUpper(input.CSV.FileHeaderInfo)
+		switch input.CSV.FileHeaderInfo {
+		case "":
+			input.CSV.FileHeaderInfo = SelectFileHeaderNone
+		case SelectFileHeaderUse, SelectFileHeaderIgnore, SelectFileHeaderNone:
+		default:
+			return ErrInvalidSelectParameter
+		}
+	}
+	if input.JSON != nil {
+		input.JSON.Type = strings.ToUpper(input.JSON.Type)
+		if input.JSON.Type != SelectJsonDocument && input.JSON.Type != SelectJsonLines {
+			return ErrInvalidSelectParameter
+		}
+	}
+	output := &request.OutputSerialization
+	if (output.CSV == nil) == (output.JSON == nil) {
+		return ErrInvalidSelectParameter
+	}
+	if output.CSV != nil {
+		output.CSV.QuoteFields = strings.ToUpper(output.CSV.QuoteFields)
+		switch output.CSV.QuoteFields {
+		case "":
+			output.CSV.QuoteFields = SelectQuoteFieldsAsNeeded
+		case SelectQuoteFieldsAlways, SelectQuoteFieldsAsNeeded:
+		default:
+			return ErrInvalidSelectParameter
+		}
+	}
+	if request.ScanRange != nil {
+		// only records of uncompressed CSV and JSON lines could be located
+		if input.CompressionType != SelectCompressionNone ||
+			(input.JSON != nil && input.JSON.Type != SelectJsonLines) ||
+			(input.CSV != nil && input.CSV.AllowQuotedRecordDelimiter) {
+			return ErrInvalidScanRange
+		}
+		start, end := request.ScanRange.Start, request.ScanRange.End
+		if (start == nil && end == nil) || (start != nil && *start < 0) || (end != nil && *end < 0) ||
+			(start != nil && end != nil && *start > *end) {
+			return ErrInvalidScanRange
+		}
+	}
+	return nil
+}
+
+// Returns the range of the object to process, inclusive, start > end if
+// nothing is in range.
+func (scanRange *ScanRange) Locate(size int64) (start, end int64) {
+	if scanRange == nil {
+		return 0, size - 1
+	}
+	if scanRange.Start == nil {
+		start = size - *scanRange.End
+		if start < 0 {
+			start = 0
+		}
+		return start, size - 1
+	}
+	start, end = *scanRange.Start, size-1
+	if scanRange.End != nil && *scanRange.End < end {
+		end = *scanRange.End
+	}
+	return start, end
+}
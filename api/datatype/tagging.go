@@ -0,0 +1,126 @@
+package datatype
+
+import (
+	"encoding/xml"
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	. "github.com/journeymidnight/yig/error"
+	"github.com/journeymidnight/yig/helper"
+)
+
+const (
+	MAX_TAGGING_SIZE = 64 << 10 // 64 KB
+
+	MaxObjectTagCount = 10
+	MaxBucketTagCount = 50
+	MaxTagKeyLength   = 128
+	MaxTagValueLength = 256
+)
+
+// Directives of x-amz-tagging-directive for CopyObject, tags of the source
+// are copied by default
+const (
+	TaggingDirectiveCopy    = "COPY"
+	TaggingDirectiveReplace = "REPLACE"
+)
+
+type Tag struct {
+	Key   string `xml:"Key"`
+	Value string `xml:"Value"`
+}
+
+type Tagging struct {
+	XMLName xml.Name `xml:"Tagging" json:"-"`
+	Xmlns   string   `xml:"xmlns,attr,omitempty" json:"-"`
+	TagSet  []Tag    `xml:"TagSet>Tag"`
+}
+
+// Parse the body of PUT ?tagging, with at most `maxTags` tags
+func TaggingFromXml(taggingBuffer []byte, maxTags int) (tagging Tagging, err error) {
+	helper.Debugln("Incoming tagging XML:", string(taggingBuffer))
+	err = xml.Unmarshal(taggingBuffer, &tagging)
+	if err != nil {
+		helper.ErrorIf(err, "Unable to unmarshal tagging XML")
+		return tagging, ErrMalformedXML
+	}
+	return tagging, tagging.Validate(maxTags)
+}
+
+// Parse x-amz-tagging header of object writes, i.e. URL query encoded tags
+// like "key1=value1&key2=value2"
+func TaggingFromHeader(header string) (tagging Tagging, err error) {
+	if header == "" {
+		return
+	}
+	values, err := url.ParseQuery(header)
+	if err != nil {
+		return tagging, ErrInvalidTag
+	}
+	for key, v := range values {
+		if len(v) != 1 {
+			return tagging, ErrInvalidTag
+		}
+		tagging.TagSet = append(tagging.TagSet, Tag{Key: key, Value: v[0]})
+	}
+	return tagging, tagging.Validate(MaxObjectTagCount)
+}
+
+// Check tags against limits of S3
+func (tagging Tagging) Validate(maxTags int) error {
+	if len(tagging.TagSet) > maxTags {
+		return ErrTooManyTags
+	}
+	keys := make(map[string]bool)
+	for _, tag := range tagging.TagSet {
+		if keys[tag.Key] {
+			return ErrInvalidTag
+		}
+		keys[tag.Key] = true
+		if tag.Key == "" || utf8.RuneCountInString(tag.Key) > MaxTagKeyLength ||
+			utf8.RuneCountInString(tag.Value) > MaxTagValueLength {
+			return ErrInvalidTag
+		}
+		if strings.HasPrefix(tag.Key, "aws:") {
+			return ErrInvalidTag
+		}
+		if !isValidTagString(tag.Key) || !isValidTagString(tag.Value) {
+			return ErrInvalidTag
+		}
+	}
+	return nil
+}
+
+// Letters, numbers, spaces and + - = . _ : / @ are allowed
+func isValidTagString(s string) bool {
+	for _, c := range s {
+		if unicode.IsLetter(c) || unicode.IsNumber(c) || unicode.IsSpace(c) {
+			continue
+		}
+		if !strings.ContainsRune("+-=._:/@", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// Value of tag `key`, ok is false if not tagged
+func (tagging Tagging) Get(key string) (value string, ok bool) {
+	for _, tag := range tagging.TagSet {
+		if tag.Key == key {
+			return tag.Value, true
+		}
+	}
+	return "", false
+}
+
+// Header value of x-amz-tagging
+func (tagging Tagging) Encode() string {
+	values := make(url.Values)
+	for _, tag := range tagging.TagSet {
+		values.Set(tag.Key, tag.Value)
+	}
+	return values.Encode()
+}
@@ -0,0 +1,61 @@
+package datatype
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	. "github.com/journeymidnight/yig/error"
+)
+
+func TestTaggingFromHeader(t *testing.T) {
+	tagging, err := TaggingFromHeader("project=yig&team=storage%20dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, ok := tagging.Get("team"); !ok || value != "storage dev" {
+		t.Fatal("unexpected tagging:", tagging)
+	}
+	if _, ok := tagging.Get("owner"); ok {
+		t.Fatal("unexpected tag owner:", tagging)
+	}
+
+	for header, expected := range map[string]error{
+		"a=1&a=2":                       ErrInvalidTag,
+		"aws:key=1":                     ErrInvalidTag,
+		"=1":                            ErrInvalidTag,
+		"key=a%23b":                     ErrInvalidTag,
+		strings.Repeat("k", 129) + "=1": ErrInvalidTag,
+		"1&2&3&4&5&6&7&8&9&10&11":       ErrTooManyTags,
+	} {
+		if _, err := TaggingFromHeader(header); err != expected {
+			t.Error(header, "should fail with", expected, "but:", err)
+		}
+	}
+}
+
+func TestLcRuleMatches(t *testing.T) {
+	var lc Lc
+	err := xml.Unmarshal([]byte(`<LifecycleConfiguration>
+  <Rule><ID>1</ID><Filter><And><Prefix>logs/</Prefix>
+    <Tag><Key>temp</Key><Value>true</Value></Tag></And></Filter>
+    <Status>Enabled</Status><Expiration><Days>1</Days></Expiration></Rule>
+  <Rule><ID>2</ID><Prefix></Prefix>
+    <Status>Enabled</Status><Expiration><Days>30</Days></Expiration></Rule>
+</LifecycleConfiguration>`), &lc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = lc.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	rule := lc.Rule[0]
+	if rule.IsDefault() || !lc.Rule[1].IsDefault() {
+		t.Fatal("unexpected default rules:", lc)
+	}
+	temp := Tagging{TagSet: []Tag{{Key: "temp", Value: "true"}}}
+	if !rule.Matches("logs/a", temp) || rule.Matches("logs/a", Tagging{}) ||
+		rule.Matches("data/a", temp) {
+		t.Fatal("unexpected matches of rule:", rule)
+	}
+}
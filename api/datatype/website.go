@@ -0,0 +1,176 @@
+package datatype
+
+import (
+	"encoding/xml"
+	"strconv"
+	"strings"
+
+	. "github.com/journeymidnight/yig/error"
+	"github.com/journeymidnight/yig/helper"
+)
+
+const (
+	MAX_WEBSITE_SIZE = 64 << 10 // 64 KB
+
+	MaxWebsiteRoutingRules = 50
+)
+
+type RedirectAllRequestsTo struct {
+	HostName string `xml:"HostName"`
+	Protocol string `xml:"Protocol,omitempty"`
+}
+
+type IndexDocument struct {
+	Suffix string `xml:"Suffix"`
+}
+
+type ErrorDocument struct {
+	Key string `xml:"Key"`
+}
+
+// A condition with HttpErrorCodeReturnedEquals applies when reading the
+// object fails with that code, otherwise before reading the object.
+type RoutingRuleCondition struct {
+	KeyPrefixEquals             string `xml:"KeyPrefixEquals,omitempty"`
+	HttpErrorCodeReturnedEquals string `xml:"HttpErrorCodeReturnedEquals,omitempty"`
+}
+
+// ReplaceKeyPrefixWith is a pointer since an empty one removes the prefix
+// matched, which differs from an absent one.
+type RoutingRuleRedirect struct {
+	HostName             string  `xml:"HostName,omitempty"`
+	HttpRedirectCode     string  `xml:"HttpRedirectCode,omitempty"`
+	Protocol             string  `xml:"Protocol,omitempty"`
+	ReplaceKeyPrefixWith *string `xml:"ReplaceKeyPrefixWith,omitempty"`
+	ReplaceKeyWith       string  `xml:"ReplaceKeyWith,omitempty"`
+}
+
+type RoutingRule struct {
+	Condition *RoutingRuleCondition `xml:"Condition,omitempty"`
+	Redirect  RoutingRuleRedirect   `xml:"Redirect"`
+}
+
+type WebsiteConfiguration struct {
+	XMLName               xml.Name               `xml:"WebsiteConfiguration" json:"-"`
+	Xmlns                 string                 `xml:"xmlns,attr,omitempty" json:"-"`
+	RedirectAllRequestsTo *RedirectAllRequestsTo `xml:"RedirectAllRequestsTo,omitempty"`
+	IndexDocument         *IndexDocument         `xml:"IndexDocument,omitempty"`
+	ErrorDocument         *ErrorDocument         `xml:"ErrorDocument,omitempty"`
+	RoutingRules          []RoutingRule          `xml:"RoutingRules>RoutingRule,omitempty"`
+}
+
+func WebsiteFromXml(websiteBuffer []byte) (website WebsiteConfiguration, err error) {
+	helper.Debugln("Incoming website XML:", string(websiteBuffer))
+	err = xml.Unmarshal(websiteBuffer, &website)
+	if err != nil {
+		helper.ErrorIf(err, "Unable to unmarshal website XML")
+		return website, ErrMalformedXML
+	}
+	return website, website.Validate()
+}
+
+func isValidRedirectProtocol(protocol string) bool {
+	return protocol == "" || protocol == "http" || protocol == "https"
+}
+
+func (website WebsiteConfiguration) Validate() error {
+	if redirect := website.RedirectAllRequestsTo; redirect != nil {
+		if redirect.HostName == "" || !isValidRedirectProtocol(redirect.Protocol) {
+			return ErrInvalidWebsiteConfiguration
+		}
+		// no other elements are allowed with RedirectAllRequestsTo
+		if website.IndexDocument != nil || website.ErrorDocument != nil || len(website.RoutingRules) != 0 {
+			return ErrInvalidWebsiteConfiguration
+		}
+		return nil
+	}
+	if website.IndexDocument == nil || website.IndexDocument.Suffix == "" ||
+		strings.Contains(website.IndexDocument.Suffix, "/") {
+		return ErrInvalidWebsiteConfiguration
+	}
+	if website.ErrorDocument != nil && website.ErrorDocument.Key == "" {
+		return ErrInvalidWebsiteConfiguration
+	}
+	if len(website.RoutingRules) > MaxWebsiteRoutingRules {
+		return ErrInvalidWebsiteConfiguration
+	}
+	for _, rule := range website.RoutingRules {
+		if c := rule.Condition; c != nil {
+			if c.KeyPrefixEquals == "" && c.HttpErrorCodeReturnedEquals == "" {
+				return ErrInvalidWebsiteConfiguration
+			}
+			if c.HttpErrorCodeReturnedEquals != "" {
+				code, err := strconv.Atoi(c.HttpErrorCodeReturnedEquals)
+				if err != nil || code < 400 || code > 599 {
+					return ErrInvalidWebsiteConfiguration
+				}
+			}
+		}
+		redirect := rule.Redirect
+		if redirect == (RoutingRuleRedirect{}) || !isValidRedirectProtocol(redirect.Protocol) {
+			return ErrInvalidWebsiteConfiguration
+		}
+		if redirect.ReplaceKeyWith != "" && redirect.ReplaceKeyPrefixWith != nil {
+			return ErrInvalidWebsiteConfiguration
+		}
+		if redirect.HttpRedirectCode != "" {
+			code, err := strconv.Atoi(redirect.HttpRedirectCode)
+			if err != nil || code < 300 || code > 399 {
+				return ErrInvalidWebsiteConfiguration
+			}
+		}
+	}
+	return nil
+}
+
+func (website WebsiteConfiguration) IsEnabled() bool {
+	return website.RedirectAllRequestsTo != nil || website.IndexDocument != nil
+}
+
+// First routing rule applies to `key`, `errorCode` is the HTTP status of
+// reading the object, or 0 before reading it. Returns nil if none.
+func (website WebsiteConfiguration) RoutingRuleFor(key string, errorCode int) *RoutingRule {
+	for i, rule := range website.RoutingRules {
+		c := rule.Condition
+		if c == nil {
+			if errorCode == 0 {
+				return &website.RoutingRules[i]
+			}
+			continue
+		}
+		if !strings.HasPrefix(key, c.KeyPrefixEquals) {
+			continue
+		}
+		if c.HttpErrorCodeReturnedEquals == "" && errorCode == 0 ||
+			c.HttpErrorCodeReturnedEquals != "" && c.HttpErrorCodeReturnedEquals == strconv.Itoa(errorCode) {
+			return &website.RoutingRules[i]
+		}
+	}
+	return nil
+}
+
+// Location and status code to redirect requests of `key` to, `host` and
+// `protocol` are of the request and used if not set in the rule.
+func (rule RoutingRule) RedirectLocation(key, host, protocol string) (location string, code int) {
+	redirect := rule.Redirect
+	if redirect.HostName != "" {
+		host = redirect.HostName
+	}
+	if redirect.Protocol != "" {
+		protocol = redirect.Protocol
+	}
+	if redirect.ReplaceKeyWith != "" {
+		key = redirect.ReplaceKeyWith
+	} else if redirect.ReplaceKeyPrefixWith != nil {
+		var prefix string
+		if rule.Condition != nil {
+			prefix = rule.Condition.KeyPrefixEquals
+		}
+		key = *redirect.ReplaceKeyPrefixWith + strings.TrimPrefix(key, prefix)
+	}
+	code = 301
+	if redirect.HttpRedirectCode != "" {
+		code, _ = strconv.Atoi(redirect.HttpRedirectCode)
+	}
+	return protocol + "://" + host + "/" + key, code
+}
@@ -0,0 +1,65 @@
+package datatype
+
+import (
+	"testing"
+
+	. "github.com/journeymidnight/yig/error"
+)
+
+func TestWebsiteRoutingRules(t *testing.T) {
+	website, err := WebsiteFromXml([]byte(`<WebsiteConfiguration>
+  <IndexDocument><Suffix>index.html</Suffix></IndexDocument>
+  <ErrorDocument><Key>404.html</Key></ErrorDocument>
+  <RoutingRules>
+    <RoutingRule>
+      <Condition><KeyPrefixEquals>docs/</KeyPrefixEquals></Condition>
+      <Redirect><ReplaceKeyPrefixWith>documents/</ReplaceKeyPrefixWith></Redirect>
+    </RoutingRule>
+    <RoutingRule>
+      <Condition><HttpErrorCodeReturnedEquals>404</HttpErrorCodeReturnedEquals></Condition>
+      <Redirect><HostName>example.com</HostName><ReplaceKeyWith>app/</ReplaceKeyWith>
+        <HttpRedirectCode>302</HttpRedirectCode></Redirect>
+    </RoutingRule>
+  </RoutingRules>
+</WebsiteConfiguration>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !website.IsEnabled() {
+		t.Fatal("website should be enabled:", website)
+	}
+	for _, c := range []struct {
+		key       string
+		errorCode int
+		location  string
+		code      int
+	}{
+		{"docs/a.html", 0, "http://s3.test.com/documents/a.html", 301},
+		{"about/me", 404, "http://example.com/app/", 302},
+		{"about/me", 0, "", 0},
+		{"about/me", 403, "", 0},
+	} {
+		rule := website.RoutingRuleFor(c.key, c.errorCode)
+		if rule == nil {
+			if c.location != "" {
+				t.Error("no routing rule for", c.key, c.errorCode)
+			}
+			continue
+		}
+		location, code := rule.RedirectLocation(c.key, "s3.test.com", "http")
+		if location != c.location || code != c.code {
+			t.Error("unexpected redirect of", c.key, c.errorCode, ":", location, code)
+		}
+	}
+
+	for _, invalid := range []string{
+		`<WebsiteConfiguration></WebsiteConfiguration>`,
+		`<WebsiteConfiguration><IndexDocument><Suffix>a/index.html</Suffix></IndexDocument></WebsiteConfiguration>`,
+		`<WebsiteConfiguration><RedirectAllRequestsTo><HostName>example.com</HostName></RedirectAllRequestsTo>` +
+			`<IndexDocument><Suffix>index.html</Suffix></IndexDocument></WebsiteConfiguration>`,
+	} {
+		if _, err := WebsiteFromXml([]byte(invalid)); err != ErrInvalidWebsiteConfiguration {
+			t.Error(invalid, "should be invalid, but:", err)
+		}
+	}
+}
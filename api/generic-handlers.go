@@ -262,12 +262,7 @@ func ignoreNotImplementedObjectResources(req *http.Request) bool {
 
 // List of not implemented bucket queries
 var notimplementedBucketResourceNames = map[string]bool{
-	"logging":        true,
-	"notification":   true,
-	"replication":    true,
-	"tagging":        true,
 	"requestPayment": true,
-	"website":        true,
 }
 
 // List of not implemented object queries
@@ -279,7 +274,11 @@ func GetBucketAndObjectInfoFromRequest(r *http.Request) (bucketName string, obje
 	splits := strings.SplitN(r.URL.Path[1:], "/", 2)
 	v := strings.Split(r.Host, ":")
 	hostWithOutPort := v[0]
-	ok, bucketName := helper.HasBucketInDomain(hostWithOutPort, ".", helper.CONFIG.S3Domain)
+	// website domains first, which may be subdomains of s3 domains
+	ok, bucketName := helper.HasBucketInDomain(hostWithOutPort, ".", helper.CONFIG.WebsiteDomain)
+	if !ok {
+		ok, bucketName = helper.HasBucketInDomain(hostWithOutPort, ".", helper.CONFIG.S3Domain)
+	}
 	if ok {
 		if len(splits) == 1 {
 			objectName = splits[0]
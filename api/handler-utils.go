@@ -67,6 +67,7 @@ var supportedHeaders = []string{
 	"content-type",
 	"expires",
 	"website-redirect-location",
+	"x-amz-website-redirect-location",
 	// Add more supported headers here
 }
 
@@ -179,3 +180,64 @@ func parseSseHeader(header http.Header) (request SseRequest, err error) {
 func hasSuffix(s string, suffix string) bool {
 	return strings.HasSuffix(s, suffix)
 }
+
+// Parse x-amz-checksum-* headers, the value could also be sent as trailer
+// of aws-chunked body, named by x-amz-trailer.
+func parseChecksumHeader(header http.Header) (request ChecksumRequest, err error) {
+	for _, algorithm := range ChecksumAlgorithms {
+		value := header.Get(ChecksumHeader(algorithm))
+		if value == "" {
+			continue
+		}
+		if request.Algorithm != "" || !IsValidChecksumValue(algorithm, value) {
+			return request, ErrInvalidChecksum
+		}
+		request.Algorithm, request.Value = algorithm, value
+	}
+
+	if trailer := header.Get("X-Amz-Trailer"); trailer != "" {
+		name := http.CanonicalHeaderKey(trailer)
+		if request.Algorithm != "" {
+			return request, ErrInvalidChecksum
+		}
+		for _, algorithm := range ChecksumAlgorithms {
+			if name == ChecksumHeader(algorithm) {
+				request.Algorithm, request.Trailer = algorithm, name
+			}
+		}
+		if request.Algorithm == "" {
+			return request, ErrInvalidChecksum
+		}
+	}
+
+	// value to be calculated by us, or algorithm of multipart upload
+	algorithm := header.Get("X-Amz-Checksum-Algorithm")
+	if algorithm == "" {
+		algorithm = header.Get("X-Amz-Sdk-Checksum-Algorithm")
+	}
+	if algorithm != "" {
+		algorithm = strings.ToUpper(algorithm)
+		if !IsValidChecksumAlgorithm(algorithm) ||
+			(request.Algorithm != "" && request.Algorithm != algorithm) {
+			return request, ErrInvalidChecksum
+		}
+		request.Algorithm = algorithm
+	}
+
+	request.Type = strings.ToUpper(header.Get("X-Amz-Checksum-Type"))
+	switch request.Type {
+	case "":
+		if request.Algorithm != "" {
+			request.Type = ChecksumTypeComposite
+		}
+	case ChecksumTypeComposite:
+		break
+	case ChecksumTypeFullObject:
+		if request.Algorithm != ChecksumAlgorithmCRC32 && request.Algorithm != ChecksumAlgorithmCRC32C {
+			return request, ErrInvalidChecksum
+		}
+	default:
+		return request, ErrInvalidChecksum
+	}
+	return request, nil
+}
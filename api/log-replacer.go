@@ -279,8 +279,7 @@ func (r *replacer) getSubstitution(key string) string {
 		return "-"
 
 	case "{bucket_logging}":
-		// TODO: Add bucket logging
-		return strconv.FormatBool(false)
+		return strconv.FormatBool(r.responseRecorder.bucketLogging)
 	case "{cdn_request}":
 		// If you have another judgment method, implement and replace the judgeFunc
 		var judgeFunc JudgeCdnRequest
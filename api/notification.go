@@ -0,0 +1,191 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	. "github.com/journeymidnight/yig/api/datatype"
+	. "github.com/journeymidnight/yig/error"
+	"github.com/journeymidnight/yig/helper"
+	"github.com/journeymidnight/yig/iam/common"
+	bus "github.com/journeymidnight/yig/messagebus"
+	"github.com/journeymidnight/yig/messagebus/types"
+)
+
+// Hex encoded HMAC-SHA256 of event records, keyed by the secret of the configuration
+const EVENT_SIGNATURE_HEADER = "X-Yig-Signature"
+
+// Event records sent to message bus, in the format of S3 event notifications, see
+// https://docs.aws.amazon.com/AmazonS3/latest/dev/notification-content-structure.html
+
+type EventIdentity struct {
+	PrincipalId string `json:"principalId"`
+}
+
+type EventBucket struct {
+	Name          string        `json:"name"`
+	OwnerIdentity EventIdentity `json:"ownerIdentity"`
+	Arn           string        `json:"arn"`
+}
+
+type EventObject struct {
+	Key       string `json:"key"`
+	Size      int64  `json:"size,omitempty"`
+	ETag      string `json:"eTag,omitempty"`
+	VersionId string `json:"versionId,omitempty"`
+	Sequencer string `json:"sequencer"`
+}
+
+type EventS3 struct {
+	SchemaVersion   string      `json:"s3SchemaVersion"`
+	ConfigurationId string      `json:"configurationId"`
+	Bucket          EventBucket `json:"bucket"`
+	Object          EventObject `json:"object"`
+}
+
+type EventRecord struct {
+	EventVersion      string            `json:"eventVersion"`
+	EventSource       string            `json:"eventSource"`
+	AwsRegion         string            `json:"awsRegion"`
+	EventTime         string            `json:"eventTime"`
+	EventName         string            `json:"eventName"`
+	UserIdentity      EventIdentity     `json:"userIdentity"`
+	RequestParameters map[string]string `json:"requestParameters"`
+	ResponseElements  map[string]string `json:"responseElements"`
+	S3                EventS3           `json:"s3"`
+}
+
+type EventRecords struct {
+	Records []EventRecord `json:"Records"`
+}
+
+// Send event `eventName` of `object` to topics of matching notification
+// configurations of the bucket requested. Events are dropped on failures,
+// since the request has succeeded.
+func (api ObjectAPIHandlers) sendEvent(r *http.Request, credential common.Credential, eventName string,
+	object EventObject) {
+
+	ctx, ok := r.Context().Value(RequestContextKey).(RequestContext)
+	if !ok || ctx.BucketInfo == nil {
+		return
+	}
+	if !helper.CONFIG.MsgBus.Enabled || len(ctx.BucketInfo.Notification.TopicConfigurations) == 0 {
+		return
+	}
+	bucket := ctx.BucketInfo
+	now := time.Now().UTC()
+	key := object.Key
+	object.Key = url.QueryEscape(key)
+	object.Sequencer = fmt.Sprintf("%016X", now.UnixNano())
+	for _, config := range bucket.Notification.TopicConfigurations {
+		if !config.Matches(eventName, key) {
+			continue
+		}
+		record := EventRecord{
+			EventVersion: "2.1",
+			EventSource:  "aws:s3",
+			AwsRegion:    helper.CONFIG.Region,
+			EventTime:    now.Format(timeFormatAMZ),
+			EventName:    strings.TrimPrefix(eventName, "s3:"),
+			UserIdentity: EventIdentity{PrincipalId: credential.UserId},
+			RequestParameters: map[string]string{
+				"sourceIPAddress": GetSourceIP(r),
+			},
+			ResponseElements: map[string]string{
+				"x-amz-request-id": ctx.RequestId,
+			},
+			S3: EventS3{
+				SchemaVersion:   "1.0",
+				ConfigurationId: config.Id,
+				Bucket: EventBucket{
+					Name:          bucket.Name,
+					OwnerIdentity: EventIdentity{PrincipalId: bucket.OwnerId},
+					Arn:           "arn:aws:s3:::" + bucket.Name,
+				},
+				Object: object,
+			},
+		}
+		val, err := json.Marshal(EventRecords{Records: []EventRecord{record}})
+		if err != nil {
+			helper.Logger.Printf(2, "failed to marshal event %v, err: %v", record, err)
+			continue
+		}
+		// topics of URLs are sent by webhook, others by the message bus
+		sender, err := bus.GetTopicSender(config.TopicName())
+		if err != nil {
+			helper.Logger.Printf(2, "failed to get sender of topic %s, err: %v", config.TopicName(), err)
+			continue
+		}
+		// events of the same object are sent to the same partition to keep their order
+		msg := &types.Message{
+			Topic:   config.TopicName(),
+			Key:     bucket.Name + "/" + key,
+			ErrChan: nil,
+			Value:   val,
+		}
+		if config.Secret != "" {
+			msg.Headers = map[string]string{
+				EVENT_SIGNATURE_HEADER: signEvent(config.Secret, val),
+			}
+		}
+		err = sender.AsyncSend(msg)
+		if err != nil {
+			helper.Logger.Printf(2, "failed to send event %s of %s/%s to topic %s, err: %v",
+				eventName, bucket.Name, key, msg.Topic, err)
+			continue
+		}
+		helper.Logger.Printf(20, "succeed to send event %s of %s/%s to topic %s.",
+			eventName, bucket.Name, key, msg.Topic)
+	}
+}
+
+// Send the event of `result` of deleting `objectName`, i.e. a version
+// removed or a delete marker created
+func (api ObjectAPIHandlers) sendDeleteEvent(r *http.Request, credential common.Credential,
+	objectName string, result DeleteObjectResult) {
+
+	eventName := EventObjectRemovedDelete
+	if result.DeleteMarker {
+		eventName = EventObjectRemovedDeleteMarkerCreated
+	}
+	api.sendEvent(r, credential, eventName, EventObject{
+		Key:       objectName,
+		VersionId: result.VersionId,
+	})
+}
+
+// Endpoints of topics of URLs are checked by webhook before configured,
+// so bucket owners could not send events to internal services
+func checkNotificationTopics(notification Notification) error {
+	for _, config := range notification.TopicConfigurations {
+		topic := config.TopicName()
+		if !types.IsUrlTopic(topic) {
+			continue
+		}
+		sender, err := bus.GetWebhookSender()
+		if err != nil {
+			helper.Logger.Printf(5, "failed to get webhook sender for topic %s, err: %v", topic, err)
+			return ErrInvalidNotificationDestination
+		}
+		if checker, ok := sender.(bus.TopicChecker); ok {
+			if err = checker.CheckTopic(topic); err != nil {
+				helper.Logger.Printf(5, "webhook endpoint %s is not allowed, err: %v", topic, err)
+				return ErrInvalidNotificationDestination
+			}
+		}
+	}
+	return nil
+}
+
+func signEvent(secret string, val []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(val)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -164,6 +164,10 @@ func (api ObjectAPIHandlers) GetObjectHandler(w http.ResponseWriter, r *http.Req
 		WriteErrorResponse(w, r, ErrNoSuchKey)
 		return
 	}
+	if !object.IsReadable() {
+		WriteErrorResponse(w, r, ErrInvalidObjectState)
+		return
+	}
 
 	// Get request range.
 	var hrange *HttpRange
@@ -224,6 +228,7 @@ func (api ObjectAPIHandlers) GetObjectHandler(w http.ResponseWriter, r *http.Req
 			// Set headers on the first write.
 			// Set standard object headers.
 			SetObjectHeaders(w, object, hrange)
+			setObjectChecksumHeaders(w, r, object, hrange)
 
 			// Set any additional requested response headers.
 			setGetRespHeaders(w, r.URL.Query())
@@ -352,6 +357,7 @@ func (api ObjectAPIHandlers) HeadObjectHandler(w http.ResponseWriter, r *http.Re
 
 	// Set standard object headers.
 	SetObjectHeaders(w, object, nil)
+	setObjectChecksumHeaders(w, r, object, nil)
 
 	switch object.SseType {
 	case "":
@@ -371,6 +377,124 @@ func (api ObjectAPIHandlers) HeadObjectHandler(w http.ResponseWriter, r *http.Re
 	w.WriteHeader(http.StatusOK)
 }
 
+// GetObjectAttributesHandler - GET Object attributes
+// -----------
+// Returns attributes named by x-amz-object-attributes without the object data.
+func (api ObjectAPIHandlers) GetObjectAttributesHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucket"]
+	objectName := vars["object"]
+
+	var credential common.Credential
+	var err error
+	if credential, err = checkRequestAuth(api, r, policy.GetObjectAction, bucketName, objectName); err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+
+	attributes := make(map[string]bool)
+	for _, header := range r.Header["X-Amz-Object-Attributes"] {
+		for _, attribute := range strings.Split(header, ",") {
+			attribute = strings.TrimSpace(attribute)
+			switch attribute {
+			case "ETag", "Checksum", "ObjectParts", "StorageClass", "ObjectSize":
+				attributes[attribute] = true
+			default:
+				WriteErrorResponse(w, r, ErrInvalidObjectAttributes)
+				return
+			}
+		}
+	}
+	if len(attributes) == 0 {
+		WriteErrorResponse(w, r, ErrInvalidObjectAttributes)
+		return
+	}
+
+	maxParts := MaxPartsList
+	if maxPartsHeader := r.Header.Get("X-Amz-Max-Parts"); maxPartsHeader != "" {
+		maxParts, err = strconv.Atoi(maxPartsHeader)
+		if err != nil || maxParts < 0 {
+			WriteErrorResponse(w, r, ErrInvalidMaxParts)
+			return
+		}
+	}
+	partNumberMarker := 0
+	if markerHeader := r.Header.Get("X-Amz-Part-Number-Marker"); markerHeader != "" {
+		partNumberMarker, err = strconv.Atoi(markerHeader)
+		if err != nil || partNumberMarker < 0 {
+			WriteErrorResponse(w, r, ErrInvalidPartNumberMarker)
+			return
+		}
+	}
+
+	version := r.URL.Query().Get("versionId")
+	object, err := api.ObjectAPI.GetObjectInfo(bucketName, objectName, version, credential)
+	if err != nil {
+		helper.ErrorIf(err, "Unable to fetch object info.")
+		if err == ErrNoSuchKey {
+			err = api.errAllowableObjectNotFound(r, bucketName, credential)
+		}
+		WriteErrorResponse(w, r, err)
+		return
+	}
+	if object.DeleteMarker {
+		w.Header().Set("x-amz-delete-marker", "true")
+		WriteErrorResponse(w, r, ErrNoSuchKey)
+		return
+	}
+
+	var response GetObjectAttributesResponse
+	if attributes["ETag"] {
+		response.ETag = object.Etag
+	}
+	if attributes["Checksum"] {
+		algorithm, value, checksumType := object.GetChecksum()
+		if algorithm != "" {
+			response.Checksum = &ObjectAttributesChecksum{ChecksumType: checksumType}
+			response.Checksum.Set(algorithm, value)
+		}
+	}
+	if attributes["ObjectParts"] && len(object.Parts) > 0 {
+		parts := &ObjectAttributesParts{
+			TotalPartsCount:  len(object.Parts),
+			PartNumberMarker: partNumberMarker,
+			MaxParts:         maxParts,
+		}
+		for i := partNumberMarker + 1; i <= len(object.Parts); i++ {
+			p, ok := object.Parts[i]
+			if !ok {
+				continue
+			}
+			if len(parts.Parts) == maxParts {
+				parts.IsTruncated = true
+				break
+			}
+			part := ObjectAttributesPart{
+				PartNumber: p.PartNumber,
+				Size:       p.Size,
+			}
+			part.Checksums.Set(p.ChecksumAlgorithm, p.ChecksumValue)
+			parts.Parts = append(parts.Parts, part)
+			parts.NextPartNumberMarker = p.PartNumber
+		}
+		response.ObjectParts = parts
+	}
+	if attributes["StorageClass"] {
+		response.StorageClass = object.StorageClass.ToString()
+	}
+	if attributes["ObjectSize"] {
+		response.ObjectSize = &object.Size
+	}
+
+	encodedSuccessResponse := EncodeResponse(response)
+	w.Header().Set("Last-Modified", object.LastModifiedTime.UTC().Format(http.TimeFormat))
+	if version != "" {
+		w.Header().Set("x-amz-version-id", version)
+	}
+	setXmlHeader(w, encodedSuccessResponse)
+	WriteSuccessResponse(w, encodedSuccessResponse)
+}
+
 // CopyObjectHandler - Copy Object
 // ----------
 // This implementation of the PUT operation adds an object to a bucket
@@ -476,11 +600,33 @@ func (api ObjectAPIHandlers) CopyObjectHandler(w http.ResponseWriter, r *http.Re
 		WriteErrorResponse(w, r, err)
 		return
 	}
-	if storageClassFromHeader == meta.ObjectStorageClassGlacier || storageClassFromHeader == meta.ObjectStorageClassDeepArchive {
-		WriteErrorResponse(w, r, ErrInvalidCopySourceStorageClass)
+	// archived sources could be copied only if restored
+	if !sourceObject.IsReadable() {
+		WriteErrorResponseWithResource(w, r, ErrInvalidObjectState, copySource)
+		return
+	}
+
+	// tags of the source are copied unless x-amz-tagging-directive is REPLACE
+	tagging := sourceObject.Tagging
+	switch r.Header.Get("X-Amz-Tagging-Directive") {
+	case "", TaggingDirectiveCopy:
+		break
+	case TaggingDirectiveReplace:
+		tagging, err = TaggingFromHeader(r.Header.Get("X-Amz-Tagging"))
+		if err != nil {
+			WriteErrorResponse(w, r, err)
+			return
+		}
+	default:
+		WriteErrorResponse(w, r, ErrInvalidTaggingDirective)
 		return
 	}
 
+	// data is copied if moved into or out of archive storage classes
+	if storageClassFromHeader.IsArchive() != sourceObject.IsArchived() {
+		isOnlyUpdateMetadata = false
+	}
+
 	//if source == dest and X-Amz-Metadata-Directive == REPLACE, only update the meta;
 	if isOnlyUpdateMetadata {
 		targetObject := sourceObject
@@ -494,6 +640,7 @@ func (api ObjectAPIHandlers) CopyObjectHandler(w http.ResponseWriter, r *http.Re
 		}
 		targetObject.CustomAttributes = newMetadata
 		targetObject.StorageClass = storageClassFromHeader
+		targetObject.Tagging = tagging
 
 		result, err := api.ObjectAPI.UpdateObjectAttrs(targetObject, credential)
 		if err != nil {
@@ -507,6 +654,7 @@ func (api ObjectAPIHandlers) CopyObjectHandler(w http.ResponseWriter, r *http.Re
 		if result.Md5 != "" {
 			w.Header()["ETag"] = []string{"\"" + result.Md5 + "\""}
 		}
+		setChecksumHeader(w, result.Checksum)
 		if sourceVersion != "" {
 			w.Header().Set("x-amz-copy-source-version-id", sourceVersion)
 		}
@@ -535,20 +683,6 @@ func (api ObjectAPIHandlers) CopyObjectHandler(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	pipeReader, pipeWriter := io.Pipe()
-	go func() {
-		startOffset := int64(0) // Read the whole file.
-		// Get the object.
-		err = api.ObjectAPI.GetObject(sourceObject, startOffset, sourceObject.Size,
-			pipeWriter, sseRequest)
-		if err != nil {
-			helper.ErrorIf(err, "Unable to read an object.")
-			pipeWriter.CloseWithError(err)
-			return
-		}
-		pipeWriter.Close()
-	}()
-
 	targetACL, err := getAclFromHeader(r.Header)
 	if err != nil {
 		WriteErrorResponse(w, r, err)
@@ -562,9 +696,13 @@ func (api ObjectAPIHandlers) CopyObjectHandler(w http.ResponseWriter, r *http.Re
 	targetObject.Name = targetObjectName
 	targetObject.Size = sourceObject.Size
 	targetObject.Etag = sourceObject.Etag
+	targetObject.Checksum = sourceObject.Checksum
+	targetObject.ChecksumAlgorithm = sourceObject.ChecksumAlgorithm
+	targetObject.ChecksumValue = sourceObject.ChecksumValue
 	targetObject.ContentType = sourceObject.ContentType
 	targetObject.CustomAttributes = sourceObject.CustomAttributes
 	targetObject.Parts = sourceObject.Parts
+	targetObject.Tagging = tagging
 
 	if r.Header.Get("X-Amz-Storage-Class") != "" {
 		targetObject.StorageClass = storageClassFromHeader
@@ -572,14 +710,47 @@ func (api ObjectAPIHandlers) CopyObjectHandler(w http.ResponseWriter, r *http.Re
 		targetObject.StorageClass = sourceObject.StorageClass
 	}
 
-	// Create the object.
-	result, err := api.ObjectAPI.CopyObject(targetObject, pipeReader, credential, sseRequest)
+	// Share data of the source if possible, only metadata is written then.
+	result, shared, err := api.ObjectAPI.CopyObjectByReference(targetObject, sourceObject,
+		credential, sseRequest)
 	if err != nil {
 		helper.ErrorIf(err, "Unable to copy object from "+
 			sourceObjectName+" to "+targetObjectName)
 		WriteErrorResponse(w, r, err)
 		return
 	}
+	if !shared {
+		pipeReader, pipeWriter := io.Pipe()
+		go func() {
+			startOffset := int64(0) // Read the whole file.
+			// Get the object.
+			err := api.ObjectAPI.GetObject(sourceObject, startOffset, sourceObject.Size,
+				pipeWriter, sseRequest)
+			if err != nil {
+				helper.ErrorIf(err, "Unable to read an object.")
+				pipeWriter.CloseWithError(err)
+				return
+			}
+			pipeWriter.Close()
+		}()
+		// Explicitly close the reader, to avoid fd leaks.
+		defer pipeReader.Close()
+
+		// Create the object.
+		result, err = api.ObjectAPI.CopyObject(targetObject, pipeReader, credential, sseRequest)
+		if err != nil {
+			helper.ErrorIf(err, "Unable to copy object from "+
+				sourceObjectName+" to "+targetObjectName)
+			WriteErrorResponse(w, r, err)
+			return
+		}
+	}
+	api.sendEvent(r, credential, EventObjectCreatedCopy, EventObject{
+		Key:       targetObjectName,
+		Size:      targetObject.Size,
+		ETag:      result.Md5,
+		VersionId: result.VersionId,
+	})
 
 	response := GenerateCopyObjectResponse(result.Md5, result.LastModified)
 	encodedSuccessResponse := EncodeResponse(response)
@@ -587,6 +758,7 @@ func (api ObjectAPIHandlers) CopyObjectHandler(w http.ResponseWriter, r *http.Re
 	if result.Md5 != "" {
 		w.Header()["ETag"] = []string{"\"" + result.Md5 + "\""}
 	}
+	setChecksumHeader(w, result.Checksum)
 	if sourceVersion != "" {
 		w.Header().Set("x-amz-copy-source-version-id", sourceVersion)
 	}
@@ -606,8 +778,69 @@ func (api ObjectAPIHandlers) CopyObjectHandler(w http.ResponseWriter, r *http.Re
 	}
 	// write success response.
 	WriteSuccessResponse(w, encodedSuccessResponse)
-	// Explicitly close the reader, to avoid fd leaks.
-	pipeReader.Close()
+}
+
+// RenameObjectHandler - PUT Object ?rename
+// ----------
+// YIG extension, renames the object or every key under the prefix given in
+// x-yig-rename-source to the target, by rewriting metadata only.
+// Both the source and target should be prefixes ending with "/" to rename
+// a prefix.
+func (api ObjectAPIHandlers) RenameObjectHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucket"]
+	targetObjectName := vars["object"]
+
+	if !isValidObjectName(targetObjectName) {
+		WriteErrorResponse(w, r, ErrInvalidObjectName)
+		return
+	}
+
+	var credential common.Credential
+	var err error
+	if credential, err = checkRequestAuth(api, r, policy.PutObjectAction, bucketName, targetObjectName); err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+
+	// rename source is of form: /bucket-name/object-name, in the same bucket
+	renameSource := strings.TrimPrefix(r.Header.Get("X-Yig-Rename-Source"), "/")
+	splits := strings.SplitN(renameSource, "/", 2)
+	if len(splits) != 2 {
+		WriteErrorResponse(w, r, ErrInvalidRenameSource)
+		return
+	}
+	sourceBucketName, err := url.QueryUnescape(splits[0])
+	if err != nil || sourceBucketName != bucketName {
+		WriteErrorResponse(w, r, ErrInvalidRenameSource)
+		return
+	}
+	sourceObjectName, err := url.QueryUnescape(splits[1])
+	if err != nil || !isValidObjectName(sourceObjectName) {
+		WriteErrorResponse(w, r, ErrInvalidRenameSource)
+		return
+	}
+	if credential, err = checkRequestAuth(api, r, policy.DeleteObjectAction, bucketName, sourceObjectName); err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+
+	sourceNames, targetNames, err := api.ObjectAPI.RenameObject(bucketName, sourceObjectName,
+		targetObjectName, credential)
+	if err != nil {
+		helper.ErrorIf(err, "Unable to rename object from "+
+			sourceObjectName+" to "+targetObjectName)
+		WriteErrorResponseWithResource(w, r, err, renameSource)
+		return
+	}
+	encodedSuccessResponse := EncodeResponse(RenameObjectResponse{KeyCount: len(sourceNames)})
+	WriteSuccessResponse(w, encodedSuccessResponse)
+
+	// a rename is notified as copying to the new key and removing the old one
+	for i := range sourceNames {
+		api.sendEvent(r, credential, EventObjectCreatedCopy, EventObject{Key: targetNames[i]})
+		api.sendEvent(r, credential, EventObjectRemovedDelete, EventObject{Key: sourceNames[i]})
+	}
 }
 
 // PutObjectHandler - PUT Object
@@ -724,24 +957,50 @@ func (api ObjectAPIHandlers) PutObjectHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	checksumRequest, err := parseChecksumHeader(r.Header)
+	if err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+
+	tagging, err := TaggingFromHeader(r.Header.Get("X-Amz-Tagging"))
+	if err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+
 	credential, dataReader, err := signature.VerifyUpload(r)
 	if err != nil {
 		WriteErrorResponse(w, r, err)
 		return
 	}
+	// bucket policy could deny uploads, e.g. with s3:RequestObjectTag conditions
+	if credential, err = IsBucketPolicyAllowed(credential, api, r, policy.PutObjectAction,
+		bucketName, objectName); err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
 
 	var result PutObjectResult
 	result, err = api.ObjectAPI.PutObject(bucketName, objectName, credential, size, dataReader,
-		metadata, acl, sseRequest, storageClass)
+		metadata, acl, sseRequest, storageClass, checksumRequest, tagging)
 	if err != nil {
 		helper.ErrorIf(err, "Unable to create object "+objectName)
 		WriteErrorResponse(w, r, err)
 		return
 	}
+	api.sendEvent(r, credential, EventObjectCreatedPut, EventObject{
+		Key:       objectName,
+		Size:      size,
+		ETag:      result.Md5,
+		VersionId: result.VersionId,
+	})
 
 	if result.Md5 != "" {
 		w.Header()["ETag"] = []string{"\"" + result.Md5 + "\""}
 	}
+	setChecksumHeader(w, result.Checksum)
+	setRequestedChecksumHeaders(w, result.ChecksumAlgorithm, result.ChecksumValue, "")
 	if result.VersionId != "" {
 		w.Header().Set("x-amz-version-id", result.VersionId)
 	}
@@ -929,6 +1188,7 @@ func (api ObjectAPIHandlers) AppendObjectHandler(w http.ResponseWriter, r *http.
 	if result.Md5 != "" {
 		w.Header()["ETag"] = []string{"\"" + result.Md5 + "\""}
 	}
+	setChecksumHeader(w, result.Checksum)
 	if result.VersionId != "" {
 		w.Header().Set("x-amz-version-id", result.VersionId)
 	}
@@ -1053,6 +1313,157 @@ func (api ObjectAPIHandlers) GetObjectAclHandler(w http.ResponseWriter, r *http.
 	WriteSuccessResponse(w, aclBuffer)
 }
 
+func (api ObjectAPIHandlers) PutObjectTaggingHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucket"]
+	objectName := vars["object"]
+
+	var credential common.Credential
+	var err error
+	if credential, err = checkRequestAuth(api, r, policy.PutObjectTaggingAction, bucketName, objectName); err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+
+	// If Content-Length is greater than maximum allowed tagging size.
+	if r.ContentLength > MAX_TAGGING_SIZE {
+		WriteErrorResponse(w, r, ErrEntityTooLarge)
+		return
+	}
+	taggingBuffer, err := ioutil.ReadAll(io.LimitReader(r.Body, MAX_TAGGING_SIZE))
+	if err != nil {
+		helper.ErrorIf(err, "Unable to read tagging body")
+		WriteErrorResponse(w, r, ErrInternalError)
+		return
+	}
+	tagging, err := TaggingFromXml(taggingBuffer, MaxObjectTagCount)
+	if err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+
+	version := r.URL.Query().Get("versionId")
+	versionId, err := api.ObjectAPI.SetObjectTagging(bucketName, objectName, version, tagging, credential)
+	if err != nil {
+		helper.ErrorIf(err, "Unable to set tagging for object")
+		WriteErrorResponse(w, r, err)
+		return
+	}
+	if versionId != "" {
+		w.Header().Set("x-amz-version-id", versionId)
+	}
+	WriteSuccessResponse(w, nil)
+}
+
+// RestoreObjectHandler - POST Object ?restore
+// ----------
+// Restores a temporary copy of an archived object, which could be read then.
+// Responds 202 if the restore is initiated, or 200 if already restored and
+// the expiry date of the copy is extended.
+func (api ObjectAPIHandlers) RestoreObjectHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucket"]
+	objectName := vars["object"]
+
+	var credential common.Credential
+	var err error
+	if credential, err = checkRequestAuth(api, r, policy.RestoreObjectAction, bucketName, objectName); err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+
+	if r.ContentLength > MAX_RESTORE_REQUEST_SIZE {
+		WriteErrorResponse(w, r, ErrEntityTooLarge)
+		return
+	}
+	restoreBuffer, err := ioutil.ReadAll(io.LimitReader(r.Body, MAX_RESTORE_REQUEST_SIZE))
+	if err != nil {
+		helper.ErrorIf(err, "Unable to read restore body")
+		WriteErrorResponse(w, r, ErrInternalError)
+		return
+	}
+	request, err := RestoreRequestFromXml(restoreBuffer)
+	if err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+
+	version := r.URL.Query().Get("versionId")
+	restored, err := api.ObjectAPI.RestoreObject(bucketName, objectName, version, request, credential)
+	if err != nil {
+		helper.ErrorIf(err, "Unable to restore object")
+		WriteErrorResponse(w, r, err)
+		return
+	}
+	if restored {
+		WriteSuccessResponse(w, nil)
+		return
+	}
+	w.(*ResponseRecorder).status = http.StatusAccepted
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (api ObjectAPIHandlers) GetObjectTaggingHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucket"]
+	objectName := vars["object"]
+
+	var credential common.Credential
+	var err error
+	if credential, err = checkRequestAuth(api, r, policy.GetObjectTaggingAction, bucketName, objectName); err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+
+	version := r.URL.Query().Get("versionId")
+	tagging, versionId, err := api.ObjectAPI.GetObjectTagging(bucketName, objectName, version, credential)
+	if err != nil {
+		helper.ErrorIf(err, "Unable to fetch object tagging.")
+		WriteErrorResponse(w, r, err)
+		return
+	}
+	tagging.Xmlns = XMLNS
+
+	taggingBuffer, err := xmlFormat(tagging)
+	if err != nil {
+		helper.ErrorIf(err, "Failed to marshal tagging XML for object %s", objectName)
+		WriteErrorResponse(w, r, ErrInternalError)
+		return
+	}
+
+	if versionId != "" {
+		w.Header().Set("x-amz-version-id", versionId)
+	}
+
+	setXmlHeader(w, taggingBuffer)
+	WriteSuccessResponse(w, taggingBuffer)
+}
+
+func (api ObjectAPIHandlers) DeleteObjectTaggingHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucket"]
+	objectName := vars["object"]
+
+	var credential common.Credential
+	var err error
+	if credential, err = checkRequestAuth(api, r, policy.DeleteObjectTaggingAction, bucketName, objectName); err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+
+	version := r.URL.Query().Get("versionId")
+	versionId, err := api.ObjectAPI.SetObjectTagging(bucketName, objectName, version, Tagging{}, credential)
+	if err != nil {
+		helper.ErrorIf(err, "Unable to delete tagging for object")
+		WriteErrorResponse(w, r, err)
+		return
+	}
+	if versionId != "" {
+		w.Header().Set("x-amz-version-id", versionId)
+	}
+	WriteSuccessNoContent(w)
+}
+
 /// Multipart objectAPIHandlers
 
 // NewMultipartUploadHandler - New multipart upload
@@ -1082,6 +1493,11 @@ func (api ObjectAPIHandlers) NewMultipartUploadHandler(w http.ResponseWriter, r
 			return
 		}
 	}
+	if credential, err = IsBucketPolicyAllowed(credential, api, r, policy.PutObjectAction,
+		bucketName, objectName); err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
 
 	acl, err := getAclFromHeader(r.Header)
 	if err != nil {
@@ -1107,13 +1523,29 @@ func (api ObjectAPIHandlers) NewMultipartUploadHandler(w http.ResponseWriter, r
 		return
 	}
 
+	checksumRequest, err := parseChecksumHeader(r.Header)
+	if err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+
+	tagging, err := TaggingFromHeader(r.Header.Get("X-Amz-Tagging"))
+	if err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+
 	uploadID, err := api.ObjectAPI.NewMultipartUpload(credential, bucketName, objectName,
-		metadata, acl, sseRequest, storageClass)
+		metadata, acl, sseRequest, storageClass, checksumRequest, tagging)
 	if err != nil {
 		helper.ErrorIf(err, "Unable to initiate new multipart upload id.")
 		WriteErrorResponse(w, r, err)
 		return
 	}
+	if checksumRequest.Algorithm != "" {
+		w.Header().Set("X-Amz-Checksum-Algorithm", checksumRequest.Algorithm)
+		w.Header().Set("X-Amz-Checksum-Type", checksumRequest.Type)
+	}
 
 	response := GenerateInitiateMultipartUploadResponse(bucketName, objectName, uploadID)
 	encodedSuccessResponse := EncodeResponse(response)
@@ -1196,6 +1628,12 @@ func (api ObjectAPIHandlers) PutObjectPartHandler(w http.ResponseWriter, r *http
 		return
 	}
 
+	checksumRequest, err := parseChecksumHeader(r.Header)
+	if err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+
 	credential, dataReader, err := signature.VerifyUpload(r)
 	if err != nil {
 		WriteErrorResponse(w, r, err)
@@ -1205,7 +1643,7 @@ func (api ObjectAPIHandlers) PutObjectPartHandler(w http.ResponseWriter, r *http
 	var result PutObjectPartResult
 	// No need to verify signature, anonymous request access is already allowed.
 	result, err = api.ObjectAPI.PutObjectPart(bucketName, objectName, credential,
-		uploadID, partID, size, dataReader, incomingMd5, sseRequest)
+		uploadID, partID, size, dataReader, incomingMd5, sseRequest, checksumRequest)
 	if err != nil {
 		helper.ErrorIf(err, "Unable to create object part for "+objectName)
 		// Verify if the underlying error is signature mismatch.
@@ -1216,6 +1654,8 @@ func (api ObjectAPIHandlers) PutObjectPartHandler(w http.ResponseWriter, r *http
 	if result.ETag != "" {
 		w.Header()["ETag"] = []string{"\"" + result.ETag + "\""}
 	}
+	setChecksumHeader(w, result.Checksum)
+	setRequestedChecksumHeaders(w, result.ChecksumAlgorithm, result.ChecksumValue, "")
 	switch result.SseType {
 	case "":
 		break
@@ -1326,6 +1766,10 @@ func (api ObjectAPIHandlers) CopyObjectPartHandler(w http.ResponseWriter, r *htt
 		WriteErrorResponseWithResource(w, r, err, copySource)
 		return
 	}
+	if !sourceObject.IsReadable() {
+		WriteErrorResponseWithResource(w, r, ErrInvalidObjectState, copySource)
+		return
+	}
 
 	sseRequest, err := parseSseHeader(r.Header)
 	if err != nil {
@@ -1392,6 +1836,7 @@ func (api ObjectAPIHandlers) CopyObjectPartHandler(w http.ResponseWriter, r *htt
 	if result.Md5 != "" {
 		w.Header()["ETag"] = []string{"\"" + result.Md5 + "\""}
 	}
+	setChecksumHeader(w, result.Checksum)
 	if sourceVersion != "" {
 		w.Header().Set("x-amz-copy-source-version-id", sourceVersion)
 	}
@@ -1539,9 +1984,15 @@ func (api ObjectAPIHandlers) CompleteMultipartUploadHandler(w http.ResponseWrite
 		completeParts = append(completeParts, part)
 	}
 
+	checksumRequest, err := parseChecksumHeader(r.Header)
+	if err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+
 	var result CompleteMultipartResult
 	result, err = api.ObjectAPI.CompleteMultipartUpload(credential, bucketName,
-		objectName, uploadId, completeParts)
+		objectName, uploadId, completeParts, checksumRequest)
 
 	if err != nil {
 		helper.ErrorIf(err, "Unable to complete multipart upload.")
@@ -1555,11 +2006,18 @@ func (api ObjectAPIHandlers) CompleteMultipartUploadHandler(w http.ResponseWrite
 		}
 		return
 	}
+	api.sendEvent(r, credential, EventObjectCreatedCompleteMultipartUpload, EventObject{
+		Key:       objectName,
+		ETag:      result.ETag,
+		VersionId: result.VersionId,
+	})
 
 	// Get object location.
 	location := GetLocation(r)
 	// Generate complete multipart response.
 	response := GenerateCompleteMultpartUploadResponse(bucketName, objectName, location, result.ETag)
+	response.Checksums.Set(result.ChecksumAlgorithm, result.ChecksumValue)
+	response.ChecksumType = result.ChecksumType
 	encodedSuccessResponse, err := xmlFormat(response)
 	if err != nil {
 		helper.ErrorIf(err, "Unable to parse CompleteMultipartUpload response")
@@ -1567,6 +2025,8 @@ func (api ObjectAPIHandlers) CompleteMultipartUploadHandler(w http.ResponseWrite
 		return
 	}
 
+	setChecksumHeader(w, result.Checksum)
+	setRequestedChecksumHeaders(w, result.ChecksumAlgorithm, result.ChecksumValue, result.ChecksumType)
 	if result.VersionId != "" {
 		w.Header().Set("x-amz-version-id", result.VersionId)
 	}
@@ -1623,6 +2083,7 @@ func (api ObjectAPIHandlers) DeleteObjectHandler(w http.ResponseWriter, r *http.
 		WriteErrorResponse(w, r, err)
 		return
 	}
+	api.sendDeleteEvent(r, credential, objectName, result)
 	if result.DeleteMarker {
 		w.Header().Set("x-amz-delete-marker", "true")
 	} else {
@@ -1735,16 +2196,32 @@ func (api ObjectAPIHandlers) PostObjectHandler(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	// tags of POST are in the form field "tagging" as an XML document
+	var tagging Tagging
+	if taggingXml := formValues["Tagging"]; taggingXml != "" {
+		tagging, err = TaggingFromXml([]byte(taggingXml), MaxObjectTagCount)
+		if err != nil {
+			WriteErrorResponse(w, r, err)
+			return
+		}
+	}
+
 	result, err := api.ObjectAPI.PutObject(bucketName, objectName, credential, -1, fileBody,
-		metadata, acl, sseRequest, storageClass)
+		metadata, acl, sseRequest, storageClass, ChecksumRequest{}, tagging)
 	if err != nil {
 		helper.ErrorIf(err, "Unable to create object "+objectName)
 		WriteErrorResponse(w, r, err)
 		return
 	}
+	api.sendEvent(r, credential, EventObjectCreatedPost, EventObject{
+		Key:       objectName,
+		ETag:      result.Md5,
+		VersionId: result.VersionId,
+	})
 	if result.Md5 != "" {
 		w.Header().Set("ETag", "\""+result.Md5+"\"")
 	}
+	setChecksumHeader(w, result.Checksum)
 
 	var redirect string
 	redirect, _ = formValues["Success_action_redirect"]
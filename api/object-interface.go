@@ -41,6 +41,24 @@ type ObjectLayer interface {
 	DeleteBucketCors(bucket string, credential common.Credential) error
 	GetBucketVersioning(bucket string, credential common.Credential) (datatype.Versioning, error)
 	GetBucketCors(bucket string, credential common.Credential) (datatype.Cors, error)
+	SetBucketNotification(bucket string, notification datatype.Notification, credential common.Credential) error
+	GetBucketNotification(bucket string, credential common.Credential) (datatype.Notification, error)
+	SetBucketLogging(bucket string, logging datatype.BucketLoggingStatus, credential common.Credential) error
+	GetBucketLogging(bucket string, credential common.Credential) (datatype.BucketLoggingStatus, error)
+	SetBucketTagging(bucket string, tagging datatype.Tagging, credential common.Credential) error
+	GetBucketTagging(bucket string, credential common.Credential) (datatype.Tagging, error)
+	SetBucketWebsite(bucket string, website datatype.WebsiteConfiguration, credential common.Credential) error
+	GetBucketWebsite(bucket string, credential common.Credential) (datatype.WebsiteConfiguration, error)
+	SetBucketReplication(bucket string, replication datatype.ReplicationConfiguration,
+		credential common.Credential) error
+	GetBucketReplication(bucket string, credential common.Credential) (datatype.ReplicationConfiguration, error)
+	SetBucketInventory(bucket string, inventory datatype.InventoryConfiguration, credential common.Credential) error
+	GetBucketInventory(bucket, id string, credential common.Credential) (datatype.InventoryConfiguration, error)
+	DeleteBucketInventory(bucket, id string, credential common.Credential) error
+	ListBucketInventory(bucket, continuationToken string,
+		credential common.Credential) (datatype.ListInventoryConfigurationsResult, error)
+	// Buffer an access record of `bucket`, which are written into its logging target later
+	AppendBucketLog(bucket *meta.Bucket, record string)
 	GetBucket(bucketName string) (bucket *meta.Bucket, err error) // For INTERNAL USE ONLY
 	GetBucketInfo(bucket string, credential common.Credential) (bucketInfo *meta.Bucket, err error)
 	ListBuckets(credential common.Credential) (buckets []meta.Bucket, err error)
@@ -61,31 +79,42 @@ type ObjectLayer interface {
 	GetObjectInfo(bucket, object, version string, credential common.Credential) (objInfo *meta.Object,
 		err error)
 	PutObject(bucket, object string, credential common.Credential, size int64, data io.Reader,
-		metadata map[string]string, acl datatype.Acl,
-		sse datatype.SseRequest, storageClass meta.StorageClass) (result datatype.PutObjectResult, err error)
+		metadata map[string]string, acl datatype.Acl, sse datatype.SseRequest, storageClass meta.StorageClass,
+		checksum datatype.ChecksumRequest, tagging datatype.Tagging) (result datatype.PutObjectResult, err error)
 	AppendObject(bucket, object string, credential common.Credential, offset uint64, size int64, data io.Reader,
 		metadata map[string]string, acl datatype.Acl,
 		sse datatype.SseRequest, storageClass meta.StorageClass, objInfo *meta.Object) (result datatype.AppendObjectResult, err error)
 
 	CopyObject(targetObject *meta.Object, source io.Reader, credential common.Credential,
 		sse datatype.SseRequest) (result datatype.PutObjectResult, err error)
+	CopyObjectByReference(targetObject *meta.Object, sourceObject *meta.Object, credential common.Credential,
+		sse datatype.SseRequest) (result datatype.PutObjectResult, shared bool, err error)
+	RenameObject(bucket, sourceObject, targetObject string,
+		credential common.Credential) (sourceObjects, targetObjects []string, err error)
 	UpdateObjectAttrs(targetObject *meta.Object, credential common.Credential) (result datatype.PutObjectResult, err error)
 	SetObjectAcl(bucket string, object string, version string, policy datatype.AccessControlPolicy,
 		acl datatype.Acl, credential common.Credential) error
 	GetObjectAcl(bucket string, object string, version string, credential common.Credential) (
 		policy datatype.AccessControlPolicyResponse, err error)
+	SetObjectTagging(bucket, object, version string, tagging datatype.Tagging,
+		credential common.Credential) (versionId string, err error)
+	GetObjectTagging(bucket, object, version string, credential common.Credential) (
+		tagging datatype.Tagging, versionId string, err error)
 	DeleteObject(bucket, object, version string, credential common.Credential) (datatype.DeleteObjectResult,
 		error)
+	// Returns true if the archived object is restored already, false if the restore is initiated
+	RestoreObject(bucket, object, version string, request datatype.RestoreRequest,
+		credential common.Credential) (restored bool, err error)
 
 	// Multipart operations.
 	ListMultipartUploads(credential common.Credential, bucket string,
 		request datatype.ListUploadsRequest) (result datatype.ListMultipartUploadsResponse, err error)
 	NewMultipartUpload(credential common.Credential, bucket, object string,
-		metadata map[string]string, acl datatype.Acl,
-		sse datatype.SseRequest, storageClass meta.StorageClass) (uploadID string, err error)
+		metadata map[string]string, acl datatype.Acl, sse datatype.SseRequest, storageClass meta.StorageClass,
+		checksum datatype.ChecksumRequest, tagging datatype.Tagging) (uploadID string, err error)
 	PutObjectPart(bucket, object string, credential common.Credential, uploadID string, partID int,
-		size int64, data io.Reader, md5Hex string,
-		sse datatype.SseRequest) (result datatype.PutObjectPartResult, err error)
+		size int64, data io.Reader, md5Hex string, sse datatype.SseRequest,
+		checksum datatype.ChecksumRequest) (result datatype.PutObjectPartResult, err error)
 	CopyObjectPart(bucketName, objectName, uploadId string, partId int, size int64, data io.Reader,
 		credential common.Credential, sse datatype.SseRequest) (result datatype.PutObjectResult,
 		err error)
@@ -93,5 +122,6 @@ type ObjectLayer interface {
 		request datatype.ListPartsRequest) (result datatype.ListPartsResponse, err error)
 	AbortMultipartUpload(credential common.Credential, bucket, object, uploadID string) error
 	CompleteMultipartUpload(credential common.Credential, bucket, object, uploadID string,
-		uploadedParts []meta.CompletePart) (result datatype.CompleteMultipartResult, err error)
+		uploadedParts []meta.CompletePart,
+		checksum datatype.ChecksumRequest) (result datatype.CompleteMultipartResult, err error)
 }
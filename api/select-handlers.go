@@ -0,0 +1,129 @@
+package api
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	. "github.com/journeymidnight/yig/api/datatype"
+	"github.com/journeymidnight/yig/api/datatype/policy"
+	. "github.com/journeymidnight/yig/error"
+	"github.com/journeymidnight/yig/helper"
+	"github.com/journeymidnight/yig/iam/common"
+	"github.com/journeymidnight/yig/s3select"
+)
+
+// SelectObjectContentHandler - POST Object?select&select-type=2
+// ----------
+// Filters the object by a SQL expression, records selected are returned in
+// the event stream encoding. Once the response starts, errors are returned
+// as error events.
+func (api ObjectAPIHandlers) SelectObjectContentHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucket"]
+	objectName := vars["object"]
+
+	var credential common.Credential
+	var err error
+	if credential, err = checkRequestAuth(api, r, policy.GetObjectAction, bucketName, objectName); err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+	if r.URL.Query().Get("select-type") != "2" {
+		WriteErrorResponse(w, r, ErrInvalidQueryParams)
+		return
+	}
+
+	// If Content-Length is unknown or zero, deny the request.
+	if !contains(r.TransferEncoding, "chunked") {
+		if r.ContentLength == -1 || r.ContentLength == 0 {
+			WriteErrorResponse(w, r, ErrMissingContentLength)
+			return
+		}
+		// If Content-Length is greater than maximum allowed request size.
+		if r.ContentLength > MAX_SELECT_REQUEST_SIZE {
+			WriteErrorResponse(w, r, ErrEntityTooLarge)
+			return
+		}
+	}
+
+	requestBuffer, err := ioutil.ReadAll(io.LimitReader(r.Body, MAX_SELECT_REQUEST_SIZE))
+	if err != nil {
+		helper.ErrorIf(err, "Unable to read select request body")
+		WriteErrorResponse(w, r, ErrInternalError)
+		return
+	}
+	request, err := SelectObjectContentFromXml(requestBuffer)
+	if err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+	selector, err := s3select.New(request)
+	if err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+
+	version := r.URL.Query().Get("versionId")
+	object, err := api.ObjectAPI.GetObjectInfo(bucketName, objectName, version, credential)
+	if err != nil {
+		helper.ErrorIf(err, "Unable to fetch object info.")
+		if err == ErrNoSuchKey {
+			err = api.errAllowableObjectNotFound(r, bucketName, credential)
+		}
+		WriteErrorResponse(w, r, err)
+		return
+	}
+	if object.DeleteMarker {
+		w.Header().Set("x-amz-delete-marker", "true")
+		WriteErrorResponse(w, r, ErrNoSuchKey)
+		return
+	}
+	if !object.IsReadable() {
+		WriteErrorResponse(w, r, ErrInvalidObjectState)
+		return
+	}
+
+	sseRequest, err := parseSseHeader(r.Header)
+	if err != nil {
+		WriteErrorResponse(w, r, err)
+		return
+	}
+	if len(sseRequest.CopySourceSseCustomerKey) != 0 {
+		WriteErrorResponse(w, r, ErrInvalidSseHeader)
+		return
+	}
+
+	offset, length := selector.ReadRange(object.Size)
+	pipeReader, pipeWriter := io.Pipe()
+	defer pipeReader.Close()
+	go func() {
+		var err error
+		if length > 0 {
+			err = api.ObjectAPI.GetObject(object, offset, length, pipeWriter, sseRequest)
+		}
+		pipeWriter.CloseWithError(err)
+	}()
+	// errors like wrong SSE-C keys are found once reading starts, return
+	// them before the response starts.
+	data := bufio.NewReader(pipeReader)
+	if _, err = data.Peek(1); err != nil && err != io.EOF {
+		helper.ErrorIf(err, "Unable to read object for select.")
+		WriteErrorResponse(w, r, err)
+		return
+	}
+
+	w.(*ResponseRecorder).status = http.StatusOK
+	w.WriteHeader(http.StatusOK)
+	writer := funcToWriter(func(p []byte) (int, error) {
+		n, err := w.Write(p)
+		w.(*ResponseRecorder).size += int64(n)
+		w.(http.Flusher).Flush()
+		return n, err
+	})
+	if err = selector.Run(data, writer); err != nil {
+		helper.ErrorIf(err, "Unable to select object content.")
+	}
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/journeymidnight/yig/api/datatype"
+	"github.com/journeymidnight/yig/api/datatype/policy"
+	. "github.com/journeymidnight/yig/error"
+	"github.com/journeymidnight/yig/helper"
+	"github.com/journeymidnight/yig/iam/common"
+	meta "github.com/journeymidnight/yig/meta/types"
+	"github.com/journeymidnight/yig/signature"
+)
+
+const websiteRedirectLocationKey = "x-amz-website-redirect-location"
+
+// WebsiteHandler - GET/HEAD on website domains
+// ----------
+// Serves buckets with website configuration as static websites, for anonymous
+// requests only. Objects are read with the permission of anonymous users, i.e.
+// they should be public by ACL or bucket policy.
+//   - RedirectAllRequestsTo and routing rules are applied before reading objects
+//   - the index document is served for keys of "directories" ending with "/"
+//   - "dir" is redirected to "dir/" if the index document of "dir/" exists
+//   - x-amz-website-redirect-location of objects is redirected to
+//   - the error document, if any, is served on errors
+func (api ObjectAPIHandlers) WebsiteHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName := vars["bucket"]
+	objectName := vars["object"]
+
+	if signature.GetRequestAuthType(r) != signature.AuthTypeAnonymous {
+		writeWebsiteError(w, r, ErrAccessDenied)
+		return
+	}
+	bucket, err := api.ObjectAPI.GetBucket(bucketName)
+	if err != nil {
+		writeWebsiteError(w, r, err)
+		return
+	}
+	website := bucket.Website
+	if !website.IsEnabled() {
+		writeWebsiteError(w, r, ErrNoSuchWebsiteConfiguration)
+		return
+	}
+
+	protocol := "http"
+	if r.TLS != nil {
+		protocol = "https"
+	}
+	if redirect := website.RedirectAllRequestsTo; redirect != nil {
+		if redirect.Protocol != "" {
+			protocol = redirect.Protocol
+		}
+		writeWebsiteRedirect(w, protocol+"://"+redirect.HostName+"/"+objectName,
+			http.StatusMovedPermanently)
+		return
+	}
+	if rule := website.RoutingRuleFor(objectName, 0); rule != nil {
+		location, code := rule.RedirectLocation(objectName, r.Host, protocol)
+		writeWebsiteRedirect(w, location, code)
+		return
+	}
+
+	suffix := website.IndexDocument.Suffix
+	key := objectName
+	if key == "" || strings.HasSuffix(key, "/") {
+		key += suffix
+	}
+	object, err := api.getWebsiteObject(r, bucketName, key)
+	if err == ErrNoSuchKey && key == objectName {
+		if _, err := api.getWebsiteObject(r, bucketName, key+"/"+suffix); err == nil {
+			writeWebsiteRedirect(w, "/"+key+"/", http.StatusFound)
+			return
+		}
+	}
+	if err != nil {
+		helper.Debugln("Unable to serve website object", bucketName, key, "err:", err)
+		code := http.StatusInternalServerError
+		if apiErr, ok := err.(ApiError); ok {
+			code = apiErr.HttpStatusCode()
+		}
+		if rule := website.RoutingRuleFor(objectName, code); rule != nil {
+			location, code := rule.RedirectLocation(objectName, r.Host, protocol)
+			writeWebsiteRedirect(w, location, code)
+			return
+		}
+		if website.ErrorDocument != nil {
+			api.writeWebsiteErrorDocument(w, r, bucketName, website.ErrorDocument.Key, err)
+			return
+		}
+		writeWebsiteError(w, r, err)
+		return
+	}
+	if location := object.CustomAttributes[websiteRedirectLocationKey]; location != "" {
+		writeWebsiteRedirect(w, location, http.StatusMovedPermanently)
+		return
+	}
+
+	// serve as GET/HEAD Object, for ranges and preconditions
+	vars["object"] = key
+	if r.Method == http.MethodHead {
+		api.HeadObjectHandler(w, r)
+	} else {
+		api.GetObjectHandler(w, r)
+	}
+}
+
+func (api ObjectAPIHandlers) getWebsiteObject(r *http.Request, bucketName,
+	key string) (object *meta.Object, err error) {
+
+	credential, err := IsBucketPolicyAllowed(common.Credential{}, api, r, policy.GetObjectAction,
+		bucketName, key)
+	if err != nil {
+		return nil, err
+	}
+	object, err = api.ObjectAPI.GetObjectInfo(bucketName, key, "", credential)
+	if err != nil {
+		return nil, err
+	}
+	if object.DeleteMarker {
+		return nil, ErrNoSuchKey
+	}
+	return object, nil
+}
+
+// Serve the error document with status code of `err`, or the default error
+// page if the document is not readable either.
+func (api ObjectAPIHandlers) writeWebsiteErrorDocument(w http.ResponseWriter, r *http.Request,
+	bucketName, key string, err error) {
+
+	object, e := api.getWebsiteObject(r, bucketName, key)
+	if e != nil {
+		writeWebsiteError(w, r, err)
+		return
+	}
+	SetObjectHeaders(w, object, nil)
+	WriteErrorResponseHeaders(w, err)
+	if r.Method == http.MethodHead {
+		return
+	}
+	writer := funcToWriter(func(p []byte) (int, error) {
+		n, err := w.Write(p)
+		w.(*ResponseRecorder).size += int64(n)
+		return n, err
+	})
+	if e = api.ObjectAPI.GetObject(object, 0, object.Size, writer, datatype.SseRequest{}); e != nil {
+		helper.ErrorIf(e, "Unable to write error document "+bucketName+"/"+key)
+	}
+}
+
+func writeWebsiteRedirect(w http.ResponseWriter, location string, code int) {
+	w.(*ResponseRecorder).status = code
+	w.Header().Set("Location", location)
+	w.WriteHeader(code)
+}
+
+// Error pages of website endpoints are in HTML instead of XML
+func writeWebsiteError(w http.ResponseWriter, r *http.Request, err error) {
+	code, message := "InternalError", err.Error()
+	if apiErr, ok := err.(ApiError); ok {
+		code, message = apiErr.AwsErrorCode(), apiErr.Description()
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	WriteErrorResponseHeaders(w, err)
+	if r.Method == http.MethodHead {
+		return
+	}
+	page := fmt.Sprintf("<html>\n<head><title>%[1]s</title></head>\n<body>\n<h1>%[1]s</h1>\n"+
+		"<ul>\n<li>Message: %[2]s</li>\n<li>RequestId: %[3]s</li>\n</ul>\n</body>\n</html>\n",
+		html.EscapeString(code), html.EscapeString(message),
+		r.Context().Value(RequestContextKey).(RequestContext).RequestId)
+	w.(*ResponseRecorder).size = int64(len(page))
+	w.Write([]byte(page))
+}
@@ -0,0 +1,31 @@
+package circuitbreak
+
+import (
+	"time"
+
+	"github.com/cep21/circuit"
+	"github.com/cep21/circuit/closers/hystrix"
+	"github.com/journeymidnight/yig/helper"
+)
+
+// Circuit for reads and writes of a pool in a Ceph cluster. Requests are
+// not limited by time or concurrency here, since writes are as slow as
+// clients; reads take care of their own timeout.
+func NewCephCircuit(name string) *circuit.Circuit {
+	return circuit.NewCircuitFromConfig(name, circuit.Config{
+		General: circuit.GeneralConfig{
+			OpenToClosedFactory: hystrix.CloserFactory(hystrix.ConfigureCloser{
+				SleepWindow:                  time.Duration(helper.CONFIG.CephCircuitCloseSleepWindow) * time.Second,
+				RequiredConcurrentSuccessful: int64(helper.CONFIG.CephCircuitCloseRequiredCount),
+			}),
+			ClosedToOpenFactory: hystrix.OpenerFactory(hystrix.ConfigureOpener{
+				RequestVolumeThreshold:   int64(helper.CONFIG.CephCircuitOpenThreshold),
+				ErrorThresholdPercentage: int64(helper.CONFIG.CephCircuitErrorPercentage),
+			}),
+		},
+		Execution: circuit.ExecutionConfig{
+			Timeout:               -1,
+			MaxConcurrentRequests: -1,
+		},
+	})
+}
@@ -2,6 +2,9 @@ package main
 
 import (
 	"sync"
+	"sync/atomic"
+
+	"github.com/journeymidnight/yig/storage"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -22,6 +25,12 @@ func NewMetrics(namespace string) *Metrics {
 	return &Metrics{
 		metrics: map[string]*prometheus.Desc{
 			"bucket_usage_byte_metric": newGlobalMetric(namespace, "bucket_usage_byte_metric","The description of bucket_usage_byte_metric", []string{"bucket_name", "owner"}),
+			"meta_cache_negative_hit_metric": newGlobalMetric(namespace, "meta_cache_negative_hit_metric", "Number of metadata lookups answered by a cached not-found entry", nil),
+			"meta_cache_coalesced_metric": newGlobalMetric(namespace, "meta_cache_coalesced_metric", "Number of metadata cache misses which shared another request's database query", nil),
+			"ceph_circuit_open_metric": newGlobalMetric(namespace, "ceph_circuit_open_metric", "Whether the circuit of a Ceph cluster and pool is open", []string{"cluster", "pool"}),
+			"ceph_used_percent_metric": newGlobalMetric(namespace, "ceph_used_percent_metric", "Used space percentage of a Ceph cluster", []string{"cluster", "pool"}),
+			"ceph_latency_metric": newGlobalMetric(namespace, "ceph_latency_metric", "Latency in seconds of probing a Ceph pool", []string{"cluster", "pool"}),
+			"ceph_hedged_read_metric": newGlobalMetric(namespace, "ceph_hedged_read_metric", "Number of reads issued again because Ceph is slow", nil),
 		},
 	}
 }
@@ -40,6 +49,26 @@ func (c *Metrics) Collect(ch chan<- prometheus.Metric) {
 	for bucket, data := range GaugeMetricData {
 		ch <-prometheus.MustNewConstMetric(c.metrics["bucket_usage_byte_metric"], prometheus.GaugeValue, float64(data.value), bucket, data.owner)
 	}
+
+	cacheStats := adminServer.Yig.MetaStorage.Cache.GetCacheStats()
+	ch <- prometheus.MustNewConstMetric(c.metrics["meta_cache_negative_hit_metric"], prometheus.CounterValue, float64(cacheStats.NegativeHit))
+	ch <- prometheus.MustNewConstMetric(c.metrics["meta_cache_coalesced_metric"], prometheus.CounterValue, float64(cacheStats.Coalesced))
+
+	for fsid, cluster := range adminServer.Yig.DataStorage {
+		for pool := range cluster.Circuits {
+			var open float64
+			if !cluster.IsHealthy(pool) {
+				open = 1
+			}
+			ch <- prometheus.MustNewConstMetric(c.metrics["ceph_circuit_open_metric"], prometheus.GaugeValue, open, fsid, pool)
+			if status, ok := cluster.GetPoolStatus(pool); ok {
+				ch <- prometheus.MustNewConstMetric(c.metrics["ceph_used_percent_metric"], prometheus.GaugeValue, float64(status.UsedPercent), fsid, pool)
+				ch <- prometheus.MustNewConstMetric(c.metrics["ceph_latency_metric"], prometheus.GaugeValue, status.Latency.Seconds(), fsid, pool)
+			}
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(c.metrics["ceph_hedged_read_metric"], prometheus.CounterValue,
+		float64(atomic.LoadInt64(&storage.HedgedReadCount)))
 }
 
 func (c *Metrics) GenerateUsageData() (GaugeMetricData map[string]UsageData) {
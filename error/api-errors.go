@@ -112,6 +112,53 @@ const (
 	ErrInvalidPosition
 	ErrObjectNotAppendable
 	ErrPositionNotEqualToLength
+	ErrStorageFull
+	ErrNoSuchDrainJob
+	ErrDrainJobExists
+	ErrClusterNotDrainable
+	ErrInvalidChecksum
+	ErrBadChecksum
+	ErrInvalidObjectAttributes
+	ErrInvalidCompression
+	ErrInvalidRenameSource
+	ErrRenameTargetExists
+	ErrTooManyKeysToRename
+	ErrRenameEncryptedObject
+	ErrInvalidNotificationDocument
+	ErrInvalidNotificationDestination
+	ErrInvalidTargetBucketForLogging
+	ErrInvalidTag
+	ErrTooManyTags
+	ErrNoSuchTagSet
+	ErrInvalidTaggingDirective
+	ErrNoSuchWebsiteConfiguration
+	ErrInvalidWebsiteConfiguration
+	ErrReplicationConfigurationNotFound
+	ErrInvalidReplicationConfiguration
+	ErrInvalidReplicationDestination
+	ErrReplicationRequiresVersioning
+	ErrNoSuchInventoryConfiguration
+	ErrInvalidInventoryConfiguration
+	ErrInvalidInventoryDestination
+	ErrTooManyInventoryConfigurations
+	ErrInvalidExpressionType
+	ErrInvalidCompressionFormat
+	ErrInvalidSelectParameter
+	ErrInvalidScanRange
+	ErrParseSelectFailure
+	ErrUnsupportedSqlOperation
+	ErrInvalidDataType
+	ErrInvalidCast
+	ErrDivisionByZero
+	ErrEvaluatorInvalidArguments
+	ErrCSVParsingError
+	ErrJSONParsingError
+	ErrNoSuchBatchJob
+	ErrInvalidBatchJob
+	ErrInvalidBatchJobStatus
+	ErrInvalidObjectState
+	ErrRestoreAlreadyInProgress
+	ErrMalformedRestoreRequest
 	// Add new error codes here.
 
 	// SSE-S3 related API errors
@@ -648,6 +695,241 @@ var ErrorCodeResponse = map[ApiErrorCode]ApiErrorStruct{
 		Description:    "The value of position does not match the length of the current Object.",
 		HttpStatusCode: http.StatusConflict,
 	},
+	ErrStorageFull: {
+		AwsErrorCode:   "StorageFull",
+		Description:    "Storage backend has reached its maximum used space, please delete some objects or try again later.",
+		HttpStatusCode: http.StatusInsufficientStorage,
+	},
+	ErrNoSuchDrainJob: {
+		AwsErrorCode:   "NoSuchDrainJob",
+		Description:    "No drain job is running for the specified cluster.",
+		HttpStatusCode: http.StatusNotFound,
+	},
+	ErrDrainJobExists: {
+		AwsErrorCode:   "DrainJobExists",
+		Description:    "A drain job for the specified cluster already exists.",
+		HttpStatusCode: http.StatusConflict,
+	},
+	ErrClusterNotDrainable: {
+		AwsErrorCode:   "ClusterNotDrainable",
+		Description:    "The specified cluster does not exist or its weight is not 0.",
+		HttpStatusCode: http.StatusConflict,
+	},
+	ErrInvalidChecksum: {
+		AwsErrorCode:   "InvalidRequest",
+		Description:    "The checksum algorithm, header or trailer you specified is not valid.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrBadChecksum: {
+		AwsErrorCode:   "BadDigest",
+		Description:    "The checksum you specified did not match the calculated checksum.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrInvalidObjectAttributes: {
+		AwsErrorCode:   "InvalidArgument",
+		Description:    "Invalid attribute name specified.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrInvalidCompression: {
+		AwsErrorCode:   "InvalidCompression",
+		Description:    "The compression you specified is not supported.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrInvalidRenameSource: {
+		AwsErrorCode:   "InvalidArgument",
+		Description:    "Rename source must be a key, or a prefix ending with '/' if renaming to a prefix, in the same bucket.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrRenameTargetExists: {
+		AwsErrorCode:   "RenameTargetExists",
+		Description:    "Versions of the rename target exist in the versioned bucket.",
+		HttpStatusCode: http.StatusConflict,
+	},
+	ErrTooManyKeysToRename: {
+		AwsErrorCode:   "TooManyKeysToRename",
+		Description:    "Too many keys under the prefix to rename in one request.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrRenameEncryptedObject: {
+		AwsErrorCode:   "InvalidRequest",
+		Description:    "Objects encrypted with SSE-S3 cannot be renamed, copy them instead.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrInvalidNotificationDocument: {
+		AwsErrorCode:   "InvalidArgument",
+		Description:    "The notification configuration you provided is not valid.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrInvalidNotificationDestination: {
+		AwsErrorCode:   "InvalidArgument",
+		Description:    "Unable to validate the destination configurations of the notification.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrInvalidTargetBucketForLogging: {
+		AwsErrorCode:   "InvalidTargetBucketForLogging",
+		Description:    "The target bucket for logging does not exist or is not owned by you.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrInvalidTag: {
+		AwsErrorCode:   "InvalidTag",
+		Description:    "The tag provided was not a valid tag.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrTooManyTags: {
+		AwsErrorCode:   "BadRequest",
+		Description:    "Too many tags are provided.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrNoSuchTagSet: {
+		AwsErrorCode:   "NoSuchTagSet",
+		Description:    "The TagSet does not exist.",
+		HttpStatusCode: http.StatusNotFound,
+	},
+	ErrInvalidTaggingDirective: {
+		AwsErrorCode:   "InvalidArgument",
+		Description:    "Unknown tagging directive.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrNoSuchWebsiteConfiguration: {
+		AwsErrorCode:   "NoSuchWebsiteConfiguration",
+		Description:    "The specified bucket does not have a website configuration.",
+		HttpStatusCode: http.StatusNotFound,
+	},
+	ErrInvalidWebsiteConfiguration: {
+		AwsErrorCode:   "InvalidArgument",
+		Description:    "The website configuration you provided is not valid.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrReplicationConfigurationNotFound: {
+		AwsErrorCode:   "ReplicationConfigurationNotFoundError",
+		Description:    "The replication configuration was not found.",
+		HttpStatusCode: http.StatusNotFound,
+	},
+	ErrInvalidReplicationConfiguration: {
+		AwsErrorCode:   "InvalidRequest",
+		Description:    "The replication configuration you provided is not valid.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrInvalidReplicationDestination: {
+		AwsErrorCode:   "InvalidRequest",
+		Description:    "Destination bucket must exist and be owned by the bucket owner, or its target must be configured.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrReplicationRequiresVersioning: {
+		AwsErrorCode:   "InvalidRequest",
+		Description:    "Versioning must be 'Enabled' on the bucket to apply a replication configuration.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrNoSuchInventoryConfiguration: {
+		AwsErrorCode:   "NoSuchConfiguration",
+		Description:    "The specified inventory configuration does not exist.",
+		HttpStatusCode: http.StatusNotFound,
+	},
+	ErrInvalidInventoryConfiguration: {
+		AwsErrorCode:   "InvalidArgument",
+		Description:    "The inventory configuration you provided is not valid.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrInvalidInventoryDestination: {
+		AwsErrorCode:   "InvalidArgument",
+		Description:    "Destination bucket must exist and be owned by the bucket owner.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrTooManyInventoryConfigurations: {
+		AwsErrorCode:   "TooManyConfigurations",
+		Description:    "You are attempting to create a new configuration but have already reached the 1,000-configuration limit.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrInvalidExpressionType: {
+		AwsErrorCode:   "InvalidExpressionType",
+		Description:    "The ExpressionType is invalid. Only SQL expressions are supported.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrInvalidCompressionFormat: {
+		AwsErrorCode:   "InvalidCompressionFormat",
+		Description:    "The file is not in a supported compression format. Only GZIP and BZIP2 are supported.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrInvalidSelectParameter: {
+		AwsErrorCode:   "InvalidRequestParameter",
+		Description:    "The value of a parameter in SelectRequest element is invalid. Check the service API documentation and try again.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrInvalidScanRange: {
+		AwsErrorCode:   "InvalidRequestParameter",
+		Description:    "The scan range is invalid, or the object could not be scanned by range.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrParseSelectFailure: {
+		AwsErrorCode:   "ParseSelectFailure",
+		Description:    "The SQL expression contains an error and could not be parsed.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrUnsupportedSqlOperation: {
+		AwsErrorCode:   "UnsupportedSqlOperation",
+		Description:    "Encountered an unsupported SQL operation.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrInvalidDataType: {
+		AwsErrorCode:   "InvalidDataType",
+		Description:    "The SQL expression contains an invalid data type.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrInvalidCast: {
+		AwsErrorCode:   "CastFailed",
+		Description:    "Attempt to convert from one data type to another using CAST failed in the SQL expression.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrDivisionByZero: {
+		AwsErrorCode:   "DivisionByZero",
+		Description:    "Division by zero in the SQL expression.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrEvaluatorInvalidArguments: {
+		AwsErrorCode:   "EvaluatorInvalidArguments",
+		Description:    "Incorrect number of arguments in the function call in the SQL expression.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrCSVParsingError: {
+		AwsErrorCode:   "CSVParsingError",
+		Description:    "Encountered an error parsing the CSV file. Check the file and try again.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrJSONParsingError: {
+		AwsErrorCode:   "JSONParsingError",
+		Description:    "Encountered an error parsing the JSON file. Check the file and try again.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrNoSuchBatchJob: {
+		AwsErrorCode:   "NoSuchJob",
+		Description:    "The specified batch job does not exist.",
+		HttpStatusCode: http.StatusNotFound,
+	},
+	ErrInvalidBatchJob: {
+		AwsErrorCode:   "InvalidRequest",
+		Description:    "The batch job has an invalid operation, manifest or report.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
+	ErrInvalidBatchJobStatus: {
+		AwsErrorCode:   "JobStatusConflict",
+		Description:    "The status of the batch job does not allow the requested change.",
+		HttpStatusCode: http.StatusConflict,
+	},
+	ErrInvalidObjectState: {
+		AwsErrorCode:   "InvalidObjectState",
+		Description:    "The operation is not valid for the object's storage class.",
+		HttpStatusCode: http.StatusForbidden,
+	},
+	ErrRestoreAlreadyInProgress: {
+		AwsErrorCode:   "RestoreAlreadyInProgress",
+		Description:    "Object restore is already in progress.",
+		HttpStatusCode: http.StatusConflict,
+	},
+	ErrMalformedRestoreRequest: {
+		AwsErrorCode:   "MalformedXML",
+		Description:    "The restore request is not well-formed or does not have a positive number of days.",
+		HttpStatusCode: http.StatusBadRequest,
+	},
 	ErrInvalidStorageClass: {
 		AwsErrorCode:   "InvalidStorageClass",
 		Description:    "The storage class you specified in header is invalid.",
@@ -11,6 +11,7 @@ const (
 	YIG_CONF_PATH = "/etc/yig/yig.toml"
 	MIN_DOWNLOAD_BUFPOOL_SIZE = 512 << 10  // 512k
 	MAX_DOWNLOAD_BUFPOOL_SIZE = 8 << 20    // 8M
+	MAX_INLINE_OBJECT_THRESHOLD = 32 << 10 // 32K
 )
 
 type Config struct {
@@ -48,8 +49,21 @@ type Config struct {
 	RedisAddress          string `toml:"redis_address"`           // redis connection string, e.g localhost:1234
 	RedisConnectionNumber int    `toml:"redis_connection_number"` // number of connections to redis(i.e max concurrent request number)
 	RedisPassword         string `toml:"redis_password"`          // redis auth password
+	// 0 for single node, 1 for sentinel, 2 for cluster
+	RedisMode              int      `toml:"redis_mode"`
+	RedisSentinelAddresses []string `toml:"redis_sentinel_addresses"` // e.g ["sentinel1:26379", "sentinel2:26379"]
+	RedisSentinelMaster    string   `toml:"redis_sentinel_master"`    // master name monitored by sentinels
+	RedisClusterAddresses  []string `toml:"redis_cluster_addresses"`  // some of the cluster nodes, used to find the others
 	MetaCacheType         int    `toml:"meta_cache_type"`
+	// Seconds to cache a "not found" metadata lookup, negative value to disable
+	MetaCacheNegativeTTL  int    `toml:"meta_cache_negative_ttl"`
 	EnableDataCache       bool   `toml:"enable_data_cache"`
+	DataCacheBackend      string `toml:"data_cache_backend"`    // "redis" or "disk"
+	DataCacheChunkSize    int    `toml:"data_cache_chunk_size"` // in bytes, object data is cached in aligned chunks
+	DataCacheDiskPath     string `toml:"data_cache_disk_path"`  // directory for "disk" backend, better on local NVMe
+	DataCacheDiskCapacity int64  `toml:"data_cache_disk_capacity"` // in bytes, least recently used chunks are evicted beyond it
+	DataCacheRedisTTL     int    `toml:"data_cache_redis_ttl"`     // in seconds, expiry of chunks in "redis" backend
+	DataCacheRedisMaxSize int64  `toml:"data_cache_redis_max_size"` // in bytes, larger objects are not cached in "redis" backend
 	RedisConnectTimeout   int    `toml:"redis_connect_timeout"`
 	RedisReadTimeout      int    `toml:"redis_read_timeout"`
 	RedisWriteTimeout     int    `toml:"redis_write_timeout"`
@@ -67,6 +81,83 @@ type Config struct {
 	// This property sets the minimum number of requests in a rolling window that will trip the circuit.
 	CacheCircuitOpenThreshold int `toml:"cache_circuit_open_threshold"`
 
+	// Circuits for each Ceph cluster and pool, same meanings as cache circuit above
+	CephCircuitCloseSleepWindow    int `toml:"ceph_circuit_close_sleep_window"`
+	CephCircuitCloseRequiredCount  int `toml:"ceph_circuit_close_required_count"`
+	CephCircuitOpenThreshold       int `toml:"ceph_circuit_open_threshold"`
+	// Percentage of failed requests in a rolling window to trip the circuit
+	CephCircuitErrorPercentage     int `toml:"ceph_circuit_error_percentage"`
+	// In milliseconds, a read from Ceph taking longer fails and counts against the circuit, 0 to disable
+	CephReadTimeout int `toml:"ceph_read_timeout"`
+	// In milliseconds, if a read from Ceph doesn't return in time, issue the same read again
+	// and take the faster one, 0 to disable
+	CephHedgedReadDelay int `toml:"ceph_hedged_read_delay"`
+
+	// In seconds, how often used space and latency of Ceph clusters are refreshed
+	CephCapacityCheckInterval int `toml:"ceph_capacity_check_interval"`
+	// Clusters over soft threshold are written only if all clusters are over it,
+	// clusters over hard threshold are never written. Both are percentages of used space.
+	CephSoftUsedPercent int `toml:"ceph_soft_used_percent"`
+	CephHardUsedPercent int `toml:"ceph_hard_used_percent"`
+	// In milliseconds, pools slower to probe are written only if all pools are slow or fenced
+	CephSlowLatency int `toml:"ceph_slow_latency"`
+
+	// In seconds, data of uploads not committed in this time is put into gc by the delete daemon.
+	// Metadata commits after it fail, so it should be longer than the slowest upload.
+	WriteIntentTimeout int `toml:"write_intent_timeout"`
+
+	// Objects smaller than it are stored in metadata rows instead of Ceph, 0 to disable
+	InlineObjectThreshold int `toml:"inline_object_threshold"`
+
+	// Pack objects smaller than 128K into large RADOS objects called containers, to keep
+	// RADOS objects fewer. Containers are sealed after `pack_container_size` bytes written.
+	PackSmallObjects  bool  `toml:"pack_small_objects"`
+	PackContainerSize int64 `toml:"pack_container_size"`
+	// Containers whose ratio of live data is under it are compacted by the delete daemon,
+	// which checks them every `pack_compaction_interval` seconds
+	PackCompactionRatio    float64 `toml:"pack_compaction_ratio"`
+	PackCompactionInterval int     `toml:"pack_compaction_interval"`
+
+	// Verify checksums of objects and parts read in whole, responses are aborted on mismatch
+	VerifyChecksumOnRead bool `toml:"verify_checksum_on_read"`
+
+	// Defaults of cluster drain jobs started from admin API
+	DrainRateLimit   int64 `toml:"drain_rate_limit"` // in bytes per second, 0 for unlimited
+	DrainConcurrency int   `toml:"drain_concurrency"`
+
+	// Max keys renamed by one prefix rename request, all of them are renamed in one transaction
+	RenameMaxKeys int `toml:"rename_max_keys"`
+
+	// Access logs of buckets are buffered and written into their target buckets
+	// every interval(in seconds), or once the buffer of a bucket is over size(in bytes)
+	BucketLoggingFlushInterval int   `toml:"bucket_logging_flush_interval"`
+	BucketLoggingBufferSize    int64 `toml:"bucket_logging_buffer_size"`
+
+	// Domains serving buckets as static websites, i.e. bucket_name.website.domain,
+	// for anonymous GET and HEAD requests only
+	WebsiteDomain []string `toml:"website_domain"`
+
+	// Workers replicating objects on this instance, negative to disable
+	ReplicationConcurrency int `toml:"replication_concurrency"`
+	// Failed replications are retried with backoff from 1 second up to
+	// ReplicationRetryMaxInterval seconds, and given up after ReplicationMaxRetries
+	ReplicationMaxRetries       int `toml:"replication_max_retries"`
+	ReplicationRetryMaxInterval int `toml:"replication_retry_max_interval"`
+	// Remote S3 compatible endpoints named by destination bucket ARNs of
+	// replication rules, e.g. "arn:aws:s3:dc2::bucket" for [replication_targets.dc2]
+	ReplicationTargets map[string]ReplicationTarget `toml:"replication_targets"`
+
+	// Keys processed concurrently by batch jobs created without concurrency,
+	// batch jobs are not run on this instance if negative
+	BatchJobConcurrency int `toml:"batch_job_concurrency"`
+
+	// Ceph pool storing data of GLACIER and DEEP_ARCHIVE objects, which could be
+	// read only from temporary copies made by RestoreObject. They are stored
+	// with others if empty.
+	ArchivePool string `toml:"archive_pool"`
+	// Workers restoring archived objects on this instance, negative to disable
+	RestoreConcurrency int `toml:"restore_concurrency"`
+
 	DownLoadBufPoolSize int `toml:"download_buf_pool_size"`
 
 	KMS KMSConfig `toml:"kms"`
@@ -81,6 +172,13 @@ type PluginConfig struct {
         Args  map[string]interface{} `toml:"args"`
 }
 
+type ReplicationTarget struct {
+	Endpoint  string `toml:"endpoint"` // e.g. "https://s3.dc2.example.com"
+	Region    string `toml:"region"`
+	AccessKey string `toml:"access_key"`
+	SecretKey string `toml:"secret_key"`
+}
+
 type KMSConfig struct {
 	Type     string
 	Endpoint string
@@ -107,6 +205,9 @@ type MsgBusConfig struct {
 	// Controls the settings for the implementation of message bus.
 	// For kafka, the 'broker_list' must be set, like 'broker_list = "kafka:29092"'
 	Server map[string]interface{} `toml:"msg_bus_server"`
+	// Controls the settings of webhook, which sends notifications to topics of
+	// endpoint URLs beside the message bus. The 'spool_dir' must be set to enable it.
+	Webhook map[string]interface{} `toml:"msg_bus_webhook"`
 }
 
 var CONFIG Config
@@ -129,6 +230,7 @@ func MarshalTOMLConfig() error {
 	}
 	// setup CONFIG with defaults
 	CONFIG.S3Domain = c.S3Domain
+	CONFIG.WebsiteDomain = c.WebsiteDomain
 	CONFIG.Region = c.Region
 	CONFIG.Plugins = c.Plugins
 	CONFIG.LogPath = c.LogPath
@@ -165,10 +267,22 @@ func MarshalTOMLConfig() error {
 
 	CONFIG.RedisAddress = c.RedisAddress
 	CONFIG.RedisPassword = c.RedisPassword
+	CONFIG.RedisMode = c.RedisMode
+	CONFIG.RedisSentinelAddresses = c.RedisSentinelAddresses
+	CONFIG.RedisSentinelMaster = c.RedisSentinelMaster
+	CONFIG.RedisClusterAddresses = c.RedisClusterAddresses
 	CONFIG.RedisConnectionNumber = Ternary(c.RedisConnectionNumber == 0,
 		10, c.RedisConnectionNumber).(int)
 	CONFIG.EnableDataCache = c.EnableDataCache
+	CONFIG.DataCacheBackend = Ternary(c.DataCacheBackend == "", "redis", c.DataCacheBackend).(string)
+	CONFIG.DataCacheChunkSize = Ternary(c.DataCacheChunkSize <= 0, 1<<20, c.DataCacheChunkSize).(int)
+	CONFIG.DataCacheDiskPath = Ternary(c.DataCacheDiskPath == "", "/var/cache/yig", c.DataCacheDiskPath).(string)
+	CONFIG.DataCacheDiskCapacity = Ternary(c.DataCacheDiskCapacity <= 0, int64(10<<30), c.DataCacheDiskCapacity).(int64)
+	CONFIG.DataCacheRedisTTL = Ternary(c.DataCacheRedisTTL <= 0, 3600, c.DataCacheRedisTTL).(int)
+	CONFIG.DataCacheRedisMaxSize = Ternary(c.DataCacheRedisMaxSize <= 0, int64(4<<20), c.DataCacheRedisMaxSize).(int64)
 	CONFIG.MetaCacheType = c.MetaCacheType
+	CONFIG.MetaCacheNegativeTTL = Ternary(c.MetaCacheNegativeTTL == 0, 5, c.MetaCacheNegativeTTL).(int)
+	CONFIG.MetaCacheNegativeTTL = Ternary(c.MetaCacheNegativeTTL < 0, 0, CONFIG.MetaCacheNegativeTTL).(int)
 	CONFIG.RedisConnectTimeout = Ternary(c.RedisConnectTimeout < 0, 0, c.RedisConnectTimeout).(int)
 	CONFIG.RedisReadTimeout = Ternary(c.RedisReadTimeout < 0, 0, c.RedisReadTimeout).(int)
 	CONFIG.RedisWriteTimeout = Ternary(c.RedisWriteTimeout < 0, 0, c.RedisWriteTimeout).(int)
@@ -181,6 +295,45 @@ func MarshalTOMLConfig() error {
 	CONFIG.CacheCircuitCloseRequiredCount = Ternary(c.CacheCircuitCloseRequiredCount < 0, 0, c.CacheCircuitCloseRequiredCount).(int)
 	CONFIG.CacheCircuitOpenThreshold = Ternary(c.CacheCircuitOpenThreshold < 0, 0, c.CacheCircuitOpenThreshold).(int)
 
+	CONFIG.CephCircuitCloseSleepWindow = Ternary(c.CephCircuitCloseSleepWindow <= 0, 5, c.CephCircuitCloseSleepWindow).(int)
+	CONFIG.CephCircuitCloseRequiredCount = Ternary(c.CephCircuitCloseRequiredCount <= 0, 1, c.CephCircuitCloseRequiredCount).(int)
+	CONFIG.CephCircuitOpenThreshold = Ternary(c.CephCircuitOpenThreshold <= 0, 20, c.CephCircuitOpenThreshold).(int)
+	CONFIG.CephCircuitErrorPercentage = Ternary(c.CephCircuitErrorPercentage <= 0 || c.CephCircuitErrorPercentage > 100,
+		50, c.CephCircuitErrorPercentage).(int)
+	CONFIG.CephReadTimeout = Ternary(c.CephReadTimeout < 0, 0, c.CephReadTimeout).(int)
+	CONFIG.CephHedgedReadDelay = Ternary(c.CephHedgedReadDelay < 0, 0, c.CephHedgedReadDelay).(int)
+	CONFIG.CephCapacityCheckInterval = Ternary(c.CephCapacityCheckInterval <= 0, 30, c.CephCapacityCheckInterval).(int)
+	CONFIG.CephHardUsedPercent = Ternary(c.CephHardUsedPercent <= 0 || c.CephHardUsedPercent > 100,
+		90, c.CephHardUsedPercent).(int)
+	CONFIG.CephSoftUsedPercent = Ternary(c.CephSoftUsedPercent <= 0 || c.CephSoftUsedPercent > CONFIG.CephHardUsedPercent,
+		Ternary(CONFIG.CephHardUsedPercent > 80, 80, CONFIG.CephHardUsedPercent), c.CephSoftUsedPercent).(int)
+	CONFIG.CephSlowLatency = Ternary(c.CephSlowLatency <= 0, 1000, c.CephSlowLatency).(int)
+	CONFIG.WriteIntentTimeout = Ternary(c.WriteIntentTimeout <= 0, 86400, c.WriteIntentTimeout).(int)
+	CONFIG.InlineObjectThreshold = Ternary(c.InlineObjectThreshold < 0, 0, c.InlineObjectThreshold).(int)
+	CONFIG.InlineObjectThreshold = Ternary(CONFIG.InlineObjectThreshold > MAX_INLINE_OBJECT_THRESHOLD,
+		MAX_INLINE_OBJECT_THRESHOLD, CONFIG.InlineObjectThreshold).(int)
+	CONFIG.PackSmallObjects = c.PackSmallObjects
+	CONFIG.PackContainerSize = Ternary(c.PackContainerSize <= 0, int64(64<<20), c.PackContainerSize).(int64)
+	CONFIG.PackCompactionRatio = Ternary(c.PackCompactionRatio <= 0 || c.PackCompactionRatio > 1,
+		0.5, c.PackCompactionRatio).(float64)
+	CONFIG.PackCompactionInterval = Ternary(c.PackCompactionInterval <= 0, 600, c.PackCompactionInterval).(int)
+	CONFIG.VerifyChecksumOnRead = c.VerifyChecksumOnRead
+	CONFIG.DrainRateLimit = Ternary(c.DrainRateLimit < 0, int64(0), c.DrainRateLimit).(int64)
+	CONFIG.DrainConcurrency = Ternary(c.DrainConcurrency <= 0, 4, c.DrainConcurrency).(int)
+	CONFIG.RenameMaxKeys = Ternary(c.RenameMaxKeys <= 0, 1000, c.RenameMaxKeys).(int)
+	CONFIG.BucketLoggingFlushInterval = Ternary(c.BucketLoggingFlushInterval <= 0, 300,
+		c.BucketLoggingFlushInterval).(int)
+	CONFIG.BucketLoggingBufferSize = Ternary(c.BucketLoggingBufferSize <= 0, int64(8<<20),
+		c.BucketLoggingBufferSize).(int64)
+	CONFIG.ReplicationConcurrency = Ternary(c.ReplicationConcurrency == 0, 4, c.ReplicationConcurrency).(int)
+	CONFIG.ReplicationMaxRetries = Ternary(c.ReplicationMaxRetries <= 0, 20, c.ReplicationMaxRetries).(int)
+	CONFIG.ReplicationRetryMaxInterval = Ternary(c.ReplicationRetryMaxInterval <= 0, 3600,
+		c.ReplicationRetryMaxInterval).(int)
+	CONFIG.ReplicationTargets = c.ReplicationTargets
+	CONFIG.BatchJobConcurrency = Ternary(c.BatchJobConcurrency == 0, 16, c.BatchJobConcurrency).(int)
+	CONFIG.ArchivePool = c.ArchivePool
+	CONFIG.RestoreConcurrency = Ternary(c.RestoreConcurrency == 0, 2, c.RestoreConcurrency).(int)
+
 	CONFIG.DownLoadBufPoolSize = Ternary(c.DownLoadBufPoolSize < MIN_DOWNLOAD_BUFPOOL_SIZE || c.DownLoadBufPoolSize > MAX_DOWNLOAD_BUFPOOL_SIZE, MIN_DOWNLOAD_BUFPOOL_SIZE, c.DownLoadBufPoolSize).(int)
 
 	CONFIG.KMS = c.KMS
@@ -50,4 +50,176 @@ CREATE TABLE `objects` (
    UNIQUE KEY `rowkey` (`bucketname`,`name`,`version`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_bin;
 
-INSERT INTO `objects` SELECT * FROM `objects_bak`;
\ No newline at end of file
+INSERT INTO `objects` SELECT * FROM `objects_bak`;
+
+-- write intents of uploads in progress
+
+CREATE TABLE IF NOT EXISTS `writeintent` (
+                       `location` varchar(255) DEFAULT NULL,
+                       `pool` varchar(255) DEFAULT NULL,
+                       `objectid` varchar(255) DEFAULT NULL,
+                       `bucketname` varchar(255) DEFAULT NULL,
+                       `objectname` varchar(255) DEFAULT NULL,
+                       `createtime` datetime DEFAULT NULL,
+                       UNIQUE KEY `rowkey` (`location`,`pool`,`objectid`),
+                       KEY `createtime` (`createtime`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_bin;
+
+-- CRC32C checksums of objects and parts
+
+ALTER TABLE `objects` ADD COLUMN `checksum` varchar(255) DEFAULT '';
+ALTER TABLE `objectpart` ADD COLUMN `checksum` varchar(255) DEFAULT '';
+ALTER TABLE `multipartpart` ADD COLUMN `checksum` varchar(255) DEFAULT '';
+
+-- x-amz-checksum algorithms and values
+
+ALTER TABLE `objects` ADD COLUMN `checksumalgorithm` varchar(20) DEFAULT '';
+ALTER TABLE `objects` ADD COLUMN `checksumvalue` varchar(255) DEFAULT '';
+ALTER TABLE `objectpart` ADD COLUMN `checksumalgorithm` varchar(20) DEFAULT '';
+ALTER TABLE `objectpart` ADD COLUMN `checksumvalue` varchar(255) DEFAULT '';
+ALTER TABLE `multiparts` ADD COLUMN `checksumalgorithm` varchar(20) DEFAULT '';
+ALTER TABLE `multiparts` ADD COLUMN `checksumtype` varchar(20) DEFAULT '';
+ALTER TABLE `multipartpart` ADD COLUMN `checksumalgorithm` varchar(20) DEFAULT '';
+ALTER TABLE `multipartpart` ADD COLUMN `checksumvalue` varchar(255) DEFAULT '';
+
+-- compression of buckets, objects and parts
+
+ALTER TABLE `buckets` ADD COLUMN `compression` varchar(20) DEFAULT '';
+ALTER TABLE `objects` ADD COLUMN `compression` varchar(20) DEFAULT '';
+ALTER TABLE `objects` ADD COLUMN `compressedsize` bigint(20) DEFAULT 0;
+ALTER TABLE `objectpart` ADD COLUMN `compressedsize` bigint(20) DEFAULT 0;
+ALTER TABLE `multiparts` ADD COLUMN `compression` varchar(20) DEFAULT '';
+ALTER TABLE `multipartpart` ADD COLUMN `compressedsize` bigint(20) DEFAULT 0;
+
+-- small objects packed into containers
+
+ALTER TABLE `objects` ADD COLUMN `packed` tinyint(1) DEFAULT 0;
+ALTER TABLE `objects` ADD COLUMN `packoffset` bigint(20) DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS `container` (
+                       `location` varchar(255) DEFAULT NULL,
+                       `pool` varchar(255) DEFAULT NULL,
+                       `objectid` varchar(255) DEFAULT NULL,
+                       `size` bigint(20) DEFAULT 0,
+                       `livesize` bigint(20) DEFAULT 0,
+                       `sealed` tinyint(1) DEFAULT 0,
+                       `createtime` datetime DEFAULT NULL,
+                       UNIQUE KEY `rowkey` (`location`,`pool`,`objectid`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_bin;
+
+CREATE TABLE IF NOT EXISTS `packedobject` (
+                       `location` varchar(255) DEFAULT NULL,
+                       `pool` varchar(255) DEFAULT NULL,
+                       `objectid` varchar(255) DEFAULT NULL,
+                       `offset` bigint(20) DEFAULT 0,
+                       `size` bigint(20) DEFAULT 0,
+                       `bucketname` varchar(255) DEFAULT NULL,
+                       `objectname` varchar(255) DEFAULT NULL,
+                       `version` bigint(20) UNSIGNED DEFAULT NULL,
+                       UNIQUE KEY `rowkey` (`location`,`pool`,`objectid`,`offset`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_bin;
+
+-- data of tiny objects stored in their rows
+
+ALTER TABLE `objects` ADD COLUMN `inlinedata` blob DEFAULT NULL;
+
+-- reference counts of data shared by copied objects
+
+CREATE TABLE IF NOT EXISTS `objectref` (
+                       `location` varchar(255) DEFAULT NULL,
+                       `pool` varchar(255) DEFAULT NULL,
+                       `objectid` varchar(255) DEFAULT NULL,
+                       `refcount` bigint(20) DEFAULT 0,
+                       UNIQUE KEY `rowkey` (`location`,`pool`,`objectid`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_bin;
+
+-- bucket notifications
+
+ALTER TABLE `buckets` ADD COLUMN `notification` JSON DEFAULT NULL;
+
+-- bucket logging
+
+ALTER TABLE `buckets` ADD COLUMN `logging` JSON DEFAULT NULL;
+
+-- tags of buckets, objects and multipart uploads
+
+ALTER TABLE `buckets` ADD COLUMN `tagging` JSON DEFAULT NULL;
+ALTER TABLE `objects` ADD COLUMN `tagging` JSON DEFAULT NULL;
+ALTER TABLE `multiparts` ADD COLUMN `tagging` JSON DEFAULT NULL;
+
+-- static website hosting
+
+ALTER TABLE `buckets` ADD COLUMN `website` JSON DEFAULT NULL;
+
+-- bucket replication
+
+ALTER TABLE `buckets` ADD COLUMN `replication` JSON DEFAULT NULL;
+ALTER TABLE `objects` ADD COLUMN `replicationstatus` varchar(20) DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS `replication` (
+                       `bucketname` varchar(255) DEFAULT NULL,
+                       `objectname` varchar(255) DEFAULT NULL,
+                       `version` bigint(20) UNSIGNED DEFAULT NULL,
+                       `operation` varchar(20) DEFAULT NULL,
+                       `retries` int(11) DEFAULT 0,
+                       `nexttime` datetime DEFAULT NULL,
+                       `lasterror` varchar(1024) DEFAULT '',
+                       `createtime` datetime DEFAULT NULL,
+                       UNIQUE KEY `rowkey` (`bucketname`,`objectname`,`version`,`operation`),
+                       KEY `nexttime` (`nexttime`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_bin;
+
+-- bucket inventory
+
+ALTER TABLE `buckets` ADD COLUMN `inventory` JSON DEFAULT NULL;
+
+CREATE TABLE IF NOT EXISTS `inventory` (
+                       `bucketname` varchar(255) DEFAULT NULL,
+                       `configid` varchar(64) DEFAULT NULL,
+                       `reporttime` datetime DEFAULT NULL,
+                       `keymarker` varchar(255) DEFAULT '',
+                       `versionmarker` bigint(20) UNSIGNED DEFAULT 0,
+                       `objects` bigint(20) DEFAULT 0,
+                       `files` JSON DEFAULT NULL,
+                       `done` tinyint(1) DEFAULT 0,
+                       `updatetime` datetime DEFAULT NULL,
+                       UNIQUE KEY `rowkey` (`bucketname`,`configid`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_bin;
+
+-- batch operations jobs
+
+CREATE TABLE IF NOT EXISTS `batchjob` (
+                       `id` varchar(64) DEFAULT NULL,
+                       `status` varchar(20) DEFAULT NULL,
+                       `operation` JSON DEFAULT NULL,
+                       `manifest` JSON DEFAULT NULL,
+                       `report` JSON DEFAULT NULL,
+                       `concurrency` int(11) DEFAULT 0,
+                       `total` bigint(20) DEFAULT 0,
+                       `succeeded` bigint(20) DEFAULT 0,
+                       `failed` bigint(20) DEFAULT 0,
+                       `marker` varchar(1024) DEFAULT '',
+                       `reportfiles` JSON DEFAULT NULL,
+                       `leasetime` datetime DEFAULT NULL,
+                       `lasterror` varchar(1024) DEFAULT '',
+                       `createtime` datetime DEFAULT NULL,
+                       `updatetime` datetime DEFAULT NULL,
+                       UNIQUE KEY `rowkey` (`id`),
+                       KEY `status` (`status`,`leasetime`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_bin;
+
+-- restores of archived objects
+
+ALTER TABLE `objects` ADD COLUMN `restore` JSON DEFAULT NULL;
+
+CREATE TABLE IF NOT EXISTS `restore` (
+                       `bucketname` varchar(255) DEFAULT NULL,
+                       `objectname` varchar(255) DEFAULT NULL,
+                       `version` bigint(20) UNSIGNED DEFAULT NULL,
+                       `retries` int(11) DEFAULT 0,
+                       `nexttime` datetime DEFAULT NULL,
+                       `lasterror` varchar(1024) DEFAULT '',
+                       `createtime` datetime DEFAULT NULL,
+                       UNIQUE KEY `rowkey` (`bucketname`,`objectname`,`version`),
+                       KEY `nexttime` (`nexttime`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_bin;
@@ -32,6 +32,13 @@ CREATE TABLE `buckets` (
   `createtime` datetime DEFAULT NULL,
   `usages` bigint(20) DEFAULT NULL,
   `versioning` varchar(255) DEFAULT NULL,
+  `compression` varchar(20) DEFAULT '',
+  `notification` JSON DEFAULT NULL,
+  `logging` JSON DEFAULT NULL,
+  `tagging` JSON DEFAULT NULL,
+  `website` JSON DEFAULT NULL,
+  `replication` JSON DEFAULT NULL,
+  `inventory` JSON DEFAULT NULL,
   PRIMARY KEY (`bucketname`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_bin;
 /*!40101 SET character_set_client = @saved_cs_client */;
@@ -113,6 +120,10 @@ CREATE TABLE `multipartpart` (
   `bucketname` varchar(255) DEFAULT NULL,
   `objectname` varchar(255) DEFAULT NULL,
   `uploadtime` bigint(20) UNSIGNED DEFAULT NULL,
+  `checksum` varchar(255) DEFAULT '',
+  `checksumalgorithm` varchar(20) DEFAULT '',
+  `checksumvalue` varchar(255) DEFAULT '',
+  `compressedsize` bigint(20) DEFAULT 0,
    KEY `rowkey` (`bucketname`,`objectname`,`uploadtime`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_bin;
 /*!40101 SET character_set_client = @saved_cs_client */;
@@ -138,6 +149,10 @@ CREATE TABLE `multiparts` (
   `encryption` blob DEFAULT NULL,
   `attrs` JSON DEFAULT NULL,
   `storageclass` tinyint(1) DEFAULT 0,
+  `checksumalgorithm` varchar(20) DEFAULT '',
+  `checksumtype` varchar(20) DEFAULT '',
+  `compression` varchar(20) DEFAULT '',
+  `tagging` JSON DEFAULT NULL,
   UNIQUE KEY `rowkey` (`bucketname`,`objectname`,`uploadtime`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_bin;
 /*!40101 SET character_set_client = @saved_cs_client */;
@@ -160,6 +175,10 @@ CREATE TABLE `objectpart` (
   `bucketname` varchar(255) DEFAULT NULL,
   `objectname` varchar(255) DEFAULT NULL,
   `version` varchar(255) DEFAULT NULL,
+  `checksum` varchar(255) DEFAULT '',
+  `checksumalgorithm` varchar(20) DEFAULT '',
+  `checksumvalue` varchar(255) DEFAULT '',
+  `compressedsize` bigint(20) DEFAULT 0,
    KEY `rowkey` (`bucketname`,`objectname`,`version`)
 
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_bin;
@@ -193,6 +212,17 @@ CREATE TABLE `objects` (
   `initializationvector` blob DEFAULT NULL,
   `type` tinyint(1) DEFAULT 0,
   `storageclass` tinyint(1) DEFAULT 0,
+  `checksum` varchar(255) DEFAULT '',
+  `checksumalgorithm` varchar(20) DEFAULT '',
+  `checksumvalue` varchar(255) DEFAULT '',
+  `compression` varchar(20) DEFAULT '',
+  `compressedsize` bigint(20) DEFAULT 0,
+  `packed` tinyint(1) DEFAULT 0,
+  `packoffset` bigint(20) DEFAULT 0,
+  `inlinedata` blob DEFAULT NULL,
+  `tagging` JSON DEFAULT NULL,
+  `replicationstatus` varchar(20) DEFAULT '',
+  `restore` JSON DEFAULT NULL,
    UNIQUE KEY `rowkey` (`bucketname`,`name`,`version`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_bin;
 /*!40101 SET character_set_client = @saved_cs_client */;
@@ -241,4 +271,112 @@ DROP TABLE IF EXISTS `lifecycle`;
 CREATE TABLE `lifecycle` (
                        `bucketname` varchar(255) DEFAULT NULL,
                        `status` varchar(255) DEFAULT NULL
-) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_bin;
\ No newline at end of file
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_bin;
+
+DROP TABLE IF EXISTS `writeintent`;
+CREATE TABLE `writeintent` (
+                       `location` varchar(255) DEFAULT NULL,
+                       `pool` varchar(255) DEFAULT NULL,
+                       `objectid` varchar(255) DEFAULT NULL,
+                       `bucketname` varchar(255) DEFAULT NULL,
+                       `objectname` varchar(255) DEFAULT NULL,
+                       `createtime` datetime DEFAULT NULL,
+                       UNIQUE KEY `rowkey` (`location`,`pool`,`objectid`),
+                       KEY `createtime` (`createtime`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_bin;
+
+DROP TABLE IF EXISTS `replication`;
+CREATE TABLE `replication` (
+                       `bucketname` varchar(255) DEFAULT NULL,
+                       `objectname` varchar(255) DEFAULT NULL,
+                       `version` bigint(20) UNSIGNED DEFAULT NULL,
+                       `operation` varchar(20) DEFAULT NULL,
+                       `retries` int(11) DEFAULT 0,
+                       `nexttime` datetime DEFAULT NULL,
+                       `lasterror` varchar(1024) DEFAULT '',
+                       `createtime` datetime DEFAULT NULL,
+                       UNIQUE KEY `rowkey` (`bucketname`,`objectname`,`version`,`operation`),
+                       KEY `nexttime` (`nexttime`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_bin;
+
+DROP TABLE IF EXISTS `restore`;
+CREATE TABLE `restore` (
+                       `bucketname` varchar(255) DEFAULT NULL,
+                       `objectname` varchar(255) DEFAULT NULL,
+                       `version` bigint(20) UNSIGNED DEFAULT NULL,
+                       `retries` int(11) DEFAULT 0,
+                       `nexttime` datetime DEFAULT NULL,
+                       `lasterror` varchar(1024) DEFAULT '',
+                       `createtime` datetime DEFAULT NULL,
+                       UNIQUE KEY `rowkey` (`bucketname`,`objectname`,`version`),
+                       KEY `nexttime` (`nexttime`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_bin;
+
+DROP TABLE IF EXISTS `inventory`;
+CREATE TABLE `inventory` (
+                       `bucketname` varchar(255) DEFAULT NULL,
+                       `configid` varchar(64) DEFAULT NULL,
+                       `reporttime` datetime DEFAULT NULL,
+                       `keymarker` varchar(255) DEFAULT '',
+                       `versionmarker` bigint(20) UNSIGNED DEFAULT 0,
+                       `objects` bigint(20) DEFAULT 0,
+                       `files` JSON DEFAULT NULL,
+                       `done` tinyint(1) DEFAULT 0,
+                       `updatetime` datetime DEFAULT NULL,
+                       UNIQUE KEY `rowkey` (`bucketname`,`configid`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_bin;
+
+DROP TABLE IF EXISTS `batchjob`;
+CREATE TABLE `batchjob` (
+                       `id` varchar(64) DEFAULT NULL,
+                       `status` varchar(20) DEFAULT NULL,
+                       `operation` JSON DEFAULT NULL,
+                       `manifest` JSON DEFAULT NULL,
+                       `report` JSON DEFAULT NULL,
+                       `concurrency` int(11) DEFAULT 0,
+                       `total` bigint(20) DEFAULT 0,
+                       `succeeded` bigint(20) DEFAULT 0,
+                       `failed` bigint(20) DEFAULT 0,
+                       `marker` varchar(1024) DEFAULT '',
+                       `reportfiles` JSON DEFAULT NULL,
+                       `leasetime` datetime DEFAULT NULL,
+                       `lasterror` varchar(1024) DEFAULT '',
+                       `createtime` datetime DEFAULT NULL,
+                       `updatetime` datetime DEFAULT NULL,
+                       UNIQUE KEY `rowkey` (`id`),
+                       KEY `status` (`status`,`leasetime`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_bin;
+
+DROP TABLE IF EXISTS `objectref`;
+CREATE TABLE `objectref` (
+                       `location` varchar(255) DEFAULT NULL,
+                       `pool` varchar(255) DEFAULT NULL,
+                       `objectid` varchar(255) DEFAULT NULL,
+                       `refcount` bigint(20) DEFAULT 0,
+                       UNIQUE KEY `rowkey` (`location`,`pool`,`objectid`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_bin;
+
+DROP TABLE IF EXISTS `container`;
+CREATE TABLE `container` (
+                       `location` varchar(255) DEFAULT NULL,
+                       `pool` varchar(255) DEFAULT NULL,
+                       `objectid` varchar(255) DEFAULT NULL,
+                       `size` bigint(20) DEFAULT 0,
+                       `livesize` bigint(20) DEFAULT 0,
+                       `sealed` tinyint(1) DEFAULT 0,
+                       `createtime` datetime DEFAULT NULL,
+                       UNIQUE KEY `rowkey` (`location`,`pool`,`objectid`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_bin;
+
+DROP TABLE IF EXISTS `packedobject`;
+CREATE TABLE `packedobject` (
+                       `location` varchar(255) DEFAULT NULL,
+                       `pool` varchar(255) DEFAULT NULL,
+                       `objectid` varchar(255) DEFAULT NULL,
+                       `offset` bigint(20) DEFAULT 0,
+                       `size` bigint(20) DEFAULT 0,
+                       `bucketname` varchar(255) DEFAULT NULL,
+                       `objectname` varchar(255) DEFAULT NULL,
+                       `version` bigint(20) UNSIGNED DEFAULT NULL,
+                       UNIQUE KEY `rowkey` (`location`,`pool`,`objectid`,`offset`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COLLATE=utf8_bin;
@@ -14,6 +14,8 @@ import (
 	"github.com/journeymidnight/yig/iam"
 	bus "github.com/journeymidnight/yig/messagebus"
 	_ "github.com/journeymidnight/yig/messagebus/kafka"
+	"github.com/journeymidnight/yig/messagebus/types"
+	_ "github.com/journeymidnight/yig/messagebus/webhook"
 	"github.com/journeymidnight/yig/redis"
 	"github.com/journeymidnight/yig/storage"
 )
@@ -65,13 +67,14 @@ func main() {
 		Logger:  logger,
 		Yig:     yig,
 	}
-	if redis.Pool() != nil && helper.CONFIG.CacheCircuitCheckInterval != 0 {
+	if redis.Initialized() && helper.CONFIG.CacheCircuitCheckInterval != 0 {
 		go yig.PingCache(time.Duration(helper.CONFIG.CacheCircuitCheckInterval) * time.Second)
 	}
 
 	// try to create message bus sender if message bus is enabled.
 	// message bus sender is singleton so create it beforehand.
-	if helper.CONFIG.MsgBus.Enabled {
+	// message bus type could be unset if only webhook is used.
+	if helper.CONFIG.MsgBus.Enabled && helper.CONFIG.MsgBus.Type != types.MSG_BUS_SENDER_UNKNOWN {
 		messageBusSender, err := bus.GetMessageSender()
 		if err != nil {
 			helper.Logger.Printf(2, "failed to create message bus sender, err: %v", err)
@@ -83,6 +86,15 @@ func main() {
 		}
 		helper.Logger.Printf(20, "succeed to create message bus sender.")
 	}
+	// webhook starts delivering messages spooled before
+	if bus.WebhookEnabled() {
+		_, err := bus.GetWebhookSender()
+		if err != nil {
+			helper.Logger.Printf(2, "failed to create webhook sender, err: %v", err)
+			panic("failed to create webhook sender")
+		}
+		helper.Logger.Printf(20, "succeed to create webhook sender.")
+	}
 
 
 	//Read all *.so from plugins directory, and fill the varaible allPlugins
@@ -119,6 +131,17 @@ func main() {
 			stopAdminServer()
 			stopApiServer()
 			yig.Stop()
+			if helper.CONFIG.MsgBus.Enabled && helper.CONFIG.MsgBus.Type != types.MSG_BUS_SENDER_UNKNOWN {
+				if sender, err := bus.GetMessageSender(); err == nil {
+					sender.Close()
+				}
+			}
+			if bus.WebhookEnabled() {
+				// messages not delivered are left in spool
+				if sender, err := bus.GetWebhookSender(); err == nil {
+					sender.Close()
+				}
+			}
 			return
 		}
 	}
@@ -19,34 +19,74 @@ type MessageSender interface {
 	Close()
 }
 
+// implemented by senders checking topics before notifications are configured
+type TopicChecker interface {
+	CheckTopic(topic string) error
+}
+
 var MsgSender MessageSender
 var initialized uint32
 var mu sync.Mutex
 
-// create the singleton MessageSender
+var WebhookSender MessageSender
+var webhookInitialized uint32
+
+// create the singleton MessageSender of `msg_bus_type`, for access logs and
+// notifications to topics other than URLs.
 func GetMessageSender() (MessageSender, error) {
+	if helper.CONFIG.MsgBus.Type == types.MSG_BUS_SENDER_WEBHOOK {
+		return nil, errors.New("webhook should be configured in msg_bus_webhook.")
+	}
+	return getSender(&MsgSender, &initialized, helper.CONFIG.MsgBus)
+}
+
+// create the singleton MessageSender for notifications to topics of URLs
+func GetWebhookSender() (MessageSender, error) {
+	if !WebhookEnabled() {
+		return nil, errors.New("webhook is not configured.")
+	}
+	config := helper.CONFIG.MsgBus
+	config.Type = types.MSG_BUS_SENDER_WEBHOOK
+	config.Server = helper.CONFIG.MsgBus.Webhook
+	return getSender(&WebhookSender, &webhookInitialized, config)
+}
+
+// get the MessageSender of `topic`, webhook for URLs and message bus for others
+func GetTopicSender(topic string) (MessageSender, error) {
+	if types.IsUrlTopic(topic) {
+		return GetWebhookSender()
+	}
+	return GetMessageSender()
+}
+
+func WebhookEnabled() bool {
+	return helper.CONFIG.MsgBus.Enabled && len(helper.CONFIG.MsgBus.Webhook) != 0
+}
+
+func getSender(sender *MessageSender, initialized *uint32, config helper.MsgBusConfig) (MessageSender, error) {
 	var err error
-	if atomic.LoadUint32(&initialized) == 1 {
-		return MsgSender, nil
+	if atomic.LoadUint32(initialized) == 1 {
+		return *sender, nil
 	}
 	mu.Lock()
 	defer mu.Unlock()
-	if initialized == 0 {
-		builder, ok := MsgBuilders[helper.CONFIG.MsgBus.Type]
+	if *initialized == 0 {
+		builder, ok := MsgBuilders[config.Type]
 		if !ok {
 			return nil, errors.New("msg_bus config is invalidate.")
 		}
-		MsgSender, err = builder.Create(helper.CONFIG.MsgBus)
-		if err != nil || nil == MsgSender {
+		*sender, err = builder.Create(config)
+		if err != nil || nil == *sender {
 			return nil, errors.New(fmt.Sprintf("failed to create message_sender with err: %v", err))
 		}
 
-		atomic.StoreUint32(&initialized, 1)
+		atomic.StoreUint32(initialized, 1)
 	}
-	return MsgSender, nil
+	return *sender, nil
 }
 
 // this func is just for testing. Don't use it in other place.
 func ClearInit() {
 	atomic.StoreUint32(&initialized, 0)
+	atomic.StoreUint32(&webhookInitialized, 0)
 }
@@ -3,6 +3,7 @@ package types
 const (
 	MSG_BUS_SENDER_UNKNOWN = iota
 	MSG_BUS_SENDER_KAFKA
+	MSG_BUS_SENDER_WEBHOOK
 )
 
 const (
@@ -16,3 +17,15 @@ const (
 	KAFKA_CFG_AUTO_OFFSET_STORE = "auto_offset_store"
 	KAFKA_CFG_MSG_TOPIC         = "topic"
 )
+
+const (
+	// directory to spool messages not delivered yet, required
+	WEBHOOK_CFG_SPOOL_DIR = "spool_dir"
+	// a message is dropped after failed so many times
+	WEBHOOK_CFG_MAX_ATTEMPTS = "max_attempts"
+	// messages over these limits of a target are dropped when sent
+	WEBHOOK_CFG_MAX_PENDING    = "max_pending"
+	WEBHOOK_CFG_MAX_SPOOL_SIZE = "max_spool_size"
+	// hosts of endpoints allowed, any host of public addresses if unset
+	WEBHOOK_CFG_ALLOWED_HOSTS = "allowed_hosts"
+)
@@ -1,10 +1,14 @@
 package types
 
+import "strings"
+
 type Message struct {
 	Topic   string
 	Key     string
 	ErrChan chan error
 	Value   []byte
+	// sent as HTTP headers by webhook, ignored by other senders
+	Headers map[string]string
 }
 
 func NewMsg(topic, key string, val []byte) *Message {
@@ -16,3 +20,8 @@ func NewMsg(topic, key string, val []byte) *Message {
 	msg.ErrChan = make(chan error)
 	return msg
 }
+
+// Topics of URLs are sent to by webhook, others by the message bus
+func IsUrlTopic(topic string) bool {
+	return strings.HasPrefix(topic, "http://") || strings.HasPrefix(topic, "https://")
+}
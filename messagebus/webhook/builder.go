@@ -0,0 +1,104 @@
+package webhook
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/journeymidnight/yig/helper"
+	bus "github.com/journeymidnight/yig/messagebus"
+	"github.com/journeymidnight/yig/messagebus/types"
+)
+
+const (
+	DEFAULT_MAX_ATTEMPTS   = 20
+	DEFAULT_MAX_PENDING    = 10000
+	DEFAULT_MAX_SPOOL_SIZE = 64 << 20
+)
+
+type WebhookBuilder struct {
+}
+
+func (wb *WebhookBuilder) Create(config helper.MsgBusConfig) (bus.MessageSender, error) {
+	params := config.Server
+	if nil == params || len(params) == 0 {
+		return nil, errors.New("input webhook params is invalid")
+	}
+
+	v, ok := params[types.WEBHOOK_CFG_SPOOL_DIR]
+	if !ok {
+		return nil, errors.New("params doesn't contain validate spool dir")
+	}
+	spoolDir, ok := v.(string)
+	if !ok || spoolDir == "" {
+		return nil, errors.New("params doesn't contain validate spool dir")
+	}
+	err := os.MkdirAll(spoolDir, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	maxAttempts := DEFAULT_MAX_ATTEMPTS
+	if v, ok = params[types.WEBHOOK_CFG_MAX_ATTEMPTS]; ok {
+		// numbers in toml are int64
+		if n, ok := v.(int64); ok && n > 0 {
+			maxAttempts = int(n)
+		}
+	}
+
+	maxPending := DEFAULT_MAX_PENDING
+	if v, ok = params[types.WEBHOOK_CFG_MAX_PENDING]; ok {
+		if n, ok := v.(int64); ok && n > 0 {
+			maxPending = int(n)
+		}
+	}
+
+	maxSpoolSize := int64(DEFAULT_MAX_SPOOL_SIZE)
+	if v, ok = params[types.WEBHOOK_CFG_MAX_SPOOL_SIZE]; ok {
+		if n, ok := v.(int64); ok && n > 0 {
+			maxSpoolSize = n
+		}
+	}
+
+	allowedHosts := make(map[string]bool)
+	if v, ok = params[types.WEBHOOK_CFG_ALLOWED_HOSTS]; ok {
+		hosts, ok := v.([]interface{})
+		if !ok {
+			return nil, errors.New("params doesn't contain validate allowed hosts")
+		}
+		for _, h := range hosts {
+			host, ok := h.(string)
+			if !ok || host == "" {
+				return nil, errors.New("params doesn't contain validate allowed hosts")
+			}
+			allowedHosts[strings.ToLower(host)] = true
+		}
+	}
+
+	webhook := &Webhook{
+		spoolDir:     spoolDir,
+		maxAttempts:  maxAttempts,
+		maxPending:   maxPending,
+		maxSpoolSize: maxSpoolSize,
+		allowedHosts: allowedHosts,
+		targets:      make(map[string]*target),
+		closing:      make(chan struct{}),
+	}
+	// proxies are not used, they would connect to any address
+	webhook.client = &http.Client{
+		Transport: &http.Transport{
+			DialContext:         webhook.dialContext,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+		Timeout: time.Duration(config.RequestTimeoutMs) * time.Millisecond,
+	}
+	err = webhook.Start()
+	if err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
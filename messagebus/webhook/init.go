@@ -0,0 +1,12 @@
+package webhook
+
+import (
+	bus "github.com/journeymidnight/yig/messagebus"
+	"github.com/journeymidnight/yig/messagebus/types"
+)
+
+func init() {
+	webhookBuilder := &WebhookBuilder{}
+
+	bus.AddMsgBuilder(types.MSG_BUS_SENDER_WEBHOOK, webhookBuilder)
+}
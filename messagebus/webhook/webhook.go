@@ -0,0 +1,490 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/journeymidnight/yig/helper"
+	"github.com/journeymidnight/yig/messagebus/types"
+)
+
+// Messages are POSTed to their topics, which are URLs of endpoints called
+// targets. Each message is written into the spool directory of its target
+// before AsyncSend returns, and removed after delivered or dropped, so
+// messages survive restarts and outages of endpoints:
+//
+//   spool_dir/<sha1 of target URL>/<time>-<sequence>.json
+//
+// Every target is delivered to by a goroutine of its own, in the order
+// sent, retrying the oldest message with exponential backoff. A failing
+// endpoint never delays other targets. Spool of a target is limited by
+// `max_pending` messages and `max_spool_size` bytes, messages sent over
+// the limits are dropped, so endpoints down for long never fill the disk.
+//
+// Topics are set by bucket owners, so endpoints must not reach internal
+// services: if `allowed_hosts` is set, only endpoints on these hosts are
+// connected to, otherwise connections to loopback, private and link-local
+// addresses are refused after DNS resolution.
+
+const (
+	MIN_RETRY_INTERVAL = 1 * time.Second
+	MAX_RETRY_INTERVAL = 5 * time.Minute
+)
+
+// Delivery metrics of a target since started
+type TargetStats struct {
+	Url           string
+	Pending       int
+	SpoolSize     int64 // bytes of pending messages
+	Delivered     int64
+	Failed        int64 // failed attempts
+	Dropped       int64 // messages given up after max attempts or over limits
+	LastError     string
+	LastErrorTime time.Time
+}
+
+// Message saved in spool files
+type spooledMessage struct {
+	Url     string
+	Key     string
+	Headers map[string]string
+	Value   []byte
+}
+
+type pendingMessage struct {
+	file    string
+	size    int64
+	errChan chan error // nil for messages spooled before restarts
+}
+
+type target struct {
+	dir     string
+	pending []pendingMessage
+	writing int   // messages being spooled
+	size    int64 // bytes of pending and writing messages
+	running bool
+	stats   TargetStats
+}
+
+type Webhook struct {
+	spoolDir     string
+	client       *http.Client
+	maxAttempts  int
+	maxPending   int
+	maxSpoolSize int64
+	allowedHosts map[string]bool // lower case host names
+
+	mutex    sync.Mutex
+	targets  map[string]*target // URL -> target
+	sequence uint64
+	closing  chan struct{}
+	workers  sync.WaitGroup
+}
+
+// Load messages spooled before and start delivering them
+func (wh *Webhook) Start() error {
+	dirs, err := ioutil.ReadDir(wh.spoolDir)
+	if err != nil {
+		return err
+	}
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		err = wh.loadSpool(filepath.Join(wh.spoolDir, dir.Name()))
+		if err != nil {
+			return err
+		}
+	}
+	wh.mutex.Lock()
+	defer wh.mutex.Unlock()
+	for _, t := range wh.targets {
+		wh.startDelivery(t)
+	}
+	return nil
+}
+
+func (wh *Webhook) loadSpool(dir string) error {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var names []string
+	sizes := make(map[string]int64)
+	for _, file := range files {
+		if strings.HasSuffix(file.Name(), ".json") {
+			names = append(names, file.Name())
+			sizes[file.Name()] = file.Size()
+		} else {
+			// partially written
+			os.Remove(filepath.Join(dir, file.Name()))
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		message, err := readSpool(path)
+		if err != nil {
+			helper.Logger.Printf(2, "drop unreadable webhook spool file %s, err: %v", path, err)
+			os.Remove(path)
+			continue
+		}
+		// kept even if over limits, which might be lowered since spooled
+		t := wh.getTarget(message.Url)
+		t.pending = append(t.pending, pendingMessage{file: path, size: sizes[name]})
+		t.size += sizes[name]
+	}
+	return nil
+}
+
+// Must be called with mutex held
+func (wh *Webhook) getTarget(url string) *target {
+	t, ok := wh.targets[url]
+	if !ok {
+		hash := sha1.Sum([]byte(url))
+		t = &target{
+			dir:   filepath.Join(wh.spoolDir, hex.EncodeToString(hash[:])),
+			stats: TargetStats{Url: url},
+		}
+		wh.targets[url] = t
+	}
+	return t
+}
+
+// Must be called with mutex held
+func (wh *Webhook) startDelivery(t *target) {
+	if t.running || len(t.pending) == 0 {
+		return
+	}
+	t.running = true
+	wh.workers.Add(1)
+	go wh.deliver(t)
+}
+
+func (wh *Webhook) AsyncSend(msg *types.Message) error {
+	if nil == msg.Value || !types.IsUrlTopic(msg.Topic) {
+		return errors.New(fmt.Sprintf("input message[%v] is invalid.", msg))
+	}
+	select {
+	case <-wh.closing:
+		return errors.New("webhook is closed")
+	default:
+	}
+	data, err := json.Marshal(spooledMessage{
+		Url:     msg.Topic,
+		Key:     msg.Key,
+		Headers: msg.Headers,
+		Value:   msg.Value,
+	})
+	if err != nil {
+		return err
+	}
+	size := int64(len(data))
+	wh.mutex.Lock()
+	t := wh.getTarget(msg.Topic)
+	if len(t.pending)+t.writing >= wh.maxPending || t.size+size > wh.maxSpoolSize {
+		t.stats.Dropped++
+		wh.mutex.Unlock()
+		return errors.New("webhook spool of " + msg.Topic + " is full")
+	}
+	t.writing++
+	t.size += size
+	wh.sequence++
+	path := filepath.Join(t.dir, fmt.Sprintf("%019d-%010d.json", time.Now().UnixNano(), wh.sequence))
+	wh.mutex.Unlock()
+	// not holding the lock, since it syncs to disk
+	err = writeSpool(path, data)
+	wh.mutex.Lock()
+	defer wh.mutex.Unlock()
+	t.writing--
+	if err != nil {
+		t.size -= size
+		return err
+	}
+	t.pending = append(t.pending, pendingMessage{file: path, size: size, errChan: msg.ErrChan})
+	wh.startDelivery(t)
+	return nil
+}
+
+// Deliver messages of `t` one by one until none is left
+func (wh *Webhook) deliver(t *target) {
+	defer wh.workers.Done()
+	attempts := 0
+	for {
+		select {
+		case <-wh.closing:
+			return
+		default:
+		}
+		wh.mutex.Lock()
+		if len(t.pending) == 0 {
+			t.running = false
+			wh.mutex.Unlock()
+			return
+		}
+		message := t.pending[0]
+		wh.mutex.Unlock()
+
+		err := wh.post(message.file)
+		attempts++
+
+		wh.mutex.Lock()
+		if err == nil {
+			t.stats.Delivered++
+		} else {
+			t.stats.Failed++
+			t.stats.LastError = err.Error()
+			t.stats.LastErrorTime = time.Now()
+		}
+		done := err == nil || attempts >= wh.maxAttempts
+		if done {
+			if err != nil {
+				t.stats.Dropped++
+				helper.Logger.Printf(2, "drop webhook message %s after %d attempts, err: %v",
+					message.file, attempts, err)
+			}
+			t.pending = t.pending[1:]
+			t.size -= message.size
+			os.Remove(message.file)
+			attempts = 0
+		}
+		wh.mutex.Unlock()
+
+		if done {
+			if message.errChan != nil {
+				go func(c chan error, err error) {
+					c <- err
+				}(message.errChan, err)
+			}
+			continue
+		}
+		helper.Logger.Printf(5, "failed to deliver webhook message %s, attempt %d, err: %v",
+			message.file, attempts, err)
+		select {
+		case <-wh.closing:
+			return
+		case <-time.After(retryInterval(attempts)):
+		}
+	}
+}
+
+func (wh *Webhook) post(path string) error {
+	message, err := readSpool(path)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("POST", message.Url, bytes.NewReader(message.Value))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if message.Key != "" {
+		request.Header.Set("X-Yig-Message-Key", message.Key)
+	}
+	for k, v := range message.Headers {
+		request.Header.Set(k, v)
+	}
+	response, err := wh.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	io.Copy(ioutil.Discard, response.Body)
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return errors.New("endpoint responded " + response.Status)
+	}
+	return nil
+}
+
+// Returns an error if `topic` is not an endpoint allowed
+func (wh *Webhook) CheckTopic(topic string) error {
+	u, err := url.Parse(topic)
+	if err != nil {
+		return err
+	}
+	if !types.IsUrlTopic(topic) || u.Hostname() == "" {
+		return errors.New("invalid webhook endpoint " + topic)
+	}
+	if len(wh.allowedHosts) != 0 {
+		if !wh.allowedHosts[strings.ToLower(u.Hostname())] {
+			return errors.New("host of webhook endpoint is not allowed: " + u.Hostname())
+		}
+		return nil
+	}
+	// checked again when connecting, as DNS records could change
+	ips, err := net.LookupIP(u.Hostname())
+	if err != nil {
+		return err
+	}
+	for _, ip := range ips {
+		if err = checkAddress(ip); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (wh *Webhook) dialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	if len(wh.allowedHosts) != 0 {
+		if !wh.allowedHosts[strings.ToLower(host)] {
+			return nil, errors.New("host of webhook endpoint is not allowed: " + host)
+		}
+	} else {
+		// called with the resolved address before connecting
+		dialer.Control = func(network, address string, c syscall.RawConn) error {
+			ip, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			return checkAddress(net.ParseIP(ip))
+		}
+	}
+	return dialer.DialContext(ctx, network, address)
+}
+
+// Wait until all messages are delivered or dropped, timeout is in ms.
+func (wh *Webhook) Flush(timeout int) error {
+	deadline := time.Now().Add(time.Duration(timeout) * time.Millisecond)
+	for {
+		wh.mutex.Lock()
+		pending := 0
+		for _, t := range wh.targets {
+			pending += len(t.pending)
+		}
+		wh.mutex.Unlock()
+		if pending == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return errors.New(fmt.Sprintf("%d webhook messages are not delivered yet", pending))
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// Stop delivering, messages not delivered are left in spool for next start
+func (wh *Webhook) Close() {
+	select {
+	case <-wh.closing:
+		return
+	default:
+	}
+	close(wh.closing)
+	wh.workers.Wait()
+}
+
+func (wh *Webhook) Stats() []TargetStats {
+	wh.mutex.Lock()
+	defer wh.mutex.Unlock()
+	stats := make([]TargetStats, 0, len(wh.targets))
+	for _, t := range wh.targets {
+		s := t.stats
+		s.Pending = len(t.pending)
+		s.SpoolSize = t.size
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Url < stats[j].Url
+	})
+	return stats
+}
+
+// Interval before attempt `attempts` + 1
+func retryInterval(attempts int) time.Duration {
+	interval := MIN_RETRY_INTERVAL
+	for i := 1; i < attempts && interval < MAX_RETRY_INTERVAL; i++ {
+		interval *= 2
+	}
+	if interval > MAX_RETRY_INTERVAL {
+		interval = MAX_RETRY_INTERVAL
+	}
+	return interval
+}
+
+var internalNetworks = parseCIDRs(
+	"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", // private
+	"100.64.0.0/10", // carrier-grade NAT
+	"fc00::/7",      // unique local
+)
+
+func parseCIDRs(cidrs ...string) (networks []*net.IPNet) {
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// Returns an error if `ip` is not a public unicast address
+func checkAddress(ip net.IP) error {
+	if ip == nil || ip.IsLoopback() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
+		return fmt.Errorf("webhook endpoint address %v is not allowed", ip)
+	}
+	for _, network := range internalNetworks {
+		if network.Contains(ip) {
+			return fmt.Errorf("webhook endpoint address %v is not allowed", ip)
+		}
+	}
+	return nil
+}
+
+// Write to a temporary file and rename it, so spool files are complete
+func writeSpool(path string, data []byte) error {
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	f.Close()
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func readSpool(path string) (message spooledMessage, err error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(data, &message)
+	return
+}
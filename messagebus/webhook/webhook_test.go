@@ -0,0 +1,195 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/journeymidnight/yig/helper"
+	"github.com/journeymidnight/yig/log"
+	"github.com/journeymidnight/yig/messagebus/types"
+)
+
+func newTestWebhook(t *testing.T, spoolDir string, allowedHosts ...interface{}) *Webhook {
+	params := map[string]interface{}{
+		types.WEBHOOK_CFG_SPOOL_DIR:    spoolDir,
+		types.WEBHOOK_CFG_MAX_ATTEMPTS: int64(3),
+	}
+	if len(allowedHosts) != 0 {
+		params[types.WEBHOOK_CFG_ALLOWED_HOSTS] = allowedHosts
+	}
+	sender, err := (&WebhookBuilder{}).Create(helper.MsgBusConfig{
+		RequestTimeoutMs: 3000,
+		Server:           params,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sender.(*Webhook)
+}
+
+func TestWebhookSpool(t *testing.T) {
+	helper.Logger = log.New(ioutil.Discard, "[yig]", log.LstdFlags, 0)
+	spoolDir, err := ioutil.TempDir("", "webhook")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(spoolDir)
+
+	var mutex sync.Mutex
+	available := false
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if !available {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		received = append(received, string(body)+r.Header.Get("X-Yig-Signature"))
+	}))
+	defer server.Close()
+
+	// endpoint is down, messages are left in spool after close
+	// test servers listen on loopback addresses
+	webhook := newTestWebhook(t, spoolDir, "127.0.0.1")
+	for _, value := range []string{"first", "second"} {
+		msg := &types.Message{
+			Topic:   server.URL,
+			Value:   []byte(value),
+			Headers: map[string]string{"X-Yig-Signature": "-signed"},
+		}
+		err = webhook.AsyncSend(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = webhook.Flush(100); err == nil {
+		t.Fatal("messages should not be delivered")
+	}
+	webhook.Close()
+	files, _ := filepath.Glob(filepath.Join(spoolDir, "*", "*.json"))
+	if len(files) != 2 {
+		t.Fatal("messages should be spooled, got", files)
+	}
+
+	// delivered in order after restart
+	mutex.Lock()
+	available = true
+	mutex.Unlock()
+	webhook = newTestWebhook(t, spoolDir, "127.0.0.1")
+	defer webhook.Close()
+	err = webhook.Flush(10000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(received) != 2 || received[0] != "first-signed" || received[1] != "second-signed" {
+		t.Fatal("unexpected messages received:", received)
+	}
+	stats := webhook.Stats()
+	if len(stats) != 1 || stats[0].Delivered != 2 || stats[0].Pending != 0 {
+		t.Fatal("unexpected stats:", stats)
+	}
+	files, _ = filepath.Glob(filepath.Join(spoolDir, "*", "*.json"))
+	if len(files) != 0 {
+		t.Fatal("spool files should be removed, got", files)
+	}
+}
+
+func TestWebhookEndpoints(t *testing.T) {
+	helper.Logger = log.New(ioutil.Discard, "[yig]", log.LstdFlags, 0)
+	spoolDir, err := ioutil.TempDir("", "webhook")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(spoolDir)
+
+	for _, address := range []string{"127.0.0.1", "::1", "0.0.0.0", "10.1.2.3", "172.16.0.1",
+		"192.168.1.1", "169.254.169.254", "fe80::1", "fd00::1", "::ffff:127.0.0.1"} {
+		if checkAddress(net.ParseIP(address)) == nil {
+			t.Fatal("address should not be allowed:", address)
+		}
+	}
+	for _, address := range []string{"8.8.8.8", "2001:4860:4860::8888"} {
+		if err = checkAddress(net.ParseIP(address)); err != nil {
+			t.Fatal("address should be allowed:", address, err)
+		}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	webhook := newTestWebhook(t, spoolDir)
+	defer webhook.Close()
+	if webhook.CheckTopic(server.URL) == nil {
+		t.Fatal("loopback endpoint should not be allowed")
+	}
+	// refused when connecting as well
+	data, _ := json.Marshal(spooledMessage{Url: server.URL, Value: []byte("test")})
+	err = writeSpool(filepath.Join(spoolDir, "test.json"), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if webhook.post(filepath.Join(spoolDir, "test.json")) == nil {
+		t.Fatal("loopback endpoint should not be connected to")
+	}
+
+	allowed := newTestWebhook(t, spoolDir, "127.0.0.1")
+	defer allowed.Close()
+	if err = allowed.CheckTopic(server.URL); err != nil {
+		t.Fatal("allowed host should be allowed:", err)
+	}
+	if allowed.CheckTopic("http://localhost/") == nil {
+		t.Fatal("hosts not in allowed_hosts should not be allowed")
+	}
+}
+
+func TestWebhookLimits(t *testing.T) {
+	helper.Logger = log.New(ioutil.Discard, "[yig]", log.LstdFlags, 0)
+	spoolDir, err := ioutil.TempDir("", "webhook")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(spoolDir)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	sender, err := (&WebhookBuilder{}).Create(helper.MsgBusConfig{
+		RequestTimeoutMs: 3000,
+		Server: map[string]interface{}{
+			types.WEBHOOK_CFG_SPOOL_DIR:     spoolDir,
+			types.WEBHOOK_CFG_MAX_PENDING:   int64(2),
+			types.WEBHOOK_CFG_ALLOWED_HOSTS: []interface{}{"127.0.0.1"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	webhook := sender.(*Webhook)
+	defer webhook.Close()
+
+	for i, value := range []string{"first", "second", "third"} {
+		err = webhook.AsyncSend(&types.Message{Topic: server.URL, Value: []byte(value)})
+		if (err != nil) != (i == 2) {
+			t.Fatal("unexpected result of sending", value, err)
+		}
+	}
+	stats := webhook.Stats()
+	if len(stats) != 1 || stats[0].Pending != 2 || stats[0].Dropped != 1 || stats[0].SpoolSize == 0 {
+		t.Fatal("unexpected stats:", stats)
+	}
+	files, _ := filepath.Glob(filepath.Join(spoolDir, "*", "*.json"))
+	if len(files) != 2 {
+		t.Fatal("messages over limits should not be spooled, got", files)
+	}
+}
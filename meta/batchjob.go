@@ -0,0 +1,42 @@
+package meta
+
+import (
+	"time"
+
+	. "github.com/journeymidnight/yig/meta/types"
+)
+
+func (m *Meta) PutBatchJob(job *BatchJob) error {
+	job.CreateTime = time.Now().UTC()
+	job.UpdateTime = job.CreateTime
+	job.LeaseTime = job.CreateTime
+	return m.Client.PutBatchJob(job)
+}
+
+// Returns nil if the job does not exist
+func (m *Meta) GetBatchJob(id string) (*BatchJob, error) {
+	return m.Client.GetBatchJob(id)
+}
+
+func (m *Meta) ListBatchJobs(limit int) ([]BatchJob, error) {
+	return m.Client.ListBatchJobs(limit)
+}
+
+// Active jobs not claimed by any instance
+func (m *Meta) ScanRunnableBatchJobs(limit int) ([]BatchJob, error) {
+	return m.Client.ScanBatchJobs(BatchJobStatusActive, time.Now(), limit)
+}
+
+// Claim `job` for `lease`, in which other instances won't process it
+func (m *Meta) ClaimBatchJob(job *BatchJob, lease time.Duration) (bool, error) {
+	return m.Client.ClaimBatchJob(job, time.Now().Add(lease))
+}
+
+func (m *Meta) UpdateBatchJobProgress(job *BatchJob) error {
+	job.UpdateTime = time.Now().UTC()
+	return m.Client.UpdateBatchJobProgress(job)
+}
+
+func (m *Meta) SetBatchJobStatus(id string, from []string, to string) (bool, error) {
+	return m.Client.SetBatchJobStatus(id, from, to)
+}
@@ -1,9 +1,14 @@
 package meta
 
 import (
+	"bytes"
+	"database/sql"
+	"sync/atomic"
+
+	. "github.com/journeymidnight/yig/error"
 	"github.com/journeymidnight/yig/helper"
 	"github.com/journeymidnight/yig/redis"
-	"database/sql"
+	"golang.org/x/sync/singleflight"
 )
 
 type CacheType int
@@ -22,6 +27,14 @@ type MetaCache interface {
 		unmarshaller func([]byte) (interface{}, error), willNeed bool) (value interface{}, err error)
 	Remove(table redis.RedisDatabase, key string)
 	GetCacheHitRatio() float64
+	GetCacheStats() CacheStats
+}
+
+type CacheStats struct {
+	Hit         int64
+	Miss        int64
+	NegativeHit int64 // lookups answered by a cached "not found"
+	Coalesced   int64 // lookups which waited for another request's `onCacheMiss`
 }
 
 type disabledMetaCache struct{}
@@ -59,9 +72,66 @@ func (m *disabledMetaCache) GetCacheHitRatio() float64 {
 	return -1
 }
 
+func (m *disabledMetaCache) GetCacheStats() CacheStats {
+	return CacheStats{}
+}
+
+// Negative entries and tombstones are stored in the same redis key as the
+// cached value, so removing the key invalidates all of them. Both start with
+// 0xc1, which is never used by msgpack and so can't collide with a value.
+var (
+	negativeEntryPrefix = []byte{0xc1, 'n'}
+	tombstoneEntry      = []byte{0xc1, 't'}
+)
+
+// Errors meaning "the entry does not exist" which are worth caching
+var notFoundErrors = map[string]error{
+	"NoRows":       sql.ErrNoRows,
+	"NoSuchKey":    ErrNoSuchKey,
+	"NoSuchBucket": ErrNoSuchBucket,
+}
+
+type negativeEntry struct {
+	err error
+}
+
+func notFoundReason(err error) (reason string, ok bool) {
+	for reason, e := range notFoundErrors {
+		if err == e {
+			return reason, true
+		}
+	}
+	return "", false
+}
+
 type enabledSimpleMetaCache struct {
-	Hit  int64
-	Miss int64
+	Hit         int64
+	Miss        int64
+	NegativeHit int64
+	Coalesced   int64
+	// coalesces concurrent `onCacheMiss` calls for the same table and key
+	group singleflight.Group
+}
+
+func flightKey(table redis.RedisDatabase, key string) string {
+	return table.String() + ":" + key
+}
+
+// Wraps `unmarshaller` to recognize negative entries and tombstones
+func cachedEntryUnmarshaller(unmarshaller func([]byte) (interface{}, error)) func([]byte) (interface{}, error) {
+	return func(in []byte) (interface{}, error) {
+		if bytes.Equal(in, tombstoneEntry) {
+			return nil, nil
+		}
+		if bytes.HasPrefix(in, negativeEntryPrefix) {
+			err, ok := notFoundErrors[string(in[len(negativeEntryPrefix):])]
+			if !ok {
+				return nil, nil
+			}
+			return negativeEntry{err: err}, nil
+		}
+		return unmarshaller(in)
+	}
 }
 
 func (m *enabledSimpleMetaCache) Get(table redis.RedisDatabase, key string,
@@ -70,42 +140,102 @@ func (m *enabledSimpleMetaCache) Get(table redis.RedisDatabase, key string,
 
 	helper.Logger.Println(10, "enabledSimpleMetaCache Get. table:", table, "key:", key)
 
-	value, err = redis.Get(table, key, unmarshaller)
+	value, err = redis.Get(table, key, cachedEntryUnmarshaller(unmarshaller))
 	if err != nil {
 		helper.Logger.Println(5, "enabledSimpleMetaCache Get err:", err, "table:", table, "key:", key)
 	}
 	if err == nil && value != nil {
-		m.Hit = m.Hit + 1
+		if negative, ok := value.(negativeEntry); ok {
+			atomic.AddInt64(&m.NegativeHit, 1)
+			return nil, negative.err
+		}
+		atomic.AddInt64(&m.Hit, 1)
 		return value, nil
 	}
 
 	//if redis doesn't have the entry
 	if onCacheMiss != nil {
-		value, err = onCacheMiss()
-		if err != nil{
-			if  err != sql.ErrNoRows {
-				helper.ErrorIf(err, "exec onCacheMiss() err.")
-			}
-			return
+		var executed bool
+		v, err, shared := m.group.Do(flightKey(table, key), func() (interface{}, error) {
+			executed = true
+			return m.onCacheMiss(table, key, onCacheMiss, willNeed)
+		})
+		if executed {
+			atomic.AddInt64(&m.Miss, 1)
+		} else {
+			atomic.AddInt64(&m.Coalesced, 1)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !shared {
+			return v, nil
 		}
+		// Callers may modify the value they get, so each of them needs its own copy
+		encoded, err := helper.MsgPackMarshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return unmarshaller(encoded)
+	}
+	return nil, nil
+}
+
+func (m *enabledSimpleMetaCache) onCacheMiss(table redis.RedisDatabase, key string,
+	onCacheMiss func() (interface{}, error), willNeed bool) (value interface{}, err error) {
 
-		if willNeed == true {
-			err = redis.Set(table, key, value)
-			if err != nil {
+	value, err = onCacheMiss()
+	if err != nil {
+		reason, notFound := notFoundReason(err)
+		if !notFound {
+			helper.ErrorIf(err, "exec onCacheMiss() err.")
+			return
+		}
+		if willNeed && helper.CONFIG.MetaCacheNegativeTTL > 0 {
+			// Don't overwrite a tombstone, the entry was just changed and
+			// this lookup might have raced with the change
+			e := redis.SetRaw(table, key, append(negativeEntryPrefix, reason...),
+				helper.CONFIG.MetaCacheNegativeTTL, true)
+			if e != nil {
 				helper.Logger.Println(5, "WARNING: redis is down!")
-				//do nothing, even if redis is down.
 			}
 		}
-		m.Miss = m.Miss + 1
-		return value, nil
+		return
 	}
-	return nil, nil
+
+	if willNeed == true {
+		err = redis.Set(table, key, value)
+		if err != nil {
+			helper.Logger.Println(5, "WARNING: redis is down!")
+			//do nothing, even if redis is down.
+		}
+	}
+	return value, nil
 }
 
 func (m *enabledSimpleMetaCache) Remove(table redis.RedisDatabase, key string) {
+	// Later lookups should not join a query which might have started before the change
+	m.group.Forget(flightKey(table, key))
+	if helper.CONFIG.MetaCacheNegativeTTL > 0 {
+		// Leave a tombstone instead of deleting, so a lookup racing with
+		// this change, on any instance, can't cache a stale "not found"
+		redis.SetRaw(table, key, tombstoneEntry, helper.CONFIG.MetaCacheNegativeTTL, false)
+		return
+	}
 	redis.Remove(table, key)
 }
 
 func (m *enabledSimpleMetaCache) GetCacheHitRatio() float64 {
-	return float64(m.Hit) / float64(m.Hit+m.Miss)
+	hit := atomic.LoadInt64(&m.Hit) + atomic.LoadInt64(&m.NegativeHit)
+	miss := atomic.LoadInt64(&m.Miss) + atomic.LoadInt64(&m.Coalesced)
+	return float64(hit) / float64(hit+miss)
+}
+
+func (m *enabledSimpleMetaCache) GetCacheStats() CacheStats {
+	return CacheStats{
+		Hit:         atomic.LoadInt64(&m.Hit),
+		Miss:        atomic.LoadInt64(&m.Miss),
+		NegativeHit: atomic.LoadInt64(&m.NegativeHit),
+		Coalesced:   atomic.LoadInt64(&m.Coalesced),
+	}
 }
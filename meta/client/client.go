@@ -1,6 +1,8 @@
 package client
 
 import (
+	"time"
+
 	"github.com/journeymidnight/yig/api/datatype"
 	. "github.com/journeymidnight/yig/meta/types"
 )
@@ -19,6 +21,10 @@ type Client interface {
 	UpdateObjectAttrs(object *Object) error
 	DeleteObject(object *Object, tx interface{}) error
 	UpdateObjectAcl(object *Object) error
+	UpdateObjectTagging(object *Object) error
+	UpdateObjectReplicationStatus(object *Object, tx interface{}) error
+	ScanObjectsByLocation(location string, limit int, marker string) (objects []*Object, nextMarker string, err error)
+	SwitchObjectLocation(object *Object, target *Object, tx interface{}) (switched bool, err error)
 	//bucket
 	GetBucket(bucketName string) (bucket *Bucket, err error)
 	GetBuckets() (buckets []Bucket, err error)
@@ -33,6 +39,7 @@ type Client interface {
 	CreateMultipart(multipart Multipart) (err error)
 	PutObjectPart(multipart *Multipart, part *Part, tx interface{}) (err error)
 	DeleteMultipart(multipart *Multipart, tx interface{}) (err error)
+	CountMultipartsByLocation(location string) (count int64, err error)
 	ListMultipartUploads(bucketName, keyMarker, uploadIdMarker, prefix, delimiter, encodingType string, maxUploads int) (uploads []datatype.Upload, prefixs []string, isTruncated bool, nextKeyMarker, nextUploadIdMarker string, err error)
 	//objmap
 	GetObjectMap(bucketName, objectName string) (objMap *ObjMap, err error)
@@ -52,4 +59,51 @@ type Client interface {
 	PutObjectToGarbageCollection(object *Object, tx interface{}) error
 	ScanGarbageCollection(limit int, startRowKey string) ([]GarbageCollection, error)
 	RemoveGarbageCollection(garbage GarbageCollection) error
+	//write intent
+	PutWriteIntent(intent *WriteIntent) error
+	RemoveWriteIntent(intent *WriteIntent, tx interface{}) (removed bool, err error)
+	ScanWriteIntents(createdBefore time.Time, limit int) ([]WriteIntent, error)
+	//rename
+	ListObjectNames(bucketName, prefix string, limit int) (names []string, err error)
+	RenameObject(bucketName, sourceName, targetName string, tx interface{}) (objects []*Object, err error)
+	//reference
+	AddObjectReference(source *Object, tx interface{}) error
+	ReleaseObjectReference(object *Object, tx interface{}) (referenced bool, err error)
+	//container
+	PutContainer(container *Container) error
+	SealContainer(container *Container) error
+	AddPackedObject(object *Object, tx interface{}) error
+	RemovePackedObject(object *Object, tx interface{}) error
+	ScanContainers(liveRatio float64, abandonedBefore time.Time, marker Container, limit int) ([]Container, error)
+	GetPackedObjects(container *Container) ([]*Object, error)
+	RemoveContainer(container *Container, tx interface{}) (removed bool, err error)
+	//replication
+	PutReplicationTask(task *ReplicationTask, tx interface{}) error
+	ScanReplicationTasks(dueBefore time.Time, failedOnly bool, limit int) ([]ReplicationTask, error)
+	ClaimReplicationTask(task *ReplicationTask, until time.Time) (claimed bool, err error)
+	UpdateReplicationTask(task *ReplicationTask) error
+	RemoveReplicationTask(task *ReplicationTask) error
+	GetReplicationBacklog() (backlog ReplicationBacklog, err error)
+	//inventory
+	ScanObjectVersions(bucketName, prefix, keyMarker string, versionMarker uint64, limit int) ([]*Object, error)
+	GetInventoryCheckpoint(bucketName, configId string) (*InventoryCheckpoint, error)
+	PutInventoryCheckpoint(checkpoint *InventoryCheckpoint) error
+	//restore
+	PutRestoreTask(task *RestoreTask, tx interface{}) error
+	ScanRestoreTasks(dueBefore time.Time, limit int) ([]RestoreTask, error)
+	ClaimRestoreTask(task *RestoreTask, until time.Time) (claimed bool, err error)
+	UpdateRestoreTask(task *RestoreTask) error
+	RemoveRestoreTask(task *RestoreTask) error
+	LockObjectRestore(object *Object, tx interface{}) (*ObjectRestore, error)
+	UpdateObjectRestore(object *Object, tx interface{}) error
+	//batch job
+	PutBatchJob(job *BatchJob) error
+	GetBatchJob(id string) (*BatchJob, error)
+	ListBatchJobs(limit int) ([]BatchJob, error)
+	ScanBatchJobs(status string, leaseBefore time.Time, limit int) ([]BatchJob, error)
+	ClaimBatchJob(job *BatchJob, until time.Time) (claimed bool, err error)
+	UpdateBatchJobProgress(job *BatchJob) error
+	SetBatchJobStatus(id string, from []string, to string) (updated bool, err error)
+	//scrub
+	ScanReferencedObjectIds(walk func(objectId string) error) error
 }
@@ -0,0 +1,172 @@
+package tidbclient
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+
+	. "github.com/journeymidnight/yig/meta/types"
+)
+
+const batchJobColumns = "id,status,operation,manifest,report,concurrency,total,succeeded,failed,marker," +
+	"reportfiles,leasetime,lasterror,createtime,updatetime"
+
+func scanBatchJob(row rowScanner) (*BatchJob, error) {
+	job := new(BatchJob)
+	var operation, manifest, report, reportFiles sql.NullString
+	var leaseTime, createTime, updateTime string
+	err := row.Scan(
+		&job.Id,
+		&job.Status,
+		&operation,
+		&manifest,
+		&report,
+		&job.Concurrency,
+		&job.Total,
+		&job.Succeeded,
+		&job.Failed,
+		&job.Marker,
+		&reportFiles,
+		&leaseTime,
+		&job.LastError,
+		&createTime,
+		&updateTime,
+	)
+	if err != nil {
+		return nil, err
+	}
+	for _, field := range []struct {
+		column sql.NullString
+		value  interface{}
+	}{
+		{operation, &job.Operation},
+		{manifest, &job.Manifest},
+		{report, &job.Report},
+		{reportFiles, &job.ReportFiles},
+	} {
+		if !field.column.Valid {
+			continue
+		}
+		err = json.Unmarshal([]byte(field.column.String), field.value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	job.LeaseTime, err = time.Parse(TIME_LAYOUT_TIDB, leaseTime)
+	if err != nil {
+		return nil, err
+	}
+	job.CreateTime, err = time.Parse(TIME_LAYOUT_TIDB, createTime)
+	if err != nil {
+		return nil, err
+	}
+	job.UpdateTime, err = time.Parse(TIME_LAYOUT_TIDB, updateTime)
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+func (t *TidbClient) PutBatchJob(job *BatchJob) error {
+	operation, _ := json.Marshal(job.Operation)
+	manifest, _ := json.Marshal(job.Manifest)
+	report, _ := json.Marshal(job.Report)
+	reportFiles, _ := json.Marshal(job.ReportFiles)
+	sqltext := "insert into batchjob(" + batchJobColumns + ") values(?,?,?,?,?,?,?,?,?,?,?,?,?,?,?);"
+	_, err := t.Client.Exec(sqltext, job.Id, job.Status, operation, manifest, report, job.Concurrency,
+		job.Total, job.Succeeded, job.Failed, job.Marker, reportFiles,
+		job.LeaseTime.UTC().Format(TIME_LAYOUT_TIDB), job.LastError,
+		job.CreateTime.UTC().Format(TIME_LAYOUT_TIDB), job.UpdateTime.UTC().Format(TIME_LAYOUT_TIDB))
+	return err
+}
+
+// Returns nil if the job does not exist
+func (t *TidbClient) GetBatchJob(id string) (*BatchJob, error) {
+	sqltext := "select " + batchJobColumns + " from batchjob where id=?;"
+	job, err := scanBatchJob(t.Client.QueryRow(sqltext, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return job, err
+}
+
+func (t *TidbClient) queryBatchJobs(sqltext string, args ...interface{}) (jobs []BatchJob, err error) {
+	rows, err := t.Client.Query(sqltext, args...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var job *BatchJob
+		job, err = scanBatchJob(rows)
+		if err != nil {
+			return
+		}
+		jobs = append(jobs, *job)
+	}
+	err = rows.Err()
+	return
+}
+
+// Latest jobs first
+func (t *TidbClient) ListBatchJobs(limit int) ([]BatchJob, error) {
+	sqltext := "select " + batchJobColumns + " from batchjob order by createtime desc limit ?;"
+	return t.queryBatchJobs(sqltext, limit)
+}
+
+// Jobs of `status` whose leases end before `leaseBefore`, oldest first
+func (t *TidbClient) ScanBatchJobs(status string, leaseBefore time.Time, limit int) ([]BatchJob, error) {
+	sqltext := "select " + batchJobColumns + " from batchjob where status=? and leasetime<? " +
+		"order by createtime limit ?;"
+	return t.queryBatchJobs(sqltext, status, leaseBefore.UTC().Format(TIME_LAYOUT_TIDB), limit)
+}
+
+// Push LeaseTime of the job to `until` if it's not changed since scanned,
+// so only one instance could process it. Returns false if claimed by others.
+func (t *TidbClient) ClaimBatchJob(job *BatchJob, until time.Time) (claimed bool, err error) {
+	sqltext := "update batchjob set leasetime=? where id=? and status=? and leasetime=?;"
+	result, err := t.Client.Exec(sqltext, until.UTC().Format(TIME_LAYOUT_TIDB), job.Id, job.Status,
+		job.LeaseTime.UTC().Format(TIME_LAYOUT_TIDB))
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected > 0 {
+		job.LeaseTime = until
+	}
+	return affected > 0, nil
+}
+
+// Status of the job is left unchanged, see SetBatchJobStatus
+func (t *TidbClient) UpdateBatchJobProgress(job *BatchJob) error {
+	reportFiles, _ := json.Marshal(job.ReportFiles)
+	sqltext := "update batchjob set total=?,succeeded=?,failed=?,marker=?,reportfiles=?,leasetime=?," +
+		"lasterror=?,updatetime=? where id=?;"
+	_, err := t.Client.Exec(sqltext, job.Total, job.Succeeded, job.Failed, job.Marker, reportFiles,
+		job.LeaseTime.UTC().Format(TIME_LAYOUT_TIDB), job.LastError,
+		job.UpdateTime.UTC().Format(TIME_LAYOUT_TIDB), job.Id)
+	return err
+}
+
+// Change status of the job to `to` if it's one of `from`. Returns false if not.
+func (t *TidbClient) SetBatchJobStatus(id string, from []string, to string) (updated bool, err error) {
+	args := []interface{}{to, time.Now().UTC().Format(TIME_LAYOUT_TIDB), id}
+	for _, status := range from {
+		args = append(args, status)
+	}
+	sqltext := "update batchjob set status=?,updatetime=? where id=? and status in (?" +
+		strings.Repeat(",?", len(from)-1) + ");"
+	result, err := t.Client.Exec(sqltext, args...)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
@@ -13,9 +13,15 @@ import (
 	. "github.com/journeymidnight/yig/meta/types"
 )
 
+// Columns of `buckets` in the order scanned, listed by name so new columns
+// added by migrations don't shift them
+const bucketColumns = "bucketname,acl,cors,lc,uid,policy,createtime,usages,versioning,compression," +
+	"notification,logging,tagging,website,replication,inventory"
+
 func (t *TidbClient) GetBucket(bucketName string) (bucket *Bucket, err error) {
 	var acl, cors, lc, policy, createTime string
-	sqltext := "select * from buckets where bucketname=?;"
+	var notification, logging, tagging, website, replication, inventory sql.NullString
+	sqltext := "select " + bucketColumns + " from buckets where bucketname=?;"
 	bucket = new(Bucket)
 	err = t.Client.QueryRow(sqltext, bucketName).Scan(
 		&bucket.Name,
@@ -27,6 +33,13 @@ func (t *TidbClient) GetBucket(bucketName string) (bucket *Bucket, err error) {
 		&createTime,
 		&bucket.Usage,
 		&bucket.Versioning,
+		&bucket.Compression,
+		&notification,
+		&logging,
+		&tagging,
+		&website,
+		&replication,
+		&inventory,
 	)
 	if err != nil && err == sql.ErrNoRows {
 		err = ErrNoSuchBucket
@@ -54,11 +67,47 @@ func (t *TidbClient) GetBucket(bucketName string) (bucket *Bucket, err error) {
 	if err != nil {
 		return
 	}
+	if notification.Valid {
+		err = json.Unmarshal([]byte(notification.String), &bucket.Notification)
+		if err != nil {
+			return
+		}
+	}
+	if logging.Valid {
+		err = json.Unmarshal([]byte(logging.String), &bucket.Logging)
+		if err != nil {
+			return
+		}
+	}
+	if tagging.Valid {
+		err = json.Unmarshal([]byte(tagging.String), &bucket.Tagging)
+		if err != nil {
+			return
+		}
+	}
+	if website.Valid {
+		err = json.Unmarshal([]byte(website.String), &bucket.Website)
+		if err != nil {
+			return
+		}
+	}
+	if replication.Valid {
+		err = json.Unmarshal([]byte(replication.String), &bucket.Replication)
+		if err != nil {
+			return
+		}
+	}
+	if inventory.Valid {
+		err = json.Unmarshal([]byte(inventory.String), &bucket.Inventory)
+		if err != nil {
+			return
+		}
+	}
 	return
 }
 
 func (t *TidbClient) GetBuckets() (buckets []Bucket, err error) {
-	sqltext := "select * from buckets;"
+	sqltext := "select " + bucketColumns + " from buckets;"
 	rows, err := t.Client.Query(sqltext)
 	if err == sql.ErrNoRows {
 		err = nil
@@ -71,6 +120,7 @@ func (t *TidbClient) GetBuckets() (buckets []Bucket, err error) {
 	for rows.Next() {
 		var tmp Bucket
 		var acl, cors, lc, policy, createTime string
+		var notification, logging, tagging, website, replication, inventory sql.NullString
 		err = rows.Scan(
 			&tmp.Name,
 			&acl,
@@ -80,7 +130,14 @@ func (t *TidbClient) GetBuckets() (buckets []Bucket, err error) {
 			&policy,
 			&createTime,
 			&tmp.Usage,
-			&tmp.Versioning)
+			&tmp.Versioning,
+			&tmp.Compression,
+			&notification,
+			&logging,
+			&tagging,
+			&website,
+			&replication,
+			&inventory)
 		if err != nil {
 			return
 		}
@@ -104,6 +161,42 @@ func (t *TidbClient) GetBuckets() (buckets []Bucket, err error) {
 		if err != nil {
 			return
 		}
+		if notification.Valid {
+			err = json.Unmarshal([]byte(notification.String), &tmp.Notification)
+			if err != nil {
+				return
+			}
+		}
+		if logging.Valid {
+			err = json.Unmarshal([]byte(logging.String), &tmp.Logging)
+			if err != nil {
+				return
+			}
+		}
+		if tagging.Valid {
+			err = json.Unmarshal([]byte(tagging.String), &tmp.Tagging)
+			if err != nil {
+				return
+			}
+		}
+		if website.Valid {
+			err = json.Unmarshal([]byte(website.String), &tmp.Website)
+			if err != nil {
+				return
+			}
+		}
+		if replication.Valid {
+			err = json.Unmarshal([]byte(replication.String), &tmp.Replication)
+			if err != nil {
+				return
+			}
+		}
+		if inventory.Valid {
+			err = json.Unmarshal([]byte(inventory.String), &tmp.Inventory)
+			if err != nil {
+				return
+			}
+		}
 		buckets = append(buckets, tmp)
 	}
 	return
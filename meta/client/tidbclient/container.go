@@ -0,0 +1,212 @@
+package tidbclient
+
+import (
+	"database/sql"
+	"errors"
+	. "github.com/journeymidnight/yig/error"
+	. "github.com/journeymidnight/yig/meta/types"
+	"math"
+	"strconv"
+	"time"
+)
+
+var ErrContainerNotFound = errors.New("container not found")
+
+func (t *TidbClient) PutContainer(container *Container) error {
+	sqltext := "insert into container(location,pool,objectid,size,livesize,sealed,createtime) values(?,?,?,?,?,?,?);"
+	_, err := t.Client.Exec(sqltext, container.Location, container.Pool, container.ObjectId,
+		container.Size, container.LiveSize, container.Sealed, container.CreateTime.UTC().Format(TIME_LAYOUT_TIDB))
+	return err
+}
+
+func (t *TidbClient) SealContainer(container *Container) error {
+	sqltext := "update container set sealed=1 where location=? and pool=? and objectid=?;"
+	_, err := t.Client.Exec(sqltext, container.Location, container.Pool, container.ObjectId)
+	return err
+}
+
+// Record `object` packed in its container as live. Fails if the container
+// is already removed by compaction, since its data might be removed too.
+func (t *TidbClient) AddPackedObject(object *Object, tx interface{}) (err error) {
+	var sqlTx *sql.Tx
+	if tx == nil {
+		tx, err = t.Client.Begin()
+		defer func() {
+			if err == nil {
+				err = sqlTx.Commit()
+			}
+			if err != nil {
+				sqlTx.Rollback()
+			}
+		}()
+	}
+	sqlTx, _ = tx.(*sql.Tx)
+
+	size := object.StoredSize()
+	sqltext := "update container set size=greatest(size,?), livesize=livesize+? " +
+		"where location=? and pool=? and objectid=?;"
+	result, err := sqlTx.Exec(sqltext, object.PackOffset+size, size,
+		object.Location, object.Pool, object.ObjectId)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrContainerNotFound
+	}
+	version := math.MaxUint64 - uint64(object.LastModifiedTime.UnixNano())
+	sqltext = "insert into packedobject(location,pool,objectid,offset,size,bucketname,objectname,version) " +
+		"values(?,?,?,?,?,?,?,?);"
+	_, err = sqlTx.Exec(sqltext, object.Location, object.Pool, object.ObjectId, object.PackOffset, size,
+		object.BucketName, object.Name, version)
+	return err
+}
+
+// Mark space of `object` in its container as a hole
+func (t *TidbClient) RemovePackedObject(object *Object, tx interface{}) (err error) {
+	var sqlTx *sql.Tx
+	if tx == nil {
+		tx, err = t.Client.Begin()
+		defer func() {
+			if err == nil {
+				err = sqlTx.Commit()
+			}
+			if err != nil {
+				sqlTx.Rollback()
+			}
+		}()
+	}
+	sqlTx, _ = tx.(*sql.Tx)
+
+	sqltext := "delete from packedobject where location=? and pool=? and objectid=? and offset=?;"
+	result, err := sqlTx.Exec(sqltext, object.Location, object.Pool, object.ObjectId, object.PackOffset)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil || affected == 0 {
+		return err
+	}
+	sqltext = "update container set livesize=livesize-? where location=? and pool=? and objectid=?;"
+	_, err = sqlTx.Exec(sqltext, object.StoredSize(), object.Location, object.Pool, object.ObjectId)
+	return err
+}
+
+// Containers after `marker` whose ratio of live size is under `liveRatio`,
+// only those sealed or created before `abandonedBefore` are returned.
+func (t *TidbClient) ScanContainers(liveRatio float64, abandonedBefore time.Time, marker Container,
+	limit int) (containers []Container, err error) {
+
+	sqltext := "select location,pool,objectid,size,livesize,sealed,createtime from container " +
+		"where (location,pool,objectid)>(?,?,?) and (sealed=1 or createtime<?) " +
+		"and (livesize<size*? or livesize=0) order by location,pool,objectid limit ?;"
+	rows, err := t.Client.Query(sqltext, marker.Location, marker.Pool, marker.ObjectId,
+		abandonedBefore.UTC().Format(TIME_LAYOUT_TIDB), liveRatio, limit)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var container Container
+		var createTime string
+		err = rows.Scan(
+			&container.Location,
+			&container.Pool,
+			&container.ObjectId,
+			&container.Size,
+			&container.LiveSize,
+			&container.Sealed,
+			&createTime,
+		)
+		if err != nil {
+			return
+		}
+		container.CreateTime, err = time.Parse(TIME_LAYOUT_TIDB, createTime)
+		if err != nil {
+			return
+		}
+		containers = append(containers, container)
+	}
+	err = rows.Err()
+	return
+}
+
+// Live objects packed in `container`, objects removed meanwhile are skipped
+func (t *TidbClient) GetPackedObjects(container *Container) (objects []*Object, err error) {
+	sqltext := "select bucketname,objectname,version from packedobject " +
+		"where location=? and pool=? and objectid=? order by offset;"
+	rows, err := t.Client.Query(sqltext, container.Location, container.Pool, container.ObjectId)
+	if err != nil {
+		return
+	}
+	type entry struct {
+		bucketName, objectName string
+		version                uint64
+	}
+	var entries []entry
+	for rows.Next() {
+		var e entry
+		err = rows.Scan(&e.bucketName, &e.objectName, &e.version)
+		if err != nil {
+			rows.Close()
+			return
+		}
+		entries = append(entries, e)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		var object *Object
+		object, err = t.GetObject(e.bucketName, e.objectName, strconv.FormatUint(e.version, 10))
+		if err == ErrNoSuchKey {
+			err = nil
+			continue
+		}
+		if err != nil {
+			return
+		}
+		objects = append(objects, object)
+	}
+	return
+}
+
+// Remove `container` if no live object is packed in it.
+// Returns false if any object is still there.
+func (t *TidbClient) RemoveContainer(container *Container, tx interface{}) (removed bool, err error) {
+	var sqlTx *sql.Tx
+	if tx == nil {
+		tx, err = t.Client.Begin()
+		defer func() {
+			if err == nil && removed {
+				err = sqlTx.Commit()
+			}
+			if err != nil || !removed {
+				sqlTx.Rollback()
+			}
+		}()
+	}
+	sqlTx, _ = tx.(*sql.Tx)
+
+	var count int64
+	sqltext := "select count(*) from packedobject where location=? and pool=? and objectid=?;"
+	err = sqlTx.QueryRow(sqltext, container.Location, container.Pool, container.ObjectId).Scan(&count)
+	if err != nil || count > 0 {
+		return false, err
+	}
+	sqltext = "delete from container where location=? and pool=? and objectid=?;"
+	result, err := sqlTx.Exec(sqltext, container.Location, container.Pool, container.ObjectId)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
@@ -0,0 +1,75 @@
+package tidbclient
+
+import (
+	"database/sql"
+	. "github.com/journeymidnight/yig/meta/types"
+	"time"
+)
+
+func (t *TidbClient) PutWriteIntent(intent *WriteIntent) error {
+	sqltext := "insert into writeintent(location,pool,objectid,bucketname,objectname,createtime) values(?,?,?,?,?,?);"
+	_, err := t.Client.Exec(sqltext, intent.Location, intent.Pool, intent.ObjectId,
+		intent.BucketName, intent.ObjectName, intent.CreateTime.UTC().Format(TIME_LAYOUT_TIDB))
+	return err
+}
+
+// Returns false if the intent does not exist, e.g. already swept into gc
+func (t *TidbClient) RemoveWriteIntent(intent *WriteIntent, tx interface{}) (removed bool, err error) {
+	var sqlTx *sql.Tx
+	if tx == nil {
+		tx, err = t.Client.Begin()
+		defer func() {
+			if err == nil {
+				err = sqlTx.Commit()
+			}
+			if err != nil {
+				sqlTx.Rollback()
+			}
+		}()
+	}
+	sqlTx, _ = tx.(*sql.Tx)
+
+	sqltext := "delete from writeintent where location=? and pool=? and objectid=?;"
+	result, err := sqlTx.Exec(sqltext, intent.Location, intent.Pool, intent.ObjectId)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// Intents created before `createdBefore`, oldest first
+func (t *TidbClient) ScanWriteIntents(createdBefore time.Time, limit int) (intents []WriteIntent, err error) {
+	sqltext := "select location,pool,objectid,bucketname,objectname,createtime from writeintent " +
+		"where createtime<? order by createtime limit ?;"
+	rows, err := t.Client.Query(sqltext, createdBefore.UTC().Format(TIME_LAYOUT_TIDB), limit)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var intent WriteIntent
+		var createTime string
+		err = rows.Scan(
+			&intent.Location,
+			&intent.Pool,
+			&intent.ObjectId,
+			&intent.BucketName,
+			&intent.ObjectName,
+			&createTime,
+		)
+		if err != nil {
+			return
+		}
+		intent.CreateTime, err = time.Parse(TIME_LAYOUT_TIDB, createTime)
+		if err != nil {
+			return
+		}
+		intents = append(intents, intent)
+	}
+	err = rows.Err()
+	return
+}
@@ -0,0 +1,99 @@
+package tidbclient
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+
+	. "github.com/journeymidnight/yig/meta/types"
+)
+
+// Versions of objects with `prefix` after (keyMarker, versionMarker), ordered
+// by name and then version, i.e. newest versions first. Parts of multipart
+// objects are not loaded.
+func (t *TidbClient) ScanObjectVersions(bucketName, prefix, keyMarker string, versionMarker uint64,
+	limit int) (objects []*Object, err error) {
+
+	if keyMarker < prefix {
+		keyMarker, versionMarker = prefix, 0
+	}
+	sqltext := "select " + ObjectColumns + " from objects where bucketname=? and (name>? or name=? and version>?) " +
+		"order by bucketname,name,version limit ?;"
+	if versionMarker == 0 {
+		// the first version of `keyMarker` is included
+		sqltext = "select " + ObjectColumns + " from objects where bucketname=? and (name>? or name=? and version>=?) " +
+			"order by bucketname,name,version limit ?;"
+	}
+	rows, err := t.Client.Query(sqltext, bucketName, keyMarker, keyMarker, versionMarker, limit)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var object *Object
+		object, _, err = scanObject(rows)
+		if err != nil {
+			return
+		}
+		if !strings.HasPrefix(object.Name, prefix) {
+			break
+		}
+		objects = append(objects, object)
+	}
+	err = rows.Err()
+	return
+}
+
+// Returns nil if no report of the configuration is ever generated
+func (t *TidbClient) GetInventoryCheckpoint(bucketName, configId string) (*InventoryCheckpoint, error) {
+	var reportTime, updateTime string
+	var files sql.NullString
+	checkpoint := &InventoryCheckpoint{
+		BucketName: bucketName,
+		ConfigId:   configId,
+	}
+	sqltext := "select reporttime,keymarker,versionmarker,objects,files,done,updatetime from inventory " +
+		"where bucketname=? and configid=?;"
+	err := t.Client.QueryRow(sqltext, bucketName, configId).Scan(
+		&reportTime,
+		&checkpoint.KeyMarker,
+		&checkpoint.VersionMarker,
+		&checkpoint.Objects,
+		&files,
+		&checkpoint.Done,
+		&updateTime,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	checkpoint.ReportTime, err = time.Parse(TIME_LAYOUT_TIDB, reportTime)
+	if err != nil {
+		return nil, err
+	}
+	checkpoint.UpdateTime, err = time.Parse(TIME_LAYOUT_TIDB, updateTime)
+	if err != nil {
+		return nil, err
+	}
+	if files.Valid {
+		err = json.Unmarshal([]byte(files.String), &checkpoint.Files)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return checkpoint, nil
+}
+
+func (t *TidbClient) PutInventoryCheckpoint(checkpoint *InventoryCheckpoint) error {
+	files, _ := json.Marshal(checkpoint.Files)
+	sqltext := "insert into inventory(bucketname,configid,reporttime,keymarker,versionmarker,objects,files,done,updatetime) " +
+		"values(?,?,?,?,?,?,?,?,?) on duplicate key update reporttime=values(reporttime),keymarker=values(keymarker)," +
+		"versionmarker=values(versionmarker),objects=values(objects),files=values(files),done=values(done)," +
+		"updatetime=values(updatetime);"
+	_, err := t.Client.Exec(sqltext, checkpoint.BucketName, checkpoint.ConfigId,
+		checkpoint.ReportTime.UTC().Format(TIME_LAYOUT_TIDB), checkpoint.KeyMarker, checkpoint.VersionMarker,
+		checkpoint.Objects, files, checkpoint.Done, checkpoint.UpdateTime.UTC().Format(TIME_LAYOUT_TIDB))
+	return err
+}
@@ -17,6 +17,10 @@ import (
 	"time"
 )
 
+// Columns of `multiparts` in the order of inserts and scans
+const multipartColumns = "bucketname,objectname,uploadtime,initiatorid,ownerid,contenttype,location,pool,acl," +
+	"sserequest,encryption,attrs,storageclass,checksumalgorithm,checksumtype,compression,tagging"
+
 func (t *TidbClient) GetMultipart(bucketName, objectName, uploadId string) (multipart Multipart, err error) {
 	multipart.Parts = make(map[int]*Part)
 	timestampString, err := util.Decrypt(uploadId)
@@ -28,9 +32,10 @@ func (t *TidbClient) GetMultipart(bucketName, objectName, uploadId string) (mult
 		return
 	}
 	uploadTime = math.MaxUint64 - uploadTime
-	sqltext := "select * from multiparts where bucketname=? and objectname=? and uploadtime=?;"
+	sqltext := "select " + multipartColumns + " from multiparts where bucketname=? and objectname=? and uploadtime=?;"
 	var initialTime uint64
 	var acl, sseRequest, attrs string
+	var tagging sql.NullString
 	err = t.Client.QueryRow(sqltext, bucketName, objectName, uploadTime).Scan(
 		&multipart.BucketName,
 		&multipart.ObjectName,
@@ -45,6 +50,10 @@ func (t *TidbClient) GetMultipart(bucketName, objectName, uploadId string) (mult
 		&multipart.Metadata.EncryptionKey,
 		&attrs,
 		&multipart.Metadata.StorageClass,
+		&multipart.Metadata.ChecksumAlgorithm,
+		&multipart.Metadata.ChecksumType,
+		&multipart.Metadata.Compression,
+		&tagging,
 	)
 	if err != nil && err == sql.ErrNoRows {
 		err = ErrNoSuchUpload
@@ -68,8 +77,14 @@ func (t *TidbClient) GetMultipart(bucketName, objectName, uploadId string) (mult
 	if err != nil {
 		return
 	}
+	if tagging.Valid {
+		err = json.Unmarshal([]byte(tagging.String), &multipart.Metadata.Tagging)
+		if err != nil {
+			return
+		}
+	}
 
-	sqltext = "select partnumber,size,objectid,offset,etag,lastmodified,initializationvector from multipartpart where bucketname=? and objectname=? and uploadtime=?;"
+	sqltext = "select partnumber,size,objectid,offset,etag,lastmodified,initializationvector,checksum,checksumalgorithm,checksumvalue,compressedsize from multipartpart where bucketname=? and objectname=? and uploadtime=?;"
 	rows, err := t.Client.Query(sqltext, bucketName, objectName, uploadTime)
 	if err != nil {
 		return
@@ -85,6 +100,10 @@ func (t *TidbClient) GetMultipart(bucketName, objectName, uploadId string) (mult
 			&p.Etag,
 			&p.LastModified,
 			&p.InitializationVector,
+			&p.Checksum,
+			&p.ChecksumAlgorithm,
+			&p.ChecksumValue,
+			&p.CompressedSize,
 		)
 		ts, e := time.Parse(TIME_LAYOUT_TIDB, p.LastModified)
 		if e != nil {
@@ -105,9 +124,11 @@ func (t *TidbClient) CreateMultipart(multipart Multipart) (err error) {
 	acl, _ := json.Marshal(m.Acl)
 	sseRequest, _ := json.Marshal(m.SseRequest)
 	attrs, _ := json.Marshal(m.Attrs)
-	sqltext := "insert into multiparts(bucketname,objectname,uploadtime,initiatorid,ownerid,contenttype,location,pool,acl,sserequest,encryption,attrs,storageclass) " +
-		"values(?,?,?,?,?,?,?,?,?,?,?,?,?)"
-	_, err = t.Client.Exec(sqltext, multipart.BucketName, multipart.ObjectName, uploadtime, m.InitiatorId, m.OwnerId, m.ContentType, m.Location, m.Pool, acl, sseRequest, m.EncryptionKey, attrs, m.StorageClass)
+	tagging, _ := json.Marshal(m.Tagging)
+	sqltext := "insert into multiparts(" + multipartColumns + ") " +
+		"values(?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)"
+	_, err = t.Client.Exec(sqltext, multipart.BucketName, multipart.ObjectName, uploadtime, m.InitiatorId, m.OwnerId, m.ContentType, m.Location, m.Pool, acl, sseRequest, m.EncryptionKey, attrs, m.StorageClass,
+		m.ChecksumAlgorithm, m.ChecksumType, m.Compression, tagging)
 	return
 }
 
@@ -132,9 +153,10 @@ func (t *TidbClient) PutObjectPart(multipart *Multipart, part *Part, tx interfac
 		return
 	}
 	lastModified := lastt.Format(TIME_LAYOUT_TIDB)
-	sqltext := "insert into multipartpart(partnumber,size,objectid,offset,etag,lastmodified,initializationvector,bucketname,objectname,uploadtime) " +
-		"values(?,?,?,?,?,?,?,?,?,?)"
-	_, err = sqlTx.Exec(sqltext, part.PartNumber, part.Size, part.ObjectId, part.Offset, part.Etag, lastModified, part.InitializationVector, multipart.BucketName, multipart.ObjectName, uploadtime)
+	sqltext := "insert into multipartpart(partnumber,size,objectid,offset,etag,lastmodified,initializationvector,bucketname,objectname,uploadtime,checksum,checksumalgorithm,checksumvalue,compressedsize) " +
+		"values(?,?,?,?,?,?,?,?,?,?,?,?,?,?)"
+	_, err = sqlTx.Exec(sqltext, part.PartNumber, part.Size, part.ObjectId, part.Offset, part.Etag, lastModified, part.InitializationVector, multipart.BucketName, multipart.ObjectName, uploadtime,
+		part.Checksum, part.ChecksumAlgorithm, part.ChecksumValue, part.CompressedSize)
 	return
 }
 
@@ -288,3 +310,9 @@ func (t *TidbClient) ListMultipartUploads(bucketName, keyMarker, uploadIdMarker,
 	prefixs = helper.Keys(commonPrefixes)
 	return
 }
+
+func (t *TidbClient) CountMultipartsByLocation(location string) (count int64, err error) {
+	sqltext := "select count(*) from multiparts where location=?;"
+	err = t.Client.QueryRow(sqltext, location).Scan(&count)
+	return
+}
@@ -9,25 +9,59 @@ import (
 	"github.com/xxtea/xxtea-go/xxtea"
 	"math"
 	"strconv"
+	"strings"
 	"time"
 )
 
 func (t *TidbClient) GetObject(bucketName, objectName, version string) (object *Object, err error) {
-	var ibucketname, iname, customattributes, acl, lastModifiedTime string
-	var iversion uint64
 	var sqltext string
 	var row *sql.Row
 	if version == "" {
-		sqltext = "select * from objects where bucketname=? and name=? order by bucketname,name,version limit 1;"
+		sqltext = "select " + ObjectColumns + " from objects where bucketname=? and name=? " +
+			"order by bucketname,name,version limit 1;"
 		row = t.Client.QueryRow(sqltext, bucketName, objectName)
 	} else {
-		sqltext = "select * from objects where bucketname=? and name=? and version=?;"
+		sqltext = "select " + ObjectColumns + " from objects where bucketname=? and name=? and version=?;"
 		row = t.Client.QueryRow(sqltext, bucketName, objectName, version)
 	}
+	object, iversion, err := scanObject(row)
+	if err == sql.ErrNoRows {
+		err = ErrNoSuchKey
+		return
+	} else if err != nil {
+		return
+	}
+	object.GetRowkey()
+	object.Name = objectName
+	object.BucketName = bucketName
+	object.Parts, err = getParts(object.BucketName, object.Name, iversion, t.Client)
+	//build simple index for multipart
+	if len(object.Parts) != 0 {
+		var sortedPartNum = make([]int64, len(object.Parts))
+		for k, v := range object.Parts {
+			sortedPartNum[k-1] = v.Offset
+		}
+		object.PartsIndex = &SimpleIndex{Index: sortedPartNum}
+	}
+	var reversedTime uint64
+	timestamp := math.MaxUint64 - reversedTime
+	timeData := []byte(strconv.FormatUint(timestamp, 10))
+	object.VersionId = hex.EncodeToString(xxtea.Encrypt(timeData, XXTEA_KEY))
+	return
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// Scan a row of ObjectColumns selected from objects, without parts of the object
+func scanObject(row rowScanner) (object *Object, iversion uint64, err error) {
+	var customattributes, acl, lastModifiedTime string
+	var tagging, replicationStatus, restore sql.NullString
 	object = &Object{}
 	err = row.Scan(
-		&ibucketname,
-		&iname,
+		&object.BucketName,
+		&object.Name,
 		&iversion,
 		&object.Location,
 		&object.Pool,
@@ -46,20 +80,25 @@ func (t *TidbClient) GetObject(bucketName, objectName, version string) (object *
 		&object.InitializationVector,
 		&object.Type,
 		&object.StorageClass,
+		&object.Checksum,
+		&object.ChecksumAlgorithm,
+		&object.ChecksumValue,
+		&object.Compression,
+		&object.CompressedSize,
+		&object.Packed,
+		&object.PackOffset,
+		&object.InlineData,
+		&tagging,
+		&replicationStatus,
+		&restore,
 	)
-	if err == sql.ErrNoRows {
-		err = ErrNoSuchKey
-		return
-	} else if err != nil {
+	if err != nil {
 		return
 	}
 	rversion := math.MaxUint64 - iversion
 	s := int64(rversion) / 1e9
 	ns := int64(rversion) % 1e9
 	object.LastModifiedTime = time.Unix(s, ns)
-	object.GetRowkey()
-	object.Name = objectName
-	object.BucketName = bucketName
 	err = json.Unmarshal([]byte(acl), &object.ACL)
 	if err != nil {
 		return
@@ -68,19 +107,19 @@ func (t *TidbClient) GetObject(bucketName, objectName, version string) (object *
 	if err != nil {
 		return
 	}
-	object.Parts, err = getParts(object.BucketName, object.Name, iversion, t.Client)
-	//build simple index for multipart
-	if len(object.Parts) != 0 {
-		var sortedPartNum = make([]int64, len(object.Parts))
-		for k, v := range object.Parts {
-			sortedPartNum[k-1] = v.Offset
+	if tagging.Valid {
+		err = json.Unmarshal([]byte(tagging.String), &object.Tagging)
+		if err != nil {
+			return
+		}
+	}
+	object.ReplicationStatus = replicationStatus.String
+	if restore.Valid {
+		err = json.Unmarshal([]byte(restore.String), &object.Restore)
+		if err != nil {
+			return
 		}
-		object.PartsIndex = &SimpleIndex{Index: sortedPartNum}
 	}
-	var reversedTime uint64
-	timestamp := math.MaxUint64 - reversedTime
-	timeData := []byte(strconv.FormatUint(timestamp, 10))
-	object.VersionId = hex.EncodeToString(xxtea.Encrypt(timeData, XXTEA_KEY))
 	return
 }
 
@@ -117,6 +156,32 @@ func (t *TidbClient) UpdateObjectAcl(object *Object) error {
 	return err
 }
 
+func (t *TidbClient) UpdateObjectTagging(object *Object) error {
+	sql, args := object.GetUpdateTaggingSql()
+	_, err := t.Client.Exec(sql, args...)
+	return err
+}
+
+func (t *TidbClient) UpdateObjectReplicationStatus(object *Object, tx interface{}) (err error) {
+	var sqlTx *sql.Tx
+	if tx == nil {
+		tx, err = t.Client.Begin()
+		defer func() {
+			if err == nil {
+				err = sqlTx.Commit()
+			}
+			if err != nil {
+				sqlTx.Rollback()
+			}
+		}()
+	}
+	sqlTx, _ = tx.(*sql.Tx)
+
+	sqltext, args := object.GetUpdateReplicationStatusSql()
+	_, err = sqlTx.Exec(sqltext, args...)
+	return err
+}
+
 func (t *TidbClient) UpdateObjectAttrs(object *Object) error {
 	sql, args := object.GetUpdateAttrsSql()
 	_, err := t.Client.Exec(sql, args...)
@@ -125,8 +190,19 @@ func (t *TidbClient) UpdateObjectAttrs(object *Object) error {
 
 func (t *TidbClient) UpdateAppendObject(o *Object) (err error) {
 	sql, args := o.GetAppendSql()
-	_, err = t.Client.Exec(sql, args...)
-	return err
+	result, err := t.Client.Exec(sql, args...)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		// object is moved to another cluster after we read it, the client should retry
+		return ErrInternalError
+	}
+	return nil
 }
 
 func (t *TidbClient) PutObject(object *Object, tx interface{}) (err error) {
@@ -189,10 +265,118 @@ func (t *TidbClient) DeleteObject(object *Object, tx interface{}) (err error) {
 	return nil
 }
 
+// List objects stored in cluster `location`, or in all clusters if
+// `location` is empty, in the order of rowkey and starting after `marker`.
+// `nextMarker` is empty if no more objects.
+func (t *TidbClient) ScanObjectsByLocation(location string, limit int, marker string) (objects []*Object,
+	nextMarker string, err error) {
+
+	sqltext := "select bucketname,name,version from objects where "
+	var args []interface{}
+	if location != "" {
+		sqltext += "location=? and "
+		args = append(args, location)
+	}
+	if marker == "" {
+		sqltext += "true "
+	} else {
+		s := strings.Split(marker, ObjectNameSeparator)
+		if len(s) != 3 {
+			return nil, "", ErrInvalidContinuationToken
+		}
+		bucketName, objectName, version := s[0], s[1], s[2]
+		sqltext += "(bucketname>? or (bucketname=? and name>?) or (bucketname=? and name=? and version>?)) "
+		args = append(args, bucketName, bucketName, objectName, bucketName, objectName, version)
+	}
+	sqltext += "order by bucketname,name,version limit ?;"
+	args = append(args, limit)
+	rows, err := t.Client.Query(sqltext, args...)
+	if err != nil {
+		return
+	}
+	var keys [][3]string
+	for rows.Next() {
+		var b, o, v string
+		err = rows.Scan(&b, &o, &v)
+		if err != nil {
+			rows.Close()
+			return
+		}
+		keys = append(keys, [3]string{b, o, v})
+	}
+	rows.Close()
+	for _, k := range keys {
+		var object *Object
+		object, err = t.GetObject(k[0], k[1], k[2])
+		if err == ErrNoSuchKey { // removed after listed
+			err = nil
+			continue
+		}
+		if err != nil {
+			return
+		}
+		objects = append(objects, object)
+	}
+	if len(keys) == limit {
+		last := keys[len(keys)-1]
+		nextMarker = strings.Join(last[:], ObjectNameSeparator)
+	}
+	return
+}
+
+// Point `object` to the data of `target` if the data of `object` is not
+// changed since read. Returns false if the object is changed, in which
+// case `tx` should be aborted.
+func (t *TidbClient) SwitchObjectLocation(object *Object, target *Object, tx interface{}) (switched bool, err error) {
+	var sqlTx *sql.Tx
+	if tx == nil {
+		tx, err = t.Client.Begin()
+		defer func() {
+			if err == nil && switched {
+				err = sqlTx.Commit()
+			}
+			if err != nil || !switched {
+				sqlTx.Rollback()
+			}
+		}()
+	}
+	sqlTx, _ = tx.(*sql.Tx)
+
+	v := math.MaxUint64 - uint64(object.LastModifiedTime.UnixNano())
+	version := strconv.FormatUint(v, 10)
+	sqltext := "update objects set location=?, pool=?, objectid=?, packed=?, packoffset=? " +
+		"where bucketname=? and name=? and version=? " +
+		"and location=? and pool=? and objectid=? and packoffset=? and size=?;"
+	result, err := sqlTx.Exec(sqltext, target.Location, target.Pool, target.ObjectId, target.Packed, target.PackOffset,
+		object.BucketName, object.Name, version,
+		object.Location, object.Pool, object.ObjectId, object.PackOffset, object.Size)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil || affected == 0 {
+		return false, err
+	}
+	for n, p := range object.Parts {
+		sqltext = "update objectpart set objectid=? where bucketname=? and objectname=? and version=? " +
+			"and partnumber=? and objectid=?;"
+		result, err = sqlTx.Exec(sqltext, target.Parts[n].ObjectId,
+			object.BucketName, object.Name, version, n, p.ObjectId)
+		if err != nil {
+			return false, err
+		}
+		affected, err = result.RowsAffected()
+		if err != nil || affected == 0 {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
 //util function
 func getParts(bucketName, objectName string, version uint64, cli *sql.DB) (parts map[int]*Part, err error) {
 	parts = make(map[int]*Part)
-	sqltext := "select partnumber,size,objectid,offset,etag,lastmodified,initializationvector from objectpart where bucketname=? and objectname=? and version=?;"
+	sqltext := "select partnumber,size,objectid,offset,etag,lastmodified,initializationvector,checksum,checksumalgorithm,checksumvalue,compressedsize from objectpart where bucketname=? and objectname=? and version=?;"
 	rows, err := cli.Query(sqltext, bucketName, objectName, version)
 	if err != nil {
 		return
@@ -208,6 +392,10 @@ func getParts(bucketName, objectName string, version uint64, cli *sql.DB) (parts
 			&p.Etag,
 			&p.LastModified,
 			&p.InitializationVector,
+			&p.Checksum,
+			&p.ChecksumAlgorithm,
+			&p.ChecksumValue,
+			&p.CompressedSize,
 		)
 		parts[p.PartNumber] = p
 	}
@@ -0,0 +1,86 @@
+package tidbclient
+
+import (
+	"database/sql"
+	. "github.com/journeymidnight/yig/error"
+	. "github.com/journeymidnight/yig/meta/types"
+	"math"
+)
+
+// References of data shared by copies are counted in `objectref`, keyed by
+// `DataObjectId`. Data without a row there is referenced by one object only.
+
+// Add a reference to data of `source`, for a copy sharing it. Fails with
+// ErrNoSuchKey if `source` is removed or its data is changed since read.
+func (t *TidbClient) AddObjectReference(source *Object, tx interface{}) (err error) {
+	var sqlTx *sql.Tx
+	if tx == nil {
+		tx, err = t.Client.Begin()
+		defer func() {
+			if err == nil {
+				err = sqlTx.Commit()
+			}
+			if err != nil {
+				sqlTx.Rollback()
+			}
+		}()
+	}
+	sqlTx, _ = tx.(*sql.Tx)
+
+	// lock the source row, so deleting it concurrently conflicts with us
+	var count int
+	version := math.MaxUint64 - uint64(source.LastModifiedTime.UnixNano())
+	sqltext := "select count(*) from objects where bucketname=? and name=? and version=? " +
+		"and location=? and pool=? and objectid=? for update;"
+	err = sqlTx.QueryRow(sqltext, source.BucketName, source.Name, version,
+		source.Location, source.Pool, source.ObjectId).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrNoSuchKey
+	}
+	sqltext = "insert into objectref(location,pool,objectid,refcount) values(?,?,?,2) " +
+		"on duplicate key update refcount=refcount+1;"
+	_, err = sqlTx.Exec(sqltext, source.Location, source.Pool, source.DataObjectId())
+	return err
+}
+
+// Drop the reference of `object` to its data. Returns true if the data is
+// still referenced by other objects, and should not be put into gc.
+func (t *TidbClient) ReleaseObjectReference(object *Object, tx interface{}) (referenced bool, err error) {
+	var sqlTx *sql.Tx
+	if tx == nil {
+		tx, err = t.Client.Begin()
+		defer func() {
+			if err == nil {
+				err = sqlTx.Commit()
+			}
+			if err != nil {
+				sqlTx.Rollback()
+			}
+		}()
+	}
+	sqlTx, _ = tx.(*sql.Tx)
+
+	var refCount int64
+	sqltext := "select refcount from objectref where location=? and pool=? and objectid=? for update;"
+	err = sqlTx.QueryRow(sqltext, object.Location, object.Pool, object.DataObjectId()).Scan(&refCount)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if refCount <= 2 {
+		// only one reference would be left
+		sqltext = "delete from objectref where location=? and pool=? and objectid=?;"
+	} else {
+		sqltext = "update objectref set refcount=refcount-1 where location=? and pool=? and objectid=?;"
+	}
+	_, err = sqlTx.Exec(sqltext, object.Location, object.Pool, object.DataObjectId())
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,110 @@
+package tidbclient
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/journeymidnight/yig/crypto"
+	. "github.com/journeymidnight/yig/error"
+	. "github.com/journeymidnight/yig/meta/types"
+)
+
+// Names of objects in `bucketName` starting with `prefix`, at most `limit`
+// of them in ascending order. Names with only delete markers are included.
+func (t *TidbClient) ListObjectNames(bucketName, prefix string, limit int) (names []string, err error) {
+	sqltext := "select distinct name from objects where bucketname=? and name like ? order by name limit ?;"
+	rows, err := t.Client.Query(sqltext, bucketName, escapeLike(prefix)+"%", limit)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		err = rows.Scan(&name)
+		if err != nil {
+			return
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// Move all versions of `sourceName` to `targetName`, along with their parts,
+// null version map and packing entries. Only metadata is changed, the data
+// is shared as is. Returns the versions moved, named `targetName` and
+// without parts.
+// Fails with ErrRenameTargetExists if any version of `targetName` exists,
+// and with ErrRenameEncryptedObject if any version is encrypted by SSE-S3,
+// whose keys are sealed with object names.
+func (t *TidbClient) RenameObject(bucketName, sourceName, targetName string,
+	tx interface{}) (objects []*Object, err error) {
+
+	var sqlTx *sql.Tx
+	if tx == nil {
+		tx, err = t.Client.Begin()
+		defer func() {
+			if err == nil {
+				err = sqlTx.Commit()
+			}
+			if err != nil {
+				sqlTx.Rollback()
+			}
+		}()
+	}
+	sqlTx, _ = tx.(*sql.Tx)
+
+	var count int
+	sqltext := "select count(*) from objects where bucketname=? and name=? for update;"
+	err = sqlTx.QueryRow(sqltext, bucketName, targetName).Scan(&count)
+	if err != nil {
+		return nil, err
+	}
+	if count != 0 {
+		return nil, ErrRenameTargetExists
+	}
+
+	sqltext = "select " + ObjectColumns + " from objects where bucketname=? and name=? for update;"
+	rows, err := sqlTx.Query(sqltext, bucketName, sourceName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var object *Object
+		object, _, err = scanObject(rows)
+		if err != nil {
+			return nil, err
+		}
+		if object.SseType == crypto.S3.String() {
+			return nil, ErrRenameEncryptedObject
+		}
+		object.Name = targetName
+		objects = append(objects, object)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	if len(objects) == 0 {
+		return nil, ErrNoSuchKey
+	}
+
+	for _, sqltext = range []string{
+		"update objects set name=? where bucketname=? and name=?;",
+		"update objectpart set objectname=? where bucketname=? and objectname=?;",
+		"update objmap set objectname=? where bucketname=? and objectname=?;",
+		"update packedobject set objectname=? where bucketname=? and objectname=?;",
+		"update restore set objectname=? where bucketname=? and objectname=?;",
+	} {
+		_, err = sqlTx.Exec(sqltext, targetName, bucketName, sourceName)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return objects, nil
+}
+
+// Escape `s` to match literally in LIKE patterns
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
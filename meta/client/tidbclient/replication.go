@@ -0,0 +1,120 @@
+package tidbclient
+
+import (
+	"database/sql"
+	"time"
+
+	. "github.com/journeymidnight/yig/meta/types"
+)
+
+// A task queued again before processed is retried from scratch
+func (t *TidbClient) PutReplicationTask(task *ReplicationTask, tx interface{}) (err error) {
+	var sqlTx *sql.Tx
+	if tx == nil {
+		tx, err = t.Client.Begin()
+		defer func() {
+			if err == nil {
+				err = sqlTx.Commit()
+			}
+			if err != nil {
+				sqlTx.Rollback()
+			}
+		}()
+	}
+	sqlTx, _ = tx.(*sql.Tx)
+
+	sqltext := "insert into replication(bucketname,objectname,version,operation,retries,nexttime,lasterror,createtime) " +
+		"values(?,?,?,?,0,?,'',?) on duplicate key update retries=0,nexttime=values(nexttime),lasterror='';"
+	_, err = sqlTx.Exec(sqltext, task.BucketName, task.ObjectName, task.Version, task.Operation,
+		task.NextTime.UTC().Format(TIME_LAYOUT_TIDB), task.CreateTime.UTC().Format(TIME_LAYOUT_TIDB))
+	return err
+}
+
+// Tasks due before `dueBefore`, earliest first. Only tasks failed at least
+// once are returned if `failedOnly` is set.
+func (t *TidbClient) ScanReplicationTasks(dueBefore time.Time, failedOnly bool,
+	limit int) (tasks []ReplicationTask, err error) {
+
+	sqltext := "select bucketname,objectname,version,operation,retries,nexttime,lasterror,createtime " +
+		"from replication where nexttime<?"
+	if failedOnly {
+		sqltext += " and retries>0"
+	}
+	sqltext += " order by nexttime limit ?;"
+	rows, err := t.Client.Query(sqltext, dueBefore.UTC().Format(TIME_LAYOUT_TIDB), limit)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var task ReplicationTask
+		var nextTime, createTime string
+		err = rows.Scan(
+			&task.BucketName,
+			&task.ObjectName,
+			&task.Version,
+			&task.Operation,
+			&task.Retries,
+			&nextTime,
+			&task.LastError,
+			&createTime,
+		)
+		if err != nil {
+			return
+		}
+		task.NextTime, err = time.Parse(TIME_LAYOUT_TIDB, nextTime)
+		if err != nil {
+			return
+		}
+		task.CreateTime, err = time.Parse(TIME_LAYOUT_TIDB, createTime)
+		if err != nil {
+			return
+		}
+		tasks = append(tasks, task)
+	}
+	err = rows.Err()
+	return
+}
+
+// Push NextTime of the task to `until` if it's not changed since scanned,
+// so only one worker could process it. Returns false if claimed by others.
+func (t *TidbClient) ClaimReplicationTask(task *ReplicationTask, until time.Time) (claimed bool, err error) {
+	sqltext := "update replication set nexttime=? where bucketname=? and objectname=? and version=? " +
+		"and operation=? and nexttime=?;"
+	result, err := t.Client.Exec(sqltext, until.UTC().Format(TIME_LAYOUT_TIDB), task.BucketName,
+		task.ObjectName, task.Version, task.Operation, task.NextTime.UTC().Format(TIME_LAYOUT_TIDB))
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected > 0 {
+		task.NextTime = until
+	}
+	return affected > 0, nil
+}
+
+func (t *TidbClient) UpdateReplicationTask(task *ReplicationTask) error {
+	sqltext := "update replication set retries=?,nexttime=?,lasterror=? where bucketname=? and objectname=? " +
+		"and version=? and operation=?;"
+	_, err := t.Client.Exec(sqltext, task.Retries, task.NextTime.UTC().Format(TIME_LAYOUT_TIDB),
+		task.LastError, task.BucketName, task.ObjectName, task.Version, task.Operation)
+	return err
+}
+
+func (t *TidbClient) RemoveReplicationTask(task *ReplicationTask) error {
+	sqltext := "delete from replication where bucketname=? and objectname=? and version=? and operation=?;"
+	_, err := t.Client.Exec(sqltext, task.BucketName, task.ObjectName, task.Version, task.Operation)
+	return err
+}
+
+func (t *TidbClient) GetReplicationBacklog() (backlog ReplicationBacklog, err error) {
+	sqltext := "select count(if(retries=0,1,null)),count(if(retries>0,1,null)) from replication;"
+	err = t.Client.QueryRow(sqltext).Scan(&backlog.Pending, &backlog.Failing)
+	if err == sql.ErrNoRows {
+		err = nil
+	}
+	return
+}
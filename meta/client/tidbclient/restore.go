@@ -0,0 +1,144 @@
+package tidbclient
+
+import (
+	"database/sql"
+	"encoding/json"
+	"math"
+	"time"
+
+	. "github.com/journeymidnight/yig/error"
+	. "github.com/journeymidnight/yig/meta/types"
+)
+
+// A task queued again before processed is retried from scratch
+func (t *TidbClient) PutRestoreTask(task *RestoreTask, tx interface{}) (err error) {
+	var sqlTx *sql.Tx
+	if tx == nil {
+		tx, err = t.Client.Begin()
+		defer func() {
+			if err == nil {
+				err = sqlTx.Commit()
+			}
+			if err != nil {
+				sqlTx.Rollback()
+			}
+		}()
+	}
+	sqlTx, _ = tx.(*sql.Tx)
+
+	sqltext := "insert into restore(bucketname,objectname,version,retries,nexttime,lasterror,createtime) " +
+		"values(?,?,?,0,?,'',?) on duplicate key update retries=0,nexttime=values(nexttime),lasterror='';"
+	_, err = sqlTx.Exec(sqltext, task.BucketName, task.ObjectName, task.Version,
+		task.NextTime.UTC().Format(TIME_LAYOUT_TIDB), task.CreateTime.UTC().Format(TIME_LAYOUT_TIDB))
+	return err
+}
+
+// Tasks due before `dueBefore`, earliest first
+func (t *TidbClient) ScanRestoreTasks(dueBefore time.Time, limit int) (tasks []RestoreTask, err error) {
+	sqltext := "select bucketname,objectname,version,retries,nexttime,lasterror,createtime " +
+		"from restore where nexttime<? order by nexttime limit ?;"
+	rows, err := t.Client.Query(sqltext, dueBefore.UTC().Format(TIME_LAYOUT_TIDB), limit)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var task RestoreTask
+		var nextTime, createTime string
+		err = rows.Scan(
+			&task.BucketName,
+			&task.ObjectName,
+			&task.Version,
+			&task.Retries,
+			&nextTime,
+			&task.LastError,
+			&createTime,
+		)
+		if err != nil {
+			return
+		}
+		task.NextTime, err = time.Parse(TIME_LAYOUT_TIDB, nextTime)
+		if err != nil {
+			return
+		}
+		task.CreateTime, err = time.Parse(TIME_LAYOUT_TIDB, createTime)
+		if err != nil {
+			return
+		}
+		tasks = append(tasks, task)
+	}
+	err = rows.Err()
+	return
+}
+
+// Push NextTime of the task to `until` if it's not changed since scanned,
+// so only one worker could process it. Returns false if claimed by others.
+func (t *TidbClient) ClaimRestoreTask(task *RestoreTask, until time.Time) (claimed bool, err error) {
+	sqltext := "update restore set nexttime=? where bucketname=? and objectname=? and version=? " +
+		"and nexttime=?;"
+	result, err := t.Client.Exec(sqltext, until.UTC().Format(TIME_LAYOUT_TIDB), task.BucketName,
+		task.ObjectName, task.Version, task.NextTime.UTC().Format(TIME_LAYOUT_TIDB))
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected > 0 {
+		task.NextTime = until
+	}
+	return affected > 0, nil
+}
+
+func (t *TidbClient) UpdateRestoreTask(task *RestoreTask) error {
+	sqltext := "update restore set retries=?,nexttime=?,lasterror=? where bucketname=? and objectname=? " +
+		"and version=?;"
+	_, err := t.Client.Exec(sqltext, task.Retries, task.NextTime.UTC().Format(TIME_LAYOUT_TIDB),
+		task.LastError, task.BucketName, task.ObjectName, task.Version)
+	return err
+}
+
+func (t *TidbClient) RemoveRestoreTask(task *RestoreTask) error {
+	sqltext := "delete from restore where bucketname=? and objectname=? and version=?;"
+	_, err := t.Client.Exec(sqltext, task.BucketName, task.ObjectName, task.Version)
+	return err
+}
+
+// Lock the row of `object` in `tx` and return its restore state,
+// ErrNoSuchKey if the object is removed
+func (t *TidbClient) LockObjectRestore(object *Object, tx interface{}) (restore *ObjectRestore, err error) {
+	sqlTx, _ := tx.(*sql.Tx)
+	version := math.MaxUint64 - uint64(object.LastModifiedTime.UnixNano())
+	sqltext := "select restore from objects where bucketname=? and name=? and version=? for update;"
+	var column sql.NullString
+	err = sqlTx.QueryRow(sqltext, object.BucketName, object.Name, version).Scan(&column)
+	if err == sql.ErrNoRows {
+		return nil, ErrNoSuchKey
+	}
+	if err != nil || !column.Valid {
+		return nil, err
+	}
+	err = json.Unmarshal([]byte(column.String), &restore)
+	return restore, err
+}
+
+func (t *TidbClient) UpdateObjectRestore(object *Object, tx interface{}) (err error) {
+	var sqlTx *sql.Tx
+	if tx == nil {
+		tx, err = t.Client.Begin()
+		defer func() {
+			if err == nil {
+				err = sqlTx.Commit()
+			}
+			if err != nil {
+				sqlTx.Rollback()
+			}
+		}()
+	}
+	sqlTx, _ = tx.(*sql.Tx)
+
+	sqltext, args := object.GetUpdateRestoreSql()
+	_, err = sqlTx.Exec(sqltext, args...)
+	return err
+}
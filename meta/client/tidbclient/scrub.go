@@ -0,0 +1,70 @@
+package tidbclient
+
+import (
+	"encoding/json"
+
+	. "github.com/journeymidnight/yig/meta/types"
+)
+
+// Call `walk` with every RADOS object id referenced by objects, restored copies,
+// multipart uploads, uploads in progress and gc, one id might be walked more than once.
+func (t *TidbClient) ScanReferencedObjectIds(walk func(objectId string) error) error {
+	tables := []string{"objects", "objectpart", "multipartpart", "gc", "gcpart", "container",
+		"writeintent"}
+	for _, table := range tables {
+		rows, err := t.Client.Query("select objectid from " + table + " where objectid<>'';")
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var objectId string
+			err = rows.Scan(&objectId)
+			if err == nil {
+				err = walk(objectId)
+			}
+			if err != nil {
+				rows.Close()
+				return err
+			}
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return t.scanRestoredObjectIds(walk)
+}
+
+// Restored copies of archived objects are recorded in their rows
+func (t *TidbClient) scanRestoredObjectIds(walk func(objectId string) error) error {
+	rows, err := t.Client.Query("select restore from objects where restore is not null;")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var column string
+		var restore ObjectRestore
+		err = rows.Scan(&column)
+		if err == nil {
+			err = json.Unmarshal([]byte(column), &restore)
+		}
+		if err != nil {
+			return err
+		}
+		if restore.ObjectId != "" {
+			err = walk(restore.ObjectId)
+			if err != nil {
+				return err
+			}
+		}
+		for _, objectId := range restore.PartObjectIds {
+			err = walk(objectId)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return rows.Err()
+}
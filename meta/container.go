@@ -0,0 +1,51 @@
+package meta
+
+import (
+	"time"
+
+	. "github.com/journeymidnight/yig/meta/types"
+)
+
+// Record `container` before packing objects into it
+func (m *Meta) PutContainer(container *Container) error {
+	return m.Client.PutContainer(container)
+}
+
+func (m *Meta) SealContainer(container *Container) error {
+	return m.Client.SealContainer(container)
+}
+
+// Containers to compact, i.e. sealed or abandoned ones whose ratio of live
+// size is under `liveRatio`
+func (m *Meta) ScanContainers(liveRatio float64, abandonedBefore time.Time, marker Container,
+	limit int) ([]Container, error) {
+	return m.Client.ScanContainers(liveRatio, abandonedBefore, marker, limit)
+}
+
+func (m *Meta) GetPackedObjects(container *Container) ([]*Object, error) {
+	return m.Client.GetPackedObjects(container)
+}
+
+// Put `container` into gc if no live object is packed in it.
+// Returns false if any object is still there.
+func (m *Meta) RemoveContainer(container *Container) (removed bool, err error) {
+	tx, err := m.Client.NewTrans()
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err != nil || !removed {
+			m.Client.AbortTrans(tx)
+		}
+	}()
+	removed, err = m.Client.RemoveContainer(container, tx)
+	if err != nil || !removed {
+		return
+	}
+	err = m.Client.PutObjectToGarbageCollection(container.GarbageObject(), tx)
+	if err != nil {
+		return
+	}
+	err = m.Client.CommitTrans(tx)
+	return
+}
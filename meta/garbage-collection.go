@@ -14,3 +14,8 @@ func (m *Meta) ScanGarbageCollection(limit int, startRowKey string) ([]GarbageCo
 func (m *Meta) RemoveGarbageCollection(garbage GarbageCollection) error {
 	return m.Client.RemoveGarbageCollection(garbage)
 }
+
+// Walk all RADOS object ids referenced in metadata, including gc
+func (m *Meta) ScanReferencedObjectIds(walk func(objectId string) error) error {
+	return m.Client.ScanReferencedObjectIds(walk)
+}
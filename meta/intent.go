@@ -0,0 +1,74 @@
+package meta
+
+import (
+	"time"
+
+	. "github.com/journeymidnight/yig/error"
+	"github.com/journeymidnight/yig/helper"
+	. "github.com/journeymidnight/yig/meta/types"
+)
+
+// Record `intent` before writing its RADOS object
+func (m *Meta) PutWriteIntent(intent *WriteIntent) error {
+	return m.Client.PutWriteIntent(intent)
+}
+
+// Remove `intents` in `tx` committing metadata that references their RADOS
+// objects. Fails if any of them is already swept into gc, since the data
+// might be removed already.
+func (m *Meta) commitWriteIntents(intents []WriteIntent, tx interface{}) error {
+	for i := range intents {
+		removed, err := m.Client.RemoveWriteIntent(&intents[i], tx)
+		if err != nil {
+			return err
+		}
+		if !removed {
+			helper.Logger.Println(5, "Write intent is swept into gc before commit:",
+				intents[i].Location, intents[i].Pool, intents[i].ObjectId)
+			return ErrInternalError
+		}
+	}
+	return nil
+}
+
+// Put RADOS objects of `intents` into gc and remove the intents. Intents
+// already removed are skipped, their RADOS objects are committed.
+func (m *Meta) AbandonWriteIntents(intents []WriteIntent) (err error) {
+	tx, err := m.Client.NewTrans()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			m.Client.AbortTrans(tx)
+		}
+	}()
+	var last time.Time
+	for i := range intents {
+		var removed bool
+		removed, err = m.Client.RemoveWriteIntent(&intents[i], tx)
+		if err != nil {
+			return
+		}
+		if !removed {
+			continue
+		}
+		garbage := intents[i].GarbageObject()
+		// gc entries of the same object need different versions
+		if !garbage.LastModifiedTime.After(last) {
+			garbage.LastModifiedTime = last.Add(time.Nanosecond)
+		}
+		last = garbage.LastModifiedTime
+		err = m.Client.PutObjectToGarbageCollection(garbage, tx)
+		if err != nil {
+			return
+		}
+	}
+	err = m.Client.CommitTrans(tx)
+	return
+}
+
+// Intents older than `timeout`, whose writes are considered failed
+func (m *Meta) ScanExpiredWriteIntents(timeout time.Duration, limit int) ([]WriteIntent, error) {
+	return m.Client.ScanWriteIntents(time.Now().Add(-timeout), limit)
+}
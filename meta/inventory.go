@@ -0,0 +1,24 @@
+package meta
+
+import (
+	"time"
+
+	. "github.com/journeymidnight/yig/meta/types"
+)
+
+// Versions of objects after (keyMarker, versionMarker), the first version of
+// `keyMarker` is included if `versionMarker` is 0.
+func (m *Meta) ScanObjectVersions(bucketName, prefix, keyMarker string, versionMarker uint64,
+	limit int) ([]*Object, error) {
+
+	return m.Client.ScanObjectVersions(bucketName, prefix, keyMarker, versionMarker, limit)
+}
+
+func (m *Meta) GetInventoryCheckpoint(bucketName, configId string) (*InventoryCheckpoint, error) {
+	return m.Client.GetInventoryCheckpoint(bucketName, configId)
+}
+
+func (m *Meta) PutInventoryCheckpoint(checkpoint *InventoryCheckpoint) error {
+	checkpoint.UpdateTime = time.Now().UTC()
+	return m.Client.PutInventoryCheckpoint(checkpoint)
+}
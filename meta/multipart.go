@@ -1,6 +1,8 @@
 package meta
 
 import (
+	"time"
+
 	. "github.com/journeymidnight/yig/meta/types"
 )
 
@@ -19,6 +21,20 @@ func (m *Meta) DeleteMultipart(multipart Multipart) (err error) {
 	if err != nil {
 		return
 	}
+	if len(multipart.Parts) > 0 {
+		garbage := &Object{
+			BucketName:       multipart.BucketName,
+			Name:             multipart.ObjectName,
+			Location:         multipart.Metadata.Location,
+			Pool:             multipart.Metadata.Pool,
+			LastModifiedTime: time.Now().UTC(),
+			Parts:            multipart.Parts,
+		}
+		err = m.Client.PutObjectToGarbageCollection(garbage, tx)
+		if err != nil {
+			return
+		}
+	}
 	var removedSize int64 = 0
 	for _, p := range multipart.Parts {
 		removedSize += p.Size
@@ -31,7 +47,8 @@ func (m *Meta) DeleteMultipart(multipart Multipart) (err error) {
 	return
 }
 
-func (m *Meta) PutObjectPart(multipart Multipart, part Part) (err error) {
+// Data of the part replaced, if any, is put into gc
+func (m *Meta) PutObjectPart(multipart Multipart, part Part, intent WriteIntent) (err error) {
 	tx, err := m.Client.NewTrans()
 	defer func() {
 		if err != nil {
@@ -42,9 +59,25 @@ func (m *Meta) PutObjectPart(multipart Multipart, part Part) (err error) {
 	if err != nil {
 		return
 	}
+	err = m.commitWriteIntents([]WriteIntent{intent}, tx)
+	if err != nil {
+		return
+	}
 	var removedSize int64 = 0
-	if part, ok := multipart.Parts[part.PartNumber]; ok {
-		removedSize += part.Size
+	if old, ok := multipart.Parts[part.PartNumber]; ok {
+		removedSize += old.Size
+		garbage := &Object{
+			BucketName:       multipart.BucketName,
+			Name:             multipart.ObjectName,
+			Location:         multipart.Metadata.Location,
+			Pool:             multipart.Metadata.Pool,
+			ObjectId:         old.ObjectId,
+			LastModifiedTime: time.Now().UTC(),
+		}
+		err = m.Client.PutObjectToGarbageCollection(garbage, tx)
+		if err != nil {
+			return
+		}
 	}
 	err = m.Client.UpdateUsage(multipart.BucketName, part.Size-removedSize, tx)
 	if err != nil {
@@ -53,3 +86,7 @@ func (m *Meta) PutObjectPart(multipart Multipart, part Part) (err error) {
 	err = m.Client.CommitTrans(tx)
 	return
 }
+
+func (m *Meta) CountMultipartsByLocation(location string) (count int64, err error) {
+	return m.Client.CountMultipartsByLocation(location)
+}
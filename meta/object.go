@@ -5,6 +5,7 @@ import (
 	"github.com/journeymidnight/yig/helper"
 	. "github.com/journeymidnight/yig/meta/types"
 	"github.com/journeymidnight/yig/redis"
+	"time"
 )
 
 func (m *Meta) GetObject(bucketName string, objectName string, willNeed bool) (object *Object, err error) {
@@ -79,7 +80,11 @@ func (m *Meta) GetObjectVersion(bucketName, objectName, version string, willNeed
 	return object, nil
 }
 
-func (m *Meta) PutObject(object *Object, multipart *Multipart, objMap *ObjMap, updateUsage bool) error {
+// `intents` of RADOS objects written for `object` are removed, and
+// `replication` task is queued if not nil, in the same transaction
+func (m *Meta) PutObject(object *Object, multipart *Multipart, objMap *ObjMap, updateUsage bool,
+	replication *ReplicationTask, intents ...WriteIntent) error {
+
 	tx, err := m.Client.NewTrans()
 	defer func() {
 		if err != nil {
@@ -92,6 +97,18 @@ func (m *Meta) PutObject(object *Object, multipart *Multipart, objMap *ObjMap, u
 		return err
 	}
 
+	if object.Packed {
+		err = m.Client.AddPackedObject(object, tx)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = m.commitWriteIntents(intents, tx)
+	if err != nil {
+		return err
+	}
+
 	if objMap != nil {
 		err = m.Client.PutObjectMap(objMap, tx)
 		if err != nil {
@@ -112,8 +129,60 @@ func (m *Meta) PutObject(object *Object, multipart *Multipart, objMap *ObjMap, u
 			return err
 		}
 	}
+
+	if replication != nil {
+		err = m.putReplicationTask(replication, tx)
+		if err != nil {
+			return err
+		}
+	}
+	err = m.Client.CommitTrans(tx)
+	return err
+}
+
+// Put `object` sharing data of `source`, whose references are counted, so
+// the data is put into gc only after all of them are deleted
+func (m *Meta) PutObjectReference(object *Object, source *Object, objMap *ObjMap, updateUsage bool,
+	replication *ReplicationTask) error {
+	tx, err := m.Client.NewTrans()
+	defer func() {
+		if err != nil {
+			m.Client.AbortTrans(tx)
+		}
+	}()
+
+	err = m.Client.AddObjectReference(source, tx)
+	if err != nil {
+		return err
+	}
+
+	err = m.Client.PutObject(object, tx)
+	if err != nil {
+		return err
+	}
+
+	if objMap != nil {
+		err = m.Client.PutObjectMap(objMap, tx)
+		if err != nil {
+			return err
+		}
+	}
+
+	if updateUsage {
+		err = m.Client.UpdateUsage(object.BucketName, object.Size, tx)
+		if err != nil {
+			return err
+		}
+	}
+
+	if replication != nil {
+		err = m.putReplicationTask(replication, tx)
+		if err != nil {
+			return err
+		}
+	}
 	err = m.Client.CommitTrans(tx)
-	return nil
+	return err
 }
 
 func (m *Meta) PutObjectEntry(object *Object) error {
@@ -126,6 +195,16 @@ func (m *Meta) UpdateObjectAcl(object *Object) error {
 	return err
 }
 
+func (m *Meta) UpdateObjectTagging(object *Object) error {
+	err := m.Client.UpdateObjectTagging(object)
+	return err
+}
+
+func (m *Meta) UpdateObjectReplicationStatus(object *Object) error {
+	err := m.Client.UpdateObjectReplicationStatus(object, nil)
+	return err
+}
+
 func (m *Meta) UpdateObjectAttrs(object *Object) error {
 	err := m.Client.UpdateObjectAttrs(object)
 	return err
@@ -144,6 +223,11 @@ func (m *Meta) DeleteObject(object *Object, DeleteMarker bool, objMap *ObjMap) e
 		}
 	}()
 
+	err = m.refreshObjectRestore(object, tx)
+	if err != nil {
+		return err
+	}
+
 	err = m.Client.DeleteObject(object, tx)
 	if err != nil {
 		return err
@@ -160,7 +244,12 @@ func (m *Meta) DeleteObject(object *Object, DeleteMarker bool, objMap *ObjMap) e
 		return nil
 	}
 
-	err = m.Client.PutObjectToGarbageCollection(object, tx)
+	err = m.putGarbage(object, tx)
+	if err != nil {
+		return err
+	}
+
+	err = m.putRestoredGarbage(object, tx)
 	if err != nil {
 		return err
 	}
@@ -174,13 +263,76 @@ func (m *Meta) DeleteObject(object *Object, DeleteMarker bool, objMap *ObjMap) e
 	return err
 }
 
-func (m *Meta) AppendObject(object *Object, isExist bool) error {
+// `intents` are only needed for new objects, appending to existing ones
+// writes no new RADOS object
+func (m *Meta) AppendObject(object *Object, isExist bool, intents ...WriteIntent) error {
 	if !isExist {
-		return m.Client.PutObject(object, nil)
+		return m.PutObject(object, nil, nil, false, nil, intents...)
 	}
 	return m.Client.UpdateAppendObject(object)
 }
 
+func (m *Meta) ScanObjectsByLocation(location string, limit int, marker string) ([]*Object, string, error) {
+	return m.Client.ScanObjectsByLocation(location, limit, marker)
+}
+
+// Point `object` to data copied to `target`, and put the old data into gc.
+// Returns false if the object is changed or removed since read.
+func (m *Meta) MigrateObject(object *Object, target *Object, intents []WriteIntent) (migrated bool, err error) {
+	tx, err := m.Client.NewTrans()
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err != nil || !migrated {
+			m.Client.AbortTrans(tx)
+		}
+	}()
+
+	migrated, err = m.Client.SwitchObjectLocation(object, target, tx)
+	if err != nil || !migrated {
+		return
+	}
+	if target.Packed {
+		err = m.Client.AddPackedObject(target, tx)
+		if err != nil {
+			return
+		}
+	}
+	err = m.commitWriteIntents(intents, tx)
+	if err != nil {
+		return
+	}
+	// give the gc entry its own version, since the object row might be
+	// deleted into gc later under its version
+	garbage := *object
+	garbage.LastModifiedTime = time.Now().UTC()
+	err = m.putGarbage(&garbage, tx)
+	if err != nil {
+		return
+	}
+	err = m.Client.CommitTrans(tx)
+	return
+}
+
+// Put data of `object` into gc, or mark it as a hole of its container
+// if packed, the container is reclaimed by compaction. Inline objects
+// have no data to collect, and data shared by copies is kept until the
+// last reference is dropped.
+func (m *Meta) putGarbage(object *Object, tx interface{}) error {
+	if object.IsInline() {
+		return nil // nothing in Ceph
+	}
+	if object.Packed {
+		return m.Client.RemovePackedObject(object, tx)
+	}
+	referenced, err := m.Client.ReleaseObjectReference(object, tx)
+	if err != nil || referenced {
+		return err
+	}
+	return m.Client.PutObjectToGarbageCollection(object, tx)
+}
+
 //func (m *Meta) DeleteObjectEntry(object *Object) error {
 //	err := m.Client.DeleteObject(object, nil)
 //	return err
@@ -0,0 +1,120 @@
+package meta
+
+import (
+	"math"
+	"sort"
+	"strconv"
+
+	. "github.com/journeymidnight/yig/meta/types"
+)
+
+func (m *Meta) ListObjectNames(bucketName, prefix string, limit int) ([]string, error) {
+	return m.Client.ListObjectNames(bucketName, prefix, limit)
+}
+
+// Rename `sourceNames[i]` to `targetNames[i]` in `bucket`, all in one
+// transaction. Objects at targets are removed first if `replace`. Versions
+// moved are queued for replication as put to targets in the transaction too.
+// Returns versions moved of each source, and versions removed at targets.
+func (m *Meta) RenameObjects(bucket *Bucket, sourceNames, targetNames []string,
+	replace bool) (versions [][]string, removed []*Object, err error) {
+
+	bucketName := bucket.Name
+	tx, err := m.Client.NewTrans()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		if err != nil {
+			m.Client.AbortTrans(tx)
+		}
+	}()
+
+	for i, sourceName := range sourceNames {
+		if replace {
+			var objects []*Object
+			objects, err = m.removeObjectVersions(bucketName, targetNames[i], tx)
+			if err != nil {
+				return nil, nil, err
+			}
+			removed = append(removed, objects...)
+		}
+		var moved []*Object
+		moved, err = m.Client.RenameObject(bucketName, sourceName, targetNames[i], tx)
+		if err != nil {
+			return nil, nil, err
+		}
+		err = m.queueRenameReplication(bucket, moved, tx)
+		if err != nil {
+			return nil, nil, err
+		}
+		var movedVersions []string
+		for _, object := range moved {
+			movedVersions = append(movedVersions,
+				strconv.FormatUint(math.MaxUint64-uint64(object.LastModifiedTime.UnixNano()), 10))
+		}
+		versions = append(versions, movedVersions)
+	}
+	err = m.Client.CommitTrans(tx)
+	return versions, removed, err
+}
+
+// Queue replication of `objects` renamed in `tx`, oldest first, and mark
+// versions to replicate PENDING
+func (m *Meta) queueRenameReplication(bucket *Bucket, objects []*Object, tx interface{}) error {
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].LastModifiedTime.Before(objects[j].LastModifiedTime)
+	})
+	for _, object := range objects {
+		task := NewReplicationTask(bucket, object)
+		if task == nil {
+			continue
+		}
+		if !object.DeleteMarker {
+			err := m.Client.UpdateObjectReplicationStatus(object, tx)
+			if err != nil {
+				return err
+			}
+		}
+		err := m.putReplicationTask(task, tx)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Remove all versions of `objectName` in `tx` and return them, versions put
+// concurrently are found by RenameObject, which fails then
+func (m *Meta) removeObjectVersions(bucketName, objectName string, tx interface{}) ([]*Object, error) {
+	objects, err := m.Client.GetAllObject(bucketName, objectName, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, object := range objects {
+		err = m.refreshObjectRestore(object, tx)
+		if err != nil {
+			return nil, err
+		}
+		err = m.Client.DeleteObject(object, tx)
+		if err != nil {
+			return nil, err
+		}
+		if object.DeleteMarker {
+			continue
+		}
+		err = m.putGarbage(object, tx)
+		if err != nil {
+			return nil, err
+		}
+		err = m.putRestoredGarbage(object, tx)
+		if err != nil {
+			return nil, err
+		}
+		err = m.Client.UpdateUsage(object.BucketName, -object.Size, tx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return objects, nil
+}
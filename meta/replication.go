@@ -0,0 +1,41 @@
+package meta
+
+import (
+	"time"
+
+	. "github.com/journeymidnight/yig/meta/types"
+)
+
+// Queue `task` in `tx` to be processed as soon as possible
+func (m *Meta) putReplicationTask(task *ReplicationTask, tx interface{}) error {
+	task.CreateTime = time.Now().UTC()
+	task.NextTime = task.CreateTime
+	return m.Client.PutReplicationTask(task, tx)
+}
+
+// Tasks to process now
+func (m *Meta) ScanDueReplicationTasks(limit int) ([]ReplicationTask, error) {
+	return m.Client.ScanReplicationTasks(time.Now(), false, limit)
+}
+
+// Tasks failed and waiting for retry, including ones being retried
+func (m *Meta) ScanFailedReplicationTasks(limit int) ([]ReplicationTask, error) {
+	return m.Client.ScanReplicationTasks(time.Now().Add(100*365*24*time.Hour), true, limit)
+}
+
+// Claim `task` for `lease`, in which other workers won't process it
+func (m *Meta) ClaimReplicationTask(task *ReplicationTask, lease time.Duration) (bool, error) {
+	return m.Client.ClaimReplicationTask(task, time.Now().Add(lease))
+}
+
+func (m *Meta) UpdateReplicationTask(task *ReplicationTask) error {
+	return m.Client.UpdateReplicationTask(task)
+}
+
+func (m *Meta) RemoveReplicationTask(task *ReplicationTask) error {
+	return m.Client.RemoveReplicationTask(task)
+}
+
+func (m *Meta) GetReplicationBacklog() (ReplicationBacklog, error) {
+	return m.Client.GetReplicationBacklog()
+}
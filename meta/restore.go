@@ -0,0 +1,157 @@
+package meta
+
+import (
+	"math"
+	"time"
+
+	. "github.com/journeymidnight/yig/error"
+	. "github.com/journeymidnight/yig/meta/types"
+)
+
+// Save restore state of `object` requested, and queue a task to process it
+// as soon as possible if `queue` is set
+func (m *Meta) RequestObjectRestore(object *Object, queue bool) (err error) {
+	if !queue {
+		return m.Client.UpdateObjectRestore(object, nil)
+	}
+	tx, err := m.Client.NewTrans()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			m.Client.AbortTrans(tx)
+		}
+	}()
+	err = m.Client.UpdateObjectRestore(object, tx)
+	if err != nil {
+		return
+	}
+	task := RestoreTask{
+		BucketName: object.BucketName,
+		ObjectName: object.Name,
+		Version:    math.MaxUint64 - uint64(object.LastModifiedTime.UnixNano()),
+		CreateTime: time.Now().UTC(),
+	}
+	task.NextTime = task.CreateTime
+	err = m.Client.PutRestoreTask(&task, tx)
+	if err != nil {
+		return
+	}
+	return m.Client.CommitTrans(tx)
+}
+
+// Point `object` to its restored copy in `restore`, written with `intents`.
+// Returns false if the object is removed since read.
+func (m *Meta) CompleteObjectRestore(object *Object, restore *ObjectRestore,
+	intents []WriteIntent) (completed bool, err error) {
+
+	tx, err := m.Client.NewTrans()
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err != nil || !completed {
+			m.Client.AbortTrans(tx)
+		}
+	}()
+	_, err = m.Client.LockObjectRestore(object, tx)
+	if err == ErrNoSuchKey {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	restored := *object
+	restored.Restore = restore
+	err = m.Client.UpdateObjectRestore(&restored, tx)
+	if err != nil {
+		return false, err
+	}
+	err = m.commitWriteIntents(intents, tx)
+	if err != nil {
+		return false, err
+	}
+	err = m.Client.CommitTrans(tx)
+	return err == nil, err
+}
+
+// Clear restore state of `object` and put its restored copy into gc, unless
+// the restore is extended. Returns false if not expired or removed since read.
+func (m *Meta) ExpireObjectRestore(object *Object) (expired bool, err error) {
+	tx, err := m.Client.NewTrans()
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err != nil || !expired {
+			m.Client.AbortTrans(tx)
+		}
+	}()
+	restore, err := m.Client.LockObjectRestore(object, tx)
+	if err == ErrNoSuchKey {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if restore == nil || restore.Ongoing || time.Now().Before(restore.ExpiryDate) {
+		return false, nil
+	}
+	expiring := *object
+	expiring.Restore = restore
+	err = m.putRestoredGarbage(&expiring, tx)
+	if err != nil {
+		return false, err
+	}
+	expiring.Restore = nil
+	err = m.Client.UpdateObjectRestore(&expiring, tx)
+	if err != nil {
+		return false, err
+	}
+	err = m.Client.CommitTrans(tx)
+	return err == nil, err
+}
+
+// Put the restored copy of `object` into gc, under its own version since
+// the object row might be deleted into gc as well
+func (m *Meta) putRestoredGarbage(object *Object, tx interface{}) error {
+	if !object.HasRestoredCopy() {
+		return nil
+	}
+	garbage := *object.RestoredCopy()
+	garbage.LastModifiedTime = time.Now().UTC()
+	return m.Client.PutObjectToGarbageCollection(&garbage, tx)
+}
+
+// Refresh restore state of archived `object` about to be deleted in `tx`,
+// since its restored copy might be completed after the object is read
+func (m *Meta) refreshObjectRestore(object *Object, tx interface{}) error {
+	if !object.IsArchived() {
+		return nil
+	}
+	restore, err := m.Client.LockObjectRestore(object, tx)
+	if err == ErrNoSuchKey {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	object.Restore = restore
+	return nil
+}
+
+// Tasks to process now
+func (m *Meta) ScanDueRestoreTasks(limit int) ([]RestoreTask, error) {
+	return m.Client.ScanRestoreTasks(time.Now(), limit)
+}
+
+// Claim `task` for `lease`, in which other workers won't process it
+func (m *Meta) ClaimRestoreTask(task *RestoreTask, lease time.Duration) (bool, error) {
+	return m.Client.ClaimRestoreTask(task, time.Now().Add(lease))
+}
+
+func (m *Meta) UpdateRestoreTask(task *RestoreTask) error {
+	return m.Client.UpdateRestoreTask(task)
+}
+
+func (m *Meta) RemoveRestoreTask(task *RestoreTask) error {
+	return m.Client.RemoveRestoreTask(task)
+}
@@ -0,0 +1,87 @@
+package types
+
+import (
+	"time"
+
+	"github.com/journeymidnight/yig/api/datatype"
+)
+
+const (
+	BatchJobStatusActive    = "Active"
+	BatchJobStatusPaused    = "Paused"
+	BatchJobStatusCancelled = "Cancelled"
+	BatchJobStatusCompleted = "Completed"
+	BatchJobStatusFailed    = "Failed" // the manifest could not be read
+)
+
+const (
+	BatchOperationCopy    = "Copy"
+	BatchOperationSetAcl  = "SetAcl"
+	BatchOperationSetTags = "SetTags"
+	BatchOperationDelete  = "Delete"
+	BatchOperationRestore = "Restore"
+)
+
+const (
+	BatchReportScopeAll    = "AllTasks"
+	BatchReportScopeFailed = "FailedTasksOnly"
+)
+
+// The operation applied to every key of a batch job
+type BatchJobOperation struct {
+	Type string
+	// Copy: objects are copied to TargetBucket, or in place if empty,
+	// with StorageClass, or their own storage class if empty
+	TargetBucket string `json:",omitempty"`
+	StorageClass string `json:",omitempty"`
+	// SetAcl
+	CannedAcl string `json:",omitempty"`
+	// SetTags: tags of objects are replaced by them
+	Tagging datatype.Tagging
+	// Restore: archived objects are restored for RestoreDays in RestoreTier,
+	// Standard if empty
+	RestoreDays int    `json:",omitempty"`
+	RestoreTier string `json:",omitempty"`
+}
+
+// Keys of a batch job are listed in a CSV object Bucket/Key, whose lines are
+// "bucket,key[,versionId]" with keys URL encoded, or are the latest
+// versions of objects with Prefix in Bucket if Key is empty. The job fails
+// if the CSV object is changed after the job is created, i.e. its ETag.
+type BatchJobManifest struct {
+	Bucket string
+	Key    string `json:",omitempty"`
+	ETag   string `json:",omitempty"`
+	Prefix string `json:",omitempty"`
+}
+
+// Results of keys are written as CSV files into Bucket under
+// "Prefix/job-<id>/results/"
+type BatchJobReport struct {
+	Bucket string
+	Prefix string `json:",omitempty"`
+	Scope  string
+}
+
+// A batch job is processed by one instance at a time, which claims it until
+// LeaseTime and renews the lease after every chunk of keys. Keys are
+// processed at least once, a chunk interrupted is processed again.
+type BatchJob struct {
+	Id          string
+	Status      string
+	Operation   BatchJobOperation
+	Manifest    BatchJobManifest
+	Report      BatchJobReport
+	Concurrency int // keys processed concurrently
+	Total       int64
+	Succeeded   int64
+	Failed      int64
+	// where keys are processed up to, the byte offset into a CSV manifest,
+	// or the last object name for a prefix
+	Marker      string
+	ReportFiles []string
+	LeaseTime   time.Time
+	LastError   string
+	CreateTime  time.Time
+	UpdateTime  time.Time
+}
@@ -23,6 +23,14 @@ type Bucket struct {
 	Policy     policy.Policy
 	Versioning string // actually enum: Disabled/Enabled/Suspended
 	Usage      int64
+	// compression of data written into the bucket, set by admin API
+	Compression  string
+	Notification datatype.Notification
+	Logging      datatype.BucketLoggingStatus
+	Tagging      datatype.Tagging
+	Website      datatype.WebsiteConfiguration
+	Replication  datatype.ReplicationConfiguration
+	Inventory    []datatype.InventoryConfiguration
 }
 
 func (b *Bucket) String() (s string) {
@@ -35,6 +43,13 @@ func (b *Bucket) String() (s string) {
 	s += "Policy: " + fmt.Sprintf("%+v", b.Policy) + "\n"
 	s += "Version: " + b.Versioning + "\n"
 	s += "Usage: " + humanize.Bytes(uint64(b.Usage)) + "\n"
+	s += "Compression: " + b.Compression + "\n"
+	s += "Notification: " + fmt.Sprintf("%+v", b.Notification) + "\n"
+	s += "Logging: " + fmt.Sprintf("%+v", b.Logging) + "\n"
+	s += "Tagging: " + fmt.Sprintf("%+v", b.Tagging) + "\n"
+	s += "Website: " + fmt.Sprintf("%+v", b.Website) + "\n"
+	s += "Replication: " + fmt.Sprintf("%+v", b.Replication) + "\n"
+	s += "Inventory: " + fmt.Sprintf("%+v", b.Inventory) + "\n"
 	return
 }
 
@@ -75,8 +90,16 @@ func (b Bucket) GetUpdateSql() (string, []interface{}) {
 	cors, _ := json.Marshal(b.CORS)
 	lc, _ := json.Marshal(b.LC)
 	bucket_policy, _ := json.Marshal(b.Policy)
-	sql := "update buckets set bucketname=?,acl=?,policy=?,cors=?,lc=?,uid=?,versioning=? where bucketname=?"
-	args := []interface{}{b.Name, acl, bucket_policy, cors, lc, b.OwnerId, b.Versioning, b.Name}
+	notification, _ := json.Marshal(b.Notification)
+	logging, _ := json.Marshal(b.Logging)
+	tagging, _ := json.Marshal(b.Tagging)
+	website, _ := json.Marshal(b.Website)
+	replication, _ := json.Marshal(b.Replication)
+	inventory, _ := json.Marshal(b.Inventory)
+	sql := "update buckets set bucketname=?,acl=?,policy=?,cors=?,lc=?,uid=?,versioning=?,compression=?," +
+		"notification=?,logging=?,tagging=?,website=?,replication=?,inventory=? where bucketname=?"
+	args := []interface{}{b.Name, acl, bucket_policy, cors, lc, b.OwnerId, b.Versioning, b.Compression,
+		notification, logging, tagging, website, replication, inventory, b.Name}
 	return sql, args
 }
 
@@ -85,10 +108,17 @@ func (b Bucket) GetCreateSql() (string, []interface{}) {
 	cors, _ := json.Marshal(b.CORS)
 	lc, _ := json.Marshal(b.LC)
 	bucket_policy, _ := json.Marshal(b.Policy)
+	notification, _ := json.Marshal(b.Notification)
+	logging, _ := json.Marshal(b.Logging)
+	tagging, _ := json.Marshal(b.Tagging)
+	website, _ := json.Marshal(b.Website)
+	replication, _ := json.Marshal(b.Replication)
+	inventory, _ := json.Marshal(b.Inventory)
 	createTime := b.CreateTime.Format(TIME_LAYOUT_TIDB)
 
-	sql := "insert into buckets(bucketname,acl,cors,lc,uid,policy,createtime,usages,versioning) " +
-		"values(?,?,?,?,?,?,?,?,?);"
-	args := []interface{}{b.Name, acl, cors, lc, b.OwnerId, bucket_policy, createTime, b.Usage, b.Versioning}
+	sql := "insert into buckets(bucketname,acl,cors,lc,uid,policy,createtime,usages,versioning,compression," +
+		"notification,logging,tagging,website,replication,inventory) values(?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?);"
+	args := []interface{}{b.Name, acl, cors, lc, b.OwnerId, bucket_policy, createTime, b.Usage, b.Versioning,
+		b.Compression, notification, logging, tagging, website, replication, inventory}
 	return sql, args
 }
@@ -0,0 +1,27 @@
+package types
+
+import "time"
+
+// A RADOS object in which small objects are packed one after another.
+// Deleting packed objects only leaves holes in their containers, which are
+// reclaimed by compaction rewriting live objects into new containers.
+type Container struct {
+	Location   string
+	Pool       string
+	ObjectId   string
+	Size       int64 // end of the last object committed
+	LiveSize   int64 // size of objects not deleted yet
+	Sealed     bool  // no more objects would be packed into it
+	CreateTime time.Time
+}
+
+// Object used to put the whole container into gc
+func (c *Container) GarbageObject() *Object {
+	return &Object{
+		Name:             c.ObjectId,
+		Location:         c.Location,
+		Pool:             c.Pool,
+		ObjectId:         c.ObjectId,
+		LastModifiedTime: time.Now().UTC(),
+	}
+}
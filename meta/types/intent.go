@@ -0,0 +1,28 @@
+package types
+
+import "time"
+
+// A RADOS object about to be written. It is recorded before writing to Ceph
+// and removed in the transaction committing metadata that references the
+// RADOS object, so data left by crashes or failed requests could be found
+// and recycled.
+type WriteIntent struct {
+	Location   string
+	Pool       string
+	ObjectId   string
+	BucketName string // for debugging only
+	ObjectName string
+	CreateTime time.Time
+}
+
+// Object used to put the RADOS object into gc
+func (w *WriteIntent) GarbageObject() *Object {
+	return &Object{
+		BucketName:       w.BucketName,
+		Name:             w.ObjectName,
+		Location:         w.Location,
+		Pool:             w.Pool,
+		ObjectId:         w.ObjectId,
+		LastModifiedTime: time.Now().UTC(),
+	}
+}
@@ -0,0 +1,25 @@
+package types
+
+import "time"
+
+// Data file of an inventory report, as listed in its manifest.json
+type InventoryFile struct {
+	Key         string `json:"key"`
+	Size        int64  `json:"size"`
+	MD5checksum string `json:"MD5checksum"`
+}
+
+// Progress of the report of an inventory configuration. It's saved once a
+// data file is written, so an interrupted report resumes from the object
+// version after (KeyMarker, VersionMarker).
+type InventoryCheckpoint struct {
+	BucketName    string
+	ConfigId      string
+	ReportTime    time.Time // the report of this schedule is in progress or done
+	KeyMarker     string
+	VersionMarker uint64
+	Objects       int64
+	Files         []InventoryFile
+	Done          bool
+	UpdateTime    time.Time
+}
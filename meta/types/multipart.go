@@ -24,10 +24,22 @@ type Part struct {
 	// `multiparts` table to `objects` table
 	Offset               int64
 	Etag                 string
+	Checksum             string // CRC32C in hex
+	ChecksumAlgorithm    string // requested by clients with x-amz-checksum-algorithm
+	ChecksumValue        string // base64 encoded
+	CompressedSize       int64  // 0 if not compressed
 	LastModified         string // time string of format "2006-01-02T15:04:05.000Z"
 	InitializationVector []byte
 }
 
+// Size of data of the part in Ceph
+func (p *Part) StoredSize() int64 {
+	if p.CompressedSize != 0 {
+		return p.CompressedSize
+	}
+	return p.Size
+}
+
 // For scenario only one part is needed to insert
 func (p *Part) GetValues() (values map[string]map[string][]byte, err error) {
 	marshaledPart, err := json.Marshal(p)
@@ -54,6 +66,13 @@ type MultipartMetadata struct {
 	CipherKey     []byte
 	Attrs         map[string]string
 	StorageClass  StorageClass
+	// Checksum requested by x-amz-checksum-algorithm and x-amz-checksum-type
+	ChecksumAlgorithm string
+	ChecksumType      string
+	// compression of the bucket when the upload is initiated
+	Compression string
+	// tags of x-amz-tagging, set to the object completed
+	Tagging datatype.Tagging
 }
 
 type Multipart struct {
@@ -148,9 +167,10 @@ func valuesForParts(parts map[int]*Part) (values map[string][]byte, err error) {
 }
 
 func (p *Part) GetCreateSql(bucketname, objectname, version string) (string, []interface{}) {
-	sql := "insert into objectpart(partnumber,size,objectid,offset,etag,lastmodified,initializationvector,bucketname,objectname,version) " +
-		"values(?,?,?,?,?,?,?,?,?,?)"
-	args := []interface{}{p.PartNumber, p.Size, p.ObjectId, p.Offset, p.Etag, p.LastModified, p.InitializationVector, bucketname, objectname, version}
+	sql := "insert into objectpart(partnumber,size,objectid,offset,etag,lastmodified,initializationvector,bucketname,objectname,version,checksum,checksumalgorithm,checksumvalue,compressedsize) " +
+		"values(?,?,?,?,?,?,?,?,?,?,?,?,?,?)"
+	args := []interface{}{p.PartNumber, p.Size, p.ObjectId, p.Offset, p.Etag, p.LastModified, p.InitializationVector, bucketname, objectname, version,
+		p.Checksum, p.ChecksumAlgorithm, p.ChecksumValue, p.CompressedSize}
 	return sql, args
 }
 
@@ -59,6 +59,27 @@ type CompletePart struct {
 
 	// Entity tag returned when the part was uploaded.
 	ETag string
+
+	// Checksums returned when the part was uploaded, at most one is set.
+	ChecksumCRC32  string
+	ChecksumCRC32C string
+	ChecksumSHA1   string
+	ChecksumSHA256 string
+}
+
+// Returns the checksum of part given, "" if none
+func (p CompletePart) Checksum() (algorithm, value string) {
+	switch {
+	case p.ChecksumCRC32 != "":
+		return datatype.ChecksumAlgorithmCRC32, p.ChecksumCRC32
+	case p.ChecksumCRC32C != "":
+		return datatype.ChecksumAlgorithmCRC32C, p.ChecksumCRC32C
+	case p.ChecksumSHA1 != "":
+		return datatype.ChecksumAlgorithmSHA1, p.ChecksumSHA1
+	case p.ChecksumSHA256 != "":
+		return datatype.ChecksumAlgorithmSHA256, p.ChecksumSHA256
+	}
+	return "", ""
 }
 
 // completedParts - is a collection satisfying sort.Interface.
@@ -5,6 +5,7 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
+	"encoding/base64"
 	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
@@ -12,6 +13,7 @@ import (
 	"io"
 	"math"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/journeymidnight/yig/api/datatype"
@@ -31,14 +33,19 @@ type Object struct {
 	ObjectId         string    // object name in Ceph
 	LastModifiedTime time.Time // in format "2006-01-02T15:04:05.000Z"
 	Etag             string
-	ContentType      string
-	CustomAttributes map[string]string
-	Parts            map[int]*Part
-	PartsIndex       *SimpleIndex
-	ACL              datatype.Acl
-	NullVersion      bool   // if this entry has `null` version
-	DeleteMarker     bool   // if this entry is a delete marker
-	VersionId        string // version cache
+	Checksum         string // CRC32C of the whole object in hex, "" for objects written before checksums
+	// Checksum requested by clients with x-amz-checksum-* headers, base64 encoded,
+	// with "-<parts count>" suffix for composite checksums of multipart objects
+	ChecksumAlgorithm string
+	ChecksumValue     string
+	ContentType       string
+	CustomAttributes  map[string]string
+	Parts             map[int]*Part
+	PartsIndex        *SimpleIndex
+	ACL               datatype.Acl
+	NullVersion       bool   // if this entry has `null` version
+	DeleteMarker      bool   // if this entry is a delete marker
+	VersionId         string // version cache
 	// type of Server Side Encryption, could be "SSE-KMS", "SSE-S3", "SSE-C"(custom), or ""(none),
 	// KMS is not implemented yet
 	SseType string
@@ -49,8 +56,31 @@ type Object struct {
 	// ObjectType include `Normal`, `Appendable`, 'Multipart'
 	Type         int
 	StorageClass StorageClass
+	// compression of data in Ceph, "" for none. `Size` is always the original
+	// size of data, `CompressedSize` is the size stored, including frame index.
+	// For multipart objects, they are recorded in each part.
+	Compression    string
+	CompressedSize int64
+	// Small objects might be packed into a container, i.e. the RADOS object
+	// `ObjectId`, their stored data starts from `PackOffset` of it.
+	Packed     bool
+	PackOffset int64
+	// Data of tiny objects stored in the metadata row, encrypted if SSE is set.
+	// Such objects have no `ObjectId`, see `IsInline`.
+	InlineData []byte
+	Tagging    datatype.Tagging
+	// x-amz-replication-status, PENDING/COMPLETED/FAILED for objects of
+	// buckets with replication rules, REPLICA for replicas, "" for others
+	ReplicationStatus string
+	// restore state of archived objects, see IsReadable
+	Restore *ObjectRestore
 }
 
+const (
+	CompressionSnappy = "snappy"
+	CompressionZstd   = "zstd"
+)
+
 type ObjectType string
 
 const (
@@ -77,6 +107,9 @@ func (o *Object) String() (s string) {
 	s += "Location: " + o.Location + "\n"
 	s += "Pool: " + o.Pool + "\n"
 	s += "Object ID: " + o.ObjectId + "\n"
+	if o.Packed {
+		s += "Pack Offset: " + strconv.FormatInt(o.PackOffset, 10) + "\n"
+	}
 	s += "Last Modified Time: " + o.LastModifiedTime.Format(CREATE_TIME_LAYOUT) + "\n"
 	s += "Version: " + o.VersionId + "\n"
 	s += "Type: " + o.ObjectTypeToString() + "\n"
@@ -211,6 +244,51 @@ func (o *Object) encryptSseKey() (err error) {
 	return nil
 }
 
+// Checksum returned to clients, objects without one requested have CRC32C
+// of the whole object computed by us.
+func (o *Object) GetChecksum() (algorithm, value, checksumType string) {
+	if o.ChecksumAlgorithm != "" {
+		checksumType = datatype.ChecksumTypeFullObject
+		if strings.Contains(o.ChecksumValue, "-") {
+			checksumType = datatype.ChecksumTypeComposite
+		}
+		return o.ChecksumAlgorithm, o.ChecksumValue, checksumType
+	}
+	crc, err := hex.DecodeString(o.Checksum)
+	if err != nil || len(crc) != 4 {
+		return "", "", ""
+	}
+	return datatype.ChecksumAlgorithmCRC32C, base64.StdEncoding.EncodeToString(crc), datatype.ChecksumTypeFullObject
+}
+
+// Returns true if data of the object is stored in `InlineData` instead of Ceph
+func (o *Object) IsInline() bool {
+	return o.Type == ObjectTypeNormal && !o.DeleteMarker && o.ObjectId == "" && len(o.Parts) == 0
+}
+
+// RADOS object identifying data of the object, whose references are counted
+// when the data is shared by copies. It is the first part for multipart objects.
+func (o *Object) DataObjectId() string {
+	if len(o.Parts) == 0 {
+		return o.ObjectId
+	}
+	first := 0
+	for n := range o.Parts {
+		if first == 0 || n < first {
+			first = n
+		}
+	}
+	return o.Parts[first].ObjectId
+}
+
+// Size of data of the object in Ceph
+func (o *Object) StoredSize() int64 {
+	if o.Compression != "" {
+		return o.CompressedSize
+	}
+	return o.Size
+}
+
 func (o *Object) GetVersionId() string {
 	if o.NullVersion {
 		return "null"
@@ -225,23 +303,43 @@ func (o *Object) GetVersionId() string {
 
 //Tidb related function
 
+// Columns of `objects` in the order of GetCreateSql, listed by name so rows
+// are read and written the same way however columns are added by migrations
+const ObjectColumns = "bucketname,name,version,location,pool,ownerid,size,objectid,lastmodifiedtime,etag," +
+	"contenttype,customattributes,acl,nullversion,deletemarker,ssetype,encryptionkey,initializationvector," +
+	"type,storageclass,checksum,checksumalgorithm,checksumvalue,compression,compressedsize,packed,packoffset," +
+	"inlinedata,tagging,replicationstatus,restore"
+
 func (o *Object) GetCreateSql() (string, []interface{}) {
 	version := math.MaxUint64 - uint64(o.LastModifiedTime.UnixNano())
 	customAttributes, _ := json.Marshal(o.CustomAttributes)
 	acl, _ := json.Marshal(o.ACL)
+	tagging, _ := json.Marshal(o.Tagging)
 	lastModifiedTime := o.LastModifiedTime.Format(TIME_LAYOUT_TIDB)
-	sql := "insert into objects values(?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)"
+	sql := "insert into objects(" + ObjectColumns + ") values(?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)"
 	args := []interface{}{o.BucketName, o.Name, version, o.Location, o.Pool, o.OwnerId, o.Size, o.ObjectId,
 		lastModifiedTime, o.Etag, o.ContentType, customAttributes, acl, o.NullVersion, o.DeleteMarker,
-		o.SseType, o.EncryptionKey, o.InitializationVector, o.Type, o.StorageClass}
+		o.SseType, o.EncryptionKey, o.InitializationVector, o.Type, o.StorageClass, o.Checksum,
+		o.ChecksumAlgorithm, o.ChecksumValue, o.Compression, o.CompressedSize, o.Packed, o.PackOffset,
+		o.InlineData, tagging, o.ReplicationStatus, o.restoreJson()}
 	return sql, args
 }
 
+// NULL if not restored
+func (o *Object) restoreJson() interface{} {
+	if o.Restore == nil {
+		return nil
+	}
+	restore, _ := json.Marshal(o.Restore)
+	return restore
+}
+
 func (o *Object) GetAppendSql() (string, []interface{}) {
 	version := math.MaxUint64 - uint64(o.LastModifiedTime.UnixNano())
 	lastModifiedTime := o.LastModifiedTime.Format(TIME_LAYOUT_TIDB)
-	sql := "update objects set lastmodifiedtime=?, size=?, version=? where bucketname=? and name=?"
-	args := []interface{}{lastModifiedTime, o.Size, version, o.BucketName, o.Name}
+	// data is appended to `objectid` in `location`, which might be moved by cluster drain meanwhile
+	sql := "update objects set lastmodifiedtime=?, size=?, version=?, checksum=? where bucketname=? and name=? and location=? and objectid=?"
+	args := []interface{}{lastModifiedTime, o.Size, version, o.Checksum, o.BucketName, o.Name, o.Location, o.ObjectId}
 	return sql, args
 }
 
@@ -253,11 +351,34 @@ func (o *Object) GetUpdateAclSql() (string, []interface{}) {
 	return sql, args
 }
 
+func (o *Object) GetUpdateTaggingSql() (string, []interface{}) {
+	version := math.MaxUint64 - uint64(o.LastModifiedTime.UnixNano())
+	tagging, _ := json.Marshal(o.Tagging)
+	sql := "update objects set tagging=? where bucketname=? and name=? and version=?"
+	args := []interface{}{tagging, o.BucketName, o.Name, version}
+	return sql, args
+}
+
+func (o *Object) GetUpdateReplicationStatusSql() (string, []interface{}) {
+	version := math.MaxUint64 - uint64(o.LastModifiedTime.UnixNano())
+	sql := "update objects set replicationstatus=? where bucketname=? and name=? and version=?"
+	args := []interface{}{o.ReplicationStatus, o.BucketName, o.Name, version}
+	return sql, args
+}
+
+func (o *Object) GetUpdateRestoreSql() (string, []interface{}) {
+	version := math.MaxUint64 - uint64(o.LastModifiedTime.UnixNano())
+	sql := "update objects set restore=? where bucketname=? and name=? and version=?"
+	args := []interface{}{o.restoreJson(), o.BucketName, o.Name, version}
+	return sql, args
+}
+
 func (o *Object) GetUpdateAttrsSql() (string, []interface{}) {
 	version := math.MaxUint64 - uint64(o.LastModifiedTime.UnixNano())
 	attrs, _ := json.Marshal(o.CustomAttributes)
-	sql := "update objects set customattributes =?, storageclass=? where bucketname=? and name=? and version=?"
-	args := []interface{}{attrs, o.StorageClass, o.BucketName, o.Name, version}
+	tagging, _ := json.Marshal(o.Tagging)
+	sql := "update objects set customattributes =?, storageclass=?, tagging=? where bucketname=? and name=? and version=?"
+	args := []interface{}{attrs, o.StorageClass, tagging, o.BucketName, o.Name, version}
 	return sql, args
 
 }
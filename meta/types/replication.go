@@ -0,0 +1,67 @@
+package types
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	ReplicationStatusPending   = "PENDING"
+	ReplicationStatusCompleted = "COMPLETED"
+	ReplicationStatusFailed    = "FAILED"
+	ReplicationStatusReplica   = "REPLICA"
+)
+
+const (
+	ReplicationOperationPut    = "Put"
+	ReplicationOperationDelete = "Delete"
+)
+
+// An object version to replicate, or a delete marker to replicate as a
+// delete of the destination object. Tasks are removed once replicated, or
+// given up after too many retries.
+type ReplicationTask struct {
+	BucketName string
+	ObjectName string
+	Version    uint64 // version of the object or delete marker in objects table
+	Operation  string
+	Retries    int
+	// tasks are processed after NextTime, which is pushed forward when a
+	// task is claimed by a worker or failed
+	NextTime   time.Time
+	LastError  string
+	CreateTime time.Time
+}
+
+type ReplicationBacklog struct {
+	Pending int64 // tasks never tried
+	Failing int64 // tasks failed at least once and waiting for retry
+}
+
+// Task to replicate `object` put into `bucket`, nil if no rule of the bucket
+// matches it. Object versions to replicate are marked PENDING, so they are
+// saved along with the task. Replicas are not replicated again.
+func NewReplicationTask(bucket *Bucket, object *Object) *ReplicationTask {
+	if len(bucket.Replication.Rules) == 0 || object.ReplicationStatus == ReplicationStatusReplica {
+		return nil
+	}
+	task := &ReplicationTask{
+		BucketName: bucket.Name,
+		ObjectName: object.Name,
+		Version:    math.MaxUint64 - uint64(object.LastModifiedTime.UnixNano()),
+		Operation:  ReplicationOperationPut,
+	}
+	if object.DeleteMarker {
+		rule := bucket.Replication.RuleFor(object.Name, nil)
+		if rule == nil || !rule.ReplicatesDeleteMarkers() {
+			return nil
+		}
+		task.Operation = ReplicationOperationDelete
+		return task
+	}
+	if bucket.Replication.RuleFor(object.Name, &object.Tagging) == nil {
+		return nil
+	}
+	object.ReplicationStatus = ReplicationStatusPending
+	return task
+}
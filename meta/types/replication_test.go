@@ -0,0 +1,70 @@
+package types
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/journeymidnight/yig/api/datatype"
+)
+
+func TestNewReplicationTask(t *testing.T) {
+	replication, err := datatype.ReplicationFromXml([]byte(`<ReplicationConfiguration>
+  <Rule>
+    <Status>Enabled</Status>
+    <Priority>1</Priority>
+    <Filter><Prefix>logs/</Prefix></Filter>
+    <DeleteMarkerReplication><Status>Enabled</Status></DeleteMarkerReplication>
+    <Destination><Bucket>arn:aws:s3:::backup</Bucket></Destination>
+  </Rule>
+  <Rule>
+    <Status>Enabled</Status>
+    <Priority>2</Priority>
+    <Filter><Prefix>data/</Prefix></Filter>
+    <Destination><Bucket>arn:aws:s3:::backup</Bucket></Destination>
+  </Rule>
+</ReplicationConfiguration>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bucket := &Bucket{Name: "source", Replication: replication}
+	now := time.Now().UTC()
+
+	object := &Object{BucketName: "source", Name: "logs/a", LastModifiedTime: now}
+	task := NewReplicationTask(bucket, object)
+	if task == nil || task.Operation != ReplicationOperationPut || task.ObjectName != "logs/a" ||
+		task.Version != math.MaxUint64-uint64(now.UnixNano()) {
+		t.Fatal("wrong task of object version:", task)
+	}
+	if object.ReplicationStatus != ReplicationStatusPending {
+		t.Error("object version should be marked PENDING:", object.ReplicationStatus)
+	}
+
+	for _, c := range []struct {
+		object    *Object
+		operation string
+	}{
+		{&Object{Name: "other/a"}, ""},
+		{&Object{Name: "logs/a", ReplicationStatus: ReplicationStatusReplica}, ""},
+		{&Object{Name: "logs/a", DeleteMarker: true}, ReplicationOperationDelete},
+		{&Object{Name: "data/a", DeleteMarker: true}, ""},
+	} {
+		task = NewReplicationTask(bucket, c.object)
+		if c.operation == "" {
+			if task != nil {
+				t.Error("unexpected task of", c.object.Name, ":", task)
+			}
+			continue
+		}
+		if task == nil || task.Operation != c.operation {
+			t.Error("wrong task of", c.object.Name, ":", task)
+		}
+		if c.object.ReplicationStatus != "" {
+			t.Error("delete markers should not be marked:", c.object.ReplicationStatus)
+		}
+	}
+
+	if NewReplicationTask(&Bucket{Name: "source"}, &Object{Name: "logs/a"}) != nil {
+		t.Error("no task without replication rules")
+	}
+}
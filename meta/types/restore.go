@@ -0,0 +1,79 @@
+package types
+
+import "time"
+
+// Objects of archive storage classes are stored in `archive_pool`, and could
+// not be read until restored by RestoreObject, which queues a task into the
+// `restore` table. Restore workers copy data of the object into a standard
+// pool as a temporary copy, which is read instead until ExpiryDate, then
+// put into gc by the same task.
+func (s StorageClass) IsArchive() bool {
+	return s == ObjectStorageClassGlacier || s == ObjectStorageClassDeepArchive
+}
+
+// Restore state of an archived object, nil if never restored or expired
+type ObjectRestore struct {
+	Ongoing    bool // the copy is being made
+	Days       int  // days the copy is kept since restored
+	Tier       string
+	ExpiryDate time.Time `json:",omitempty"`
+	// the temporary copy, its parts have the same numbers as the object
+	Location      string         `json:",omitempty"`
+	Pool          string         `json:",omitempty"`
+	ObjectId      string         `json:",omitempty"`
+	PartObjectIds map[int]string `json:",omitempty"`
+}
+
+// An object version to restore, or whose restored copy to expire at NextTime
+type RestoreTask struct {
+	BucketName string
+	ObjectName string
+	Version    uint64 // version of the object in objects table
+	Retries    int
+	// tasks are processed after NextTime, which is pushed forward when a
+	// task is claimed by a worker or failed
+	NextTime   time.Time
+	LastError  string
+	CreateTime time.Time
+}
+
+func (o *Object) IsArchived() bool {
+	return o.StorageClass.IsArchive()
+}
+
+// Returns false for archived objects not restored
+func (o *Object) IsReadable() bool {
+	if !o.IsArchived() {
+		return true
+	}
+	return o.Restore != nil && !o.Restore.Ongoing && time.Now().Before(o.Restore.ExpiryDate)
+}
+
+// Returns true if the restored copy of the object has data in Ceph
+func (o *Object) HasRestoredCopy() bool {
+	return o.Restore != nil && (o.Restore.ObjectId != "" || len(o.Restore.PartObjectIds) != 0)
+}
+
+// The object reading data from its restored copy instead, inline objects
+// have no copy. Only fields locating data are changed.
+func (o *Object) RestoredCopy() *Object {
+	if !o.HasRestoredCopy() {
+		return o
+	}
+	restored := *o
+	restored.Location = o.Restore.Location
+	restored.Pool = o.Restore.Pool
+	restored.ObjectId = o.Restore.ObjectId
+	// packed data is copied out of its container
+	restored.Packed = false
+	restored.PackOffset = 0
+	if len(o.Parts) != 0 {
+		restored.Parts = make(map[int]*Part, len(o.Parts))
+		for n, p := range o.Parts {
+			part := *p
+			part.ObjectId = o.Restore.PartObjectIds[n]
+			restored.Parts[n] = &part
+		}
+	}
+	return &restored
+}
@@ -0,0 +1,93 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	redigo "github.com/gomodule/redigo/redis"
+	"github.com/journeymidnight/yig/helper"
+)
+
+const (
+	SingleNodeMode = iota
+	SentinelMode
+	ClusterMode
+)
+
+// Client hides how YIG connects to redis, i.e. a single node, a master
+// found by sentinels, or a redis cluster.
+type Client interface {
+	// Send a command to the node which serves `key`. An empty `key`
+	// means any node, e.g. for PUBLISH.
+	Do(ctx context.Context, key string, commandName string, args ...interface{}) (reply interface{}, err error)
+	// Get a connection to one node, caller should close it after use.
+	GetConn(ctx context.Context) (redigo.Conn, error)
+	// Ping all nodes serving data
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+func dialOptions() []redigo.DialOption {
+	options := []redigo.DialOption{
+		redigo.DialReadTimeout(time.Duration(helper.CONFIG.RedisReadTimeout) * time.Second),
+		redigo.DialConnectTimeout(time.Duration(helper.CONFIG.RedisConnectTimeout) * time.Second),
+		redigo.DialWriteTimeout(time.Duration(helper.CONFIG.RedisWriteTimeout) * time.Second),
+		redigo.DialKeepAlive(time.Duration(helper.CONFIG.RedisKeepAlive) * time.Second),
+	}
+
+	if helper.CONFIG.RedisPassword != "" {
+		options = append(options, redigo.DialPassword(helper.CONFIG.RedisPassword))
+	}
+	return options
+}
+
+func newPool(dial func() (redigo.Conn, error)) *redigo.Pool {
+	return &redigo.Pool{
+		MaxIdle:     helper.CONFIG.RedisPoolMaxIdle,
+		IdleTimeout: time.Duration(helper.CONFIG.RedisPoolIdleTimeout) * time.Second,
+		Dial:        dial,
+	}
+}
+
+func doWithPool(ctx context.Context, pool *redigo.Pool,
+	commandName string, args ...interface{}) (reply interface{}, err error) {
+
+	c, err := pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	return c.Do(commandName, args...)
+}
+
+type singleNodeClient struct {
+	pool *redigo.Pool
+}
+
+func newSingleNodeClient(address string) *singleNodeClient {
+	options := dialOptions()
+	return &singleNodeClient{
+		pool: newPool(func() (redigo.Conn, error) {
+			return redigo.Dial("tcp", address, options...)
+		}),
+	}
+}
+
+func (s *singleNodeClient) Do(ctx context.Context, key string,
+	commandName string, args ...interface{}) (reply interface{}, err error) {
+
+	return doWithPool(ctx, s.pool, commandName, args...)
+}
+
+func (s *singleNodeClient) GetConn(ctx context.Context) (redigo.Conn, error) {
+	return s.pool.GetContext(ctx)
+}
+
+func (s *singleNodeClient) Ping(ctx context.Context) error {
+	_, err := doWithPool(ctx, s.pool, "PING")
+	return err
+}
+
+func (s *singleNodeClient) Close() error {
+	return s.pool.Close()
+}
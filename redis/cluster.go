@@ -0,0 +1,297 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	redigo "github.com/gomodule/redigo/redis"
+	"github.com/journeymidnight/yig/helper"
+)
+
+const (
+	CLUSTER_SLOT_NUMBER  = 16384
+	CLUSTER_MAX_REDIRECT = 5
+)
+
+// clusterClient sends every command to the master serving the slot of its
+// key, following MOVED and ASK redirections during resharding.
+type clusterClient struct {
+	seeds   []string
+	options []redigo.DialOption
+
+	mutex sync.RWMutex
+	slots [CLUSTER_SLOT_NUMBER]string // slot -> master address
+	pools map[string]*redigo.Pool     // address -> pool
+
+	refreshing sync.Mutex
+}
+
+func newClusterClient(seeds []string) (*clusterClient, error) {
+	if len(seeds) == 0 {
+		return nil, errors.New("redis cluster addresses must be set")
+	}
+	c := &clusterClient{
+		seeds:   seeds,
+		options: dialOptions(),
+		pools:   make(map[string]*redigo.Pool),
+	}
+	err := c.refresh()
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// CRC16-CCITT (XMODEM) as used by redis cluster
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc = crc << 1
+			}
+		}
+	}
+	return crc
+}
+
+// Slot of `key`, only the part inside the first non-empty "{...}" is hashed
+func keySlot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return int(crc16(key)) % CLUSTER_SLOT_NUMBER
+}
+
+// Must be called with c.mutex held
+func (c *clusterClient) poolLocked(addr string) *redigo.Pool {
+	pool, ok := c.pools[addr]
+	if !ok {
+		options := c.options
+		pool = newPool(func() (redigo.Conn, error) {
+			return redigo.Dial("tcp", addr, options...)
+		})
+		c.pools[addr] = pool
+	}
+	return pool
+}
+
+func (c *clusterClient) getPool(addr string) *redigo.Pool {
+	c.mutex.RLock()
+	pool, ok := c.pools[addr]
+	c.mutex.RUnlock()
+	if ok {
+		return pool
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.poolLocked(addr)
+}
+
+// Reload the slot map with CLUSTER SLOTS from any known node
+func (c *clusterClient) refresh() (err error) {
+	c.refreshing.Lock()
+	defer c.refreshing.Unlock()
+
+	c.mutex.RLock()
+	candidates := make([]string, 0, len(c.pools)+len(c.seeds))
+	for addr := range c.pools {
+		candidates = append(candidates, addr)
+	}
+	c.mutex.RUnlock()
+	candidates = append(candidates, c.seeds...)
+
+	for _, addr := range candidates {
+		var reply []interface{}
+		reply, err = redigo.Values(doWithPool(context.Background(), c.getPool(addr), "CLUSTER", "SLOTS"))
+		if err != nil {
+			helper.Logger.Println(5, "Failed to get slots from redis", addr, "err:", err)
+			continue
+		}
+		var slots [CLUSTER_SLOT_NUMBER]string
+		slots, err = parseClusterSlots(reply)
+		if err != nil {
+			helper.Logger.Println(5, "Bad reply of CLUSTER SLOTS from redis", addr, "err:", err)
+			continue
+		}
+		c.mutex.Lock()
+		c.slots = slots
+		for _, master := range slots {
+			if master != "" {
+				c.poolLocked(master)
+			}
+		}
+		c.mutex.Unlock()
+		return nil
+	}
+	if err == nil {
+		err = errors.New("no redis cluster node available")
+	}
+	return err
+}
+
+// Each entry of the reply is [start, end, [ip, port, id], replicas...]
+func parseClusterSlots(reply []interface{}) (slots [CLUSTER_SLOT_NUMBER]string, err error) {
+	for _, r := range reply {
+		entry, err := redigo.Values(r, nil)
+		if err != nil || len(entry) < 3 {
+			return slots, errors.New("bad slot range")
+		}
+		start, err := redigo.Int(entry[0], nil)
+		if err != nil {
+			return slots, err
+		}
+		end, err := redigo.Int(entry[1], nil)
+		if err != nil {
+			return slots, err
+		}
+		master, err := redigo.Values(entry[2], nil)
+		if err != nil || len(master) < 2 {
+			return slots, errors.New("bad master of slot range")
+		}
+		ip, err := redigo.String(master[0], nil)
+		if err != nil {
+			return slots, err
+		}
+		port, err := redigo.Int(master[1], nil)
+		if err != nil {
+			return slots, err
+		}
+		if start < 0 || end >= CLUSTER_SLOT_NUMBER || start > end {
+			return slots, errors.New("bad slot range")
+		}
+		addr := net.JoinHostPort(ip, strconv.Itoa(port))
+		for i := start; i <= end; i++ {
+			slots[i] = addr
+		}
+	}
+	return slots, nil
+}
+
+func (c *clusterClient) anyAddr() string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	for _, addr := range c.slots {
+		if addr != "" {
+			return addr
+		}
+	}
+	return c.seeds[0]
+}
+
+func (c *clusterClient) addrOfKey(key string) string {
+	if key == "" {
+		return c.anyAddr()
+	}
+	c.mutex.RLock()
+	addr := c.slots[keySlot(key)]
+	c.mutex.RUnlock()
+	if addr == "" {
+		return c.anyAddr()
+	}
+	return addr
+}
+
+// Parse "MOVED <slot> <addr>" or "ASK <slot> <addr>"
+func parseRedirect(err error) (ask bool, addr string, ok bool) {
+	e, isRedisError := err.(redigo.Error)
+	if !isRedisError {
+		return false, "", false
+	}
+	fields := strings.Fields(string(e))
+	if len(fields) != 3 {
+		return false, "", false
+	}
+	switch fields[0] {
+	case "MOVED":
+		return false, fields[2], true
+	case "ASK":
+		return true, fields[2], true
+	}
+	return false, "", false
+}
+
+func (c *clusterClient) doAsking(ctx context.Context, addr string,
+	commandName string, args ...interface{}) (reply interface{}, err error) {
+
+	conn, err := c.getPool(addr).GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	_, err = conn.Do("ASKING")
+	if err != nil {
+		return nil, err
+	}
+	return conn.Do(commandName, args...)
+}
+
+func (c *clusterClient) Do(ctx context.Context, key string,
+	commandName string, args ...interface{}) (reply interface{}, err error) {
+
+	addr := c.addrOfKey(key)
+	ask := false
+	for i := 0; i <= CLUSTER_MAX_REDIRECT; i++ {
+		if ask {
+			reply, err = c.doAsking(ctx, addr, commandName, args...)
+		} else {
+			reply, err = doWithPool(ctx, c.getPool(addr), commandName, args...)
+		}
+		var redirected bool
+		ask, addr, redirected = parseRedirect(err)
+		if !redirected {
+			if _, isRedisError := err.(redigo.Error); err != nil && !isRedisError {
+				// node might be down and replaced by a replica after failover
+				go c.refresh()
+			}
+			return reply, err
+		}
+		if !ask {
+			// slot has been migrated permanently, reload the whole map
+			helper.ErrorIf(c.refresh(), "Failed to refresh redis cluster slots.")
+		}
+	}
+	return reply, err
+}
+
+func (c *clusterClient) GetConn(ctx context.Context) (redigo.Conn, error) {
+	return c.getPool(c.anyAddr()).GetContext(ctx)
+}
+
+func (c *clusterClient) Ping(ctx context.Context) error {
+	c.mutex.RLock()
+	masters := make(map[string]bool)
+	for _, addr := range c.slots {
+		if addr != "" {
+			masters[addr] = true
+		}
+	}
+	c.mutex.RUnlock()
+	for addr := range masters {
+		_, err := doWithPool(ctx, c.getPool(addr), "PING")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *clusterClient) Close() (err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, pool := range c.pools {
+		if e := pool.Close(); e != nil {
+			err = e
+		}
+	}
+	return err
+}
@@ -0,0 +1,67 @@
+package redis
+
+import (
+	"errors"
+	"testing"
+
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+func TestKeySlot(t *testing.T) {
+	if crc := crc16("123456789"); crc != 0x31C3 {
+		t.Fatalf("crc16 of 123456789 is %x, expect 31c3", crc)
+	}
+	cases := map[string]int{
+		"foo":       12182,
+		"bar":       5061,
+		"{foo}.bar": 12182,
+		// empty hash tag, the whole key is hashed
+		"foo{}{bar}": int(crc16("foo{}{bar}")) % CLUSTER_SLOT_NUMBER,
+		"{}foo":      int(crc16("{}foo")) % CLUSTER_SLOT_NUMBER,
+		// only the first "{...}" counts
+		"foo{{bar}}zap": int(crc16("{bar")) % CLUSTER_SLOT_NUMBER,
+	}
+	for key, slot := range cases {
+		if s := keySlot(key); s != slot {
+			t.Errorf("slot of %s is %d, expect %d", key, s, slot)
+		}
+	}
+	if keySlot("{user1000}.following") != keySlot("{user1000}.followers") {
+		t.Error("keys with the same hash tag should be in the same slot")
+	}
+}
+
+func TestParseClusterSlots(t *testing.T) {
+	reply := []interface{}{
+		[]interface{}{int64(0), int64(5460),
+			[]interface{}{[]byte("10.0.0.1"), int64(7000), []byte("id1")},
+			[]interface{}{[]byte("10.0.0.4"), int64(7003), []byte("id4")}},
+		[]interface{}{int64(5461), int64(16383),
+			[]interface{}{[]byte("10.0.0.2"), int64(7001), []byte("id2")}},
+	}
+	slots, err := parseClusterSlots(reply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slots[0] != "10.0.0.1:7000" || slots[5460] != "10.0.0.1:7000" ||
+		slots[5461] != "10.0.0.2:7001" || slots[16383] != "10.0.0.2:7001" {
+		t.Error("wrong slot map:", slots[0], slots[5460], slots[5461], slots[16383])
+	}
+}
+
+func TestParseRedirect(t *testing.T) {
+	ask, addr, ok := parseRedirect(redigo.Error("MOVED 3999 127.0.0.1:6381"))
+	if !ok || ask || addr != "127.0.0.1:6381" {
+		t.Error("failed to parse MOVED")
+	}
+	ask, addr, ok = parseRedirect(redigo.Error("ASK 3999 127.0.0.1:6381"))
+	if !ok || !ask || addr != "127.0.0.1:6381" {
+		t.Error("failed to parse ASK")
+	}
+	if _, _, ok = parseRedirect(redigo.Error("ERR unknown command")); ok {
+		t.Error("ERR should not be a redirection")
+	}
+	if _, _, ok = parseRedirect(errors.New("MOVED 3999 127.0.0.1:6381")); ok {
+		t.Error("only redis error could be a redirection")
+	}
+}
@@ -8,15 +8,14 @@ import (
 	"strings"
 
 	"context"
+	"github.com/cep21/circuit"
 	redigo "github.com/gomodule/redigo/redis"
 	"github.com/journeymidnight/yig/circuitbreak"
 	"github.com/journeymidnight/yig/helper"
-	"time"
-	"github.com/cep21/circuit"
 )
 
 var (
-	redisPool *redigo.Pool
+	redisClient  Client
 	CacheCircuit *circuit.Circuit
 )
 
@@ -35,76 +34,64 @@ func (r RedisDatabase) InvalidQueue() string {
 }
 
 const (
-	UserTable    RedisDatabase = iota
-	BucketTable  
-	ObjectTable  
-	FileTable    
-	ClusterTable 
+	UserTable RedisDatabase = iota
+	BucketTable
+	ObjectTable
+	FileTable
+	ClusterTable
 )
 
 var MetadataTables = []RedisDatabase{UserTable, BucketTable, ObjectTable, ClusterTable}
 var DataTables = []RedisDatabase{FileTable}
 
 func Initialize() {
-
-	options := []redigo.DialOption{
-		redigo.DialReadTimeout(time.Duration(helper.CONFIG.RedisReadTimeout) * time.Second),
-		redigo.DialConnectTimeout(time.Duration(helper.CONFIG.RedisConnectTimeout) * time.Second),
-		redigo.DialWriteTimeout(time.Duration(helper.CONFIG.RedisWriteTimeout) * time.Second),
-		redigo.DialKeepAlive(time.Duration(helper.CONFIG.RedisKeepAlive) * time.Second),
+	var err error
+	switch helper.CONFIG.RedisMode {
+	case SentinelMode:
+		redisClient, err = newSentinelClient(helper.CONFIG.RedisSentinelAddresses,
+			helper.CONFIG.RedisSentinelMaster)
+	case ClusterMode:
+		redisClient, err = newClusterClient(helper.CONFIG.RedisClusterAddresses)
+	default:
+		redisClient = newSingleNodeClient(helper.CONFIG.RedisAddress)
 	}
-
-	if helper.CONFIG.RedisPassword != "" {
-		options = append(options, redigo.DialPassword(helper.CONFIG.RedisPassword))
-	}
-
-	df := func() (redigo.Conn, error) {
-		c, err := redigo.Dial("tcp", helper.CONFIG.RedisAddress, options...)
-		if err != nil {
-			return nil, err
-		}
-		return c, nil
+	if err != nil {
+		panic("Failed to connect redis: " + err.Error())
 	}
-
 	CacheCircuit = circuitbreak.NewCacheCircuit()
-	redisPool = &redigo.Pool{
-			MaxIdle:     helper.CONFIG.RedisPoolMaxIdle,
-			IdleTimeout: time.Duration(helper.CONFIG.RedisPoolIdleTimeout) * time.Second,
-			// Other pool configuration not shown in this example.
-			Dial: df,
-	}
 }
 
-func Pool() *redigo.Pool {
-	return redisPool
+func Initialized() bool {
+	return redisClient != nil
 }
 
 func Close() {
-	err := redisPool.Close()
+	err := redisClient.Close()
 	if err != nil {
 		helper.ErrorIf(err, "Cannot close redis pool.")
 	}
 }
 
 func GetClient(ctx context.Context) (redigo.Conn, error) {
-	return redisPool.GetContext(ctx)
+	return redisClient.GetConn(ctx)
+}
+
+// Ping all redis nodes
+func Ping(ctx context.Context) error {
+	return redisClient.Ping(ctx)
 }
 
 func Remove(table RedisDatabase, key string) (err error) {
 	return CacheCircuit.Execute(
 		context.Background(),
 		func(ctx context.Context) (err error) {
-			c, err := GetClient(ctx)
-			if err != nil {
-				return err
-			}
-			defer c.Close()
-			hashkey,err := HashSum(key)
+			hashkey, err := HashSum(key)
 			if err != nil {
 				return err
 			}
 			// Use table.String() + hashkey as Redis key
-			_, err = c.Do("DEL", table.String()+hashkey)
+			redisKey := table.String() + hashkey
+			_, err = redisClient.Do(ctx, redisKey, "DEL", redisKey)
 			if err == redigo.ErrNil {
 				return nil
 			}
@@ -119,21 +106,18 @@ func Set(table RedisDatabase, key string, value interface{}) (err error) {
 	return CacheCircuit.Execute(
 		context.Background(),
 		func(ctx context.Context) (err error) {
-			c, err := GetClient(ctx)
-			if err != nil {
-				return err
-			}
-			defer c.Close()
 			encodedValue, err := helper.MsgPackMarshal(value)
 			if err != nil {
 				return err
 			}
-			hashkey,err := HashSum(key)
+			hashkey, err := HashSum(key)
 			if err != nil {
 				return err
 			}
 			// Use table.String() + hashkey as Redis key. Set expire time to 30s.
-			r, err := redigo.String(c.Do("SET", table.String()+hashkey, string(encodedValue), "EX", 30))
+			redisKey := table.String() + hashkey
+			r, err := redigo.String(redisClient.Do(ctx, redisKey,
+				"SET", redisKey, string(encodedValue), "EX", 30))
 			if err == redigo.ErrNil {
 				return nil
 			}
@@ -145,22 +129,47 @@ func Set(table RedisDatabase, key string, value interface{}) (err error) {
 
 }
 
+// Set raw bytes without msgpack encoding, `expire` is in seconds.
+// If `nx` is true, the value is only set when the key does not exist.
+func SetRaw(table RedisDatabase, key string, value []byte, expire int, nx bool) (err error) {
+	return CacheCircuit.Execute(
+		context.Background(),
+		func(ctx context.Context) (err error) {
+			hashkey, err := HashSum(key)
+			if err != nil {
+				return err
+			}
+			// Use table.String() + hashkey as Redis key
+			redisKey := table.String() + hashkey
+			args := []interface{}{redisKey, value, "EX", expire}
+			if nx {
+				args = append(args, "NX")
+			}
+			r, err := redigo.String(redisClient.Do(ctx, redisKey, "SET", args...))
+			if err == redigo.ErrNil {
+				// key already exists when `nx` is set
+				return nil
+			}
+			helper.ErrorIf(err, "Cmd: %s. Key: %s. Reply: %s.", "SET", table.String()+key, r)
+			return err
+		},
+		nil,
+	)
+}
+
 func Get(table RedisDatabase, key string,
 	unmarshal func([]byte) (interface{}, error)) (value interface{}, err error) {
 	var encodedValue []byte
 	err = CacheCircuit.Execute(
 		context.Background(),
 		func(ctx context.Context) (err error) {
-			c, err := GetClient(ctx)
-			if err != nil {
-				return err
-			}
-			hashkey,err := HashSum(key)
+			hashkey, err := HashSum(key)
 			if err != nil {
 				return err
 			}
 			// Use table.String() + hashkey as Redis key
-			encodedValue, err = redigo.Bytes(c.Do("GET", table.String()+hashkey))
+			redisKey := table.String() + hashkey
+			encodedValue, err = redigo.Bytes(redisClient.Do(ctx, redisKey, "GET", redisKey))
 			if err != nil {
 				if err == redigo.ErrNil {
 					return nil
@@ -188,16 +197,13 @@ func GetBytes(key string, start int64, end int64) ([]byte, error) {
 	err := CacheCircuit.Execute(
 		context.Background(),
 		func(ctx context.Context) (err error) {
-			c, err := GetClient(ctx)
-			if err != nil {
-				return err
-			}
-			hashkey,err := HashSum(key)
+			hashkey, err := HashSum(key)
 			if err != nil {
 				return err
 			}
 			// Use table.String() + hashkey as Redis key
-			value, err = redigo.Bytes(c.Do("GETRANGE", FileTable.String()+hashkey, start, end))
+			redisKey := FileTable.String() + hashkey
+			value, err = redigo.Bytes(redisClient.Do(ctx, redisKey, "GETRANGE", redisKey, start, end))
 			if err != nil {
 				if err == redigo.ErrNil {
 					return nil
@@ -214,50 +220,43 @@ func GetBytes(key string, start int64, end int64) ([]byte, error) {
 	return value, nil
 }
 
-// Set file bytes
-func SetBytes(key string, value []byte) (err error) {
+// Set file bytes, `expire` is in seconds
+func SetBytes(key string, value []byte, expire int) (err error) {
 	return CacheCircuit.Execute(
 		context.Background(),
 		func(ctx context.Context) (err error) {
-			c, err := GetClient(ctx)
-			if err != nil {
-				return err
-			}
-			hashkey,err := HashSum(key)
+			hashkey, err := HashSum(key)
 			if err != nil {
 				return err
 			}
 			// Use table.String() + hashkey as Redis key
-			r, err := redigo.String(c.Do("SET", FileTable.String()+hashkey, value))
+			redisKey := FileTable.String() + hashkey
+			r, err := redigo.String(redisClient.Do(ctx, redisKey, "SET", redisKey, value, "EX", expire))
 			if err == redigo.ErrNil {
 				return nil
 			}
-			helper.ErrorIf(err, "Cmd: %s. Key: %s. Value: %s. Reply: %s.", "SET", FileTable.String()+key, string(value), r)
+			helper.ErrorIf(err, "Cmd: %s. Key: %s. Length: %d. Reply: %s.", "SET", FileTable.String()+key, len(value), r)
 			return err
 		},
 		nil,
 	)
 }
 
-// Publish the invalid message to other YIG instances through Redis
+// Publish the invalid message to other YIG instances through Redis.
+// In cluster mode messages are broadcast to all nodes by redis itself.
 func Invalid(table RedisDatabase, key string) (err error) {
 	return CacheCircuit.Execute(
 		context.Background(),
 		func(ctx context.Context) (err error) {
-			c, err := GetClient(ctx)
-			if err != nil {
-				return err
-			}
-			hashkey,err := HashSum(key)
+			hashkey, err := HashSum(key)
 			if err != nil {
 				return err
 			}
-			// Use table.String() + hashkey as Redis key
-			r, err := redigo.String(c.Do("PUBLISH", table.InvalidQueue(), hashkey))
+			r, err := redigo.Int(redisClient.Do(ctx, "", "PUBLISH", table.InvalidQueue(), hashkey))
 			if err == redigo.ErrNil {
 				return nil
 			}
-			helper.ErrorIf(err, "Cmd: %s. Queue: %s. Key: %s. Reply: %s.", "PUBLISH", table.InvalidQueue(), FileTable.String()+key, r)
+			helper.ErrorIf(err, "Cmd: %s. Queue: %s. Key: %s. Reply: %d.", "PUBLISH", table.InvalidQueue(), FileTable.String()+key, r)
 			return err
 		},
 		nil,
@@ -266,24 +265,24 @@ func Invalid(table RedisDatabase, key string) (err error) {
 }
 
 // Get Object to HighWayHash for redis
-func HashSum(ObjectName string) (string,error) {
+func HashSum(ObjectName string) (string, error) {
 	key, err := hex.DecodeString(keyvalue)
 	if err != nil {
-		return "",err
+		return "", err
 	}
 
 	ObjectNameString := strings.NewReader(ObjectName)
 
 	hash, err := highwayhash.New(key)
 	if err != nil {
-		return "",err
+		return "", err
 	}
 
 	if _, err = io.Copy(hash, ObjectNameString); err != nil {
-		return "",err
+		return "", err
 	}
 
 	sumresult := hex.EncodeToString(hash.Sum(nil))
 
-	return sumresult,nil
-}
\ No newline at end of file
+	return sumresult, nil
+}
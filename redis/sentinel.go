@@ -0,0 +1,205 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	redigo "github.com/gomodule/redigo/redis"
+	"github.com/journeymidnight/yig/helper"
+)
+
+const (
+	SENTINEL_SWITCH_MASTER_CHANNEL = "+switch-master"
+	SENTINEL_RETRY_INTERVAL        = 1 * time.Second
+)
+
+// sentinelClient connects to the master of `masterName` as told by
+// redis sentinels, and follows it on failover.
+type sentinelClient struct {
+	sentinels  []string
+	masterName string
+	options    []redigo.DialOption
+
+	mutex      sync.RWMutex
+	masterAddr string
+	pool       *redigo.Pool
+
+	stopping chan struct{}
+}
+
+func newSentinelClient(sentinels []string, masterName string) (*sentinelClient, error) {
+	if len(sentinels) == 0 || masterName == "" {
+		return nil, errors.New("redis sentinel addresses and master name must be set")
+	}
+	s := &sentinelClient{
+		sentinels:  sentinels,
+		masterName: masterName,
+		options:    dialOptions(),
+		stopping:   make(chan struct{}),
+	}
+	addr, err := s.resolveMaster()
+	if err != nil {
+		return nil, err
+	}
+	s.switchMaster(addr)
+	go s.watch()
+	return s, nil
+}
+
+func (s *sentinelClient) dialSentinel(addr string) (redigo.Conn, error) {
+	// sentinels don't share the password of redis servers
+	return redigo.Dial("tcp", addr,
+		redigo.DialConnectTimeout(time.Duration(helper.CONFIG.RedisConnectTimeout)*time.Second),
+		redigo.DialReadTimeout(time.Duration(helper.CONFIG.RedisReadTimeout)*time.Second),
+		redigo.DialWriteTimeout(time.Duration(helper.CONFIG.RedisWriteTimeout)*time.Second))
+}
+
+// Ask sentinels one by one for the current master address
+func (s *sentinelClient) resolveMaster() (addr string, err error) {
+	for _, sentinel := range s.sentinels {
+		var c redigo.Conn
+		c, err = s.dialSentinel(sentinel)
+		if err != nil {
+			helper.Logger.Println(5, "Failed to connect redis sentinel", sentinel, "err:", err)
+			continue
+		}
+		var reply []string
+		reply, err = redigo.Strings(c.Do("SENTINEL", "get-master-addr-by-name", s.masterName))
+		c.Close()
+		if err != nil {
+			helper.Logger.Println(5, "Failed to get master from redis sentinel", sentinel, "err:", err)
+			continue
+		}
+		if len(reply) != 2 {
+			err = errors.New("bad reply of SENTINEL get-master-addr-by-name")
+			continue
+		}
+		return net.JoinHostPort(reply[0], reply[1]), nil
+	}
+	if err == nil {
+		err = errors.New("no redis sentinel available")
+	}
+	return "", err
+}
+
+func (s *sentinelClient) switchMaster(addr string) {
+	s.mutex.Lock()
+	if addr == s.masterAddr {
+		s.mutex.Unlock()
+		return
+	}
+	oldPool := s.pool
+	s.masterAddr = addr
+	s.pool = newPool(func() (redigo.Conn, error) {
+		c, err := redigo.Dial("tcp", addr, s.options...)
+		if err != nil {
+			return nil, err
+		}
+		// the address might be stale if failover happens right now
+		role, err := redigo.Values(c.Do("ROLE"))
+		if err != nil || len(role) == 0 {
+			c.Close()
+			return nil, errors.New("failed to get role of redis " + addr)
+		}
+		if r, _ := redigo.String(role[0], nil); r != "master" {
+			c.Close()
+			return nil, errors.New("redis " + addr + " is not master")
+		}
+		return c, nil
+	})
+	s.mutex.Unlock()
+
+	helper.Logger.Println(5, "Redis master of", s.masterName, "is", addr)
+	if oldPool != nil {
+		oldPool.Close()
+	}
+}
+
+// Subscribe to failover notifications from sentinels. If the subscription
+// breaks, some notification might be lost, so resolve the master again.
+func (s *sentinelClient) watch() {
+	for i := 0; ; i++ {
+		select {
+		case <-s.stopping:
+			return
+		default:
+		}
+		sentinel := s.sentinels[i%len(s.sentinels)]
+		err := s.subscribe(sentinel)
+		helper.Logger.Println(5, "Redis sentinel subscription to", sentinel, "ends, err:", err)
+		time.Sleep(SENTINEL_RETRY_INTERVAL)
+		if addr, err := s.resolveMaster(); err == nil {
+			s.switchMaster(addr)
+		}
+	}
+}
+
+func (s *sentinelClient) subscribe(sentinel string) error {
+	// no read timeout, as the connection is idle until a failover happens
+	c, err := redigo.Dial("tcp", sentinel,
+		redigo.DialConnectTimeout(time.Duration(helper.CONFIG.RedisConnectTimeout)*time.Second))
+	if err != nil {
+		return err
+	}
+	psc := redigo.PubSubConn{Conn: c}
+	// unblock Receive on Close, and stop waiting once the subscription ends
+	done := make(chan struct{})
+	defer func() {
+		close(done)
+		psc.Close()
+	}()
+	err = psc.Subscribe(SENTINEL_SWITCH_MASTER_CHANNEL)
+	if err != nil {
+		return err
+	}
+	go func() {
+		select {
+		case <-s.stopping:
+			psc.Close()
+		case <-done:
+		}
+	}()
+	for {
+		switch v := psc.Receive().(type) {
+		case redigo.Message:
+			// <master name> <old ip> <old port> <new ip> <new port>
+			fields := strings.Fields(string(v.Data))
+			if len(fields) != 5 || fields[0] != s.masterName {
+				continue
+			}
+			s.switchMaster(net.JoinHostPort(fields[3], fields[4]))
+		case error:
+			return v
+		}
+	}
+}
+
+func (s *sentinelClient) currentPool() *redigo.Pool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.pool
+}
+
+func (s *sentinelClient) Do(ctx context.Context, key string,
+	commandName string, args ...interface{}) (reply interface{}, err error) {
+
+	return doWithPool(ctx, s.currentPool(), commandName, args...)
+}
+
+func (s *sentinelClient) GetConn(ctx context.Context) (redigo.Conn, error) {
+	return s.currentPool().GetContext(ctx)
+}
+
+func (s *sentinelClient) Ping(ctx context.Context) error {
+	_, err := doWithPool(ctx, s.currentPool(), "PING")
+	return err
+}
+
+func (s *sentinelClient) Close() error {
+	close(s.stopping)
+	return s.currentPool().Close()
+}
@@ -0,0 +1,530 @@
+package s3select
+
+import (
+	"math"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	. "github.com/journeymidnight/yig/error"
+)
+
+// Aggregates are evaluated to their results with a nil record, after all
+// records are accumulated.
+type expr interface {
+	eval(r record) (Value, error)
+}
+
+type literal struct {
+	v Value
+}
+
+func (l *literal) eval(r record) (Value, error) {
+	return l.v, nil
+}
+
+type pathElem struct {
+	name    string
+	quoted  bool // names quoted are case sensitive
+	index   int
+	isIndex bool
+}
+
+type columnRef struct {
+	path []pathElem
+}
+
+func (c *columnRef) eval(r record) (Value, error) {
+	if r == nil {
+		return nullValue, ErrUnsupportedSqlOperation
+	}
+	v := r.get(c.path[0].name, c.path[0].quoted)
+	for _, elem := range c.path[1:] {
+		v = nested(v, elem)
+	}
+	return v, nil
+}
+
+type logical struct {
+	and         bool
+	left, right expr
+}
+
+func toBool(v Value) (b, null bool, err error) {
+	switch v.kind {
+	case kindBool:
+		return v.b, false, nil
+	case kindMissing, kindNull:
+		return false, true, nil
+	}
+	return false, false, ErrInvalidDataType
+}
+
+// three-valued logic, NULL if undetermined
+func (l *logical) eval(r record) (Value, error) {
+	v, err := l.left.eval(r)
+	if err != nil {
+		return nullValue, err
+	}
+	left, leftNull, err := toBool(v)
+	if err != nil {
+		return nullValue, err
+	}
+	if !leftNull && left != l.and {
+		return boolValue(left), nil
+	}
+	v, err = l.right.eval(r)
+	if err != nil {
+		return nullValue, err
+	}
+	right, rightNull, err := toBool(v)
+	if err != nil {
+		return nullValue, err
+	}
+	if !rightNull && right != l.and {
+		return boolValue(right), nil
+	}
+	if leftNull || rightNull {
+		return nullValue, nil
+	}
+	return boolValue(l.and), nil
+}
+
+type not struct {
+	e expr
+}
+
+func (n *not) eval(r record) (Value, error) {
+	v, err := n.e.eval(r)
+	if err != nil {
+		return nullValue, err
+	}
+	b, null, err := toBool(v)
+	if err != nil || null {
+		return nullValue, err
+	}
+	return boolValue(!b), nil
+}
+
+type comparison struct {
+	op          string
+	left, right expr
+}
+
+// Values not comparable, e.g. a CSV column not in number compared with a
+// number, are compared to NULL, so the record is skipped by WHERE.
+func (c *comparison) eval(r record) (Value, error) {
+	left, err := c.left.eval(r)
+	if err != nil {
+		return nullValue, err
+	}
+	right, err := c.right.eval(r)
+	if err != nil {
+		return nullValue, err
+	}
+	if left.isNull() || right.isNull() {
+		return nullValue, nil
+	}
+	order, ok := compare(left, right)
+	if !ok {
+		return nullValue, nil
+	}
+	switch c.op {
+	case "=":
+		return boolValue(order == 0), nil
+	case "!=", "<>":
+		return boolValue(order != 0), nil
+	case "<":
+		return boolValue(order < 0), nil
+	case "<=":
+		return boolValue(order <= 0), nil
+	case ">":
+		return boolValue(order > 0), nil
+	}
+	return boolValue(order >= 0), nil
+}
+
+type isNull struct {
+	e       expr
+	negated bool
+}
+
+func (n *isNull) eval(r record) (Value, error) {
+	v, err := n.e.eval(r)
+	if err != nil {
+		return nullValue, err
+	}
+	return boolValue(v.isNull() != n.negated), nil
+}
+
+type like struct {
+	e, pattern, escape expr
+	negated            bool
+
+	// regexp of the last pattern, which is usually a literal
+	lastPattern, lastEscape string
+	re                      *regexp.Regexp
+}
+
+func (l *like) compile(pattern, escape string) (*regexp.Regexp, error) {
+	if l.re != nil && pattern == l.lastPattern && escape == l.lastEscape {
+		return l.re, nil
+	}
+	var escapeRune rune = -1
+	if escape != "" {
+		if utf8.RuneCountInString(escape) != 1 {
+			return nil, ErrEvaluatorInvalidArguments
+		}
+		escapeRune, _ = utf8.DecodeRuneInString(escape)
+	}
+	expression := "(?s)^"
+	escaped := false
+	for _, c := range pattern {
+		switch {
+		case escaped:
+			expression += regexp.QuoteMeta(string(c))
+			escaped = false
+		case c == escapeRune:
+			escaped = true
+		case c == '%':
+			expression += ".*"
+		case c == '_':
+			expression += "."
+		default:
+			expression += regexp.QuoteMeta(string(c))
+		}
+	}
+	if escaped {
+		return nil, ErrEvaluatorInvalidArguments
+	}
+	re, err := regexp.Compile(expression + "$")
+	if err != nil {
+		return nil, ErrEvaluatorInvalidArguments
+	}
+	l.lastPattern, l.lastEscape, l.re = pattern, escape, re
+	return re, nil
+}
+
+func (l *like) eval(r record) (Value, error) {
+	v, err := l.e.eval(r)
+	if err != nil {
+		return nullValue, err
+	}
+	pattern, err := l.pattern.eval(r)
+	if err != nil {
+		return nullValue, err
+	}
+	var escape Value
+	if l.escape != nil {
+		if escape, err = l.escape.eval(r); err != nil {
+			return nullValue, err
+		}
+	}
+	if v.isNull() || pattern.isNull() {
+		return nullValue, nil
+	}
+	re, err := l.compile(pattern.String(), escape.String())
+	if err != nil {
+		return nullValue, err
+	}
+	return boolValue(re.MatchString(v.String()) != l.negated), nil
+}
+
+type between struct {
+	e, low, high expr
+	negated      bool
+}
+
+func (b *between) eval(r record) (Value, error) {
+	condition := &logical{
+		and:   true,
+		left:  &comparison{op: ">=", left: b.e, right: b.low},
+		right: &comparison{op: "<=", left: b.e, right: b.high},
+	}
+	v, err := condition.eval(r)
+	if err != nil || !b.negated {
+		return v, err
+	}
+	return (&not{&literal{v}}).eval(r)
+}
+
+type in struct {
+	e       expr
+	list    []expr
+	negated bool
+}
+
+func (i *in) eval(r record) (Value, error) {
+	v, err := i.e.eval(r)
+	if err != nil {
+		return nullValue, err
+	}
+	if v.isNull() {
+		return nullValue, nil
+	}
+	null := false
+	for _, e := range i.list {
+		candidate, err := e.eval(r)
+		if err != nil {
+			return nullValue, err
+		}
+		if candidate.isNull() {
+			null = true
+			continue
+		}
+		if order, ok := compare(v, candidate); ok && order == 0 {
+			return boolValue(!i.negated), nil
+		}
+	}
+	if null {
+		return nullValue, nil
+	}
+	return boolValue(i.negated), nil
+}
+
+type arithmetic struct {
+	op          string
+	left, right expr
+}
+
+func (a *arithmetic) eval(r record) (Value, error) {
+	left, err := a.left.eval(r)
+	if err != nil {
+		return nullValue, err
+	}
+	right, err := a.right.eval(r)
+	if err != nil {
+		return nullValue, err
+	}
+	if left.isNull() || right.isNull() {
+		return nullValue, nil
+	}
+	if a.op == "||" {
+		return stringValue(left.String() + right.String()), nil
+	}
+	left, lok := left.toNumber()
+	right, rok := right.toNumber()
+	if !lok || !rok {
+		return nullValue, ErrInvalidDataType
+	}
+	if left.kind == kindInt && right.kind == kindInt {
+		x, y := left.i, right.i
+		switch a.op {
+		case "+":
+			return intValue(x + y), nil
+		case "-":
+			return intValue(x - y), nil
+		case "*":
+			return intValue(x * y), nil
+		}
+		if y == 0 {
+			return nullValue, ErrDivisionByZero
+		}
+		if a.op == "/" {
+			return intValue(x / y), nil
+		}
+		return intValue(x % y), nil
+	}
+	x, y := left.float(), right.float()
+	switch a.op {
+	case "+":
+		return floatValue(x + y), nil
+	case "-":
+		return floatValue(x - y), nil
+	case "*":
+		return floatValue(x * y), nil
+	}
+	if y == 0 {
+		return nullValue, ErrDivisionByZero
+	}
+	if a.op == "/" {
+		return floatValue(x / y), nil
+	}
+	return floatValue(math.Mod(x, y)), nil
+}
+
+type cast struct {
+	e        expr
+	typeName string
+}
+
+func (c *cast) eval(r record) (Value, error) {
+	v, err := c.e.eval(r)
+	if err != nil {
+		return nullValue, err
+	}
+	return v.cast(c.typeName)
+}
+
+type function struct {
+	name string
+	args []expr
+}
+
+func (f *function) checkArguments() error {
+	n := len(f.args)
+	switch f.name {
+	case "COALESCE":
+		if n == 0 {
+			return ErrEvaluatorInvalidArguments
+		}
+	case "NULLIF":
+		if n != 2 {
+			return ErrEvaluatorInvalidArguments
+		}
+	default:
+		if n != 1 {
+			return ErrEvaluatorInvalidArguments
+		}
+	}
+	return nil
+}
+
+func (f *function) eval(r record) (Value, error) {
+	args := make([]Value, len(f.args))
+	for i, e := range f.args {
+		v, err := e.eval(r)
+		if err != nil {
+			return nullValue, err
+		}
+		args[i] = v
+	}
+	switch f.name {
+	case "COALESCE":
+		for _, v := range args {
+			if !v.isNull() {
+				return v, nil
+			}
+		}
+		return nullValue, nil
+	case "NULLIF":
+		if order, ok := compare(args[0], args[1]); ok && order == 0 {
+			return nullValue, nil
+		}
+		return args[0], nil
+	}
+	for _, v := range args {
+		if v.isNull() {
+			return nullValue, nil
+		}
+	}
+	s := args[0].String()
+	switch f.name {
+	case "LOWER":
+		return stringValue(strings.ToLower(s)), nil
+	case "UPPER":
+		return stringValue(strings.ToUpper(s)), nil
+	case "TRIM":
+		return stringValue(strings.TrimSpace(s)), nil
+	case "CHAR_LENGTH", "CHARACTER_LENGTH":
+		return intValue(int64(utf8.RuneCountInString(s))), nil
+	case "SUBSTRING":
+		return substring(s, args[1:])
+	}
+	return nullValue, ErrUnsupportedSqlOperation
+}
+
+// Characters in [start, start+length) of s, which start from 1
+func substring(s string, args []Value) (Value, error) {
+	start, ok := args[0].toNumber()
+	if !ok {
+		return nullValue, ErrInvalidDataType
+	}
+	runes := []rune(s)
+	from := int64(start.float())
+	to := int64(len(runes)) + 1
+	if len(args) > 1 {
+		length, ok := args[1].toNumber()
+		if !ok || length.float() < 0 {
+			return nullValue, ErrInvalidDataType
+		}
+		if end := from + int64(length.float()); end < to {
+			to = end
+		}
+	}
+	if from < 1 {
+		from = 1
+	}
+	if from >= to {
+		return stringValue(""), nil
+	}
+	return stringValue(string(runes[from-1 : to-1])), nil
+}
+
+type aggregate struct {
+	function string
+	arg      expr // nil for COUNT(*)
+
+	count int64
+	sum   Value
+	value Value // MIN or MAX
+}
+
+func (a *aggregate) accumulate(r record) error {
+	if a.arg == nil {
+		a.count++
+		return nil
+	}
+	v, err := a.arg.eval(r)
+	if err != nil {
+		return err
+	}
+	if v.isNull() {
+		return nil
+	}
+	a.count++
+	switch a.function {
+	case "SUM", "AVG":
+		n, ok := v.toNumber()
+		if !ok {
+			return ErrInvalidDataType
+		}
+		if a.count == 1 {
+			a.sum = n
+		} else if a.sum.kind == kindInt && n.kind == kindInt {
+			a.sum.i += n.i
+		} else {
+			a.sum = floatValue(a.sum.float() + n.float())
+		}
+	case "MIN", "MAX":
+		if n, ok := v.toNumber(); ok {
+			v = n
+		}
+		if a.count == 1 {
+			a.value = v
+			return nil
+		}
+		order, ok := compare(v, a.value)
+		if !ok {
+			return ErrInvalidDataType
+		}
+		if (a.function == "MIN" && order < 0) || (a.function == "MAX" && order > 0) {
+			a.value = v
+		}
+	}
+	return nil
+}
+
+func (a *aggregate) eval(r record) (Value, error) {
+	if r != nil {
+		return nullValue, ErrUnsupportedSqlOperation
+	}
+	switch a.function {
+	case "COUNT":
+		return intValue(a.count), nil
+	case "SUM":
+		if a.count == 0 {
+			return nullValue, nil
+		}
+		return a.sum, nil
+	case "AVG":
+		if a.count == 0 {
+			return nullValue, nil
+		}
+		return floatValue(a.sum.float() / float64(a.count)), nil
+	}
+	if a.count == 0 {
+		return nullValue, nil
+	}
+	return a.value, nil
+}
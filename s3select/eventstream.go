@@ -0,0 +1,104 @@
+package s3select
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"hash/crc32"
+	"io"
+)
+
+// Messages of responses are in the event stream encoding:
+//
+//	total length (4) | headers length (4) | prelude CRC (4) |
+//	headers | payload | message CRC (4)
+//
+// where CRCs are CRC32 (IEEE), and each header is
+//
+//	name length (1) | name | value type (1), 7 for string |
+//	value length (2) | value
+
+const headerTypeString = 7
+
+type header struct {
+	name, value string
+}
+
+func encodeMessage(headers []header, payload []byte) []byte {
+	var h bytes.Buffer
+	for _, header := range headers {
+		h.WriteByte(byte(len(header.name)))
+		h.WriteString(header.name)
+		h.WriteByte(headerTypeString)
+		binary.Write(&h, binary.BigEndian, uint16(len(header.value)))
+		h.WriteString(header.value)
+	}
+	total := 12 + h.Len() + len(payload) + 4
+	message := make([]byte, 0, total)
+	message = appendUint32(message, uint32(total))
+	message = appendUint32(message, uint32(h.Len()))
+	message = appendUint32(message, crc32.ChecksumIEEE(message))
+	message = append(message, h.Bytes()...)
+	message = append(message, payload...)
+	return appendUint32(message, crc32.ChecksumIEEE(message))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+type stats struct {
+	XMLName        xml.Name
+	BytesScanned   int64 `xml:"BytesScanned"`
+	BytesProcessed int64 `xml:"BytesProcessed"`
+	BytesReturned  int64 `xml:"BytesReturned"`
+}
+
+type eventWriter struct {
+	writer io.Writer
+}
+
+func (e *eventWriter) event(eventType, contentType string, payload []byte) error {
+	headers := []header{{":event-type", eventType}}
+	if contentType != "" {
+		headers = append(headers, header{":content-type", contentType})
+	}
+	headers = append(headers, header{":message-type", "event"})
+	_, err := e.writer.Write(encodeMessage(headers, payload))
+	return err
+}
+
+func (e *eventWriter) records(payload []byte) error {
+	return e.event("Records", "application/octet-stream", payload)
+}
+
+// Progress or Stats
+func (e *eventWriter) stats(eventType string, s stats) error {
+	s.XMLName.Local = eventType
+	payload, err := xml.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return e.event(eventType, "text/xml", payload)
+}
+
+// Keeps the connection alive when no records are returned for a while
+func (e *eventWriter) cont() error {
+	return e.event("Cont", "", nil)
+}
+
+func (e *eventWriter) end() error {
+	return e.event("End", "", nil)
+}
+
+func (e *eventWriter) error(code, message string) error {
+	headers := []header{
+		{":error-code", code},
+		{":error-message", message},
+		{":message-type", "error"},
+	}
+	_, err := e.writer.Write(encodeMessage(headers, nil))
+	return err
+}
@@ -0,0 +1,66 @@
+package s3select
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+type recordWriter interface {
+	write(buf *bytes.Buffer, fields []field)
+}
+
+type csvWriter struct {
+	fieldDelimiter  string
+	recordDelimiter string
+	quote           string
+	quoteEscape     string
+	quoteAlways     bool
+}
+
+func (c *csvWriter) write(buf *bytes.Buffer, fields []field) {
+	for i, f := range fields {
+		if i > 0 {
+			buf.WriteString(c.fieldDelimiter)
+		}
+		s := f.value.String()
+		if c.quoteAlways || c.needsQuote(s) {
+			buf.WriteString(c.quote)
+			buf.WriteString(strings.Replace(s, c.quote, c.quoteEscape+c.quote, -1))
+			buf.WriteString(c.quote)
+		} else {
+			buf.WriteString(s)
+		}
+	}
+	buf.WriteString(c.recordDelimiter)
+}
+
+func (c *csvWriter) needsQuote(s string) bool {
+	return strings.Contains(s, c.fieldDelimiter) || strings.Contains(s, c.quote) ||
+		strings.Contains(s, c.recordDelimiter) || strings.ContainsAny(s, "\r\n")
+}
+
+// Missing fields are left out
+type jsonWriter struct {
+	recordDelimiter string
+}
+
+func (j *jsonWriter) write(buf *bytes.Buffer, fields []field) {
+	buf.WriteByte('{')
+	first := true
+	for _, f := range fields {
+		if f.value.kind == kindMissing {
+			continue
+		}
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		name, _ := json.Marshal(f.name)
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(f.value.json())
+	}
+	buf.WriteByte('}')
+	buf.WriteString(j.recordDelimiter)
+}
@@ -0,0 +1,298 @@
+package s3select
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	. "github.com/journeymidnight/yig/error"
+)
+
+type field struct {
+	name  string
+	value Value
+}
+
+type record interface {
+	// column by name, matched case insensitively unless quoted
+	get(name string, quoted bool) Value
+	// all columns in order, for `SELECT *`
+	fields() []field
+}
+
+type recordReader interface {
+	// returns io.EOF after the last record
+	next() (record, error)
+}
+
+// Positions of columns by name, from the header of CSV
+type csvHeader struct {
+	names   []string
+	indexes map[string]int
+	folded  map[string]int
+}
+
+func newCSVHeader(names []string) *csvHeader {
+	h := &csvHeader{
+		names:   names,
+		indexes: make(map[string]int),
+		folded:  make(map[string]int),
+	}
+	for i := len(names) - 1; i >= 0; i-- {
+		h.indexes[names[i]] = i
+		h.folded[strings.ToLower(names[i])] = i
+	}
+	return h
+}
+
+type csvRecord struct {
+	header  *csvHeader
+	columns []string
+}
+
+// Columns are also named `_1`, `_2`, ... by position
+func (c *csvRecord) get(name string, quoted bool) Value {
+	index := -1
+	if c.header != nil {
+		if i, ok := c.header.indexes[name]; ok {
+			index = i
+		} else if i, ok := c.header.folded[strings.ToLower(name)]; ok && !quoted {
+			index = i
+		}
+	}
+	if index < 0 && strings.HasPrefix(name, "_") {
+		if n, err := strconv.Atoi(name[1:]); err == nil && n > 0 {
+			index = n - 1
+		}
+	}
+	if index < 0 || index >= len(c.columns) {
+		return missingValue
+	}
+	return stringValue(c.columns[index])
+}
+
+func (c *csvRecord) fields() []field {
+	fields := make([]field, len(c.columns))
+	for i, column := range c.columns {
+		name := "_" + strconv.Itoa(i+1)
+		if c.header != nil && i < len(c.header.names) {
+			name = c.header.names[i]
+		}
+		fields[i] = field{name: name, value: stringValue(column)}
+	}
+	return fields
+}
+
+type csvReader struct {
+	reader *csv.Reader
+	header *csvHeader
+	// the first record is the header or ignored
+	headerInfo string
+}
+
+func (c *csvReader) next() (record, error) {
+	for {
+		columns, err := c.reader.Read()
+		if err == io.EOF {
+			return nil, err
+		}
+		if err != nil {
+			return nil, ErrCSVParsingError
+		}
+		switch c.headerInfo {
+		case "USE":
+			c.header = newCSVHeader(columns)
+			c.headerInfo = ""
+			continue
+		case "IGNORE":
+			c.headerInfo = ""
+			continue
+		}
+		return &csvRecord{header: c.header, columns: columns}, nil
+	}
+}
+
+// Fields of a JSON object in order, other values are records of one field
+// named `_1`.
+type jsonRecord struct {
+	keys   []string
+	values map[string]json.RawMessage
+}
+
+func newJSONRecord(raw json.RawMessage) (*jsonRecord, error) {
+	keys, values, err := decodeObject(raw)
+	if err == errNotObject {
+		return &jsonRecord{
+			keys:   []string{"_1"},
+			values: map[string]json.RawMessage{"_1": raw},
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &jsonRecord{keys: keys, values: values}, nil
+}
+
+func (j *jsonRecord) get(name string, quoted bool) Value {
+	raw, ok := j.values[name]
+	if !ok && !quoted {
+		for _, key := range j.keys {
+			if strings.EqualFold(key, name) {
+				raw, ok = j.values[key], true
+				break
+			}
+		}
+	}
+	if !ok {
+		return missingValue
+	}
+	v, err := jsonValue(raw)
+	if err != nil {
+		return missingValue
+	}
+	return v
+}
+
+func (j *jsonRecord) fields() []field {
+	fields := make([]field, 0, len(j.keys))
+	for _, key := range j.keys {
+		v, err := jsonValue(j.values[key])
+		if err != nil {
+			v = missingValue
+		}
+		fields = append(fields, field{name: key, value: v})
+	}
+	return fields
+}
+
+var errNotObject = errors.New("not a JSON object")
+
+func decodeObject(raw json.RawMessage) (keys []string, values map[string]json.RawMessage, err error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || raw[0] != '{' {
+		return nil, nil, errNotObject
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	if _, err = decoder.Token(); err != nil {
+		return nil, nil, ErrJSONParsingError
+	}
+	values = make(map[string]json.RawMessage)
+	for decoder.More() {
+		t, err := decoder.Token()
+		if err != nil {
+			return nil, nil, ErrJSONParsingError
+		}
+		key, _ := t.(string)
+		var value json.RawMessage
+		if err = decoder.Decode(&value); err != nil {
+			return nil, nil, ErrJSONParsingError
+		}
+		if _, ok := values[key]; !ok {
+			keys = append(keys, key)
+		}
+		values[key] = value
+	}
+	return keys, values, nil
+}
+
+// Field or element of nested JSON objects and arrays
+func nested(v Value, elem pathElem) Value {
+	if v.kind != kindRaw {
+		return missingValue
+	}
+	var raw json.RawMessage
+	if elem.isIndex {
+		var elements []json.RawMessage
+		if err := json.Unmarshal(v.raw, &elements); err != nil || elem.index >= len(elements) {
+			return missingValue
+		}
+		raw = elements[elem.index]
+	} else {
+		keys, values, err := decodeObject(v.raw)
+		if err != nil {
+			return missingValue
+		}
+		r := &jsonRecord{keys: keys, values: values}
+		return r.get(elem.name, elem.quoted)
+	}
+	nestedValue, err := jsonValue(raw)
+	if err != nil {
+		return missingValue
+	}
+	return nestedValue
+}
+
+type jsonReader struct {
+	decoder *json.Decoder
+	// elements of top level arrays are records
+	arrayRecords bool
+	elements     []json.RawMessage
+}
+
+func (j *jsonReader) next() (record, error) {
+	for len(j.elements) == 0 {
+		var raw json.RawMessage
+		err := j.decoder.Decode(&raw)
+		if err == io.EOF {
+			return nil, err
+		}
+		if err != nil {
+			return nil, ErrJSONParsingError
+		}
+		raw = bytes.TrimSpace(raw)
+		if j.arrayRecords && len(raw) > 0 && raw[0] == '[' {
+			if err = json.Unmarshal(raw, &j.elements); err != nil {
+				return nil, ErrJSONParsingError
+			}
+			continue
+		}
+		j.elements = []json.RawMessage{raw}
+	}
+	raw := j.elements[0]
+	j.elements = j.elements[1:]
+	return newJSONRecord(raw)
+}
+
+// Reads whole lines starting in [0, end] of data, the first line is
+// skipped if `skipFirst`, since it starts before the range. Used for scan
+// ranges, data starts from the byte before the range.
+type scanRangeReader struct {
+	reader    *bufio.Reader
+	skipFirst bool
+	offset    int64
+	end       int64
+	line      []byte
+	err       error
+}
+
+func (s *scanRangeReader) readLine() {
+	var line []byte
+	line, s.err = s.reader.ReadBytes('\n')
+	s.offset += int64(len(line))
+	s.line = line
+}
+
+func (s *scanRangeReader) Read(p []byte) (int, error) {
+	if s.skipFirst {
+		s.skipFirst = false
+		s.readLine()
+		s.line = nil
+	}
+	for len(s.line) == 0 {
+		if s.err != nil {
+			return 0, s.err
+		}
+		if s.offset > s.end {
+			return 0, io.EOF
+		}
+		s.readLine()
+	}
+	n := copy(p, s.line)
+	s.line = s.line[n:]
+	return n, nil
+}
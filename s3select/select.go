@@ -0,0 +1,324 @@
+package s3select
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"time"
+	"unicode/utf8"
+
+	"github.com/journeymidnight/yig/api/datatype"
+	. "github.com/journeymidnight/yig/error"
+)
+
+const (
+	// records are returned in events of about this size
+	maxRecordsEventSize = 256 << 10
+	// a Cont, or Progress if requested, event is sent if no records are
+	// returned for this long, so clients won't time out on queries
+	// matching few records.
+	keepAliveInterval     = 5 * time.Second
+	keepAliveCheckRecords = 1000
+)
+
+// A select request compiled, queries data of an object. Use ReadRange to
+// get the range of the object to read, then Run with its data.
+type Select struct {
+	request datatype.SelectObjectContentRequest
+	query   *query
+	writer  recordWriter
+
+	// settings of CSV input
+	fieldDelimiter rune
+	comment        rune
+
+	// the scan range, inclusive, and the offset data to Run starts from
+	start, end int64
+	offset     int64
+}
+
+type countingReader struct {
+	reader io.Reader
+	n      int64
+	// the error reading data, other than io.EOF
+	err error
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.n += int64(n)
+	if err != nil && err != io.EOF {
+		c.err = err
+	}
+	return n, err
+}
+
+// Returns the only rune of `s`, or `defaultValue` if s is nil
+func character(s *string, defaultValue rune) (rune, error) {
+	if s == nil {
+		return defaultValue, nil
+	}
+	if utf8.RuneCountInString(*s) != 1 {
+		return 0, ErrInvalidSelectParameter
+	}
+	c, _ := utf8.DecodeRuneInString(*s)
+	return c, nil
+}
+
+func stringOrDefault(s *string, defaultValue string) (string, error) {
+	if s == nil {
+		return defaultValue, nil
+	}
+	if *s == "" {
+		return "", ErrInvalidSelectParameter
+	}
+	return *s, nil
+}
+
+// The request should be validated
+func New(request datatype.SelectObjectContentRequest) (s *Select, err error) {
+	s = &Select{request: request}
+	if s.query, err = parseQuery(request.Expression); err != nil {
+		return nil, err
+	}
+	if input := request.InputSerialization.CSV; input != nil {
+		if s.fieldDelimiter, err = character(input.FieldDelimiter, ','); err != nil {
+			return nil, err
+		}
+		// encoding/csv only supports quotes escaped by doubling them
+		if quote, err := character(input.QuoteCharacter, '"'); err != nil || quote != '"' {
+			return nil, ErrInvalidSelectParameter
+		}
+		if escape, err := character(input.QuoteEscapeCharacter, '"'); err != nil || escape != '"' {
+			return nil, ErrInvalidSelectParameter
+		}
+		if delimiter, err := stringOrDefault(input.RecordDelimiter, "\n"); err != nil ||
+			(delimiter != "\n" && delimiter != "\r\n") {
+			return nil, ErrInvalidSelectParameter
+		}
+		if input.Comments == nil || *input.Comments != "" {
+			if s.comment, err = character(input.Comments, '#'); err != nil {
+				return nil, err
+			}
+		}
+		switch s.fieldDelimiter {
+		case '"', '\r', '\n', s.comment:
+			return nil, ErrInvalidSelectParameter
+		}
+	}
+	if output := request.OutputSerialization.CSV; output != nil {
+		writer := &csvWriter{quoteAlways: output.QuoteFields == datatype.SelectQuoteFieldsAlways}
+		if writer.fieldDelimiter, err = stringOrDefault(output.FieldDelimiter, ","); err != nil {
+			return nil, err
+		}
+		if writer.recordDelimiter, err = stringOrDefault(output.RecordDelimiter, "\n"); err != nil {
+			return nil, err
+		}
+		if writer.quote, err = stringOrDefault(output.QuoteCharacter, `"`); err != nil {
+			return nil, err
+		}
+		if writer.quoteEscape, err = stringOrDefault(output.QuoteEscapeCharacter, `"`); err != nil {
+			return nil, err
+		}
+		s.writer = writer
+	} else {
+		writer := &jsonWriter{}
+		if writer.recordDelimiter, err = stringOrDefault(
+			request.OutputSerialization.JSON.RecordDelimiter, "\n"); err != nil {
+			return nil, err
+		}
+		s.writer = writer
+	}
+	return s, nil
+}
+
+// Range of the object to read, `length` is 0 if nothing is to be read.
+// Records of a scan range might end after it, so data is read to the end
+// of the object.
+func (s *Select) ReadRange(size int64) (offset, length int64) {
+	s.start, s.end = s.request.ScanRange.Locate(size)
+	if s.start > s.end {
+		return 0, 0
+	}
+	if s.request.ScanRange == nil {
+		return 0, size
+	}
+	// the byte before the range tells whether the first record starts
+	// at the range
+	s.offset = s.start
+	if s.offset > 0 {
+		s.offset--
+	}
+	return s.offset, size - s.offset
+}
+
+// Queries `data` read from the object as ReadRange returns, writes events
+// of results into `w`. Errors are also written as events.
+func (s *Select) Run(data io.Reader, w io.Writer) error {
+	events := &eventWriter{writer: w}
+	err := s.run(data, events)
+	if err != nil {
+		apiErr, ok := err.(ApiError)
+		if !ok {
+			apiErr = ErrInternalError
+		}
+		events.error(apiErr.AwsErrorCode(), apiErr.Description())
+	}
+	return err
+}
+
+func (s *Select) newRecordReader(input io.Reader) recordReader {
+	if s.request.InputSerialization.CSV != nil {
+		reader := csv.NewReader(input)
+		reader.Comma = s.fieldDelimiter
+		reader.Comment = s.comment
+		reader.FieldsPerRecord = -1
+		return &csvReader{
+			reader:     reader,
+			headerInfo: s.request.InputSerialization.CSV.FileHeaderInfo,
+		}
+	}
+	return &jsonReader{
+		decoder:      json.NewDecoder(input),
+		arrayRecords: s.query.arrayRecords,
+	}
+}
+
+func (s *Select) project(r record) ([]field, error) {
+	if s.query.all {
+		return r.fields(), nil
+	}
+	fields := make([]field, len(s.query.projections))
+	for i, p := range s.query.projections {
+		v, err := p.expr.eval(r)
+		if err != nil {
+			return nil, err
+		}
+		fields[i] = field{name: p.name, value: v}
+	}
+	return fields, nil
+}
+
+func (s *Select) run(data io.Reader, events *eventWriter) error {
+	scanned := &countingReader{reader: data}
+	var input io.Reader = scanned
+	if s.request.ScanRange != nil {
+		input = &scanRangeReader{
+			reader:    bufio.NewReader(scanned),
+			skipFirst: s.start > 0,
+			end:       s.end - s.offset,
+		}
+	}
+	switch s.request.InputSerialization.CompressionType {
+	case datatype.SelectCompressionGzip:
+		decompressor, err := gzip.NewReader(input)
+		if err == io.EOF {
+			input = bytes.NewReader(nil)
+		} else if err != nil {
+			if scanned.err != nil {
+				return scanned.err
+			}
+			return ErrInvalidCompressionFormat
+		} else {
+			input = decompressor
+		}
+	case datatype.SelectCompressionBzip2:
+		input = bzip2.NewReader(input)
+	}
+	processed := &countingReader{reader: input}
+	records := s.newRecordReader(processed)
+
+	var buffer bytes.Buffer
+	var returned int64
+	lastEvent := time.Now()
+	progress := func() stats {
+		return stats{BytesScanned: scanned.n, BytesProcessed: processed.n, BytesReturned: returned}
+	}
+	flush := func() error {
+		if buffer.Len() > 0 {
+			returned += int64(buffer.Len())
+			if err := events.records(buffer.Bytes()); err != nil {
+				return err
+			}
+			buffer.Reset()
+		}
+		lastEvent = time.Now()
+		if s.request.RequestProgress.Enabled {
+			return events.stats("Progress", progress())
+		}
+		return nil
+	}
+
+	var matched int64
+	for n := 1; s.query.limit < 0 || matched < s.query.limit; n++ {
+		r, err := records.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if scanned.err != nil {
+				return scanned.err
+			}
+			return err
+		}
+		if n%keepAliveCheckRecords == 0 && time.Since(lastEvent) > keepAliveInterval {
+			if s.request.RequestProgress.Enabled {
+				err = flush()
+			} else {
+				err = events.cont()
+				lastEvent = time.Now()
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if s.query.where != nil {
+			v, err := s.query.where.eval(r)
+			if err != nil {
+				return err
+			}
+			if b, null, err := toBool(v); err != nil {
+				return err
+			} else if null || !b {
+				continue
+			}
+		}
+		matched++
+		if len(s.query.aggregates) > 0 {
+			for _, a := range s.query.aggregates {
+				if err = a.accumulate(r); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		fields, err := s.project(r)
+		if err != nil {
+			return err
+		}
+		s.writer.write(&buffer, fields)
+		if buffer.Len() >= maxRecordsEventSize {
+			if err = flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if len(s.query.aggregates) > 0 {
+		fields, err := s.project(nil)
+		if err != nil {
+			return err
+		}
+		s.writer.write(&buffer, fields)
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	if err := events.stats("Stats", progress()); err != nil {
+		return err
+	}
+	return events.end()
+}
@@ -0,0 +1,237 @@
+package s3select
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/xml"
+	"hash/crc32"
+	"strings"
+	"testing"
+
+	"github.com/journeymidnight/yig/api/datatype"
+	. "github.com/journeymidnight/yig/error"
+)
+
+const testCSV = `name,city,age
+Alice,Beijing,30
+Bob,"Shang,hai",25
+# comment
+Carol,Beijing,41
+Dave,Shenzhen,abc
+`
+
+type message struct {
+	headers map[string]string
+	payload []byte
+}
+
+func decodeMessages(t *testing.T, data []byte) (messages []message) {
+	for len(data) > 0 {
+		total := binary.BigEndian.Uint32(data[0:4])
+		headersLength := binary.BigEndian.Uint32(data[4:8])
+		if crc32.ChecksumIEEE(data[:8]) != binary.BigEndian.Uint32(data[8:12]) {
+			t.Fatal("wrong prelude CRC")
+		}
+		if crc32.ChecksumIEEE(data[:total-4]) != binary.BigEndian.Uint32(data[total-4:total]) {
+			t.Fatal("wrong message CRC")
+		}
+		m := message{headers: make(map[string]string)}
+		h := data[12 : 12+headersLength]
+		for len(h) > 0 {
+			nameLength := int(h[0])
+			name := string(h[1 : 1+nameLength])
+			h = h[1+nameLength+1:]
+			valueLength := int(binary.BigEndian.Uint16(h[0:2]))
+			m.headers[name] = string(h[2 : 2+valueLength])
+			h = h[2+valueLength:]
+		}
+		m.payload = data[12+headersLength : total-4]
+		messages = append(messages, m)
+		data = data[total:]
+	}
+	return
+}
+
+func newRequest(expression string, input datatype.InputSerialization,
+	output datatype.OutputSerialization) datatype.SelectObjectContentRequest {
+
+	request := datatype.SelectObjectContentRequest{
+		Expression:          expression,
+		ExpressionType:      "SQL",
+		InputSerialization:  input,
+		OutputSerialization: output,
+	}
+	return request
+}
+
+func csvInput(headerInfo string) datatype.InputSerialization {
+	return datatype.InputSerialization{CSV: &datatype.CSVInput{FileHeaderInfo: headerInfo}}
+}
+
+var csvOutput = datatype.OutputSerialization{CSV: &datatype.CSVOutput{}}
+var jsonOutput = datatype.OutputSerialization{JSON: &datatype.JSONOutput{}}
+
+// Returns records returned and the last event
+func runSelect(t *testing.T, request datatype.SelectObjectContentRequest, data []byte) (string, message) {
+	if err := request.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(request)
+	if err != nil {
+		t.Fatal(request.Expression, err)
+	}
+	offset, length := s.ReadRange(int64(len(data)))
+	var out bytes.Buffer
+	s.Run(bytes.NewReader(data[offset:offset+length]), &out)
+	messages := decodeMessages(t, out.Bytes())
+	var records string
+	for _, m := range messages {
+		if m.headers[":event-type"] == "Records" {
+			records += string(m.payload)
+		}
+	}
+	return records, messages[len(messages)-1]
+}
+
+func TestSelectCSV(t *testing.T) {
+	for _, c := range []struct {
+		expression string
+		output     string
+	}{
+		{"SELECT * FROM S3Object", "Alice,Beijing,30\nBob,\"Shang,hai\",25\nCarol,Beijing,41\nDave,Shenzhen,abc\n"},
+		{"select s.name, s.age + 1 from s3object s where s.city = 'Beijing'", "Alice,31\nCarol,42\n"},
+		{"SELECT _1 FROM S3Object WHERE age > 26 LIMIT 1", "Alice\n"},
+		{"SELECT name FROM S3Object WHERE name LIKE '_o%' OR age BETWEEN 40 AND 50", "Bob\nCarol\n"},
+		{"SELECT UPPER(name) FROM S3Object s WHERE s.\"city\" IN ('Shenzhen')", "DAVE\n"},
+		{"SELECT COUNT(*), SUM(CAST(age AS INT)), MAX(name), AVG(age) FROM S3Object WHERE age < 100",
+			"3,96,Carol,32\n"},
+		{"SELECT SUBSTRING(name FROM 2 FOR 2), CHAR_LENGTH(city) FROM S3Object LIMIT 2", "li,7\nob,9\n"},
+	} {
+		records, last := runSelect(t, newRequest(c.expression, csvInput("USE"), csvOutput), []byte(testCSV))
+		if records != c.output {
+			t.Errorf("%s: got %q, expect %q", c.expression, records, c.output)
+		}
+		if last.headers[":event-type"] != "End" {
+			t.Error(c.expression, "should end with End event:", last.headers)
+		}
+	}
+}
+
+func TestSelectJSON(t *testing.T) {
+	lines := `{"name": "Alice", "tags": ["a", "b"], "address": {"city": "Beijing"}}
+{"name": "Bob", "address": {"city": "Shanghai"}, "age": 25}
+`
+	input := datatype.InputSerialization{JSON: &datatype.JSONInput{Type: "LINES"}}
+	for _, c := range []struct {
+		expression string
+		output     string
+	}{
+		{"SELECT * FROM S3Object s WHERE s.age IS MISSING",
+			`{"name":"Alice","tags":["a", "b"],"address":{"city": "Beijing"}}` + "\n"},
+		{"SELECT s.address.city, s.tags[1] AS tag, s.age FROM S3Object s",
+			`{"city":"Beijing","tag":"b"}` + "\n" + `{"city":"Shanghai","age":25}` + "\n"},
+	} {
+		records, _ := runSelect(t, newRequest(c.expression, input, jsonOutput), []byte(lines))
+		if records != c.output {
+			t.Errorf("%s: got %q, expect %q", c.expression, records, c.output)
+		}
+	}
+
+	document := `[{"a": 1}, {"a": 2}]`
+	input = datatype.InputSerialization{JSON: &datatype.JSONInput{Type: "DOCUMENT"}}
+	records, _ := runSelect(t, newRequest("SELECT SUM(s.a) FROM S3Object[*] s", input, csvOutput),
+		[]byte(document))
+	if records != "3\n" {
+		t.Error("wrong sum of array records:", records)
+	}
+}
+
+func TestSelectGzip(t *testing.T) {
+	var compressed bytes.Buffer
+	w := gzip.NewWriter(&compressed)
+	w.Write([]byte(testCSV))
+	w.Close()
+	input := csvInput("IGNORE")
+	input.CompressionType = "GZIP"
+	records, _ := runSelect(t, newRequest("SELECT COUNT(*) FROM S3Object", input, csvOutput),
+		compressed.Bytes())
+	if records != "4\n" {
+		t.Error("wrong count of gzipped CSV:", records)
+	}
+}
+
+func TestSelectScanRange(t *testing.T) {
+	data := "a,1\nbb,2\nccc,3\ndddd,4\n"
+	for _, c := range []struct {
+		start, end int64
+		output     string
+	}{
+		{0, 3, "a\n"},
+		{1, 4, "bb\n"},
+		{4, 9, "bb\nccc\n"},
+		{5, 100, "ccc\ndddd\n"},
+		{21, 30, ""},
+	} {
+		request := newRequest("SELECT _1 FROM S3Object", csvInput("NONE"), csvOutput)
+		start, end := c.start, c.end
+		request.ScanRange = &datatype.ScanRange{Start: &start, End: &end}
+		records, _ := runSelect(t, request, []byte(data))
+		if records != c.output {
+			t.Errorf("range %d-%d: got %q, expect %q", c.start, c.end, records, c.output)
+		}
+	}
+}
+
+func TestSelectErrors(t *testing.T) {
+	for _, c := range []struct {
+		expression string
+		err        error
+	}{
+		{"SELECT FROM S3Object", ErrParseSelectFailure},
+		{"SELECT * FROM table", ErrParseSelectFailure},
+		{"SELECT name, COUNT(*) FROM S3Object", ErrUnsupportedSqlOperation},
+		{"SELECT * FROM S3Object WHERE COUNT(*) > 1", ErrUnsupportedSqlOperation},
+		{"SELECT UNKNOWN(name) FROM S3Object", ErrUnsupportedSqlOperation},
+		{"SELECT LOWER(a, b) FROM S3Object", ErrEvaluatorInvalidArguments},
+		{"SELECT * FROM S3Object LIMIT x", ErrParseSelectFailure},
+		{"SELECT 'abc FROM S3Object", ErrParseSelectFailure},
+	} {
+		_, err := New(newRequest(c.expression, csvInput("USE"), csvOutput))
+		if err != c.err {
+			t.Errorf("%s: got %v, expect %v", c.expression, err, c.err)
+		}
+	}
+
+	_, last := runSelect(t, newRequest("SELECT age / 0 FROM S3Object", csvInput("USE"), csvOutput),
+		[]byte(testCSV))
+	if last.headers[":message-type"] != "error" || last.headers[":error-code"] != "DivisionByZero" {
+		t.Error("error event expected:", last.headers)
+	}
+}
+
+func TestSelectStats(t *testing.T) {
+	request := newRequest("SELECT name FROM S3Object WHERE age > 40", csvInput("USE"), csvOutput)
+	s, err := New(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.ReadRange(int64(len(testCSV)))
+	var out bytes.Buffer
+	if err = s.Run(strings.NewReader(testCSV), &out); err != nil {
+		t.Fatal(err)
+	}
+	messages := decodeMessages(t, out.Bytes())
+	var st stats
+	for _, m := range messages {
+		if m.headers[":event-type"] == "Stats" {
+			if err = xml.Unmarshal(m.payload, &st); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if st.BytesScanned != int64(len(testCSV)) || st.BytesProcessed != int64(len(testCSV)) ||
+		st.BytesReturned != int64(len("Carol\n")) {
+		t.Error("wrong stats:", st)
+	}
+}
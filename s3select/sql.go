@@ -0,0 +1,669 @@
+package s3select
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	. "github.com/journeymidnight/yig/error"
+)
+
+// The SQL subset supported:
+//
+//	SELECT * | expr [[AS] alias], ...
+//	FROM S3Object[[*]] [[AS] alias]
+//	[WHERE expr]
+//	[LIMIT n]
+//
+// Expressions are made of column references like `s._1`, `s.name` or
+// `s.a.b[0]`, literals, arithmetic and comparison operators, AND/OR/NOT,
+// IS [NOT] NULL, [NOT] LIKE, [NOT] BETWEEN, [NOT] IN, CAST, string functions
+// and the aggregates COUNT, SUM, AVG, MIN and MAX. A query with aggregates
+// returns one record and could not reference columns out of aggregates.
+
+type tokenType int
+
+const (
+	tokenEOF tokenType = iota
+	tokenIdent
+	tokenQuotedIdent
+	tokenString
+	tokenNumber
+	tokenSymbol
+)
+
+type token struct {
+	typ  tokenType
+	text string
+}
+
+var keywords = map[string]bool{
+	"SELECT": true, "FROM": true, "WHERE": true, "LIMIT": true, "AS": true,
+	"AND": true, "OR": true, "NOT": true, "IS": true, "NULL": true, "MISSING": true,
+	"LIKE": true, "ESCAPE": true, "BETWEEN": true, "IN": true, "TRUE": true, "FALSE": true,
+	"FOR": true,
+}
+
+func tokenize(sql string) (tokens []token, err error) {
+	runes := []rune(sql)
+	for i := 0; i < len(runes); {
+		c := runes[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '_' || unicode.IsLetter(c):
+			j := i + 1
+			for j < len(runes) && (runes[j] == '_' || unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j])) {
+				j++
+			}
+			tokens = append(tokens, token{tokenIdent, string(runes[i:j])})
+			i = j
+		case unicode.IsDigit(c) || (c == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			j := i
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.') {
+				j++
+			}
+			if j < len(runes) && (runes[j] == 'e' || runes[j] == 'E') {
+				j++
+				if j < len(runes) && (runes[j] == '+' || runes[j] == '-') {
+					j++
+				}
+				for j < len(runes) && unicode.IsDigit(runes[j]) {
+					j++
+				}
+			}
+			tokens = append(tokens, token{tokenNumber, string(runes[i:j])})
+			i = j
+		case c == '\'' || c == '"':
+			// quotes are escaped by doubling them
+			var text []rune
+			j := i + 1
+			for {
+				if j >= len(runes) {
+					return nil, ErrParseSelectFailure
+				}
+				if runes[j] == c {
+					if j+1 < len(runes) && runes[j+1] == c {
+						text = append(text, c)
+						j += 2
+						continue
+					}
+					break
+				}
+				text = append(text, runes[j])
+				j++
+			}
+			typ := tokenString
+			if c == '"' {
+				typ = tokenQuotedIdent
+			}
+			tokens = append(tokens, token{typ, string(text)})
+			i = j + 1
+		default:
+			n := 1
+			if i+1 < len(runes) {
+				switch string(runes[i : i+2]) {
+				case "<=", ">=", "<>", "!=", "||":
+					n = 2
+				}
+			}
+			if n == 1 && !strings.ContainsRune("()[],.*=<>+-/%", c) {
+				return nil, ErrParseSelectFailure
+			}
+			tokens = append(tokens, token{tokenSymbol, string(runes[i : i+n])})
+			i += n
+		}
+	}
+	return append(tokens, token{typ: tokenEOF}), nil
+}
+
+type projection struct {
+	expr expr
+	name string
+}
+
+type query struct {
+	// SELECT *
+	all         bool
+	projections []projection
+	alias       string
+	// FROM S3Object[*], elements of JSON arrays are records
+	arrayRecords bool
+	where        expr
+	limit        int64 // -1 if no limit
+	aggregates   []*aggregate
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	alias  string
+	// parsing arguments of an aggregate
+	inAggregate bool
+	// column referenced out of aggregates
+	bareColumns bool
+	aggregates  []*aggregate
+}
+
+func parseQuery(sql string) (*query, error) {
+	tokens, err := tokenize(sql)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	return p.parse()
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.typ != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isKeyword(word string) bool {
+	t := p.peek()
+	return t.typ == tokenIdent && strings.EqualFold(t.text, word)
+}
+
+func (p *parser) acceptKeyword(word string) bool {
+	if p.isKeyword(word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) isSymbol(symbol string) bool {
+	t := p.peek()
+	return t.typ == tokenSymbol && t.text == symbol
+}
+
+func (p *parser) acceptSymbol(symbol string) bool {
+	if p.isSymbol(symbol) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectSymbol(symbol string) error {
+	if !p.acceptSymbol(symbol) {
+		return ErrParseSelectFailure
+	}
+	return nil
+}
+
+// An alias is an identifier which is not a keyword
+func (p *parser) acceptAlias(required bool) (string, error) {
+	t := p.peek()
+	if t.typ == tokenQuotedIdent || (t.typ == tokenIdent && !keywords[strings.ToUpper(t.text)]) {
+		p.pos++
+		return t.text, nil
+	}
+	if required {
+		return "", ErrParseSelectFailure
+	}
+	return "", nil
+}
+
+func (p *parser) parse() (q *query, err error) {
+	q = &query{limit: -1}
+	if !p.acceptKeyword("SELECT") {
+		return nil, ErrParseSelectFailure
+	}
+	// the alias of S3Object is needed to parse column references, so FROM
+	// is parsed before projections.
+	start := p.pos
+	depth := 0
+	for ; p.peek().typ != tokenEOF; p.pos++ {
+		if p.isSymbol("(") {
+			depth++
+		} else if p.isSymbol(")") {
+			depth--
+		} else if depth == 0 && p.isKeyword("FROM") {
+			break
+		}
+	}
+	if !p.acceptKeyword("FROM") {
+		return nil, ErrParseSelectFailure
+	}
+	if err = p.parseFrom(q); err != nil {
+		return nil, err
+	}
+	if p.acceptKeyword("WHERE") {
+		if q.where, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+		if len(p.aggregates) > 0 {
+			return nil, ErrUnsupportedSqlOperation
+		}
+	}
+	if p.acceptKeyword("LIMIT") {
+		t := p.next()
+		if t.typ != tokenNumber {
+			return nil, ErrParseSelectFailure
+		}
+		if q.limit, err = strconv.ParseInt(t.text, 10, 64); err != nil || q.limit < 0 {
+			return nil, ErrParseSelectFailure
+		}
+	}
+	if p.peek().typ != tokenEOF {
+		return nil, ErrParseSelectFailure
+	}
+	end := p.pos
+
+	p.pos = start
+	p.bareColumns = false
+	if err = p.parseProjections(q); err != nil {
+		return nil, err
+	}
+	if !p.isKeyword("FROM") {
+		return nil, ErrParseSelectFailure
+	}
+	p.pos = end
+	q.aggregates = p.aggregates
+	if len(q.aggregates) > 0 && (q.all || p.bareColumns) {
+		return nil, ErrUnsupportedSqlOperation
+	}
+	return q, nil
+}
+
+func (p *parser) parseFrom(q *query) (err error) {
+	t := p.next()
+	if t.typ != tokenIdent || !strings.EqualFold(t.text, "S3Object") {
+		return ErrParseSelectFailure
+	}
+	if p.acceptSymbol("[") {
+		if err = p.expectSymbol("*"); err != nil {
+			return err
+		}
+		if err = p.expectSymbol("]"); err != nil {
+			return err
+		}
+		q.arrayRecords = true
+	}
+	if p.isSymbol(".") {
+		// paths into documents are not supported
+		return ErrUnsupportedSqlOperation
+	}
+	q.alias, err = p.acceptAlias(p.acceptKeyword("AS"))
+	p.alias = q.alias
+	return err
+}
+
+func (p *parser) parseProjections(q *query) error {
+	if p.acceptSymbol("*") {
+		q.all = true
+		return nil
+	}
+	for {
+		e, err := p.parseExpr()
+		if err != nil {
+			return err
+		}
+		name, err := p.acceptAlias(p.acceptKeyword("AS"))
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			name = "_" + strconv.Itoa(len(q.projections)+1)
+			if c, ok := e.(*columnRef); ok {
+				if last := c.path[len(c.path)-1]; !last.isIndex {
+					name = last.name
+				}
+			}
+		}
+		q.projections = append(q.projections, projection{expr: e, name: name})
+		if !p.acceptSymbol(",") {
+			return nil
+		}
+	}
+}
+
+func (p *parser) parseExpr() (expr, error) {
+	return p.parseOr()
+}
+
+func (p *parser) parseOr() (expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("OR") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logical{and: false, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (expr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("AND") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &logical{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (expr, error) {
+	if p.acceptKeyword("NOT") {
+		e, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &not{e}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (expr, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.typ == tokenSymbol {
+		switch t.text {
+		case "=", "!=", "<>", "<", "<=", ">", ">=":
+			p.pos++
+			right, err := p.parseAdditive()
+			if err != nil {
+				return nil, err
+			}
+			return &comparison{op: t.text, left: left, right: right}, nil
+		}
+	}
+	if p.acceptKeyword("IS") {
+		negated := p.acceptKeyword("NOT")
+		if !p.acceptKeyword("NULL") && !p.acceptKeyword("MISSING") {
+			return nil, ErrParseSelectFailure
+		}
+		return &isNull{e: left, negated: negated}, nil
+	}
+	negated := p.acceptKeyword("NOT")
+	switch {
+	case p.acceptKeyword("LIKE"):
+		pattern, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		l := &like{e: left, pattern: pattern, negated: negated}
+		if p.acceptKeyword("ESCAPE") {
+			if l.escape, err = p.parseAdditive(); err != nil {
+				return nil, err
+			}
+		}
+		return l, nil
+	case p.acceptKeyword("BETWEEN"):
+		low, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		if !p.acceptKeyword("AND") {
+			return nil, ErrParseSelectFailure
+		}
+		high, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		return &between{e: left, low: low, high: high, negated: negated}, nil
+	case p.acceptKeyword("IN"):
+		list, err := p.parseArguments()
+		if err != nil {
+			return nil, err
+		}
+		if len(list) == 0 {
+			return nil, ErrParseSelectFailure
+		}
+		return &in{e: left, list: list, negated: negated}, nil
+	}
+	if negated {
+		return nil, ErrParseSelectFailure
+	}
+	return left, nil
+}
+
+func (p *parser) parseAdditive() (expr, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for p.isSymbol("+") || p.isSymbol("-") || p.isSymbol("||") {
+		op := p.next().text
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = &arithmetic{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseMultiplicative() (expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isSymbol("*") || p.isSymbol("/") || p.isSymbol("%") {
+		op := p.next().text
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &arithmetic{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (expr, error) {
+	if p.acceptSymbol("-") {
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &arithmetic{op: "-", left: &literal{intValue(0)}, right: e}, nil
+	}
+	p.acceptSymbol("+")
+	return p.parsePrimary()
+}
+
+// Parses "(expr, ...)"
+func (p *parser) parseArguments() (args []expr, err error) {
+	if err = p.expectSymbol("("); err != nil {
+		return nil, err
+	}
+	if p.acceptSymbol(")") {
+		return nil, nil
+	}
+	for {
+		e, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, e)
+		if p.acceptSymbol(")") {
+			return args, nil
+		}
+		if err = p.expectSymbol(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *parser) parsePrimary() (expr, error) {
+	t := p.next()
+	switch t.typ {
+	case tokenNumber:
+		if i, err := strconv.ParseInt(t.text, 10, 64); err == nil {
+			return &literal{intValue(i)}, nil
+		}
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, ErrParseSelectFailure
+		}
+		return &literal{floatValue(f)}, nil
+	case tokenString:
+		return &literal{stringValue(t.text)}, nil
+	case tokenSymbol:
+		if t.text != "(" {
+			return nil, ErrParseSelectFailure
+		}
+		e, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		return e, p.expectSymbol(")")
+	case tokenQuotedIdent:
+		return p.parseColumnRef(pathElem{name: t.text, quoted: true})
+	case tokenIdent:
+		word := strings.ToUpper(t.text)
+		switch word {
+		case "TRUE", "FALSE":
+			return &literal{boolValue(word == "TRUE")}, nil
+		case "NULL":
+			return &literal{nullValue}, nil
+		case "MISSING":
+			return &literal{missingValue}, nil
+		}
+		if keywords[word] {
+			return nil, ErrParseSelectFailure
+		}
+		if p.isSymbol("(") {
+			return p.parseFunction(word)
+		}
+		return p.parseColumnRef(pathElem{name: t.text})
+	}
+	return nil, ErrParseSelectFailure
+}
+
+func (p *parser) parseColumnRef(first pathElem) (expr, error) {
+	c := &columnRef{path: []pathElem{first}}
+	for {
+		if p.acceptSymbol(".") {
+			t := p.next()
+			if t.typ != tokenIdent && t.typ != tokenQuotedIdent {
+				return nil, ErrParseSelectFailure
+			}
+			c.path = append(c.path, pathElem{name: t.text, quoted: t.typ == tokenQuotedIdent})
+		} else if p.acceptSymbol("[") {
+			t := p.next()
+			index, err := strconv.Atoi(t.text)
+			if t.typ != tokenNumber || err != nil || index < 0 {
+				return nil, ErrParseSelectFailure
+			}
+			if err = p.expectSymbol("]"); err != nil {
+				return nil, err
+			}
+			c.path = append(c.path, pathElem{index: index, isIndex: true})
+		} else {
+			break
+		}
+	}
+	// strip the alias of S3Object, e.g. `s` of `s._1`
+	if len(c.path) > 1 && !first.quoted {
+		if strings.EqualFold(first.name, p.alias) ||
+			(p.alias == "" && strings.EqualFold(first.name, "S3Object")) {
+			c.path = c.path[1:]
+		}
+	}
+	if c.path[0].isIndex {
+		return nil, ErrParseSelectFailure
+	}
+	if !p.inAggregate {
+		p.bareColumns = true
+	}
+	return c, nil
+}
+
+func (p *parser) parseFunction(name string) (expr, error) {
+	switch name {
+	case "COUNT", "SUM", "AVG", "MIN", "MAX":
+		if p.inAggregate {
+			return nil, ErrUnsupportedSqlOperation
+		}
+		a := &aggregate{function: name}
+		p.pos++ // (
+		if name == "COUNT" && p.acceptSymbol("*") {
+			if err := p.expectSymbol(")"); err != nil {
+				return nil, err
+			}
+		} else {
+			p.inAggregate = true
+			e, err := p.parseExpr()
+			p.inAggregate = false
+			if err != nil {
+				return nil, err
+			}
+			if err = p.expectSymbol(")"); err != nil {
+				return nil, err
+			}
+			a.arg = e
+		}
+		p.aggregates = append(p.aggregates, a)
+		return a, nil
+	case "CAST":
+		p.pos++ // (
+		e, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.acceptKeyword("AS") {
+			return nil, ErrParseSelectFailure
+		}
+		t := p.next()
+		if t.typ != tokenIdent {
+			return nil, ErrParseSelectFailure
+		}
+		c := &cast{e: e, typeName: strings.ToUpper(t.text)}
+		if _, err = stringValue("").cast(c.typeName); err == ErrUnsupportedSqlOperation {
+			return nil, err
+		}
+		return c, p.expectSymbol(")")
+	case "SUBSTRING":
+		// SUBSTRING(s FROM start [FOR length]) or SUBSTRING(s, start [, length])
+		p.pos++ // (
+		s, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		f := &function{name: name, args: []expr{s}}
+		if !p.acceptKeyword("FROM") && !p.acceptSymbol(",") {
+			return nil, ErrParseSelectFailure
+		}
+		start, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		f.args = append(f.args, start)
+		if p.acceptKeyword("FOR") || p.acceptSymbol(",") {
+			length, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			f.args = append(f.args, length)
+		}
+		return f, p.expectSymbol(")")
+	case "COALESCE", "NULLIF", "LOWER", "UPPER", "TRIM", "CHAR_LENGTH", "CHARACTER_LENGTH":
+		args, err := p.parseArguments()
+		if err != nil {
+			return nil, err
+		}
+		f := &function{name: name, args: args}
+		return f, f.checkArguments()
+	}
+	return nil, ErrUnsupportedSqlOperation
+}
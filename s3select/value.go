@@ -0,0 +1,247 @@
+package s3select
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+
+	. "github.com/journeymidnight/yig/error"
+)
+
+type kind int
+
+const (
+	// absent columns or fields, which behave like NULL but are left out
+	// from JSON output
+	kindMissing kind = iota
+	kindNull
+	kindBool
+	kindInt
+	kindFloat
+	kindString
+	// nested objects and arrays of JSON records
+	kindRaw
+)
+
+type Value struct {
+	kind kind
+	b    bool
+	i    int64
+	f    float64
+	s    string
+	raw  json.RawMessage
+}
+
+var (
+	missingValue = Value{kind: kindMissing}
+	nullValue    = Value{kind: kindNull}
+)
+
+func boolValue(b bool) Value {
+	return Value{kind: kindBool, b: b}
+}
+
+func intValue(i int64) Value {
+	return Value{kind: kindInt, i: i}
+}
+
+func floatValue(f float64) Value {
+	return Value{kind: kindFloat, f: f}
+}
+
+func stringValue(s string) Value {
+	return Value{kind: kindString, s: s}
+}
+
+// Converts a JSON value, nested objects and arrays are kept as they are
+func jsonValue(raw json.RawMessage) (Value, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return missingValue, nil
+	}
+	switch raw[0] {
+	case '{', '[':
+		return Value{kind: kindRaw, raw: raw}, nil
+	case 'n':
+		return nullValue, nil
+	case 't', 'f':
+		return boolValue(raw[0] == 't'), nil
+	case '"':
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nullValue, ErrJSONParsingError
+		}
+		return stringValue(s), nil
+	default:
+		if i, err := strconv.ParseInt(string(raw), 10, 64); err == nil {
+			return intValue(i), nil
+		}
+		f, err := strconv.ParseFloat(string(raw), 64)
+		if err != nil {
+			return nullValue, ErrJSONParsingError
+		}
+		return floatValue(f), nil
+	}
+}
+
+func (v Value) isNull() bool {
+	return v.kind == kindMissing || v.kind == kindNull
+}
+
+func (v Value) isNumber() bool {
+	return v.kind == kindInt || v.kind == kindFloat
+}
+
+// Numbers of strings, e.g. CSV columns, are used in arithmetic and
+// comparisons with numbers.
+func (v Value) toNumber() (Value, bool) {
+	switch v.kind {
+	case kindInt, kindFloat:
+		return v, true
+	case kindString:
+		s := strings.TrimSpace(v.s)
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return intValue(i), true
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return floatValue(f), true
+		}
+	}
+	return v, false
+}
+
+func (v Value) float() float64 {
+	if v.kind == kindInt {
+		return float64(v.i)
+	}
+	return v.f
+}
+
+// Text of the value in CSV output and string functions
+func (v Value) String() string {
+	switch v.kind {
+	case kindBool:
+		return strconv.FormatBool(v.b)
+	case kindInt:
+		return strconv.FormatInt(v.i, 10)
+	case kindFloat:
+		return strconv.FormatFloat(v.f, 'f', -1, 64)
+	case kindString:
+		return v.s
+	case kindRaw:
+		return string(v.raw)
+	}
+	return ""
+}
+
+// Value in JSON output
+func (v Value) json() []byte {
+	switch v.kind {
+	case kindMissing, kindNull:
+		return []byte("null")
+	case kindFloat:
+		if math.IsInf(v.f, 0) || math.IsNaN(v.f) {
+			return []byte("null")
+		}
+		return []byte(v.String())
+	case kindString:
+		buf, _ := json.Marshal(v.s)
+		return buf
+	}
+	return []byte(v.String())
+}
+
+func (v Value) cast(typeName string) (Value, error) {
+	if v.isNull() {
+		return nullValue, nil
+	}
+	switch typeName {
+	case "INT", "INTEGER":
+		n, ok := v.toNumber()
+		if !ok {
+			return nullValue, ErrInvalidCast
+		}
+		if n.kind == kindFloat {
+			return intValue(int64(n.f)), nil
+		}
+		return n, nil
+	case "FLOAT", "DECIMAL", "NUMERIC", "DOUBLE", "REAL":
+		n, ok := v.toNumber()
+		if !ok {
+			return nullValue, ErrInvalidCast
+		}
+		return floatValue(n.float()), nil
+	case "STRING", "VARCHAR", "CHAR":
+		return stringValue(v.String()), nil
+	case "BOOL", "BOOLEAN":
+		switch v.kind {
+		case kindBool:
+			return v, nil
+		case kindString:
+			b, err := strconv.ParseBool(strings.TrimSpace(v.s))
+			if err != nil {
+				return nullValue, ErrInvalidCast
+			}
+			return boolValue(b), nil
+		case kindInt:
+			return boolValue(v.i != 0), nil
+		}
+		return nullValue, ErrInvalidCast
+	}
+	return nullValue, ErrUnsupportedSqlOperation
+}
+
+// Returns the order of a and b, ok is false if they are not comparable
+func compare(a, b Value) (order int, ok bool) {
+	if a.isNumber() || b.isNumber() {
+		var aok, bok bool
+		a, aok = a.toNumber()
+		b, bok = b.toNumber()
+		if !aok || !bok {
+			return 0, false
+		}
+		if a.kind == kindInt && b.kind == kindInt {
+			return compareInt(a.i, b.i), true
+		}
+		af, bf := a.float(), b.float()
+		switch {
+		case af < bf:
+			return -1, true
+		case af > bf:
+			return 1, true
+		}
+		return 0, true
+	}
+	if a.kind != b.kind {
+		return 0, false
+	}
+	switch a.kind {
+	case kindString:
+		return strings.Compare(a.s, b.s), true
+	case kindBool:
+		if a.b == b.b {
+			return 0, true
+		}
+		if b.b {
+			return -1, true
+		}
+		return 1, true
+	case kindRaw:
+		if bytes.Equal(a.raw, b.raw) {
+			return 0, true
+		}
+	}
+	return 0, false
+}
+
+func compareInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
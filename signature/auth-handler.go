@@ -73,7 +73,7 @@ func isRequestPostPolicySignature(r *http.Request) bool {
 
 // Verify if the request has AWS Streaming Signature Version '4'. This is only valid for 'PUT' operation.
 func isRequestSignStreamingV4(r *http.Request) bool {
-	return r.Header.Get("X-Amz-Content-Sha256") == streamingContentSHA256 &&
+	return isStreamingPayload(r.Header.Get("X-Amz-Content-Sha256")) &&
 		r.Method == http.MethodPut
 }
 
@@ -43,8 +43,26 @@ const (
 	streamingContentSHA256   = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
 	signV4ChunkedAlgorithm   = "AWS4-HMAC-SHA256-PAYLOAD"
 	streamingContentEncoding = "aws-chunked"
+
+	// Streaming with trailing headers, e.g. x-amz-checksum-crc32c, after the last chunk
+	streamingContentSHA256Trailer   = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD-TRAILER"
+	streamingUnsignedPayloadTrailer = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+	signV4TrailerAlgorithm          = "AWS4-HMAC-SHA256-TRAILER"
+	trailerSignatureHeader          = "x-amz-trailer-signature"
 )
 
+// Implemented by readers of aws-chunked body, trailers are available after
+// the body is read to EOF.
+type TrailerReader interface {
+	io.Reader
+	Trailer(name string) string
+}
+
+func isStreamingPayload(payload string) bool {
+	return payload == streamingContentSHA256 || payload == streamingContentSHA256Trailer ||
+		payload == streamingUnsignedPayloadTrailer
+}
+
 // getChunkSignature - get chunk signature.
 func getChunkSignature(cred common.Credential, seedSignature string, region string, date time.Time, hashedChunk string) string {
 	// Calculate string to sign.
@@ -64,6 +82,17 @@ func getChunkSignature(cred common.Credential, seedSignature string, region stri
 	return newSignature
 }
 
+// getTrailerSignature - get signature of trailing headers, chained after the last chunk.
+func getTrailerSignature(cred common.Credential, seedSignature string, region string, date time.Time, hashedTrailer string) string {
+	stringToSign := signV4TrailerAlgorithm + "\n" +
+		date.Format(datatype.Iso8601Format) + "\n" +
+		getScope(date, region) + "\n" +
+		seedSignature + "\n" +
+		hashedTrailer
+	signingKey := getSigningKey(cred.SecretAccessKey, date, region)
+	return getSignature(signingKey, stringToSign)
+}
+
 // calculateSeedSignature - Calculate seed signature in accordance with
 //     - http://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-streaming.html
 // returns signature, error otherwise if the signature mismatches or any other
@@ -82,10 +111,11 @@ func CalculateSeedSignature(r *http.Request) (credential common.Credential, sign
 	}
 
 	// Payload streaming.
-	payload := streamingContentSHA256
+	payload := req.Header.Get("X-Amz-Content-Sha256")
 
-	// Payload for STREAMING signature should be 'STREAMING-AWS4-HMAC-SHA256-PAYLOAD'
-	if payload != req.Header.Get("X-Amz-Content-Sha256") {
+	// Payload for STREAMING signature should be 'STREAMING-AWS4-HMAC-SHA256-PAYLOAD',
+	// or its variants with trailers
+	if !isStreamingPayload(payload) {
 		return credential, "", "", time.Time{}, ErrContentSHA256Mismatch
 	}
 
@@ -160,6 +190,7 @@ func newSignV4ChunkedReader(req *http.Request) (io.Reader, error) {
 		return nil, err
 	}
 
+	payload := req.Header.Get("X-Amz-Content-Sha256")
 	return &s3ChunkedReader{
 		reader:            bufio.NewReader(req.Body),
 		cred:              credential,
@@ -168,6 +199,9 @@ func newSignV4ChunkedReader(req *http.Request) (io.Reader, error) {
 		region:            region,
 		chunkSHA256Writer: sha256.New(),
 		state:             readChunkHeader,
+		unsigned:          payload == streamingUnsignedPayloadTrailer,
+		withTrailer:       payload != streamingContentSHA256,
+		trailers:          make(map[string]string),
 	}, nil
 }
 
@@ -185,6 +219,57 @@ type s3ChunkedReader struct {
 	chunkSHA256Writer hash.Hash // Calculates sha256 of chunk data.
 	n                 uint64    // Unread bytes in chunk
 	err               error
+	unsigned          bool              // chunks are not signed
+	withTrailer       bool              // trailing headers follow the last chunk
+	trailers          map[string]string // lower cased name -> value
+}
+
+// Trailer returns value of trailing header `name`, "" if not present.
+func (cr *s3ChunkedReader) Trailer(name string) string {
+	return cr.trailers[strings.ToLower(name)]
+}
+
+// Read trailing headers until an empty line, and verify their signature if signed.
+func (cr *s3ChunkedReader) readTrailers() error {
+	var trailerBuf bytes.Buffer
+	var signature string
+	for {
+		line, err := cr.reader.ReadSlice('\n')
+		if err == io.EOF && len(trimTrailingWhitespace(line)) == 0 {
+			break
+		}
+		if err != nil {
+			if err == bufio.ErrBufferFull {
+				return errLineTooLong
+			}
+			return err
+		}
+		line = trimTrailingWhitespace(line)
+		if len(line) == 0 {
+			break
+		}
+		colon := bytes.IndexByte(line, ':')
+		if colon < 0 {
+			return errMalformedEncoding
+		}
+		name := strings.ToLower(strings.TrimSpace(string(line[:colon])))
+		value := strings.TrimSpace(string(line[colon+1:]))
+		if name == trailerSignatureHeader {
+			signature = value
+			continue
+		}
+		cr.trailers[name] = value
+		trailerBuf.WriteString(name + ":" + value + "\n")
+	}
+	if cr.unsigned {
+		return nil
+	}
+	hashedTrailer := hex.EncodeToString(sum256(trailerBuf.Bytes()))
+	newSignature := getTrailerSignature(cr.cred, cr.seedSignature, cr.region, cr.seedDate, hashedTrailer)
+	if !compareSignatureV4(signature, newSignature) {
+		return ErrSignatureDoesNotMatch
+	}
+	return nil
 }
 
 // Read chunk reads the chunk token signature portion.
@@ -214,6 +299,7 @@ const (
 	readChunkTrailer
 	readChunk
 	verifyChunk
+	readTrailer
 	eofChunk
 )
 
@@ -228,6 +314,8 @@ func (cs chunkState) String() string {
 		stateString = "readChunk"
 	case verifyChunk:
 		stateString = "verifyChunk"
+	case readTrailer:
+		stateString = "readTrailer"
 	case eofChunk:
 		stateString = "eofChunk"
 
@@ -253,9 +341,12 @@ func (cr *s3ChunkedReader) Read(buf []byte) (n int, err error) {
 			}
 			cr.state = readChunk
 		case readChunkTrailer:
-			cr.err = readCRLF(cr.reader)
-			if cr.err != nil {
-				return 0, errMalformedEncoding
+			// trailing headers follow the last chunk header directly
+			if !(cr.lastChunk && cr.withTrailer) {
+				cr.err = readCRLF(cr.reader)
+				if cr.err != nil {
+					return 0, errMalformedEncoding
+				}
 			}
 			cr.state = verifyChunk
 		case readChunk:
@@ -293,24 +384,34 @@ func (cr *s3ChunkedReader) Read(buf []byte) (n int, err error) {
 				continue
 			}
 		case verifyChunk:
-			// Calculate the hashed chunk.
-			hashedChunk := hex.EncodeToString(cr.chunkSHA256Writer.Sum(nil))
-			// Calculate the chunk signature.
-			newSignature := getChunkSignature(cr.cred, cr.seedSignature, cr.region, cr.seedDate, hashedChunk)
-			if !compareSignatureV4(cr.chunkSignature, newSignature) {
-				// Chunk signature doesn't match we return signature does not match.
-				cr.err = ErrSignatureDoesNotMatch
-				return 0, cr.err
+			if !cr.unsigned {
+				// Calculate the hashed chunk.
+				hashedChunk := hex.EncodeToString(cr.chunkSHA256Writer.Sum(nil))
+				// Calculate the chunk signature.
+				newSignature := getChunkSignature(cr.cred, cr.seedSignature, cr.region, cr.seedDate, hashedChunk)
+				if !compareSignatureV4(cr.chunkSignature, newSignature) {
+					// Chunk signature doesn't match we return signature does not match.
+					cr.err = ErrSignatureDoesNotMatch
+					return 0, cr.err
+				}
+				// Newly calculated signature becomes the seed for the next chunk
+				// this follows the chaining.
+				cr.seedSignature = newSignature
 			}
-			// Newly calculated signature becomes the seed for the next chunk
-			// this follows the chaining.
-			cr.seedSignature = newSignature
 			cr.chunkSHA256Writer.Reset()
-			if cr.lastChunk {
+			if cr.lastChunk && cr.withTrailer {
+				cr.state = readTrailer
+			} else if cr.lastChunk {
 				cr.state = eofChunk
 			} else {
 				cr.state = readChunkHeader
 			}
+		case readTrailer:
+			cr.err = cr.readTrailers()
+			if cr.err != nil {
+				return 0, cr.err
+			}
+			cr.state = eofChunk
 		case eofChunk:
 			return n, io.EOF
 		}
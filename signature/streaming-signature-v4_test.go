@@ -19,8 +19,10 @@ package signature
 import (
 	"bufio"
 	"bytes"
+	"crypto/sha256"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"strings"
 	"testing"
 )
@@ -194,3 +196,26 @@ func TestParseHexUint(t *testing.T) {
 		}
 	}
 }
+
+// Test reading unsigned aws-chunked body with trailing checksum.
+func TestUnsignedChunkedReaderTrailer(t *testing.T) {
+	body := "5\r\nhello\r\n6\r\n world\r\n0\r\nx-amz-checksum-crc32:DUoRhQ==\r\n\r\n"
+	cr := &s3ChunkedReader{
+		reader:            bufio.NewReader(strings.NewReader(body)),
+		chunkSHA256Writer: sha256.New(),
+		state:             readChunkHeader,
+		unsigned:          true,
+		withTrailer:       true,
+		trailers:          make(map[string]string),
+	}
+	data, err := ioutil.ReadAll(cr)
+	if err != nil {
+		t.Fatal("read error:", err)
+	}
+	if string(data) != "hello world" {
+		t.Fatalf("data read %q, want %q", data, "hello world")
+	}
+	if got := cr.Trailer("X-Amz-Checksum-Crc32"); got != "DUoRhQ==" {
+		t.Fatalf("trailer %q, want %q", got, "DUoRhQ==")
+	}
+}
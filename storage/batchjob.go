@@ -0,0 +1,633 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/journeymidnight/yig/api/datatype"
+	"github.com/journeymidnight/yig/crypto"
+	. "github.com/journeymidnight/yig/error"
+	"github.com/journeymidnight/yig/helper"
+	"github.com/journeymidnight/yig/iam/common"
+	meta "github.com/journeymidnight/yig/meta/types"
+)
+
+// Batch jobs apply an operation to every key listed in a manifest, e.g. to
+// move millions of objects to another storage class. Jobs are created from
+// admin API and saved in the `batchjob` table. The runner of every instance
+// claims an active job for `batchJobLease`, reads keys of its manifest in
+// chunks, and processes keys of a chunk by `Concurrency` workers. Results of
+// a chunk are written into a report file before the marker of the job is
+// saved, so keys are processed at least once if instances crash. Status of
+// the job is checked between chunks, i.e. pausing or cancelling a job takes
+// effect once the chunk in progress is done.
+
+const (
+	batchJobLease          = 10 * time.Minute
+	batchJobChunkSize      = 1000
+	batchJobScanLimit      = 10
+	batchJobListLimit      = 100
+	maxBatchJobConcurrency = 256
+	maxBatchJobError       = 1024
+)
+
+var errBatchCopyUnsupported = errors.New("objects encrypted with customer keys could not be copied")
+
+type batchKey struct {
+	bucket  string
+	key     string
+	version string // latest version if empty
+}
+
+// Creates an active job from the operation, manifest and report of `job`,
+// `Concurrency` defaults to `batch_job_concurrency`.
+func (yig *YigStorage) CreateBatchJob(job meta.BatchJob) (meta.BatchJob, error) {
+	err := yig.validateBatchJob(&job)
+	if err != nil {
+		return job, err
+	}
+	if job.Concurrency <= 0 {
+		job.Concurrency = helper.CONFIG.BatchJobConcurrency
+	}
+	if job.Concurrency <= 0 {
+		// this instance does not run batch jobs, neither has a default
+		job.Concurrency = 1
+	}
+	if job.Concurrency > maxBatchJobConcurrency {
+		job.Concurrency = maxBatchJobConcurrency
+	}
+	job.Id = string(helper.GenerateRandomId())
+	job.Status = meta.BatchJobStatusActive
+	job.Total, job.Succeeded, job.Failed = 0, 0, 0
+	job.Marker, job.ReportFiles, job.LastError = "", nil, ""
+	err = yig.MetaStorage.PutBatchJob(&job)
+	if err != nil {
+		return job, err
+	}
+	helper.Logger.Println(5, "Created batch job", job.Id, "operation:", job.Operation.Type,
+		"manifest:", job.Manifest.Bucket, job.Manifest.Key, job.Manifest.Prefix)
+	return job, nil
+}
+
+func (yig *YigStorage) validateBatchJob(job *meta.BatchJob) error {
+	operation := job.Operation
+	switch operation.Type {
+	case meta.BatchOperationCopy:
+		if operation.TargetBucket == "" && operation.StorageClass == "" {
+			return ErrInvalidBatchJob
+		}
+		if operation.StorageClass != "" {
+			if _, err := meta.MatchStorageClassIndex(operation.StorageClass); err != nil {
+				return ErrInvalidStorageClass
+			}
+		}
+		if operation.TargetBucket != "" {
+			if _, err := yig.MetaStorage.GetBucket(operation.TargetBucket, true); err != nil {
+				return err
+			}
+		}
+	case meta.BatchOperationSetAcl:
+		if err := datatype.IsValidCannedAcl(datatype.Acl{CannedAcl: operation.CannedAcl}); err != nil {
+			return err
+		}
+	case meta.BatchOperationSetTags:
+		if err := operation.Tagging.Validate(datatype.MaxObjectTagCount); err != nil {
+			return err
+		}
+	case meta.BatchOperationDelete:
+	case meta.BatchOperationRestore:
+		if operation.RestoreDays <= 0 {
+			return ErrInvalidBatchJob
+		}
+		switch operation.RestoreTier {
+		case "", datatype.RestoreTierStandard, datatype.RestoreTierBulk, datatype.RestoreTierExpedited:
+			break
+		default:
+			return ErrInvalidBatchJob
+		}
+	default:
+		return ErrInvalidBatchJob
+	}
+
+	if job.Manifest.Bucket == "" || job.Report.Bucket == "" {
+		return ErrInvalidBatchJob
+	}
+	if job.Manifest.Key != "" {
+		manifest, err := yig.MetaStorage.GetObject(job.Manifest.Bucket, job.Manifest.Key, true)
+		if err != nil {
+			return err
+		}
+		if job.Manifest.ETag != "" && job.Manifest.ETag != manifest.Etag {
+			return ErrInvalidBatchJob
+		}
+		job.Manifest.ETag = manifest.Etag
+		job.Manifest.Prefix = ""
+	} else if _, err := yig.MetaStorage.GetBucket(job.Manifest.Bucket, true); err != nil {
+		return err
+	}
+	if _, err := yig.MetaStorage.GetBucket(job.Report.Bucket, true); err != nil {
+		return err
+	}
+	switch job.Report.Scope {
+	case "":
+		job.Report.Scope = meta.BatchReportScopeAll
+	case meta.BatchReportScopeAll, meta.BatchReportScopeFailed:
+	default:
+		return ErrInvalidBatchJob
+	}
+	return nil
+}
+
+func (yig *YigStorage) GetBatchJob(id string) (job meta.BatchJob, err error) {
+	found, err := yig.MetaStorage.GetBatchJob(id)
+	if err != nil {
+		return
+	}
+	if found == nil {
+		return job, ErrNoSuchBatchJob
+	}
+	return *found, nil
+}
+
+// Latest jobs first
+func (yig *YigStorage) ListBatchJobs() ([]meta.BatchJob, error) {
+	return yig.MetaStorage.ListBatchJobs(batchJobListLimit)
+}
+
+func (yig *YigStorage) PauseBatchJob(id string) (meta.BatchJob, error) {
+	return yig.setBatchJobStatus(id, []string{meta.BatchJobStatusActive}, meta.BatchJobStatusPaused)
+}
+
+func (yig *YigStorage) ResumeBatchJob(id string) (meta.BatchJob, error) {
+	return yig.setBatchJobStatus(id, []string{meta.BatchJobStatusPaused}, meta.BatchJobStatusActive)
+}
+
+func (yig *YigStorage) CancelBatchJob(id string) (meta.BatchJob, error) {
+	return yig.setBatchJobStatus(id, []string{meta.BatchJobStatusActive, meta.BatchJobStatusPaused},
+		meta.BatchJobStatusCancelled)
+}
+
+func (yig *YigStorage) setBatchJobStatus(id string, from []string, to string) (job meta.BatchJob, err error) {
+	updated, err := yig.MetaStorage.SetBatchJobStatus(id, from, to)
+	if err != nil {
+		return
+	}
+	job, err = yig.GetBatchJob(id)
+	if err != nil {
+		return
+	}
+	if !updated && job.Status != to {
+		return job, ErrInvalidBatchJobStatus
+	}
+	helper.Logger.Println(5, "Batch job", id, "is", to)
+	return job, nil
+}
+
+// Claim active jobs and run them one at a time on this instance
+func (yig *YigStorage) runBatchJobs() {
+	yig.WaitGroup.Add(1)
+	defer yig.WaitGroup.Done()
+	for !yig.Stopping {
+		jobs, err := yig.MetaStorage.ScanRunnableBatchJobs(batchJobScanLimit)
+		if err != nil {
+			helper.Logger.Println(2, "failed to scan batch jobs, err:", err)
+		}
+		var ran bool
+		for i := range jobs {
+			if yig.Stopping {
+				return
+			}
+			claimed, err := yig.MetaStorage.ClaimBatchJob(&jobs[i], batchJobLease)
+			if err != nil {
+				helper.Logger.Println(2, "failed to claim batch job", jobs[i].Id, "err:", err)
+				continue
+			}
+			if claimed {
+				yig.runBatchJob(&jobs[i])
+				ran = true
+				break
+			}
+		}
+		if !ran {
+			time.Sleep(5 * time.Second)
+		}
+	}
+}
+
+// Process keys of the claimed `job` chunk by chunk, until the job is done,
+// paused, cancelled, or yig is stopping.
+func (yig *YigStorage) runBatchJob(job *meta.BatchJob) {
+	helper.Logger.Println(5, "Run batch job", job.Id, "from marker", job.Marker)
+	for {
+		current, err := yig.MetaStorage.GetBatchJob(job.Id)
+		if err != nil {
+			yig.releaseBatchJob(job, err)
+			return
+		}
+		if current == nil || current.Status != meta.BatchJobStatusActive {
+			yig.releaseBatchJob(job, nil)
+			return
+		}
+
+		keys, marker, done, err := yig.readBatchManifest(job, batchJobChunkSize)
+		if err != nil {
+			if _, ok := err.(ApiError); ok {
+				// the manifest is removed, changed or malformed
+				yig.failBatchJob(job, err)
+			} else {
+				yig.releaseBatchJob(job, err)
+			}
+			return
+		}
+		errs, completed, lost := yig.processBatchKeys(job, keys)
+		if lost {
+			// progress is not saved, not to overwrite that of the new owner
+			return
+		}
+		if !completed {
+			// keys of the chunk are processed again by the next run
+			yig.releaseBatchJob(job, nil)
+			return
+		}
+		err = yig.writeBatchReport(job, keys, errs)
+		if err != nil {
+			if err == ErrNoSuchBucket {
+				yig.failBatchJob(job, err)
+			} else {
+				yig.releaseBatchJob(job, err)
+			}
+			return
+		}
+
+		job.Total += int64(len(keys))
+		for _, err := range errs {
+			if err == nil {
+				job.Succeeded++
+			} else {
+				job.Failed++
+			}
+		}
+		job.Marker = marker
+		job.LeaseTime = time.Now().Add(batchJobLease)
+		err = yig.MetaStorage.UpdateBatchJobProgress(job)
+		if err != nil {
+			helper.Logger.Println(2, "failed to update batch job", job.Id, "err:", err)
+			return
+		}
+		if done {
+			_, err = yig.MetaStorage.SetBatchJobStatus(job.Id, []string{meta.BatchJobStatusActive},
+				meta.BatchJobStatusCompleted)
+			if err != nil {
+				helper.Logger.Println(2, "failed to complete batch job", job.Id, "err:", err)
+			}
+			helper.Logger.Println(5, "Batch job", job.Id, "is completed, total:", job.Total,
+				"succeeded:", job.Succeeded, "failed:", job.Failed)
+			return
+		}
+	}
+}
+
+func truncateBatchJobError(err error) string {
+	message := err.Error()
+	if len(message) > maxBatchJobError {
+		message = message[:maxBatchJobError]
+	}
+	return message
+}
+
+// Let the job be claimed again right away, `err` is kept in LastError if any
+func (yig *YigStorage) releaseBatchJob(job *meta.BatchJob, err error) {
+	if err != nil {
+		helper.Logger.Println(2, "batch job", job.Id, "is interrupted, err:", err)
+		job.LastError = truncateBatchJobError(err)
+	}
+	job.LeaseTime = time.Now()
+	err = yig.MetaStorage.UpdateBatchJobProgress(job)
+	if err != nil {
+		helper.Logger.Println(2, "failed to release batch job", job.Id, "err:", err)
+	}
+}
+
+func (yig *YigStorage) failBatchJob(job *meta.BatchJob, err error) {
+	helper.Logger.Println(2, "batch job", job.Id, "failed, err:", err)
+	yig.releaseBatchJob(job, err)
+	_, err = yig.MetaStorage.SetBatchJobStatus(job.Id, []string{meta.BatchJobStatusActive},
+		meta.BatchJobStatusFailed)
+	if err != nil {
+		helper.Logger.Println(2, "failed to set batch job", job.Id, "failed, err:", err)
+	}
+}
+
+// Keys after the marker of the job, at most `limit` of them. Returns the
+// marker after them, and `done` if the manifest is read through.
+func (yig *YigStorage) readBatchManifest(job *meta.BatchJob, limit int) (keys []batchKey, marker string,
+	done bool, err error) {
+
+	if job.Manifest.Key == "" {
+		return yig.listBatchKeys(job.Manifest, job.Marker, limit)
+	}
+	return yig.readBatchKeys(job.Manifest, job.Marker, limit)
+}
+
+// Latest versions of objects named after `marker`, delete markers skipped.
+// `marker` is the last object name read.
+func (yig *YigStorage) listBatchKeys(manifest meta.BatchJobManifest, marker string, limit int) (keys []batchKey,
+	nextMarker string, done bool, err error) {
+
+	// versions of `marker` are all skipped
+	objects, err := yig.MetaStorage.ScanObjectVersions(manifest.Bucket, manifest.Prefix, marker,
+		math.MaxUint64, limit)
+	if err != nil {
+		return
+	}
+	nextMarker = marker
+	for _, object := range objects {
+		// versions are newest first, the rest of the latest are skipped
+		if object.Name == nextMarker {
+			continue
+		}
+		nextMarker = object.Name
+		if object.DeleteMarker {
+			continue
+		}
+		keys = append(keys, batchKey{bucket: manifest.Bucket, key: object.Name})
+	}
+	return keys, nextMarker, len(objects) < limit, nil
+}
+
+// Lines of the CSV manifest after `marker`, the byte offset of the line
+// to read next.
+func (yig *YigStorage) readBatchKeys(manifest meta.BatchJobManifest, marker string, limit int) (keys []batchKey,
+	nextMarker string, done bool, err error) {
+
+	var offset int64
+	if marker != "" {
+		offset, err = strconv.ParseInt(marker, 10, 64)
+		if err != nil {
+			return
+		}
+	}
+	object, err := yig.MetaStorage.GetObject(manifest.Bucket, manifest.Key, true)
+	if err != nil {
+		return
+	}
+	if object.Etag != manifest.ETag {
+		return nil, "", false, ErrInvalidBatchJob
+	}
+	if offset >= object.Size {
+		return nil, marker, true, nil
+	}
+
+	pipeReader, pipeWriter := io.Pipe()
+	go func() {
+		pipeWriter.CloseWithError(yig.GetObject(object, offset, object.Size-offset, pipeWriter,
+			datatype.SseRequest{}))
+	}()
+	// stop reading once enough keys are read
+	defer pipeReader.Close()
+	reader := bufio.NewReader(pipeReader)
+	for len(keys) < limit {
+		line, readErr := reader.ReadString('\n')
+		if readErr != nil && readErr != io.EOF {
+			return nil, "", false, readErr
+		}
+		key, ok, err := parseBatchManifestLine(line)
+		if err != nil {
+			helper.Logger.Printf(2, "malformed line at offset %d of manifest %s/%s: %q",
+				offset, manifest.Bucket, manifest.Key, line)
+			return nil, "", false, err
+		}
+		offset += int64(len(line))
+		if ok {
+			keys = append(keys, key)
+		}
+		if readErr == io.EOF {
+			done = true
+			break
+		}
+	}
+	if offset >= object.Size {
+		done = true
+	}
+	return keys, strconv.FormatInt(offset, 10), done, nil
+}
+
+// Parse "bucket,key[,versionId]" with the key URL encoded, `ok` is false
+// for empty lines.
+func parseBatchManifestLine(line string) (key batchKey, ok bool, err error) {
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return key, false, nil
+	}
+	record, err := csv.NewReader(strings.NewReader(line)).Read()
+	if err != nil || len(record) < 2 || len(record) > 3 || record[0] == "" {
+		return key, false, ErrInvalidBatchJob
+	}
+	key.bucket = record[0]
+	key.key, err = url.QueryUnescape(record[1])
+	if err != nil || key.key == "" {
+		return key, false, ErrInvalidBatchJob
+	}
+	if len(record) == 3 {
+		key.version = record[2]
+	}
+	return key, true, nil
+}
+
+// Apply the operation of the job to `keys` by `Concurrency` workers. The
+// lease of the job is renewed meanwhile. Returns false in `completed` if
+// yig is stopping or the lease could not be renewed, and true in `lost`
+// for the latter, as the job might be claimed by others or cancelled.
+func (yig *YigStorage) processBatchKeys(job *meta.BatchJob, keys []batchKey) (errs []error,
+	completed bool, lost bool) {
+
+	errs = make([]error, len(keys))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < job.Concurrency && i < len(keys); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				errs[i] = yig.runBatchOperation(job.Operation, keys[i])
+			}
+		}()
+	}
+	completed = true
+	for i := range keys {
+		if yig.Stopping {
+			completed = false
+			break
+		}
+		if time.Until(job.LeaseTime) < batchJobLease/2 {
+			claimed, err := yig.MetaStorage.ClaimBatchJob(job, batchJobLease)
+			if err != nil || !claimed {
+				helper.Logger.Println(2, "failed to renew lease of batch job", job.Id, "err:", err)
+				completed, lost = false, true
+				break
+			}
+		}
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return errs, completed, lost
+}
+
+// Keys are processed as the owner of their bucket
+func (yig *YigStorage) runBatchOperation(operation meta.BatchJobOperation, key batchKey) (err error) {
+	bucket, err := yig.MetaStorage.GetBucket(key.bucket, true)
+	if err != nil {
+		return err
+	}
+	credential := common.Credential{UserId: bucket.OwnerId}
+	switch operation.Type {
+	case meta.BatchOperationCopy:
+		return yig.copyObjectForBatch(operation, key)
+	case meta.BatchOperationSetAcl:
+		return yig.SetObjectAcl(key.bucket, key.key, key.version, datatype.AccessControlPolicy{},
+			datatype.Acl{CannedAcl: operation.CannedAcl}, credential)
+	case meta.BatchOperationSetTags:
+		_, err = yig.SetObjectTagging(key.bucket, key.key, key.version, operation.Tagging, credential)
+		return err
+	case meta.BatchOperationDelete:
+		_, err = yig.DeleteObject(key.bucket, key.key, key.version, credential)
+		return err
+	case meta.BatchOperationRestore:
+		tier := operation.RestoreTier
+		if tier == "" {
+			tier = datatype.RestoreTierStandard
+		}
+		_, err = yig.RestoreObject(key.bucket, key.key, key.version, datatype.RestoreRequest{
+			Days:                 operation.RestoreDays,
+			GlacierJobParameters: &datatype.GlacierJobParameters{Tier: tier},
+		}, credential)
+		if err == ErrRestoreAlreadyInProgress {
+			return nil
+		}
+		return err
+	}
+	return ErrNotImplemented
+}
+
+// Copy the object version to the target bucket of the operation, or rewrite
+// it in place, with the storage class of the operation.
+func (yig *YigStorage) copyObjectForBatch(operation meta.BatchJobOperation, key batchKey) (err error) {
+	var object *meta.Object
+	if key.version == "" {
+		object, err = yig.MetaStorage.GetObject(key.bucket, key.key, true)
+	} else {
+		object, err = yig.getObjWithVersion(key.bucket, key.key, key.version)
+	}
+	if err != nil {
+		return err
+	}
+	if object.DeleteMarker {
+		return ErrNoSuchKey
+	}
+	if object.SseType == crypto.SSEC.String() {
+		return errBatchCopyUnsupported
+	}
+	target := key.bucket
+	if operation.TargetBucket != "" {
+		target = operation.TargetBucket
+	}
+	targetBucket, err := yig.MetaStorage.GetBucket(target, true)
+	if err != nil {
+		return err
+	}
+	storageClass := object.StorageClass
+	if operation.StorageClass != "" {
+		storageClass, _ = meta.MatchStorageClassIndex(operation.StorageClass)
+	}
+
+	var reader io.Reader = bytes.NewReader(nil)
+	if object.Size > 0 {
+		pipeReader, pipeWriter := io.Pipe()
+		go func() {
+			pipeWriter.CloseWithError(yig.GetObject(object, 0, object.Size, pipeWriter,
+				datatype.SseRequest{}))
+		}()
+		// stop reading if writing fails
+		defer pipeReader.Close()
+		reader = pipeReader
+	}
+	metadata, sseRequest := copiedObjectMetadata(object)
+	_, err = yig.PutObject(target, object.Name, common.Credential{UserId: targetBucket.OwnerId},
+		object.Size, reader, metadata, object.ACL, sseRequest, storageClass, datatype.ChecksumRequest{},
+		object.Tagging)
+	return err
+}
+
+// Metadata and encryption of copies of `object` written by PutObject
+func copiedObjectMetadata(object *meta.Object) (metadata map[string]string, sseRequest datatype.SseRequest) {
+	metadata = make(map[string]string)
+	for k, v := range object.CustomAttributes {
+		metadata[k] = v
+	}
+	delete(metadata, "md5Sum")
+	metadata["Content-Type"] = object.ContentType
+	if object.SseType == crypto.S3.String() {
+		sseRequest.Type = object.SseType
+	}
+	return
+}
+
+func batchReportPrefix(job *meta.BatchJob) string {
+	prefix := job.Report.Prefix
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return prefix + "job-" + job.Id + "/results/"
+}
+
+// Results of a chunk are written into one report file, named by its index,
+// so a chunk processed again overwrites its file. Lines of the file are
+// "bucket,key,versionId,status,errorCode,errorMessage" with keys URL encoded.
+func (yig *YigStorage) writeBatchReport(job *meta.BatchJob, keys []batchKey, errs []error) error {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	for i, key := range keys {
+		status, code, message := "succeeded", "", ""
+		if errs[i] != nil {
+			status = "failed"
+			if apiErr, ok := errs[i].(ApiError); ok {
+				code, message = apiErr.AwsErrorCode(), apiErr.Description()
+			} else {
+				code, message = ErrInternalError.AwsErrorCode(), errs[i].Error()
+			}
+		} else if job.Report.Scope == meta.BatchReportScopeFailed {
+			continue
+		}
+		writer.Write([]string{key.bucket, url.QueryEscape(key.key), key.version, status, code, message})
+	}
+	writer.Flush()
+	if buffer.Len() == 0 {
+		return nil
+	}
+
+	bucket, err := yig.MetaStorage.GetBucket(job.Report.Bucket, true)
+	if err != nil {
+		return err
+	}
+	name := batchReportPrefix(job) + fmt.Sprintf("%08d.csv", len(job.ReportFiles))
+	_, err = yig.PutObject(job.Report.Bucket, name, common.Credential{UserId: bucket.OwnerId},
+		int64(buffer.Len()), &buffer, map[string]string{"Content-Type": "text/csv"},
+		datatype.Acl{CannedAcl: "private"}, datatype.SseRequest{}, meta.ObjectStorageClassStandard,
+		datatype.ChecksumRequest{}, datatype.Tagging{})
+	if err != nil {
+		return err
+	}
+	job.ReportFiles = append(job.ReportFiles, name)
+	return nil
+}
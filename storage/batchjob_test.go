@@ -0,0 +1,30 @@
+package storage
+
+import (
+	"testing"
+
+	. "github.com/journeymidnight/yig/error"
+)
+
+func TestParseBatchManifestLine(t *testing.T) {
+	for _, c := range []struct {
+		line string
+		key  batchKey
+		ok   bool
+		err  error
+	}{
+		{"bucket,a%2Fb+c.txt\n", batchKey{bucket: "bucket", key: "a/b c.txt"}, true, nil},
+		{"bucket,key,version\r\n", batchKey{bucket: "bucket", key: "key", version: "version"}, true, nil},
+		{`"bucket","k%2C1",null`, batchKey{bucket: "bucket", key: "k,1", version: "null"}, true, nil},
+		{"\n", batchKey{}, false, nil},
+		{"bucket\n", batchKey{}, false, ErrInvalidBatchJob},
+		{"bucket,key,version,extra\n", batchKey{}, false, ErrInvalidBatchJob},
+		{",key\n", batchKey{}, false, ErrInvalidBatchJob},
+		{"bucket,%zz\n", batchKey{}, false, ErrInvalidBatchJob},
+	} {
+		key, ok, err := parseBatchManifestLine(c.line)
+		if err != c.err || ok != c.ok || (ok && key != c.key) {
+			t.Errorf("%q: got %+v %v %v, expect %+v %v %v", c.line, key, ok, err, c.key, c.ok, c.err)
+		}
+	}
+}
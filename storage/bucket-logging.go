@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"github.com/journeymidnight/yig/api/datatype"
+	"github.com/journeymidnight/yig/helper"
+	"github.com/journeymidnight/yig/iam/common"
+	meta "github.com/journeymidnight/yig/meta/types"
+)
+
+// Access records of buckets with logging enabled are buffered in memory of
+// each instance, one buffer per bucket logged, and written into the target
+// buckets through PutObject as objects named
+//
+//   <TargetPrefix>YYYY-mm-DD-HH-MM-SS-<random id>
+//
+// every `bucket_logging_flush_interval` seconds, or once a buffer is over
+// `bucket_logging_buffer_size`. Records buffered are lost if the process
+// crashes.
+
+type bucketLogBuffer struct {
+	target  datatype.LoggingEnabled
+	owner   string
+	records bytes.Buffer
+}
+
+type bucketLogger struct {
+	mutex   sync.Mutex
+	buffers map[string]*bucketLogBuffer // bucket logged -> records
+}
+
+func newBucketLogger() *bucketLogger {
+	return &bucketLogger{
+		buffers: make(map[string]*bucketLogBuffer),
+	}
+}
+
+func (yig *YigStorage) AppendBucketLog(bucket *meta.Bucket, record string) {
+	if bucket == nil || bucket.Logging.LoggingEnabled == nil {
+		return
+	}
+	l := yig.bucketLogger
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	buffer, ok := l.buffers[bucket.Name]
+	if ok && buffer.target != *bucket.Logging.LoggingEnabled {
+		// target changed, records before go to the old one
+		yig.flushBucketLogBuffer(buffer)
+		ok = false
+	}
+	if !ok {
+		buffer = &bucketLogBuffer{
+			target: *bucket.Logging.LoggingEnabled,
+			owner:  bucket.OwnerId,
+		}
+		l.buffers[bucket.Name] = buffer
+	}
+	buffer.records.WriteString(record)
+	buffer.records.WriteByte('\n')
+	if int64(buffer.records.Len()) >= helper.CONFIG.BucketLoggingBufferSize {
+		yig.flushBucketLogBuffer(buffer)
+		delete(l.buffers, bucket.Name)
+	}
+}
+
+// Write records of `buffer` in background, must be called with mutex held
+func (yig *YigStorage) flushBucketLogBuffer(buffer *bucketLogBuffer) {
+	yig.WaitGroup.Add(1)
+	go func() {
+		defer yig.WaitGroup.Done()
+		yig.writeBucketLog(buffer)
+	}()
+}
+
+func (yig *YigStorage) writeBucketLog(buffer *bucketLogBuffer) {
+	objectName := buffer.target.TargetPrefix + time.Now().UTC().Format("2006-01-02-15-04-05") +
+		"-" + string(helper.GenerateRandomId())
+	size := int64(buffer.records.Len())
+	_, err := yig.PutObject(buffer.target.TargetBucket, objectName, common.Credential{UserId: buffer.owner},
+		size, &buffer.records, map[string]string{"Content-Type": "text/plain"},
+		datatype.Acl{CannedAcl: "private"}, datatype.SseRequest{}, meta.ObjectStorageClassStandard,
+		datatype.ChecksumRequest{}, datatype.Tagging{})
+	if err != nil {
+		helper.Logger.Printf(2, "failed to write %d bytes of access logs into %s/%s, err: %v",
+			size, buffer.target.TargetBucket, objectName, err)
+		return
+	}
+	helper.Logger.Printf(10, "succeed to write access logs into %s/%s", buffer.target.TargetBucket,
+		objectName)
+}
+
+// Flush buffers periodically, and all of them when stopping
+func (yig *YigStorage) flushBucketLogs() {
+	yig.WaitGroup.Add(1)
+	defer yig.WaitGroup.Done()
+	l := yig.bucketLogger
+	last := time.Now()
+	for {
+		stopping := yig.Stopping
+		interval := time.Duration(helper.CONFIG.BucketLoggingFlushInterval) * time.Second
+		if stopping || time.Since(last) >= interval {
+			l.mutex.Lock()
+			buffers := l.buffers
+			l.buffers = make(map[string]*bucketLogBuffer)
+			l.mutex.Unlock()
+			for _, buffer := range buffers {
+				yig.writeBucketLog(buffer)
+			}
+			last = time.Now()
+		}
+		if stopping {
+			return
+		}
+		time.Sleep(1 * time.Second)
+	}
+}
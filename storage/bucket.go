@@ -61,6 +61,8 @@ func (yig *YigStorage) MakeBucket(bucketName string, acl datatype.Acl,
 	}
 	if err == nil {
 		yig.MetaStorage.Cache.Remove(redis.UserTable, credential.UserId)
+		// drop NoSuchBucket cached by lookups before the bucket is created
+		yig.MetaStorage.Cache.Remove(redis.BucketTable, bucketName)
 	}
 	return err
 }
@@ -220,6 +222,129 @@ func (yig *YigStorage) GetBucketCors(bucketName string,
 	return bucket.CORS, nil
 }
 
+func (yig *YigStorage) SetBucketNotification(bucketName string, notification datatype.Notification,
+	credential common.Credential) error {
+
+	bucket, err := yig.MetaStorage.GetBucket(bucketName, false)
+	if err != nil {
+		return err
+	}
+	if bucket.OwnerId != credential.UserId {
+		return ErrBucketAccessForbidden
+	}
+	bucket.Notification = notification
+	err = yig.MetaStorage.Client.PutBucket(*bucket)
+	if err != nil {
+		return err
+	}
+	yig.MetaStorage.Cache.Remove(redis.BucketTable, bucketName)
+	return nil
+}
+
+// An empty configuration is returned if notification is not configured.
+// Secrets for signing events are not returned.
+func (yig *YigStorage) GetBucketNotification(bucketName string,
+	credential common.Credential) (notification datatype.Notification, err error) {
+
+	bucket, err := yig.MetaStorage.GetBucket(bucketName, true)
+	if err != nil {
+		return notification, err
+	}
+	if bucket.OwnerId != credential.UserId {
+		err = ErrBucketAccessForbidden
+		return
+	}
+	for _, config := range bucket.Notification.TopicConfigurations {
+		config.Secret = ""
+		notification.TopicConfigurations = append(notification.TopicConfigurations, config)
+	}
+	return notification, nil
+}
+
+// Target bucket must be owned by the owner of bucket logged, since log
+// objects are written as the owner.
+func (yig *YigStorage) SetBucketLogging(bucketName string, logging datatype.BucketLoggingStatus,
+	credential common.Credential) error {
+
+	bucket, err := yig.MetaStorage.GetBucket(bucketName, false)
+	if err != nil {
+		return err
+	}
+	if bucket.OwnerId != credential.UserId {
+		return ErrBucketAccessForbidden
+	}
+	if logging.LoggingEnabled != nil {
+		target, err := yig.MetaStorage.GetBucket(logging.LoggingEnabled.TargetBucket, true)
+		if err == ErrNoSuchBucket {
+			return ErrInvalidTargetBucketForLogging
+		} else if err != nil {
+			return err
+		}
+		if target.OwnerId != credential.UserId {
+			return ErrInvalidTargetBucketForLogging
+		}
+	}
+	bucket.Logging = logging
+	err = yig.MetaStorage.Client.PutBucket(*bucket)
+	if err != nil {
+		return err
+	}
+	yig.MetaStorage.Cache.Remove(redis.BucketTable, bucketName)
+	return nil
+}
+
+func (yig *YigStorage) GetBucketLogging(bucketName string,
+	credential common.Credential) (logging datatype.BucketLoggingStatus, err error) {
+
+	bucket, err := yig.MetaStorage.GetBucket(bucketName, true)
+	if err != nil {
+		return logging, err
+	}
+	if bucket.OwnerId != credential.UserId {
+		err = ErrBucketAccessForbidden
+		return
+	}
+	return bucket.Logging, nil
+}
+
+// Empty `website` removes the website configuration
+func (yig *YigStorage) SetBucketWebsite(bucketName string, website datatype.WebsiteConfiguration,
+	credential common.Credential) error {
+
+	bucket, err := yig.MetaStorage.GetBucket(bucketName, false)
+	if err != nil {
+		return err
+	}
+	if bucket.OwnerId != credential.UserId {
+		return ErrBucketAccessForbidden
+	}
+	bucket.Website = website
+	err = yig.MetaStorage.Client.PutBucket(*bucket)
+	if err != nil {
+		return err
+	}
+	yig.MetaStorage.Cache.Remove(redis.BucketTable, bucketName)
+	return nil
+}
+
+func (yig *YigStorage) GetBucketWebsite(bucketName string,
+	credential common.Credential) (website datatype.WebsiteConfiguration, err error) {
+
+	bucket, err := yig.MetaStorage.GetBucket(bucketName, true)
+	if err != nil {
+		return website, err
+	}
+	if bucket.OwnerId != credential.UserId {
+		err = ErrBucketAccessForbidden
+		return
+	}
+	if !bucket.Website.IsEnabled() {
+		err = ErrNoSuchWebsiteConfiguration
+		return
+	}
+	return bucket.Website, nil
+}
+
 func (yig *YigStorage) SetBucketVersioning(bucketName string, versioning datatype.Versioning,
 	credential common.Credential) error {
 
@@ -230,6 +355,9 @@ func (yig *YigStorage) SetBucketVersioning(bucketName string, versioning datatyp
 	if bucket.OwnerId != credential.UserId {
 		return ErrBucketAccessForbidden
 	}
+	if len(bucket.Replication.Rules) != 0 && versioning.Status != "Enabled" {
+		return ErrReplicationRequiresVersioning
+	}
 	bucket.Versioning = versioning.Status
 	err = yig.MetaStorage.Client.PutBucket(*bucket)
 	if err != nil {
@@ -612,3 +740,22 @@ func (yig *YigStorage) ListVersionedObjects(credential common.Credential, bucket
 
 	return
 }
+
+// Set compression of data written into the bucket later, "" to disable it.
+// Data written before are kept as is.
+func (yig *YigStorage) SetBucketCompression(bucketName string, compression string) error {
+	if !IsSupportedCompression(compression) {
+		return ErrInvalidCompression
+	}
+	bucket, err := yig.MetaStorage.GetBucket(bucketName, false)
+	if err != nil {
+		return err
+	}
+	bucket.Compression = compression
+	err = yig.MetaStorage.Client.PutBucket(*bucket)
+	if err != nil {
+		return err
+	}
+	yig.MetaStorage.Cache.Remove(redis.BucketTable, bucketName)
+	return nil
+}
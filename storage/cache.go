@@ -2,124 +2,245 @@ package storage
 
 import (
 	"io"
+	"strconv"
 
-	"bytes"
 	"github.com/journeymidnight/yig/helper"
-	meta "github.com/journeymidnight/yig/meta/types"
 	"github.com/journeymidnight/yig/redis"
 )
 
 const (
-	// only objects smaller than threshold are cached
-	FILE_CACHE_THRESHOLD_SIZE = 4 << 20 // 4M
+	// at most this many missing chunks are read from Ceph in one request
+	DATA_CACHE_MAX_MISS_CHUNKS = 8
 )
 
+// Object data are cached in chunks of `chunkSize` bytes, aligned to the
+// beginning of rados objects. Chunks are cached as stored in Ceph, i.e.
+// still encrypted for SSE objects, so `chunkSize` must be a multiple of
+// AES_BLOCK_SIZE to serve aligned readers.
 type DataCache interface {
-	WriteFromCache(object *meta.Object, startOffset int64, length int64,
-		out io.Writer, writeThrough func(io.Writer) error,
-		onCacheMiss func(io.Writer) error) error
-	GetAlignedReader(object *meta.Object, startOffset int64, length int64,
-		readThrough func() (io.ReadCloser, error),
-		onCacheMiss func(io.Writer) error) (io.ReadCloser, error)
-	Remove(key string)
+	// Get a reader of the stored bytes in [offset, offset+length) of the rados
+	// object identified by `key`, whose total size is `size`.
+	// `readThrough` reads a range from Ceph, it's used for cache missed chunks
+	// or as the whole workflow when cache is disabled.
+	GetReader(key string, size int64, offset int64, length int64,
+		readThrough func(offset int64, length int64) (io.ReadCloser, error)) (io.ReadCloser, error)
 }
 
-type enabledDataCache struct {
+// Chunks of the same key never change except the last one growing for
+// appendable objects, so chunk length is checked against object size
+// instead of explicit invalidation.
+type chunkStore interface {
+	// Returns nil if missed
+	Get(key string) []byte
+	Put(key string, chunk []byte)
+}
 
+type enabledDataCache struct {
+	chunkSize int64
+	store     chunkStore
+	// objects larger than it are read through without caching, 0 for no limit
+	maxObjectSize int64
 }
 
 type disabledDataCache struct{}
 
 func newDataCache(cacheEnabled bool) (d DataCache) {
-	if cacheEnabled {
-		return &enabledDataCache{}
+	if !cacheEnabled {
+		return &disabledDataCache{}
 	}
 
-	return &disabledDataCache{}
+	chunkSize := int64(helper.CONFIG.DataCacheChunkSize)
+	chunkSize = (chunkSize + AES_BLOCK_SIZE - 1) / AES_BLOCK_SIZE * AES_BLOCK_SIZE
+	var store chunkStore
+	var maxObjectSize int64
+	switch helper.CONFIG.DataCacheBackend {
+	case "disk":
+		s, err := newDiskChunkStore(helper.CONFIG.DataCacheDiskPath, helper.CONFIG.DataCacheDiskCapacity)
+		if err != nil {
+			helper.Logger.Panic(0, "PANIC: Failed to initialize data cache on disk:", err)
+		}
+		store = s
+	default:
+		store = &redisChunkStore{expire: helper.CONFIG.DataCacheRedisTTL}
+		maxObjectSize = helper.CONFIG.DataCacheRedisMaxSize
+	}
+	helper.Logger.Println(5, "Data cache backend:", helper.CONFIG.DataCacheBackend,
+		"chunk size:", chunkSize, "max object size:", maxObjectSize)
+	return &enabledDataCache{
+		chunkSize:     chunkSize,
+		store:         store,
+		maxObjectSize: maxObjectSize,
+	}
 }
 
-// `writeThrough` performs normal workflow without cache
-// `onCacheMiss` should be able to read the WHOLE object
-func (d *enabledDataCache) WriteFromCache(object *meta.Object, startOffset int64, length int64,
-	out io.Writer, writeThrough func(io.Writer) error, onCacheMiss func(io.Writer) error) error {
-
-	if object.Size > FILE_CACHE_THRESHOLD_SIZE {
-		return writeThrough(out)
-	}
+// Key of the data cache for a rados object
+func dataCacheKey(location string, pool string, oid string, version string) string {
+	return location + ":" + pool + ":" + oid + ":" + version
+}
 
-	cacheKey := object.BucketName + ":" + object.Name + ":" + object.GetVersionId()
+func (d *enabledDataCache) GetReader(key string, size int64, offset int64, length int64,
+	readThrough func(offset int64, length int64) (io.ReadCloser, error)) (io.ReadCloser, error) {
 
-	file, err := redis.GetBytes(cacheKey, startOffset, startOffset+length-1)
-	if err == nil && file != nil && int64(len(file)) == length {
-		helper.Debugln("File cache HIT. key:", cacheKey, "range:", startOffset, startOffset+length-1)
-		_, err := out.Write(file)
-		return err
+	if offset+length > size {
+		length = size - offset
 	}
+	if length <= 0 {
+		return newReadCloser(nil), nil
+	}
+	if d.maxObjectSize > 0 && size > d.maxObjectSize {
+		return readThrough(offset, length)
+	}
+	return &chunkedReader{
+		cache:       d,
+		key:         key,
+		size:        size,
+		offset:      offset,
+		end:         offset + length,
+		readThrough: readThrough,
+		fetched:     make(map[int64][]byte),
+	}, nil
+}
 
-	helper.Debugln("File cache MISS. key:", cacheKey , "range:", startOffset, startOffset+length-1)
-
-	var buffer bytes.Buffer
-	onCacheMiss(&buffer)
+func (d *disabledDataCache) GetReader(key string, size int64, offset int64, length int64,
+	readThrough func(offset int64, length int64) (io.ReadCloser, error)) (io.ReadCloser, error) {
 
-	redis.SetBytes(cacheKey, buffer.Bytes())
-	_, err = out.Write(buffer.Bytes()[startOffset : startOffset+length])
-	return err
+	return readThrough(offset, length)
 }
 
-func (d *disabledDataCache) WriteFromCache(object *meta.Object, startOffset int64, length int64,
-	out io.Writer, writeThrough func(io.Writer) error, onCacheMiss func(io.Writer) error) error {
-
-	return writeThrough(out)
+func (d *enabledDataCache) chunkKey(key string, index int64) string {
+	return key + ":" + strconv.FormatInt(d.chunkSize, 10) + ":" + strconv.FormatInt(index, 10)
 }
 
-// actually get a `ReadCloser`, aligned to AES_BLOCK_SIZE for encryption
-// `readThrough` performs normal workflow without cache
-// `onCacheMiss` should be able to read the WHOLE object
-// FIXME: this API causes an extra memory copy, need to patch radix to fix it
-func (d *enabledDataCache) GetAlignedReader(object *meta.Object, startOffset int64, length int64,
-	readThrough func() (io.ReadCloser, error),
-	onCacheMiss func(io.Writer) error) (io.ReadCloser, error) {
+// chunkedReader serves [offset, end) chunk by chunk, reading consecutive
+// missed chunks from Ceph in one request and caching them.
+type chunkedReader struct {
+	cache       *enabledDataCache
+	key         string
+	size        int64
+	offset      int64 // current reading position in the rados object
+	end         int64
+	readThrough func(offset int64, length int64) (io.ReadCloser, error)
+
+	current []byte           // unread bytes of current chunk
+	fetched map[int64][]byte // chunks got but not served yet
+}
 
-	if object.Size > FILE_CACHE_THRESHOLD_SIZE {
-		return readThrough()
+func (r *chunkedReader) chunkLength(index int64) int64 {
+	chunkSize := r.cache.chunkSize
+	if (index+1)*chunkSize > r.size {
+		return r.size - index*chunkSize
 	}
+	return chunkSize
+}
 
-	alignedOffset := startOffset / AES_BLOCK_SIZE * AES_BLOCK_SIZE
-	length += startOffset - alignedOffset
-	startOffset = alignedOffset
-
-	cacheKey := object.BucketName + ":" + object.Name + ":" + object.GetVersionId()
+func (r *chunkedReader) getCachedChunk(index int64) []byte {
+	chunk := r.cache.store.Get(r.cache.chunkKey(r.key, index))
+	if int64(len(chunk)) != r.chunkLength(index) {
+		return nil
+	}
+	return chunk
+}
 
-	file, err := redis.GetBytes(cacheKey, startOffset, startOffset+length-1)
-	if err == nil && file != nil && int64(len(file)) == length {
-		helper.Debugln("File cache HIT")
-		r := newReadCloser(file)
-		return r, nil
+// Read chunks [first, first+count) from Ceph and put them into cache
+func (r *chunkedReader) readChunks(first int64, count int64) error {
+	chunkSize := r.cache.chunkSize
+	start := first * chunkSize
+	length := (count-1)*chunkSize + r.chunkLength(first+count-1)
+	reader, err := r.readThrough(start, length)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	buffer := make([]byte, length)
+	_, err = io.ReadFull(reader, buffer)
+	if err != nil {
+		return err
+	}
+	for i := int64(0); i < count; i++ {
+		chunk := buffer[i*chunkSize : i*chunkSize+r.chunkLength(first+i)]
+		r.cache.store.Put(r.cache.chunkKey(r.key, first+i), chunk)
+		r.fetched[first+i] = chunk
 	}
+	return nil
+}
 
-	helper.Debugln("File cache MISS")
+func (r *chunkedReader) nextChunk() error {
+	chunkSize := r.cache.chunkSize
+	index := r.offset / chunkSize
+	chunk, ok := r.fetched[index]
+	if ok {
+		delete(r.fetched, index)
+	} else {
+		chunk = r.getCachedChunk(index)
+	}
+	if chunk == nil {
+		helper.Debugln("Data cache MISS. key:", r.key, "chunk:", index)
+		// find following missed chunks to read them together
+		lastIndex := (r.end - 1) / chunkSize
+		count := int64(1)
+		for index+count <= lastIndex && count < DATA_CACHE_MAX_MISS_CHUNKS {
+			if c := r.getCachedChunk(index + count); c != nil {
+				r.fetched[index+count] = c
+				break
+			}
+			count++
+		}
+		err := r.readChunks(index, count)
+		if err != nil {
+			return err
+		}
+		chunk = r.fetched[index]
+		delete(r.fetched, index)
+	} else {
+		helper.Debugln("Data cache HIT. key:", r.key, "chunk:", index)
+	}
 
-	var buffer bytes.Buffer
-	onCacheMiss(&buffer)
+	chunkEnd := (index + 1) * chunkSize
+	if chunkEnd > r.end {
+		chunkEnd = r.end
+	}
+	r.current = chunk[r.offset-index*chunkSize : chunkEnd-index*chunkSize]
+	return nil
+}
 
-	redis.SetBytes(cacheKey, buffer.Bytes())
-	r := newReadCloser(buffer.Bytes()[startOffset : startOffset+length])
-	return r, nil
+func (r *chunkedReader) Read(p []byte) (n int, err error) {
+	if len(r.current) == 0 {
+		if r.offset >= r.end {
+			return 0, io.EOF
+		}
+		err = r.nextChunk()
+		if err != nil {
+			return 0, err
+		}
+	}
+	n = copy(p, r.current)
+	r.current = r.current[n:]
+	r.offset += int64(n)
+	return n, nil
 }
 
-func (d *disabledDataCache) GetAlignedReader(object *meta.Object, startOffset int64, length int64,
-	readThrough func() (io.ReadCloser, error),
-	onCacheMiss func(io.Writer) error) (io.ReadCloser, error) {
+func (r *chunkedReader) Close() error {
+	r.current = nil
+	r.fetched = nil
+	return nil
+}
 
-	return readThrough()
+// Chunks in redis expire after `expire` seconds, so they are evictable under
+// volatile-* `maxmemory-policy` too
+type redisChunkStore struct {
+	expire int
 }
 
-func (d *enabledDataCache) Remove(key string) {
-	redis.Remove(redis.FileTable, key)
+func (s *redisChunkStore) Get(key string) []byte {
+	chunk, err := redis.GetBytes(key, 0, -1)
+	if err != nil {
+		return nil
+	}
+	return chunk
 }
 
-func (d *disabledDataCache) Remove(key string) {
-	return
+func (s *redisChunkStore) Put(key string, chunk []byte) {
+	redis.SetBytes(key, chunk, s.expire)
 }
 
 type ReadCloser struct {
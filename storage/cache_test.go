@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+type memoryChunkStore struct {
+	chunks map[string][]byte
+}
+
+func (s *memoryChunkStore) Get(key string) []byte {
+	return s.chunks[key]
+}
+
+func (s *memoryChunkStore) Put(key string, chunk []byte) {
+	s.chunks[key] = append([]byte(nil), chunk...)
+}
+
+type rangeRead struct {
+	offset, length int64
+}
+
+func readRange(t *testing.T, cache DataCache, data []byte, offset, length int64,
+	reads *[]rangeRead) []byte {
+
+	readThrough := func(offset int64, length int64) (io.ReadCloser, error) {
+		*reads = append(*reads, rangeRead{offset, length})
+		return ioutil.NopCloser(bytes.NewReader(data[offset : offset+length])), nil
+	}
+	reader, err := cache.GetReader("key", int64(len(data)), offset, length, readThrough)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	result, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestChunkedReader(t *testing.T) {
+	data := make([]byte, 1000)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	cache := &enabledDataCache{
+		chunkSize: 64,
+		store:     &memoryChunkStore{chunks: make(map[string][]byte)},
+	}
+
+	var reads []rangeRead
+	// chunks 1, 2
+	if r := readRange(t, cache, data, 100, 50, &reads); !bytes.Equal(r, data[100:150]) {
+		t.Fatal("wrong data of first read")
+	}
+	if len(reads) != 1 || reads[0] != (rangeRead{64, 128}) {
+		t.Fatal("first read should fetch chunk 1 and 2 together:", reads)
+	}
+
+	// chunks 0 to 4, partial hit on chunk 1 and 2
+	reads = nil
+	if r := readRange(t, cache, data, 10, 300, &reads); !bytes.Equal(r, data[10:310]) {
+		t.Fatal("wrong data of partial hit read")
+	}
+	if len(reads) != 2 || reads[0] != (rangeRead{0, 64}) || reads[1] != (rangeRead{192, 128}) {
+		t.Fatal("only missed chunks should be read:", reads)
+	}
+
+	// last chunk is shorter, reading beyond the end is clamped
+	reads = nil
+	if r := readRange(t, cache, data, 900, 500, &reads); !bytes.Equal(r, data[900:]) {
+		t.Fatal("wrong data of tail read")
+	}
+	if len(reads) != 1 || reads[0] != (rangeRead{896, 104}) {
+		t.Fatal("wrong read of tail:", reads)
+	}
+
+	// object appended, cached tail chunk is too short and should be ignored
+	data = append(data, make([]byte, 20)...)
+	reads = nil
+	if r := readRange(t, cache, data, 990, 30, &reads); !bytes.Equal(r, data[990:]) {
+		t.Fatal("wrong data after append")
+	}
+	if len(reads) != 1 || reads[0] != (rangeRead{960, 60}) {
+		t.Fatal("stale tail chunk should be read again:", reads)
+	}
+
+	// missed chunks in the middle are read at most DATA_CACHE_MAX_MISS_CHUNKS at a time
+	reads = nil
+	if r := readRange(t, cache, data, 0, 1020, &reads); !bytes.Equal(r, data) {
+		t.Fatal("wrong data of whole object")
+	}
+	if len(reads) != 2 || reads[0] != (rangeRead{320, 512}) || reads[1] != (rangeRead{832, 64}) {
+		t.Fatal("wrong reads of whole object:", reads)
+	}
+	reads = nil
+	if r := readRange(t, cache, data, 0, 1020, &reads); !bytes.Equal(r, data) || len(reads) != 0 {
+		t.Fatal("whole object should be served from cache:", reads)
+	}
+}
+
+func TestMaxObjectSize(t *testing.T) {
+	data := make([]byte, 1000)
+	store := &memoryChunkStore{chunks: make(map[string][]byte)}
+	cache := &enabledDataCache{
+		chunkSize:     64,
+		store:         store,
+		maxObjectSize: 999,
+	}
+
+	var reads []rangeRead
+	if r := readRange(t, cache, data, 100, 50, &reads); !bytes.Equal(r, data[100:150]) {
+		t.Fatal("wrong data of large object")
+	}
+	if len(reads) != 1 || reads[0] != (rangeRead{100, 50}) || len(store.chunks) != 0 {
+		t.Fatal("large object should be read through without caching:", reads)
+	}
+}
+
+func TestDiskChunkStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yig-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := newDiskChunkStore(dir, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Put("a", make([]byte, 40))
+	s.Put("b", make([]byte, 40))
+	if s.Get("a") == nil { // "a" becomes most recently used
+		t.Fatal("a should be cached")
+	}
+	s.Put("c", make([]byte, 40))
+	if s.Get("b") != nil {
+		t.Error("b should be evicted")
+	}
+	if len(s.Get("a")) != 40 || len(s.Get("c")) != 40 {
+		t.Error("a and c should be cached")
+	}
+	if s.used != 80 {
+		t.Error("wrong used size:", s.used)
+	}
+	s.Put("d", make([]byte, 200))
+	if s.Get("d") != nil {
+		t.Error("chunk larger than capacity should not be cached")
+	}
+}
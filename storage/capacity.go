@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/journeymidnight/radoshttpd/rados"
+	"github.com/journeymidnight/yig/helper"
+)
+
+const (
+	// reading this object from a pool tells the latency of the pool
+	CAPACITY_PROBE_OID = "yig-capacity-probe"
+	RADOS_ENOENT       = -2
+)
+
+// Status of a pool in a Ceph cluster, refreshed by the capacity monitor.
+// All pools share the used space of the cluster.
+type PoolStatus struct {
+	UsedPercent int
+	Latency     time.Duration
+	ProbeFailed bool
+	UpdatedAt   time.Time
+}
+
+func (s PoolStatus) IsSoftFenced() bool {
+	return s.UsedPercent >= helper.CONFIG.CephSoftUsedPercent
+}
+
+func (s PoolStatus) IsHardFenced() bool {
+	return s.UsedPercent >= helper.CONFIG.CephHardUsedPercent
+}
+
+// Pools failed to probe or responding slowly are avoided like soft fenced ones
+func (s PoolStatus) IsSlow() bool {
+	return s.ProbeFailed || s.Latency > time.Duration(helper.CONFIG.CephSlowLatency)*time.Millisecond
+}
+
+// Configured `weight` scaled by free space, in 1/100 of `weight`
+func (s PoolStatus) EffectiveWeight(weight int) int {
+	if s.IsHardFenced() {
+		return 0
+	}
+	return weight * (100 - s.UsedPercent)
+}
+
+// Returns false if status of `pool` is not got yet
+func (cluster *CephStorage) GetPoolStatus(pool string) (status PoolStatus, ok bool) {
+	cluster.StatusMutex.RLock()
+	defer cluster.StatusMutex.RUnlock()
+	status, ok = cluster.PoolStatus[pool]
+	return
+}
+
+// Returns true if `pool` is known to be over hard threshold
+func (cluster *CephStorage) IsFull(pool string) bool {
+	status, ok := cluster.GetPoolStatus(pool)
+	return ok && status.IsHardFenced()
+}
+
+func (cluster *CephStorage) probeLatency(pool string) (latency time.Duration, err error) {
+	p, err := cluster.Conn.OpenPool(pool)
+	if err != nil {
+		return 0, err
+	}
+	defer p.Destroy()
+	buffer := make([]byte, 1)
+	start := time.Now()
+	_, err = p.Read(CAPACITY_PROBE_OID, buffer, 0)
+	latency = time.Since(start)
+	if e, ok := err.(rados.RadosError); ok && int(e) == RADOS_ENOENT {
+		err = nil
+	}
+	return latency, err
+}
+
+func (cluster *CephStorage) refreshPoolStatus() {
+	pct, err := cluster.GetUsedSpacePercent()
+	if err != nil {
+		// keep the last known status
+		helper.Logger.Println(5, "Error getting used space:", err, "fsid:", cluster.Name)
+		return
+	}
+	now := time.Now()
+	status := make(map[string]PoolStatus, len(cluster.Circuits))
+	for pool := range cluster.Circuits {
+		latency, err := cluster.probeLatency(pool)
+		if err != nil {
+			helper.Logger.Println(5, "Error probing pool", pool, "fsid:", cluster.Name, "err:", err)
+		}
+		status[pool] = PoolStatus{
+			UsedPercent: pct,
+			Latency:     latency,
+			ProbeFailed: err != nil,
+			UpdatedAt:   now,
+		}
+	}
+	if pct >= helper.CONFIG.CephHardUsedPercent {
+		helper.Logger.Println(0, "Cluster used space", pct, "exceeds hard threshold, writes are fenced. fsid:", cluster.Name)
+	} else if pct >= helper.CONFIG.CephSoftUsedPercent {
+		helper.Logger.Println(5, "Cluster used space", pct, "exceeds soft threshold. fsid:", cluster.Name)
+	}
+
+	cluster.StatusMutex.Lock()
+	cluster.PoolStatus = status
+	cluster.StatusMutex.Unlock()
+}
+
+func (yig *YigStorage) refreshClusterStatus() {
+	for _, cluster := range yig.DataStorage {
+		cluster.refreshPoolStatus()
+	}
+}
+
+// Refresh used space and latency of all clusters periodically
+func (yig *YigStorage) monitorCapacity() {
+	yig.WaitGroup.Add(1)
+	defer yig.WaitGroup.Done()
+	interval := time.Duration(helper.CONFIG.CephCapacityCheckInterval) * time.Second
+	last := time.Now()
+	for {
+		if yig.Stopping {
+			return
+		}
+		time.Sleep(1 * time.Second)
+		if time.Since(last) < interval {
+			continue
+		}
+		yig.refreshClusterStatus()
+		last = time.Now()
+	}
+}
@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/journeymidnight/yig/helper"
+)
+
+func TestPoolStatusFence(t *testing.T) {
+	helper.CONFIG.CephSoftUsedPercent = 80
+	helper.CONFIG.CephHardUsedPercent = 90
+
+	cases := []struct {
+		used       int
+		soft, hard bool
+		weight     int
+	}{
+		{0, false, false, 1000},
+		{50, false, false, 500},
+		{80, true, false, 200},
+		{89, true, false, 110},
+		{90, true, true, 0},
+		{100, true, true, 0},
+	}
+	for _, c := range cases {
+		s := PoolStatus{UsedPercent: c.used}
+		if s.IsSoftFenced() != c.soft || s.IsHardFenced() != c.hard {
+			t.Error("wrong fence of used percent", c.used)
+		}
+		if w := s.EffectiveWeight(10); w != c.weight {
+			t.Error("effective weight of used percent", c.used, "is", w, "expect", c.weight)
+		}
+	}
+}
+
+func TestPoolStatusSlow(t *testing.T) {
+	helper.CONFIG.CephSlowLatency = 1000
+
+	cases := []struct {
+		status PoolStatus
+		slow   bool
+	}{
+		{PoolStatus{Latency: 10 * time.Millisecond}, false},
+		{PoolStatus{Latency: time.Second}, false},
+		{PoolStatus{Latency: 2 * time.Second}, true},
+		{PoolStatus{ProbeFailed: true}, true},
+	}
+	for _, c := range cases {
+		if c.status.IsSlow() != c.slow {
+			t.Error("wrong slowness of pool status", c.status)
+		}
+	}
+}
@@ -2,12 +2,15 @@ package storage
 
 import (
 	"container/list"
+	"context"
 	"errors"
 	"io"
 	"io/ioutil"
 	"sync"
 
+	"github.com/cep21/circuit"
 	"github.com/journeymidnight/radoshttpd/rados"
+	"github.com/journeymidnight/yig/circuitbreak"
 	"github.com/journeymidnight/yig/log"
 	"time"
 	"fmt"
@@ -30,12 +33,16 @@ const (
 )
 
 type CephStorage struct {
-	Name       string
-	Conn       *rados.Conn
-	InstanceId uint64
-	Logger     *log.Logger
-	CountMutex *sync.Mutex
-	Counter    uint64
+	Name        string
+	ConfigFile  string
+	Conn        *rados.Conn
+	InstanceId  uint64
+	Logger      *log.Logger
+	CountMutex  *sync.Mutex
+	Counter     uint64
+	Circuits    map[string]*circuit.Circuit // pool name -> circuit
+	StatusMutex *sync.RWMutex
+	PoolStatus  map[string]PoolStatus // pool name -> status
 }
 
 func NewCephStorage(configFile string, logger *log.Logger) *CephStorage {
@@ -68,11 +75,23 @@ func NewCephStorage(configFile string, logger *log.Logger) *CephStorage {
 	id := Rados.GetInstanceID()
 
 	cluster := CephStorage{
-		Conn:       Rados,
-		Name:       name,
-		InstanceId: id,
-		Logger:     logger,
-		CountMutex: new(sync.Mutex),
+		Conn:        Rados,
+		Name:        name,
+		ConfigFile:  configFile,
+		InstanceId:  id,
+		Logger:      logger,
+		CountMutex:  new(sync.Mutex),
+		StatusMutex: new(sync.RWMutex),
+		PoolStatus:  make(map[string]PoolStatus),
+		Circuits: map[string]*circuit.Circuit{
+			SMALL_FILE_POOLNAME: circuitbreak.NewCephCircuit(name + "/" + SMALL_FILE_POOLNAME),
+			BIG_FILE_POOLNAME:   circuitbreak.NewCephCircuit(name + "/" + BIG_FILE_POOLNAME),
+		},
+	}
+
+	if helper.CONFIG.ArchivePool != "" {
+		cluster.Circuits[helper.CONFIG.ArchivePool] = circuitbreak.NewCephCircuit(name + "/" +
+			helper.CONFIG.ArchivePool)
 	}
 
 	logger.Printf(5, "Ceph Cluster %s is ready, InstanceId is %d\n", name, id)
@@ -138,6 +157,17 @@ func (c *CephStorage) Shutdown() {
 	c.Conn.Shutdown()
 }
 
+// Circuit of `pool`, a nil circuit runs everything directly
+func (cluster *CephStorage) circuit(pool string) *circuit.Circuit {
+	return cluster.Circuits[pool]
+}
+
+// Returns false if the circuit of `pool` is tripped
+func (cluster *CephStorage) IsHealthy(pool string) bool {
+	c := cluster.circuit(pool)
+	return c == nil || !c.IsOpen()
+}
+
 func (cluster *CephStorage) doSmallPut(poolname string, oid string, data io.Reader) (size int64, err error) {
 	pool, err := cluster.Conn.OpenPool(poolname)
 	if err != nil {
@@ -148,7 +178,8 @@ func (cluster *CephStorage) doSmallPut(poolname string, oid string, data io.Read
 	buf, err := ioutil.ReadAll(data)
 	size = int64(len(buf))
 	if err != nil {
-		return 0, errors.New("Read from client failed")
+		// failures of clients should not trip the circuit
+		return 0, circuit.SimpleBadRequest{Err: errors.New("Read from client failed")}
 	}
 	err = pool.WriteSmallObject(oid, buf)
 	if err != nil {
@@ -163,6 +194,9 @@ type RadosSmallDownloader struct {
 	offset    int64
 	remaining int64
 	pool      *rados.Pool
+	cluster   *CephStorage
+	poolName  string
+	inflight  sync.WaitGroup // abandoned reads might still be running
 }
 
 func (rd *RadosSmallDownloader) Read(p []byte) (n int, err error) {
@@ -172,7 +206,11 @@ func (rd *RadosSmallDownloader) Read(p []byte) (n int, err error) {
 	if int64(len(p)) > rd.remaining {
 		p = p[:rd.remaining]
 	}
-	count, err := rd.pool.Read(rd.oid, p, uint64(rd.offset))
+	// abandoned reads might run after rd.offset is moved
+	offset := uint64(rd.offset)
+	count, err := rd.cluster.read(rd.poolName, &rd.inflight, p, func(buf []byte) (int, error) {
+		return rd.pool.Read(rd.oid, buf, offset)
+	})
 	if count == 0 {
 		return 0, io.EOF
 	}
@@ -194,11 +232,34 @@ func (rd *RadosSmallDownloader) Seek(offset int64, whence int) (int64, error) {
 }
 
 func (rd *RadosSmallDownloader) Close() error {
-	rd.pool.Destroy()
+	go func() {
+		rd.inflight.Wait()
+		rd.pool.Destroy()
+	}()
 	return nil
 }
 
 func (cluster *CephStorage) Put(poolname string, oid string, data io.Reader) (size int64, err error) {
+	err = cluster.circuit(poolname).Execute(context.Background(), func(ctx context.Context) (err error) {
+		size, err = cluster.doPut(poolname, oid, data)
+		return err
+	}, nil)
+	return size, err
+}
+
+// Write `data` at `offset` of RADOS object `oid`, to pack objects into containers
+func (cluster *CephStorage) WriteAt(poolname string, oid string, data []byte, offset int64) error {
+	return cluster.circuit(poolname).Execute(context.Background(), func(ctx context.Context) error {
+		pool, err := cluster.Conn.OpenPool(poolname)
+		if err != nil {
+			return errors.New("Bad poolname")
+		}
+		defer pool.Destroy()
+		return pool.Write(oid, data, uint64(offset))
+	}, nil)
+}
+
+func (cluster *CephStorage) doPut(poolname string, oid string, data io.Reader) (size int64, err error) {
 	if poolname == SMALL_FILE_POOLNAME {
 		return cluster.doSmallPut(poolname, oid, data)
 	}
@@ -237,7 +298,9 @@ func (cluster *CephStorage) Put(poolname string, oid string, data io.Reader) (si
 		count, err := data.Read(slice)
 		if err != nil && err != io.EOF {
 			drain_pending(pending)
-			return 0, fmt.Errorf("Read from client failed. pool:%s oid:%s", poolname, oid)
+			return 0, circuit.SimpleBadRequest{
+				Err: fmt.Errorf("Read from client failed. pool:%s oid:%s", poolname, oid),
+			}
 		}
 		if count == 0 {
 			break
@@ -321,6 +384,14 @@ func (cluster *CephStorage) Put(poolname string, oid string, data io.Reader) (si
 }
 
 func (cluster *CephStorage) Append(poolname string, oid string, data io.Reader, offset uint64, isExist bool) (size int64, err error) {
+	err = cluster.circuit(poolname).Execute(context.Background(), func(ctx context.Context) (err error) {
+		size, err = cluster.doAppend(poolname, oid, data, offset, isExist)
+		return err
+	}, nil)
+	return size, err
+}
+
+func (cluster *CephStorage) doAppend(poolname string, oid string, data io.Reader, offset uint64, isExist bool) (size int64, err error) {
 	if poolname != BIG_FILE_POOLNAME {
 		return 0, errors.New("specified pool must be used for storing big file.")
 	}
@@ -416,6 +487,9 @@ type RadosDownloader struct {
 	offset    int64
 	remaining int64
 	pool      *rados.Pool
+	cluster   *CephStorage
+	poolName  string
+	inflight  sync.WaitGroup // abandoned reads might still be running
 }
 
 func (rd *RadosDownloader) Read(p []byte) (n int, err error) {
@@ -425,7 +499,11 @@ func (rd *RadosDownloader) Read(p []byte) (n int, err error) {
 	if int64(len(p)) > rd.remaining {
 		p = p[:rd.remaining]
 	}
-	count, err := rd.striper.Read(rd.oid, p, uint64(rd.offset))
+	// abandoned reads might run after rd.offset is moved
+	offset := uint64(rd.offset)
+	count, err := rd.cluster.read(rd.poolName, &rd.inflight, p, func(buf []byte) (int, error) {
+		return rd.striper.Read(rd.oid, buf, offset)
+	})
 	if count == 0 {
 		return 0, io.EOF
 	}
@@ -447,8 +525,11 @@ func (rd *RadosDownloader) Seek(offset int64, whence int) (int64, error) {
 }
 
 func (rd *RadosDownloader) Close() error {
-	rd.striper.Destroy()
-	rd.pool.Destroy()
+	go func() {
+		rd.inflight.Wait()
+		rd.striper.Destroy()
+		rd.pool.Destroy()
+	}()
 	return nil
 }
 
@@ -466,6 +547,8 @@ func (cluster *CephStorage) getReader(poolName string, oid string, startOffset i
 			offset:    startOffset,
 			pool:      pool,
 			remaining: length,
+			cluster:   cluster,
+			poolName:  poolName,
 		}
 
 		return radosSmallReader, nil
@@ -489,20 +572,13 @@ func (cluster *CephStorage) getReader(poolName string, oid string, startOffset i
 		offset:    startOffset,
 		pool:      pool,
 		remaining: length,
+		cluster:   cluster,
+		poolName:  poolName,
 	}
 
 	return radosReader, nil
 }
 
-// Works together with `wrapAlignedEncryptionReader`, see comments there.
-func (cluster *CephStorage) getAlignedReader(poolName string, oid string, startOffset int64,
-	length int64) (reader io.ReadCloser, err error) {
-
-	alignedOffset := startOffset / AES_BLOCK_SIZE * AES_BLOCK_SIZE
-	length += startOffset - alignedOffset
-	return cluster.getReader(poolName, oid, alignedOffset, length)
-}
-
 /*
 func (cluster *CephStorage) get(poolName string, oid string, startOffset int64,
 	length int64, writer io.Writer) error {
@@ -549,6 +625,69 @@ func (cluster *CephStorage) Remove(poolname string, oid string) error {
 	return striper.Delete(oid)
 }
 
+var ErrNoSuchRadosObject = errors.New("RADOS object not found")
+
+// Size of RADOS object `oid`. Objects in small file pool could not be
+// stat'ed, so they are only checked whether their sizes are `expectedSize`
+// by reading around the end, and -1 is returned if not.
+func (cluster *CephStorage) StatObject(poolName string, oid string, expectedSize int64) (size int64, err error) {
+	pool, err := cluster.Conn.OpenPool(poolName)
+	if err != nil {
+		return 0, errors.New("Bad poolname")
+	}
+	defer pool.Destroy()
+
+	isNotFound := func(err error) bool {
+		e, ok := err.(rados.RadosError)
+		return ok && int(e) == RADOS_ENOENT
+	}
+	if poolName == SMALL_FILE_POOLNAME {
+		buffer := make([]byte, 1)
+		offset := expectedSize - 1
+		if offset < 0 {
+			offset = 0
+		}
+		n, err := pool.Read(oid, buffer, uint64(offset))
+		if isNotFound(err) {
+			return 0, ErrNoSuchRadosObject
+		}
+		if err != nil {
+			return 0, err
+		}
+		if expectedSize == 0 {
+			if n != 0 {
+				return -1, nil
+			}
+			return 0, nil
+		}
+		if n != 1 {
+			return -1, nil
+		}
+		n, err = pool.Read(oid, buffer, uint64(expectedSize))
+		if err != nil {
+			return 0, err
+		}
+		if n != 0 {
+			return -1, nil
+		}
+		return expectedSize, nil
+	}
+
+	striper, err := pool.CreateStriper()
+	if err != nil {
+		return 0, errors.New("Bad ioctx")
+	}
+	defer striper.Destroy()
+	s, _, err := striper.State(oid)
+	if isNotFound(err) {
+		return 0, ErrNoSuchRadosObject
+	}
+	if err != nil {
+		return 0, err
+	}
+	return int64(s), nil
+}
+
 func (cluster *CephStorage) GetUsedSpacePercent() (pct int, err error) {
 	stat, err := cluster.Conn.GetClusterStats()
 	if err != nil {
@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/journeymidnight/yig/helper"
+)
+
+var (
+	ErrCephReadTimeout = errors.New("read from ceph timeout")
+
+	// number of reads issued again because the first one is slow
+	HedgedReadCount int64
+)
+
+type readResult struct {
+	buffer []byte
+	n      int
+	err    error
+}
+
+// Read from Ceph under the circuit of `pool`. `readAt` reads into the
+// buffer given, and might be abandoned after timeout or if a hedged read
+// returns first, so `inflight` should be waited before releasing resources
+// used by `readAt`.
+func (cluster *CephStorage) read(pool string, inflight *sync.WaitGroup, p []byte,
+	readAt func([]byte) (int, error)) (n int, err error) {
+
+	timeout := time.Duration(helper.CONFIG.CephReadTimeout) * time.Millisecond
+	hedgeDelay := time.Duration(helper.CONFIG.CephHedgedReadDelay) * time.Millisecond
+	err = cluster.circuit(pool).Execute(context.Background(), func(ctx context.Context) (err error) {
+		if timeout <= 0 && hedgeDelay <= 0 {
+			n, err = readAt(p)
+			return err
+		}
+		n, err = hedgedRead(p, readAt, inflight, timeout, hedgeDelay)
+		return err
+	}, nil)
+	return n, err
+}
+
+// Issue `readAt` again if it does not return in `hedgeDelay`, and return
+// the first successful one. Reads are done with their own buffers since
+// the slower one might still be running when we return.
+func hedgedRead(p []byte, readAt func([]byte) (int, error), inflight *sync.WaitGroup,
+	timeout time.Duration, hedgeDelay time.Duration) (n int, err error) {
+
+	results := make(chan readResult, 2)
+	start := func() {
+		inflight.Add(1)
+		go func() {
+			defer inflight.Done()
+			buffer := make([]byte, len(p))
+			n, err := readAt(buffer)
+			results <- readResult{buffer: buffer, n: n, err: err}
+		}()
+	}
+
+	var hedge, deadline <-chan time.Time
+	if hedgeDelay > 0 {
+		t := time.NewTimer(hedgeDelay)
+		defer t.Stop()
+		hedge = t.C
+	}
+	if timeout > 0 {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		deadline = t.C
+	}
+
+	start()
+	running := 1
+	for {
+		select {
+		case r := <-results:
+			running--
+			if r.err != nil && running > 0 {
+				// wait for the other one
+				continue
+			}
+			copy(p, r.buffer[:r.n])
+			return r.n, r.err
+		case <-hedge:
+			hedge = nil
+			atomic.AddInt64(&HedgedReadCount, 1)
+			start()
+			running++
+		case <-deadline:
+			return 0, ErrCephReadTimeout
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHedgedRead(t *testing.T) {
+	var calls int32
+	var inflight sync.WaitGroup
+	// the first read is stuck, the hedged one returns at once
+	readAt := func(b []byte) (int, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			time.Sleep(200 * time.Millisecond)
+			return copy(b, "slow"), nil
+		}
+		return copy(b, "fast"), nil
+	}
+	p := make([]byte, 4)
+	n, err := hedgedRead(p, readAt, &inflight, time.Second, 10*time.Millisecond)
+	if err != nil || n != 4 || string(p) != "fast" {
+		t.Fatal("hedged read should win:", n, err, string(p))
+	}
+	inflight.Wait()
+	if string(p) != "fast" {
+		t.Fatal("abandoned read should not touch the buffer")
+	}
+
+	// the first read fails after hedging, the hedged one is taken
+	atomic.StoreInt32(&calls, 0)
+	readAt = func(b []byte) (int, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			time.Sleep(20 * time.Millisecond)
+			return 0, errors.New("failed")
+		}
+		time.Sleep(50 * time.Millisecond)
+		return copy(b, "good"), nil
+	}
+	n, err = hedgedRead(p, readAt, &inflight, time.Second, 10*time.Millisecond)
+	if err != nil || string(p[:n]) != "good" {
+		t.Fatal("failed read should wait for the hedged one:", n, err)
+	}
+
+	// both are too slow
+	readAt = func(b []byte) (int, error) {
+		time.Sleep(200 * time.Millisecond)
+		return len(b), nil
+	}
+	_, err = hedgedRead(p, readAt, &inflight, 50*time.Millisecond, 10*time.Millisecond)
+	if err != ErrCephReadTimeout {
+		t.Fatal("read should time out:", err)
+	}
+	inflight.Wait()
+}
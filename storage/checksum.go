@@ -0,0 +1,398 @@
+package storage
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"strconv"
+
+	"github.com/journeymidnight/yig/api/datatype"
+	"github.com/journeymidnight/yig/crypto"
+	. "github.com/journeymidnight/yig/error"
+	"github.com/journeymidnight/yig/helper"
+	meta "github.com/journeymidnight/yig/meta/types"
+	"github.com/journeymidnight/yig/signature"
+)
+
+// Checksums of objects and parts are CRC32C of the plain data, in hex.
+// Unlike MD5, CRC32C could be continued for appends and combined for
+// multipart uploads, so every object has a checksum of its whole content.
+
+var ErrChecksumMismatch = errors.New("stored data does not match its checksum")
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+type checksumWriter struct {
+	crc uint32
+}
+
+// `previous` is the checksum of data before those to write, "" for none
+func newChecksumWriter(previous string) (*checksumWriter, error) {
+	w := &checksumWriter{}
+	if previous == "" {
+		return w, nil
+	}
+	crc, err := parseChecksum(previous)
+	if err != nil {
+		return nil, err
+	}
+	w.crc = crc
+	return w, nil
+}
+
+func (w *checksumWriter) Write(p []byte) (int, error) {
+	w.crc = crc32.Update(w.crc, crc32cTable, p)
+	return len(p), nil
+}
+
+func (w *checksumWriter) Sum() string {
+	return formatChecksum(w.crc)
+}
+
+func formatChecksum(crc uint32) string {
+	return fmt.Sprintf("%08x", crc)
+}
+
+func parseChecksum(checksum string) (uint32, error) {
+	crc, err := strconv.ParseUint(checksum, 16, 32)
+	return uint32(crc), err
+}
+
+func gf2MatrixTimes(matrix []uint32, vector uint32) (sum uint32) {
+	for i := 0; vector != 0; i, vector = i+1, vector>>1 {
+		if vector&1 != 0 {
+			sum ^= matrix[i]
+		}
+	}
+	return sum
+}
+
+func gf2MatrixSquare(square, matrix []uint32) {
+	for i := range matrix {
+		square[i] = gf2MatrixTimes(matrix, matrix[i])
+	}
+}
+
+// Checksum of A+B from checksums of A and B, as crc32_combine() in zlib.
+// `poly` is the reversed polynomial, i.e. crc32.IEEE or crc32.Castagnoli.
+func combineCrc32(poly uint32, crc1, crc2 uint32, length2 int64) uint32 {
+	if length2 <= 0 {
+		return crc1
+	}
+	even := make([]uint32, 32) // operator for 2^n zero bits
+	odd := make([]uint32, 32)  // operator for 2^(n+1) zero bits
+	odd[0] = poly
+	row := uint32(1)
+	for i := 1; i < 32; i++ {
+		odd[i] = row
+		row <<= 1
+	}
+	gf2MatrixSquare(even, odd) // 2 zero bits
+	gf2MatrixSquare(odd, even) // 4 zero bits
+	// apply length2 zero bytes to crc1, the first square gives 8 zero bits
+	for {
+		gf2MatrixSquare(even, odd)
+		if length2&1 != 0 {
+			crc1 = gf2MatrixTimes(even, crc1)
+		}
+		length2 >>= 1
+		if length2 == 0 {
+			break
+		}
+		gf2MatrixSquare(odd, even)
+		if length2&1 != 0 {
+			crc1 = gf2MatrixTimes(odd, crc1)
+		}
+		length2 >>= 1
+		if length2 == 0 {
+			break
+		}
+	}
+	return crc1 ^ crc2
+}
+
+// Checksum of the whole object from checksums of its parts in order,
+// "" if any part has no checksum
+func combinePartChecksums(parts []*meta.Part) string {
+	var crc uint32
+	for _, p := range parts {
+		if p.Checksum == "" {
+			return ""
+		}
+		partCrc, err := parseChecksum(p.Checksum)
+		if err != nil {
+			return ""
+		}
+		crc = combineCrc32(crc32.Castagnoli, crc, partCrc, p.Size)
+	}
+	return formatChecksum(crc)
+}
+
+// Hash of checksum `algorithm` requested by clients, nil if not supported
+func newChecksumHash(algorithm string) hash.Hash {
+	switch algorithm {
+	case datatype.ChecksumAlgorithmCRC32:
+		return crc32.NewIEEE()
+	case datatype.ChecksumAlgorithmCRC32C:
+		return crc32.New(crc32cTable)
+	case datatype.ChecksumAlgorithmSHA1:
+		return sha1.New()
+	case datatype.ChecksumAlgorithmSHA256:
+		return sha256.New()
+	}
+	return nil
+}
+
+// Compare checksum calculated by `h` against the value requested, which
+// is got from `data` after read if sent in trailer. Returns the checksum
+// to store, "" if no checksum is requested.
+func checkRequestedChecksum(request datatype.ChecksumRequest, h hash.Hash, data io.Reader) (string, error) {
+	if request.Algorithm == "" {
+		return "", nil
+	}
+	calculated := base64.StdEncoding.EncodeToString(h.Sum(nil))
+	expected := request.Value
+	if request.Trailer != "" {
+		if trailerReader, ok := data.(signature.TrailerReader); ok {
+			expected = trailerReader.Trailer(request.Trailer)
+		}
+		if !datatype.IsValidChecksumValue(request.Algorithm, expected) {
+			return "", ErrInvalidChecksum
+		}
+	}
+	if expected != "" && expected != calculated {
+		return "", ErrBadChecksum
+	}
+	return calculated, nil
+}
+
+// Checksum with `algorithm` of a part, CRC32C is always available
+// from checksum computed by us
+func partChecksum(part *meta.Part, algorithm string) ([]byte, error) {
+	if part.ChecksumAlgorithm == algorithm && part.ChecksumValue != "" {
+		return base64.StdEncoding.DecodeString(part.ChecksumValue)
+	}
+	if algorithm == datatype.ChecksumAlgorithmCRC32C && part.Checksum != "" {
+		crc, err := parseChecksum(part.Checksum)
+		if err != nil {
+			return nil, err
+		}
+		value := make([]byte, 4)
+		binary.BigEndian.PutUint32(value, crc)
+		return value, nil
+	}
+	return nil, ErrInvalidPart
+}
+
+// Checksum of a multipart object requested by clients, from checksums of
+// its parts in order.
+func multipartChecksum(algorithm, checksumType string, parts []*meta.Part) (string, error) {
+	if checksumType == datatype.ChecksumTypeFullObject {
+		poly := uint32(crc32.IEEE)
+		if algorithm == datatype.ChecksumAlgorithmCRC32C {
+			poly = crc32.Castagnoli
+		} else if algorithm != datatype.ChecksumAlgorithmCRC32 {
+			return "", ErrInvalidChecksum
+		}
+		var crc uint32
+		for _, p := range parts {
+			value, err := partChecksum(p, algorithm)
+			if err != nil || len(value) != 4 {
+				return "", ErrInvalidPart
+			}
+			crc = combineCrc32(poly, crc, binary.BigEndian.Uint32(value), p.Size)
+		}
+		value := make([]byte, 4)
+		binary.BigEndian.PutUint32(value, crc)
+		return base64.StdEncoding.EncodeToString(value), nil
+	}
+	h := newChecksumHash(algorithm)
+	if h == nil {
+		return "", ErrInvalidChecksum
+	}
+	for _, p := range parts {
+		value, err := partChecksum(p, algorithm)
+		if err != nil {
+			return "", ErrInvalidPart
+		}
+		h.Write(value)
+	}
+	return base64.StdEncoding.EncodeToString(h.Sum(nil)) + "-" + strconv.Itoa(len(parts)), nil
+}
+
+// Writer verifying data written against `expected` checksum. The last
+// write is held back until `Close`, so clients never get complete data
+// if it turns out to be corrupted.
+type verifyingWriter struct {
+	writer   io.Writer
+	checksum *checksumWriter
+	expected string
+	held     []byte
+}
+
+func newVerifyingWriter(writer io.Writer, expected string) *verifyingWriter {
+	return &verifyingWriter{
+		writer:   writer,
+		checksum: &checksumWriter{},
+		expected: expected,
+	}
+}
+
+func (w *verifyingWriter) Write(p []byte) (int, error) {
+	if len(w.held) > 0 {
+		_, err := w.writer.Write(w.held)
+		if err != nil {
+			return 0, err
+		}
+	}
+	w.checksum.Write(p)
+	w.held = append(w.held[:0], p...)
+	return len(p), nil
+}
+
+func (w *verifyingWriter) Close() error {
+	if w.checksum.Sum() != w.expected {
+		return ErrChecksumMismatch
+	}
+	if len(w.held) > 0 {
+		_, err := w.writer.Write(w.held)
+		return err
+	}
+	return nil
+}
+
+// Copy data of a part, or the object if `part` is nil, verifying its checksum
+// if the whole part is read. `copy` writes the data into the writer given.
+func verifiedCopy(object *meta.Object, part *meta.Part, readOffset int64, length int64,
+	writer io.Writer, copy func(writer io.Writer) error) error {
+
+	checksum, size := object.Checksum, object.Size
+	if part != nil {
+		checksum, size = part.Checksum, part.Size
+	}
+	if !helper.CONFIG.VerifyChecksumOnRead || checksum == "" || readOffset != 0 || length != size {
+		return copy(writer)
+	}
+	verifier := newVerifyingWriter(writer, checksum)
+	err := copy(verifier)
+	if err != nil {
+		return err
+	}
+	err = verifier.Close()
+	if err == ErrChecksumMismatch {
+		partNumber := 0
+		if part != nil {
+			partNumber = part.PartNumber
+		}
+		helper.Logger.Println(0, "Checksum mismatch of object", object.BucketName, object.Name,
+			"version:", object.GetVersionId(), "part:", partNumber, "location:", object.Location,
+			object.Pool, "expected:", checksum, "actual:", verifier.checksum.Sum())
+	}
+	return err
+}
+
+// Verifier re-reads data of objects from Ceph, bypassing the data cache,
+// and compares them against their checksums.
+type Verifier struct {
+	yig      *YigStorage
+	throttle *throttle
+}
+
+// `rateLimit` in bytes per second, 0 for unlimited
+func (yig *YigStorage) NewVerifier(rateLimit int64) *Verifier {
+	return &Verifier{
+		yig:      yig,
+		throttle: &throttle{rate: rateLimit},
+	}
+}
+
+// Returns numbers of parts whose data mismatch, 0 for the object itself if
+// it has no parts. Objects without checksums, encrypted by SSE-C or stored
+// inline are not verified, `verified` is false for them.
+func (v *Verifier) Verify(object *meta.Object) (verified bool, mismatched []int, err error) {
+	if object.DeleteMarker || object.SseType == crypto.SSEC.String() || object.IsInline() {
+		return false, nil, nil
+	}
+	cluster, ok := v.yig.DataStorage[object.Location]
+	if !ok {
+		return false, nil, errors.New("Cannot find specified ceph cluster: " + object.Location)
+	}
+	encryptionKey, err := v.yig.decryptionKey(object, datatype.SseRequest{})
+	if err != nil {
+		return false, nil, err
+	}
+	check := func(oid string, size int64, compressedSize int64, iv []byte, expected string) (bool, error) {
+		readStored := func(offset, length int64) (io.ReadCloser, error) {
+			reader, err := cluster.getReader(object.Pool, oid, object.PackOffset+offset, length)
+			if err != nil {
+				return nil, err
+			}
+			var dataReader io.Reader = &throttledReader{reader: reader, throttle: v.throttle}
+			if object.SseType != "" {
+				dataReader, err = wrapAlignedEncryptionReader(dataReader, offset, encryptionKey, iv)
+				if err != nil {
+					reader.Close()
+					return nil, err
+				}
+			}
+			return &readCloser{Reader: io.LimitReader(dataReader, length), Closer: reader}, nil
+		}
+		checksum := &checksumWriter{}
+		if compressedSize != 0 {
+			err := copyDecompressed(object.Compression, readStored, size, compressedSize, 0, size, checksum)
+			if err == ErrCorruptedCompression {
+				return false, nil
+			}
+			if err != nil {
+				return false, err
+			}
+			return checksum.Sum() == expected, nil
+		}
+		reader, err := readStored(0, size)
+		if err != nil {
+			return false, err
+		}
+		defer reader.Close()
+		n, err := io.Copy(checksum, reader)
+		if err != nil {
+			return false, err
+		}
+		return n == size && checksum.Sum() == expected, nil
+	}
+
+	if len(object.Parts) == 0 {
+		if object.Checksum == "" {
+			return false, nil, nil
+		}
+		ok, err := check(object.ObjectId, object.Size, object.CompressedSize, object.InitializationVector,
+			object.Checksum)
+		if err != nil {
+			return false, nil, err
+		}
+		if !ok {
+			mismatched = append(mismatched, 0)
+		}
+		return true, mismatched, nil
+	}
+	for i := 1; i <= len(object.Parts); i++ {
+		p, ok := object.Parts[i]
+		if !ok || p.Checksum == "" {
+			continue
+		}
+		ok, err := check(p.ObjectId, p.Size, p.CompressedSize, p.InitializationVector, p.Checksum)
+		if err != nil {
+			return verified, mismatched, err
+		}
+		verified = true
+		if !ok {
+			mismatched = append(mismatched, p.PartNumber)
+		}
+	}
+	return verified, mismatched, nil
+}
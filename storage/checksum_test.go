@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"hash/crc32"
+	"testing"
+
+	"github.com/journeymidnight/yig/api/datatype"
+	meta "github.com/journeymidnight/yig/meta/types"
+)
+
+func TestCombinePartChecksums(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789abcdef"), 10000)
+	whole := formatChecksum(crc32.Checksum(data, crc32cTable))
+	var parts []*meta.Part
+	for _, bound := range [][2]int{{0, 1}, {1, 65536}, {65536, 65536}, {65536, len(data)}} {
+		piece := data[bound[0]:bound[1]]
+		parts = append(parts, &meta.Part{
+			Size:     int64(len(piece)),
+			Checksum: formatChecksum(crc32.Checksum(piece, crc32cTable)),
+		})
+	}
+	if combined := combinePartChecksums(parts); combined != whole {
+		t.Fatal("combined checksum", combined, "should be", whole)
+	}
+	parts[1].Checksum = ""
+	if combined := combinePartChecksums(parts); combined != "" {
+		t.Fatal("parts without checksum should not be combined:", combined)
+	}
+
+	// continued for appends
+	w, err := newChecksumWriter(formatChecksum(crc32.Checksum(data[:100], crc32cTable)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(data[100:])
+	if w.Sum() != whole {
+		t.Fatal("continued checksum", w.Sum(), "should be", whole)
+	}
+}
+
+func TestVerifyingWriter(t *testing.T) {
+	data := []byte("hello, world")
+	checksum := formatChecksum(crc32.Checksum(data, crc32cTable))
+
+	var out bytes.Buffer
+	w := newVerifyingWriter(&out, checksum)
+	w.Write(data[:5])
+	w.Write(data[5:])
+	if err := w.Close(); err != nil || out.String() != string(data) {
+		t.Fatal("good data should be written:", out.String(), err)
+	}
+
+	out.Reset()
+	w = newVerifyingWriter(&out, checksum)
+	w.Write(data[:5])
+	w.Write([]byte("XXXXXXX"))
+	if err := w.Close(); err != ErrChecksumMismatch {
+		t.Fatal("corrupted data should be detected:", err)
+	}
+	if out.Len() >= len(data) {
+		t.Fatal("corrupted data should not be written in whole:", out.String())
+	}
+}
+
+func TestMultipartChecksum(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789abcdef"), 10000)
+	whole := make([]byte, 4)
+	binary.BigEndian.PutUint32(whole, crc32.ChecksumIEEE(data))
+	var parts []*meta.Part
+	var composite []byte
+	for _, bound := range [][2]int{{0, 65536}, {65536, len(data)}} {
+		piece := data[bound[0]:bound[1]]
+		value := make([]byte, 4)
+		binary.BigEndian.PutUint32(value, crc32.ChecksumIEEE(piece))
+		composite = append(composite, value...)
+		parts = append(parts, &meta.Part{
+			Size:              int64(len(piece)),
+			ChecksumAlgorithm: datatype.ChecksumAlgorithmCRC32,
+			ChecksumValue:     base64.StdEncoding.EncodeToString(value),
+		})
+	}
+
+	checksum, err := multipartChecksum(datatype.ChecksumAlgorithmCRC32, datatype.ChecksumTypeFullObject, parts)
+	if err != nil || checksum != base64.StdEncoding.EncodeToString(whole) {
+		t.Fatal("full object checksum", checksum, err)
+	}
+	expected := make([]byte, 4)
+	binary.BigEndian.PutUint32(expected, crc32.ChecksumIEEE(composite))
+	checksum, err = multipartChecksum(datatype.ChecksumAlgorithmCRC32, datatype.ChecksumTypeComposite, parts)
+	if err != nil || checksum != base64.StdEncoding.EncodeToString(expected)+"-2" {
+		t.Fatal("composite checksum", checksum, err)
+	}
+	if _, err = multipartChecksum(datatype.ChecksumAlgorithmSHA256, datatype.ChecksumTypeComposite, parts); err == nil {
+		t.Fatal("parts without SHA256 checksums should fail")
+	}
+}
@@ -0,0 +1,251 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/golang/snappy"
+	meta "github.com/journeymidnight/yig/meta/types"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compressed data is stored as frames, each frame is an independently
+// compressed block of COMPRESSION_BLOCK_SIZE bytes of plain data, except
+// the last one. Compressed lengths of frames follow as index, then the
+// trailer of block size and magic:
+//
+//   | frame 0 | frame 1 | ... | frame n-1 | len 0 | ... | len n-1 | block size | magic |
+//
+// The number of frames is known from the plain size of object, so range
+// reads fetch the index at tail and decompress only frames needed.
+
+const (
+	COMPRESSION_BLOCK_SIZE = 256 << 10
+	compressionMagic       = 0x59435a31 // "YCZ1"
+	compressionTrailerSize = 8
+)
+
+var ErrCorruptedCompression = errors.New("compressed data is corrupted")
+
+type compressor struct {
+	encode func(dst, src []byte) []byte
+	decode func(dst, src []byte) ([]byte, error)
+}
+
+var compressors = map[string]compressor{
+	meta.CompressionSnappy: {
+		encode: snappy.Encode,
+		decode: snappy.Decode,
+	},
+	meta.CompressionZstd: {
+		encode: func(dst, src []byte) []byte {
+			return zstdEncoder.EncodeAll(src, dst[:0])
+		},
+		decode: func(dst, src []byte) ([]byte, error) {
+			return zstdDecoder.DecodeAll(src, dst[:0])
+		},
+	},
+}
+
+// Shared by all frames, EncodeAll and DecodeAll are safe for concurrent use
+// and run up to GOMAXPROCS at once
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+func IsSupportedCompression(compression string) bool {
+	if compression == "" {
+		return true
+	}
+	_, ok := compressors[compression]
+	return ok
+}
+
+// Reader of compressed frames of data from `source`
+type compressionReader struct {
+	source     io.Reader
+	compressor compressor
+	block      []byte
+	frame      []byte // to be read
+	lengths    []byte // index of frames written
+	plainSize  int64
+	done       bool
+}
+
+// Returns `reader` itself if `compression` is ""
+func wrapCompressionReader(reader io.Reader, compression string) (io.Reader, error) {
+	if compression == "" {
+		return reader, nil
+	}
+	c, ok := compressors[compression]
+	if !ok {
+		return nil, errors.New("unsupported compression: " + compression)
+	}
+	return &compressionReader{
+		source:     reader,
+		compressor: c,
+		block:      make([]byte, COMPRESSION_BLOCK_SIZE),
+	}, nil
+}
+
+func (r *compressionReader) Read(p []byte) (n int, err error) {
+	for len(r.frame) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		err = r.nextFrame()
+		if err != nil {
+			return 0, err
+		}
+	}
+	n = copy(p, r.frame)
+	r.frame = r.frame[n:]
+	return n, nil
+}
+
+func (r *compressionReader) nextFrame() error {
+	n, err := io.ReadFull(r.source, r.block)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	if n > 0 {
+		r.plainSize += int64(n)
+		r.frame = r.compressor.encode(r.frame[:cap(r.frame)], r.block[:n])
+		var length [4]byte
+		binary.BigEndian.PutUint32(length[:], uint32(len(r.frame)))
+		r.lengths = append(r.lengths, length[:]...)
+	}
+	if n == COMPRESSION_BLOCK_SIZE {
+		return nil
+	}
+	// source drained, output index and trailer
+	var trailer [compressionTrailerSize]byte
+	binary.BigEndian.PutUint32(trailer[:4], COMPRESSION_BLOCK_SIZE)
+	binary.BigEndian.PutUint32(trailer[4:], compressionMagic)
+	r.frame = append(r.frame, r.lengths...)
+	r.frame = append(r.frame, trailer[:]...)
+	r.done = true
+	return nil
+}
+
+// Sizes of data written by `cephCluster.Put` from `reader`, which might be
+// wrapped by `wrapCompressionReader`. `compressedSize` is 0 if not compressed.
+func writtenSizes(reader io.Reader, bytesWritten int64) (size int64, compressedSize int64) {
+	if r, ok := reader.(*compressionReader); ok {
+		return r.plainSize, bytesWritten
+	}
+	return bytesWritten, 0
+}
+
+func compressionFrameCount(size int64) int64 {
+	return (size + COMPRESSION_BLOCK_SIZE - 1) / COMPRESSION_BLOCK_SIZE
+}
+
+// Copy plain data in [offset, offset+length) of compressed data, whose
+// plain size is `size` and stored size is `storedSize`. `readStored` gets
+// stored data in a range, after decryption if encrypted.
+func copyDecompressed(compression string, readStored func(offset, length int64) (io.ReadCloser, error),
+	size, storedSize, offset, length int64, writer io.Writer) error {
+
+	c, ok := compressors[compression]
+	if !ok {
+		return errors.New("unsupported compression: " + compression)
+	}
+	if length <= 0 {
+		return nil
+	}
+	frameCount := compressionFrameCount(size)
+	tailSize := frameCount*4 + compressionTrailerSize
+	if tailSize > storedSize {
+		return ErrCorruptedCompression
+	}
+	tail, err := readFull(readStored, storedSize-tailSize, tailSize)
+	if err != nil {
+		return err
+	}
+	trailer := tail[len(tail)-compressionTrailerSize:]
+	if binary.BigEndian.Uint32(trailer[4:]) != compressionMagic ||
+		binary.BigEndian.Uint32(trailer[:4]) != COMPRESSION_BLOCK_SIZE {
+		return ErrCorruptedCompression
+	}
+
+	first := offset / COMPRESSION_BLOCK_SIZE
+	last := (offset + length - 1) / COMPRESSION_BLOCK_SIZE
+	if last >= frameCount {
+		return ErrCorruptedCompression
+	}
+	frameLength := func(i int64) int64 {
+		return int64(binary.BigEndian.Uint32(tail[i*4:]))
+	}
+	var frameOffset, readLength int64
+	for i := int64(0); i < first; i++ {
+		frameOffset += frameLength(i)
+	}
+	for i := first; i <= last; i++ {
+		readLength += frameLength(i)
+	}
+	if frameOffset+readLength > storedSize-tailSize {
+		return ErrCorruptedCompression
+	}
+	reader, err := readStored(frameOffset, readLength)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	var frame, block []byte
+	skip := offset - first*COMPRESSION_BLOCK_SIZE
+	for i := first; i <= last && length > 0; i++ {
+		n := frameLength(i)
+		if int64(cap(frame)) < n {
+			frame = make([]byte, n)
+		}
+		frame = frame[:n]
+		_, err = io.ReadFull(reader, frame)
+		if err != nil {
+			return err
+		}
+		block, err = c.decode(block[:cap(block)], frame)
+		if err != nil {
+			return ErrCorruptedCompression
+		}
+		if skip > int64(len(block)) {
+			return ErrCorruptedCompression
+		}
+		data := block[skip:]
+		skip = 0
+		if int64(len(data)) > length {
+			data = data[:length]
+		}
+		_, err = writer.Write(data)
+		if err != nil {
+			return err
+		}
+		length -= int64(len(data))
+	}
+	if length > 0 {
+		return ErrCorruptedCompression
+	}
+	return nil
+}
+
+func readFull(readStored func(offset, length int64) (io.ReadCloser, error),
+	offset, length int64) ([]byte, error) {
+
+	reader, err := readStored(offset, length)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	buf := make([]byte, length)
+	_, err = io.ReadFull(reader, buf)
+	return buf, err
+}
+
+// Reader closing the underlying reader it wraps
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+
+	meta "github.com/journeymidnight/yig/meta/types"
+)
+
+func TestCompression(t *testing.T) {
+	for _, compression := range []string{meta.CompressionSnappy, meta.CompressionZstd} {
+		testCompression(t, compression)
+	}
+}
+
+func testCompression(t *testing.T, compression string) {
+	random := make([]byte, 100000)
+	rand.Read(random)
+	text := bytes.Repeat([]byte("2019-06-17 13:30:11 GET /bucket/object 200\n"), 20000)
+	data := append(text, random...)
+
+	for _, size := range []int{0, 1, COMPRESSION_BLOCK_SIZE, len(text), len(data)} {
+		plain := data[:size]
+		reader, err := wrapCompressionReader(bytes.NewReader(plain), compression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stored, err := ioutil.ReadAll(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		plainSize, storedSize := writtenSizes(reader, int64(len(stored)))
+		if plainSize != int64(size) || storedSize != int64(len(stored)) {
+			t.Fatal("sizes:", plainSize, storedSize, "should be", size, len(stored))
+		}
+		if size == len(text) && storedSize > int64(size)/10 {
+			t.Fatal(compression, "text is not compressed:", storedSize)
+		}
+
+		reads := 0
+		readStored := func(offset, length int64) (io.ReadCloser, error) {
+			reads++
+			return ioutil.NopCloser(bytes.NewReader(stored[offset : offset+length])), nil
+		}
+		for _, r := range [][2]int64{{0, int64(size)}, {1, 10}, {COMPRESSION_BLOCK_SIZE - 5, 10},
+			{int64(size) - 1, 1}, {int64(size) / 3, int64(size) / 2}} {
+			offset, length := r[0], r[1]
+			if offset < 0 || offset+length > int64(size) {
+				continue
+			}
+			var out bytes.Buffer
+			reads = 0
+			err = copyDecompressed(compression, readStored, int64(size), storedSize,
+				offset, length, &out)
+			if err != nil {
+				t.Fatal("read", size, offset, length, err)
+			}
+			if !bytes.Equal(out.Bytes(), plain[offset:offset+length]) {
+				t.Fatal(compression, "data mismatch", size, offset, length)
+			}
+			if length > 0 && reads != 2 {
+				t.Fatal("index and frames should be read once:", reads)
+			}
+		}
+	}
+
+	reader, _ := wrapCompressionReader(bytes.NewReader(data), compression)
+	stored, _ := ioutil.ReadAll(reader)
+	stored[len(stored)-1] ^= 0xff
+	readStored := func(offset, length int64) (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(stored[offset : offset+length])), nil
+	}
+	err := copyDecompressed(compression, readStored, int64(len(data)), int64(len(stored)),
+		0, 10, ioutil.Discard)
+	if err != ErrCorruptedCompression {
+		t.Fatal("corrupted trailer should be detected:", err)
+	}
+}
@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"container/list"
+	"crypto/sha1"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/journeymidnight/yig/helper"
+)
+
+const DISK_CACHE_DIR_NAME = "chunks"
+
+type diskChunk struct {
+	key  string
+	size int64
+}
+
+// diskChunkStore keeps chunks as files under `dir`, evicting the least
+// recently used ones when their total size exceeds `capacity`.
+// The index is in memory only, so the cache starts empty every time.
+type diskChunkStore struct {
+	dir      string
+	capacity int64
+
+	mutex  sync.Mutex
+	used   int64
+	lru    *list.List // of *diskChunk, most recently used at front
+	chunks map[string]*list.Element
+}
+
+func newDiskChunkStore(path string, capacity int64) (*diskChunkStore, error) {
+	// only clean up our own sub-directory in case `path` is shared
+	dir := filepath.Join(path, DISK_CACHE_DIR_NAME)
+	err := os.RemoveAll(dir)
+	if err != nil {
+		return nil, err
+	}
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+	return &diskChunkStore{
+		dir:      dir,
+		capacity: capacity,
+		lru:      list.New(),
+		chunks:   make(map[string]*list.Element),
+	}, nil
+}
+
+// Files are spread into 256 sub-directories by the first byte of hash
+func (s *diskChunkStore) path(key string) string {
+	sum := sha1.Sum([]byte(key))
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(s.dir, name[:2], name)
+}
+
+func (s *diskChunkStore) Get(key string) []byte {
+	s.mutex.Lock()
+	e, ok := s.chunks[key]
+	if ok {
+		s.lru.MoveToFront(e)
+	}
+	s.mutex.Unlock()
+	if !ok {
+		return nil
+	}
+
+	chunk, err := ioutil.ReadFile(s.path(key))
+	if err != nil {
+		// might be evicted right now
+		helper.Debugln("Failed to read disk cache", key, "err:", err)
+		s.remove(e)
+		return nil
+	}
+	return chunk
+}
+
+func (s *diskChunkStore) Put(key string, chunk []byte) {
+	size := int64(len(chunk))
+	if size > s.capacity {
+		return
+	}
+	path := s.path(key)
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		helper.ErrorIf(err, "Failed to create disk cache directory.")
+		return
+	}
+	// write to a temporary file and rename, so readers never see half chunks
+	f, err := ioutil.TempFile(filepath.Dir(path), ".tmp")
+	if err != nil {
+		helper.ErrorIf(err, "Failed to create disk cache file.")
+		return
+	}
+	_, err = f.Write(chunk)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		helper.ErrorIf(err, "Failed to write disk cache file.")
+		os.Remove(f.Name())
+		return
+	}
+
+	var evicted []string
+	s.mutex.Lock()
+	if e, ok := s.chunks[key]; ok {
+		c := e.Value.(*diskChunk)
+		s.used += size - c.size
+		c.size = size
+		s.lru.MoveToFront(e)
+	} else {
+		s.chunks[key] = s.lru.PushFront(&diskChunk{key: key, size: size})
+		s.used += size
+	}
+	for s.used > s.capacity {
+		e := s.lru.Back()
+		c := e.Value.(*diskChunk)
+		s.lru.Remove(e)
+		delete(s.chunks, c.key)
+		s.used -= c.size
+		evicted = append(evicted, c.key)
+	}
+	s.mutex.Unlock()
+
+	for _, k := range evicted {
+		os.Remove(s.path(k))
+	}
+}
+
+func (s *diskChunkStore) remove(e *list.Element) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	c := e.Value.(*diskChunk)
+	if s.chunks[c.key] != e {
+		return
+	}
+	s.lru.Remove(e)
+	delete(s.chunks, c.key)
+	s.used -= c.size
+}
@@ -0,0 +1,455 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/journeymidnight/yig/error"
+	"github.com/journeymidnight/yig/helper"
+	meta "github.com/journeymidnight/yig/meta/types"
+	"github.com/journeymidnight/yig/redis"
+)
+
+// Move all objects out of a Ceph cluster, so the cluster could be
+// decommissioned. The cluster should have weight 0 in `cluster` table
+// before draining, otherwise new objects keep coming in.
+//
+// Objects are copied to clusters picked by PickOneClusterAndPool, then
+// switched to the new copy in metadata only if they are not changed
+// meanwhile, so reads keep working during draining. The old data is put
+// into gc in the same transaction. Objects changed while copying are tried
+// again in the next pass over the cluster.
+//
+// Multipart uploads in progress are not moved since parts could be uploaded
+// to them concurrently, they are counted in the progress instead.
+
+const (
+	DRAIN_SCAN_LIMIT = 1000
+
+	DrainStateRunning = "Running"
+	DrainStatePaused  = "Paused"
+	DrainStateDone    = "Done"
+	DrainStateStopped = "Stopped" // yig is shutting down
+)
+
+type DrainProgress struct {
+	Fsid           string
+	State          string
+	RateLimit      int64 // bytes per second, 0 for unlimited
+	Concurrency    int
+	StartTime      time.Time
+	EndTime        time.Time
+	Passes         int
+	Scanned        int64
+	Migrated       int64
+	MigratedBytes  int64
+	Skipped        int64 // changed while copying, tried again in the next pass
+	Failed         int64
+	PendingUploads int64 // multipart uploads in progress on the cluster
+	LastError      string
+}
+
+type DrainJob struct {
+	yig      *YigStorage
+	source   *CephStorage
+	throttle *throttle
+
+	mutex    sync.Mutex
+	progress DrainProgress
+}
+
+// Limits bytes per second shared by all readers using it
+type throttle struct {
+	mutex sync.Mutex
+	rate  int64     // 0 for unlimited
+	next  time.Time // when bytes taken so far are paid off
+}
+
+func (t *throttle) setRate(rate int64) {
+	t.mutex.Lock()
+	t.rate = rate
+	t.mutex.Unlock()
+}
+
+// Block until `n` bytes are allowed
+func (t *throttle) wait(n int) {
+	t.mutex.Lock()
+	if t.rate <= 0 {
+		t.mutex.Unlock()
+		return
+	}
+	now := time.Now()
+	if t.next.Before(now) {
+		t.next = now
+	}
+	t.next = t.next.Add(time.Duration(int64(n) * int64(time.Second) / t.rate))
+	delay := t.next.Sub(now)
+	t.mutex.Unlock()
+	time.Sleep(delay)
+}
+
+type throttledReader struct {
+	reader   io.Reader
+	throttle *throttle
+}
+
+func (r *throttledReader) Read(p []byte) (n int, err error) {
+	n, err = r.reader.Read(p)
+	r.throttle.wait(n)
+	return
+}
+
+// Start draining cluster `fsid`, `rateLimit` < 0 or `concurrency` <= 0
+// means using the value in config.
+func (yig *YigStorage) StartDrain(fsid string, rateLimit int64, concurrency int) (progress DrainProgress, err error) {
+	source, ok := yig.DataStorage[fsid]
+	if !ok {
+		return progress, ErrClusterNotDrainable
+	}
+	for pool := range source.Circuits {
+		// read from database directly, cached weight might be stale
+		cluster, err := yig.MetaStorage.Client.GetCluster(fsid, pool)
+		if err == nil && cluster.Weight != 0 {
+			return progress, ErrClusterNotDrainable
+		}
+	}
+	if rateLimit < 0 {
+		rateLimit = helper.CONFIG.DrainRateLimit
+	}
+	if concurrency <= 0 {
+		concurrency = helper.CONFIG.DrainConcurrency
+	}
+
+	yig.DrainMutex.Lock()
+	defer yig.DrainMutex.Unlock()
+	if job, ok := yig.Drains[fsid]; ok {
+		state := job.Progress().State
+		if state == DrainStateRunning || state == DrainStatePaused {
+			return progress, ErrDrainJobExists
+		}
+	}
+	job := &DrainJob{
+		yig:      yig,
+		source:   source,
+		throttle: &throttle{rate: rateLimit},
+		progress: DrainProgress{
+			Fsid:        fsid,
+			State:       DrainStateRunning,
+			RateLimit:   rateLimit,
+			Concurrency: concurrency,
+			StartTime:   time.Now().UTC(),
+		},
+	}
+	yig.Drains[fsid] = job
+	yig.WaitGroup.Add(1)
+	go job.run()
+	helper.Logger.Println(5, "Start draining cluster", fsid, "rate limit:", rateLimit,
+		"concurrency:", concurrency)
+	return job.Progress(), nil
+}
+
+func (yig *YigStorage) getDrainJob(fsid string) (*DrainJob, error) {
+	yig.DrainMutex.Lock()
+	defer yig.DrainMutex.Unlock()
+	job, ok := yig.Drains[fsid]
+	if !ok {
+		return nil, ErrNoSuchDrainJob
+	}
+	return job, nil
+}
+
+// Returns true if a drain job of cluster `fsid` is running or paused
+func (yig *YigStorage) isDraining(fsid string) bool {
+	yig.DrainMutex.Lock()
+	defer yig.DrainMutex.Unlock()
+	job, ok := yig.Drains[fsid]
+	if !ok {
+		return false
+	}
+	state := job.Progress().State
+	return state == DrainStateRunning || state == DrainStatePaused
+}
+
+func (yig *YigStorage) GetDrainProgress(fsid string) (progress DrainProgress, err error) {
+	job, err := yig.getDrainJob(fsid)
+	if err != nil {
+		return
+	}
+	return job.Progress(), nil
+}
+
+func (yig *YigStorage) ListDrainProgress() (progresses []DrainProgress) {
+	yig.DrainMutex.Lock()
+	defer yig.DrainMutex.Unlock()
+	for _, job := range yig.Drains {
+		progresses = append(progresses, job.Progress())
+	}
+	return
+}
+
+func (yig *YigStorage) PauseDrain(fsid string) (progress DrainProgress, err error) {
+	return yig.setDrainState(fsid, DrainStateRunning, DrainStatePaused)
+}
+
+func (yig *YigStorage) ResumeDrain(fsid string) (progress DrainProgress, err error) {
+	return yig.setDrainState(fsid, DrainStatePaused, DrainStateRunning)
+}
+
+func (yig *YigStorage) setDrainState(fsid string, from, to string) (progress DrainProgress, err error) {
+	job, err := yig.getDrainJob(fsid)
+	if err != nil {
+		return
+	}
+	job.mutex.Lock()
+	defer job.mutex.Unlock()
+	if job.progress.State != from && job.progress.State != to {
+		return job.progress, ErrNoSuchDrainJob
+	}
+	job.progress.State = to
+	helper.Logger.Println(5, "Drain job of cluster", fsid, "is", to)
+	return job.progress, nil
+}
+
+func (yig *YigStorage) SetDrainRateLimit(fsid string, rateLimit int64) (progress DrainProgress, err error) {
+	job, err := yig.getDrainJob(fsid)
+	if err != nil {
+		return
+	}
+	if rateLimit < 0 {
+		rateLimit = 0
+	}
+	job.throttle.setRate(rateLimit)
+	job.mutex.Lock()
+	defer job.mutex.Unlock()
+	job.progress.RateLimit = rateLimit
+	return job.progress, nil
+}
+
+func (job *DrainJob) Progress() DrainProgress {
+	job.mutex.Lock()
+	defer job.mutex.Unlock()
+	return job.progress
+}
+
+func (job *DrainJob) update(f func(progress *DrainProgress)) {
+	job.mutex.Lock()
+	defer job.mutex.Unlock()
+	f(&job.progress)
+}
+
+// Returns false if yig is stopping
+func (job *DrainJob) waitIfPaused() bool {
+	for {
+		if job.yig.Stopping {
+			return false
+		}
+		if job.Progress().State != DrainStatePaused {
+			return true
+		}
+		time.Sleep(1 * time.Second)
+	}
+}
+
+func (job *DrainJob) fail(object *meta.Object, err error) {
+	helper.Logger.Println(5, "Failed to migrate object", object.BucketName, object.Name,
+		"from cluster", job.source.Name, "err:", err)
+	job.update(func(progress *DrainProgress) {
+		progress.Failed++
+		progress.LastError = fmt.Sprintf("%s/%s: %v", object.BucketName, object.Name, err)
+	})
+}
+
+func (job *DrainJob) run() {
+	defer job.yig.WaitGroup.Done()
+	for {
+		job.update(func(progress *DrainProgress) {
+			progress.Passes++
+		})
+		found, migrated, completed := job.drainOnce()
+		if !completed {
+			job.finish(DrainStateStopped)
+			return
+		}
+		pending, err := job.yig.MetaStorage.CountMultipartsByLocation(job.source.Name)
+		if err != nil {
+			helper.ErrorIf(err, "Failed to count multipart uploads of cluster %s", job.source.Name)
+		} else {
+			job.update(func(progress *DrainProgress) {
+				progress.PendingUploads = pending
+			})
+		}
+		// objects failed every time are left, see Failed and LastError
+		if found == 0 || migrated == 0 {
+			job.finish(DrainStateDone)
+			return
+		}
+	}
+}
+
+func (job *DrainJob) finish(state string) {
+	job.update(func(progress *DrainProgress) {
+		progress.State = state
+		progress.EndTime = time.Now().UTC()
+	})
+	progress := job.Progress()
+	helper.Logger.Println(5, "Drain job of cluster", progress.Fsid, "is", state,
+		"migrated:", progress.Migrated, "failed:", progress.Failed,
+		"pending uploads:", progress.PendingUploads)
+}
+
+// Walk through the cluster once. Returns false in `completed` if yig is stopping.
+func (job *DrainJob) drainOnce() (found int64, migrated int64, completed bool) {
+	objects := make(chan *meta.Object)
+	var wg sync.WaitGroup
+	for i := 0; i < job.Progress().Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for object := range objects {
+				if job.migrate(object) {
+					atomic.AddInt64(&migrated, 1)
+				}
+			}
+		}()
+	}
+
+	marker := ""
+scan:
+	for {
+		if !job.waitIfPaused() {
+			break
+		}
+		batch, nextMarker, err := job.yig.MetaStorage.ScanObjectsByLocation(job.source.Name,
+			DRAIN_SCAN_LIMIT, marker)
+		if err != nil {
+			helper.ErrorIf(err, "Failed to scan objects of cluster %s", job.source.Name)
+			job.update(func(progress *DrainProgress) {
+				progress.LastError = err.Error()
+			})
+			time.Sleep(1 * time.Second)
+			continue
+		}
+		for _, object := range batch {
+			if !job.waitIfPaused() {
+				break scan
+			}
+			found++
+			job.update(func(progress *DrainProgress) {
+				progress.Scanned++
+			})
+			objects <- object
+		}
+		if nextMarker == "" {
+			completed = true
+			break
+		}
+		marker = nextMarker
+	}
+	close(objects)
+	wg.Wait()
+	return found, atomic.LoadInt64(&migrated), completed
+}
+
+// Copy data of `object` to another cluster and switch to it.
+// Returns true if the object is migrated.
+func (job *DrainJob) migrate(object *meta.Object) bool {
+	yig := job.yig
+	target, poolName, err := yig.PickOneClusterAndPool(object.BucketName, object.Name, object.Size,
+		object.Type == meta.ObjectTypeAppendable, object.StorageClass)
+	if err != nil {
+		job.fail(object, err)
+		return false
+	}
+	if target == job.source {
+		job.fail(object, ErrClusterNotDrainable)
+		return false
+	}
+
+	moved := *object
+	moved.Location = target.Name
+	moved.Pool = poolName
+	var intents []meta.WriteIntent
+	copyData := func(sourceOid string, sourceOffset int64, oid string, size int64) error {
+		intent, err := yig.newWriteIntent(target, poolName, oid, object.BucketName, object.Name)
+		if err != nil {
+			return err
+		}
+		intents = append(intents, intent)
+		return job.copyData(object.Pool, sourceOid, sourceOffset, target, poolName, oid, size)
+	}
+	if len(object.Parts) == 0 {
+		// packed objects are moved out of their containers
+		moved.ObjectId = target.GetUniqUploadName()
+		moved.Packed = false
+		moved.PackOffset = 0
+		err = copyData(object.ObjectId, object.PackOffset, moved.ObjectId, object.StoredSize())
+	} else {
+		moved.Parts = make(map[int]*meta.Part, len(object.Parts))
+		for n, p := range object.Parts {
+			part := *p
+			part.ObjectId = target.GetUniqUploadName()
+			moved.Parts[n] = &part
+			err = copyData(p.ObjectId, 0, part.ObjectId, p.StoredSize())
+			if err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		yig.abandonWriteIntents(intents...)
+		job.fail(object, err)
+		return false
+	}
+
+	migrated, err := yig.MetaStorage.MigrateObject(object, &moved, intents)
+	if err != nil {
+		yig.abandonWriteIntents(intents...)
+		job.fail(object, err)
+		return false
+	}
+	if !migrated {
+		yig.abandonWriteIntents(intents...)
+		helper.Logger.Println(10, "Object changed while migrating, skip it.", object.BucketName, object.Name)
+		job.update(func(progress *DrainProgress) {
+			progress.Skipped++
+		})
+		return false
+	}
+
+	version := strconv.FormatUint(math.MaxUint64-uint64(object.LastModifiedTime.UnixNano()), 10)
+	yig.MetaStorage.Cache.Remove(redis.ObjectTable, object.BucketName+":"+object.Name+":")
+	yig.MetaStorage.Cache.Remove(redis.ObjectTable, object.BucketName+":"+object.Name+":"+version)
+	helper.Logger.Println(20, "Migrated object", object.BucketName, object.Name, "from",
+		object.Location, object.Pool, "to", moved.Location, moved.Pool)
+	job.update(func(progress *DrainProgress) {
+		progress.Migrated++
+		progress.MigratedBytes += object.Size
+	})
+	return true
+}
+
+func (job *DrainJob) copyData(sourcePool, sourceOid string, sourceOffset int64, target *CephStorage,
+	poolName, oid string, size int64) error {
+
+	reader, err := job.source.getReader(sourcePool, sourceOid, sourceOffset, size)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	written, err := target.Put(poolName, oid, &throttledReader{
+		reader:   reader,
+		throttle: job.throttle,
+	})
+	if err != nil {
+		return err
+	}
+	if written != size {
+		return fmt.Errorf("copied %d bytes of %s/%s, expected %d", written, sourcePool, sourceOid, size)
+	}
+	return nil
+}
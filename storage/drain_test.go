@@ -0,0 +1,32 @@
+package storage
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestThrottledReader(t *testing.T) {
+	throttle := &throttle{rate: 1000}
+	reader := &throttledReader{
+		reader:   bytes.NewReader(make([]byte, 200)),
+		throttle: throttle,
+	}
+	start := time.Now()
+	data, err := ioutil.ReadAll(reader)
+	if err != nil || len(data) != 200 {
+		t.Fatal("wrong data read:", len(data), err)
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond || elapsed > time.Second {
+		t.Fatal("200 bytes at 1000 bytes/s should take about 200ms:", elapsed)
+	}
+
+	throttle.setRate(0)
+	reader.reader = bytes.NewReader(make([]byte, 1<<20))
+	start = time.Now()
+	ioutil.ReadAll(reader)
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatal("unlimited read should not be throttled:", elapsed)
+	}
+}
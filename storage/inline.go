@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/journeymidnight/yig/helper"
+	meta "github.com/journeymidnight/yig/meta/types"
+)
+
+// Returns true if objects of `size` should be stored in metadata rows,
+// skipping Ceph entirely. They are never compressed.
+func isInlinable(size int64) bool {
+	return size >= 0 && size < int64(helper.CONFIG.InlineObjectThreshold)
+}
+
+// Copy data of inline `object` in [offset, offset+length)
+func copyInline(object *meta.Object, offset int64, length int64, encryptionKey []byte,
+	writer io.Writer) error {
+
+	data := object.InlineData
+	if offset+length > int64(len(data)) {
+		length = int64(len(data)) - offset
+	}
+	if offset < 0 || length <= 0 {
+		return nil
+	}
+	if object.SseType == "" {
+		_, err := writer.Write(data[offset : offset+length])
+		return err
+	}
+	alignedOffset := offset / AES_BLOCK_SIZE * AES_BLOCK_SIZE
+	reader, err := wrapAlignedEncryptionReader(bytes.NewReader(data[alignedOffset:offset+length]),
+		offset, encryptionKey, object.InitializationVector)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(writer, reader)
+	return err
+}
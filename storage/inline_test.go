@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	meta "github.com/journeymidnight/yig/meta/types"
+)
+
+func TestCopyInline(t *testing.T) {
+	data := []byte("hello, inline object")
+	object := &meta.Object{Size: int64(len(data)), InlineData: data}
+	if !object.IsInline() {
+		t.Fatal("object without ObjectId should be inline")
+	}
+	for _, r := range [][2]int64{{0, int64(len(data))}, {3, 5}, {int64(len(data)) - 1, 10}, {0, 0}} {
+		var out bytes.Buffer
+		err := copyInline(object, r[0], r[1], nil, &out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		end := r[0] + r[1]
+		if end > int64(len(data)) {
+			end = int64(len(data))
+		}
+		if !bytes.Equal(out.Bytes(), data[r[0]:end]) {
+			t.Fatal("range", r, "got", out.String())
+		}
+	}
+
+	key := bytes.Repeat([]byte{1}, ENCRYPTION_KEY_LENGTH)
+	iv, _ := newInitializationVector()
+	reader, _ := wrapEncryptionReader(bytes.NewReader(data), key, iv)
+	encrypted, _ := ioutil.ReadAll(reader)
+	object = &meta.Object{Size: int64(len(data)), InlineData: encrypted, SseType: "S3",
+		InitializationVector: iv}
+	var out bytes.Buffer
+	err := copyInline(object, 0, object.Size, key, &out)
+	if err != nil || !bytes.Equal(out.Bytes(), data) {
+		t.Fatal("encrypted inline object should be decrypted:", out.String(), err)
+	}
+}
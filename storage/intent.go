@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/journeymidnight/yig/helper"
+	meta "github.com/journeymidnight/yig/meta/types"
+)
+
+// Record that `oid` is about to be written to `cluster`, so its data could
+// be recycled if metadata referencing it is never committed, even if we
+// crash meanwhile.
+func (yig *YigStorage) newWriteIntent(cluster *CephStorage, poolName string, oid string,
+	bucketName string, objectName string) (intent meta.WriteIntent, err error) {
+
+	intent = meta.WriteIntent{
+		Location:   cluster.Name,
+		Pool:       poolName,
+		ObjectId:   oid,
+		BucketName: bucketName,
+		ObjectName: objectName,
+		CreateTime: time.Now().UTC(),
+	}
+	err = yig.MetaStorage.PutWriteIntent(&intent)
+	if err != nil {
+		helper.ErrorIf(err, "Failed to put write intent of %s/%s", bucketName, objectName)
+	}
+	return
+}
+
+// Recycle data of failed writes. Intents failed to be abandoned here are
+// swept into gc after timeout by the delete daemon.
+func (yig *YigStorage) abandonWriteIntents(intents ...meta.WriteIntent) {
+	if len(intents) == 0 {
+		return
+	}
+	err := yig.MetaStorage.AbandonWriteIntents(intents)
+	if err != nil {
+		helper.Logger.Println(5, "Failed to abandon write intents:", err,
+			"they would be swept into gc after timeout")
+	}
+}
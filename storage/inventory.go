@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"sort"
+
+	"github.com/journeymidnight/yig/api/datatype"
+	. "github.com/journeymidnight/yig/error"
+	"github.com/journeymidnight/yig/iam/common"
+	meta "github.com/journeymidnight/yig/meta/types"
+	"github.com/journeymidnight/yig/redis"
+)
+
+// Inventory configurations are kept in the bucket sorted by Id, reports of
+// them are generated by tools/inventory.go.
+
+func (yig *YigStorage) getBucketForInventory(bucketName string, willNeed bool,
+	credential common.Credential) (*meta.Bucket, error) {
+
+	bucket, err := yig.MetaStorage.GetBucket(bucketName, willNeed)
+	if err != nil {
+		return nil, err
+	}
+	if bucket.OwnerId != credential.UserId {
+		return nil, ErrBucketAccessForbidden
+	}
+	return bucket, nil
+}
+
+func (yig *YigStorage) putBucketInventory(bucket *meta.Bucket) error {
+	err := yig.MetaStorage.Client.PutBucket(*bucket)
+	if err != nil {
+		return err
+	}
+	yig.MetaStorage.Cache.Remove(redis.BucketTable, bucket.Name)
+	return nil
+}
+
+// Add the configuration, or replace the one with the same Id
+func (yig *YigStorage) SetBucketInventory(bucketName string, inventory datatype.InventoryConfiguration,
+	credential common.Credential) error {
+
+	bucket, err := yig.getBucketForInventory(bucketName, false, credential)
+	if err != nil {
+		return err
+	}
+	destination, err := yig.MetaStorage.GetBucket(
+		inventory.Destination.S3BucketDestination.DestinationBucket(), true)
+	if err != nil || destination.OwnerId != bucket.OwnerId {
+		return ErrInvalidInventoryDestination
+	}
+	inventory.Xmlns = ""
+	i := sort.Search(len(bucket.Inventory), func(i int) bool {
+		return bucket.Inventory[i].Id >= inventory.Id
+	})
+	if i < len(bucket.Inventory) && bucket.Inventory[i].Id == inventory.Id {
+		bucket.Inventory[i] = inventory
+	} else {
+		if len(bucket.Inventory) >= datatype.MaxInventoryConfigurations {
+			return ErrTooManyInventoryConfigurations
+		}
+		bucket.Inventory = append(bucket.Inventory, datatype.InventoryConfiguration{})
+		copy(bucket.Inventory[i+1:], bucket.Inventory[i:])
+		bucket.Inventory[i] = inventory
+	}
+	return yig.putBucketInventory(bucket)
+}
+
+func (yig *YigStorage) GetBucketInventory(bucketName, id string,
+	credential common.Credential) (inventory datatype.InventoryConfiguration, err error) {
+
+	bucket, err := yig.getBucketForInventory(bucketName, true, credential)
+	if err != nil {
+		return
+	}
+	for _, inventory := range bucket.Inventory {
+		if inventory.Id == id {
+			return inventory, nil
+		}
+	}
+	return inventory, ErrNoSuchInventoryConfiguration
+}
+
+func (yig *YigStorage) DeleteBucketInventory(bucketName, id string, credential common.Credential) error {
+	bucket, err := yig.getBucketForInventory(bucketName, false, credential)
+	if err != nil {
+		return err
+	}
+	for i, inventory := range bucket.Inventory {
+		if inventory.Id == id {
+			bucket.Inventory = append(bucket.Inventory[:i], bucket.Inventory[i+1:]...)
+			return yig.putBucketInventory(bucket)
+		}
+	}
+	return ErrNoSuchInventoryConfiguration
+}
+
+// Configurations with Id after `continuationToken`, which is the Id of the
+// last configuration listed before.
+func (yig *YigStorage) ListBucketInventory(bucketName, continuationToken string,
+	credential common.Credential) (result datatype.ListInventoryConfigurationsResult, err error) {
+
+	bucket, err := yig.getBucketForInventory(bucketName, true, credential)
+	if err != nil {
+		return
+	}
+	result.ContinuationToken = continuationToken
+	for _, inventory := range bucket.Inventory {
+		if inventory.Id <= continuationToken {
+			continue
+		}
+		if len(result.InventoryConfigurations) == datatype.MaxInventoryConfigurationsListed {
+			result.IsTruncated = true
+			result.NextContinuationToken =
+				result.InventoryConfigurations[len(result.InventoryConfigurations)-1].Id
+			break
+		}
+		result.InventoryConfigurations = append(result.InventoryConfigurations, inventory)
+	}
+	return result, nil
+}
@@ -83,8 +83,9 @@ func (yig *YigStorage) ListMultipartUploads(credential common.Credential, bucket
 }
 
 func (yig *YigStorage) NewMultipartUpload(credential common.Credential, bucketName, objectName string,
-	metadata map[string]string, acl datatype.Acl,
-	sseRequest datatype.SseRequest, storageClass meta.StorageClass) (uploadId string, err error) {
+	metadata map[string]string, acl datatype.Acl, sseRequest datatype.SseRequest,
+	storageClass meta.StorageClass, checksumRequest datatype.ChecksumRequest,
+	tagging datatype.Tagging) (uploadId string, err error) {
 
 	bucket, err := yig.MetaStorage.GetBucket(bucketName, true)
 	if err != nil {
@@ -105,7 +106,10 @@ func (yig *YigStorage) NewMultipartUpload(credential common.Credential, bucketNa
 		contentType = "application/octet-stream"
 	}
 
-	cephCluster, pool := yig.PickOneClusterAndPool(bucketName, objectName, -1, false)
+	cephCluster, pool, err := yig.PickOneClusterAndPool(bucketName, objectName, -1, false, storageClass)
+	if err != nil {
+		return
+	}
 	multipartMetadata := meta.MultipartMetadata{
 		InitiatorId:  credential.UserId,
 		OwnerId:      bucket.OwnerId,
@@ -116,6 +120,11 @@ func (yig *YigStorage) NewMultipartUpload(credential common.Credential, bucketNa
 		SseRequest:   sseRequest,
 		Attrs:        metadata,
 		StorageClass: storageClass,
+
+		ChecksumAlgorithm: checksumRequest.Algorithm,
+		ChecksumType:      checksumRequest.Type,
+		Compression:       bucket.Compression,
+		Tagging:           tagging,
 	}
 	if sseRequest.Type == crypto.S3.String() {
 		multipartMetadata.EncryptionKey, multipartMetadata.CipherKey, err = yig.encryptionKeyFromSseRequest(sseRequest, bucketName, objectName)
@@ -143,11 +152,20 @@ func (yig *YigStorage) NewMultipartUpload(credential common.Credential, bucketNa
 
 func (yig *YigStorage) PutObjectPart(bucketName, objectName string, credential common.Credential,
 	uploadId string, partId int, size int64, data io.Reader, md5Hex string,
-	sseRequest datatype.SseRequest) (result datatype.PutObjectPartResult, err error) {
+	sseRequest datatype.SseRequest,
+	checksumRequest datatype.ChecksumRequest) (result datatype.PutObjectPartResult, err error) {
 	multipart, err := yig.MetaStorage.GetMultipart(bucketName, objectName, uploadId)
 	if err != nil {
 		return
 	}
+	// parts are checksummed with the algorithm of the upload
+	if checksumRequest.Algorithm == "" {
+		checksumRequest.Algorithm = multipart.Metadata.ChecksumAlgorithm
+	} else if multipart.Metadata.ChecksumAlgorithm != "" &&
+		checksumRequest.Algorithm != multipart.Metadata.ChecksumAlgorithm {
+		err = ErrInvalidChecksum
+		return
+	}
 
 	if size > MAX_PART_SIZE {
 		err = ErrEntityTooLarge
@@ -178,8 +196,17 @@ func (yig *YigStorage) PutObjectPart(bucketName, objectName string, credential c
 	if err != nil {
 		return
 	}
+	if cephCluster.IsFull(poolName) {
+		return result, ErrStorageFull
+	}
 	oid := cephCluster.GetUniqUploadName()
-	dataReader := io.TeeReader(limitedDataReader, md5Writer)
+	crcWriter := &checksumWriter{}
+	hashWriter := io.MultiWriter(md5Writer, crcWriter)
+	requestedHash := newChecksumHash(checksumRequest.Algorithm)
+	if requestedHash != nil {
+		hashWriter = io.MultiWriter(hashWriter, requestedHash)
+	}
+	dataReader := io.TeeReader(limitedDataReader, hashWriter)
 
 	var initializationVector []byte
 	if len(encryptionKey) != 0 {
@@ -188,46 +215,56 @@ func (yig *YigStorage) PutObjectPart(bucketName, objectName string, credential c
 			return
 		}
 	}
-	storageReader, err := wrapEncryptionReader(dataReader, encryptionKey,
-		initializationVector)
+	compressedReader, err := wrapCompressionReader(dataReader, multipart.Metadata.Compression)
 	if err != nil {
 		return
 	}
-	bytesWritten, err := cephCluster.Put(poolName, oid, storageReader)
+	storageReader, err := wrapEncryptionReader(compressedReader, encryptionKey,
+		initializationVector)
 	if err != nil {
 		return
 	}
-	// Should metadata update failed, add `maybeObjectToRecycle` to `RecycleQueue`,
+	// Should metadata update failed, the intent is put into gc,
 	// so the object in Ceph could be removed asynchronously
-	maybeObjectToRecycle := objectToRecycle{
-		location: cephCluster.Name,
-		pool:     poolName,
-		objectId: oid,
+	intent, err := yig.newWriteIntent(cephCluster, poolName, oid, bucketName, objectName)
+	if err != nil {
+		return
+	}
+	bytesWritten, err := cephCluster.Put(poolName, oid, storageReader)
+	if err != nil {
+		yig.abandonWriteIntents(intent)
+		return
 	}
+	bytesWritten, compressedSize := writtenSizes(compressedReader, bytesWritten)
 	if bytesWritten < size {
-		RecycleQueue <- maybeObjectToRecycle
+		yig.abandonWriteIntents(intent)
 		err = ErrIncompleteBody
 		return
 	}
 
 	calculatedMd5 := hex.EncodeToString(md5Writer.Sum(nil))
 	if md5Hex != "" && md5Hex != calculatedMd5 {
-		RecycleQueue <- maybeObjectToRecycle
+		yig.abandonWriteIntents(intent)
 		err = ErrBadDigest
 		return
 	}
+	checksumValue, err := checkRequestedChecksum(checksumRequest, requestedHash, data)
+	if err != nil {
+		yig.abandonWriteIntents(intent)
+		return
+	}
 
 	if signVerifyReader, ok := data.(*signature.SignVerifyReader); ok {
 		credential, err = signVerifyReader.Verify()
 		if err != nil {
-			RecycleQueue <- maybeObjectToRecycle
+			yig.abandonWriteIntents(intent)
 			return
 		}
 	}
 
 	bucket, err := yig.MetaStorage.GetBucket(bucketName, true)
 	if err != nil {
-		RecycleQueue <- maybeObjectToRecycle
+		yig.abandonWriteIntents(intent)
 		return
 	}
 	switch bucket.ACL.CannedAcl {
@@ -235,7 +272,7 @@ func (yig *YigStorage) PutObjectPart(bucketName, objectName string, credential c
 		break
 	default:
 		if bucket.OwnerId != credential.UserId {
-			RecycleQueue <- maybeObjectToRecycle
+			yig.abandonWriteIntents(intent)
 			return result, ErrBucketAccessForbidden
 		}
 	} // TODO policy and fancy ACL
@@ -245,24 +282,23 @@ func (yig *YigStorage) PutObjectPart(bucketName, objectName string, credential c
 		Size:                 size,
 		ObjectId:             oid,
 		Etag:                 calculatedMd5,
+		Checksum:             crcWriter.Sum(),
+		ChecksumAlgorithm:    checksumRequest.Algorithm,
+		ChecksumValue:        checksumValue,
+		CompressedSize:       compressedSize,
 		LastModified:         time.Now().UTC().Format(meta.CREATE_TIME_LAYOUT),
 		InitializationVector: initializationVector,
 	}
-	err = yig.MetaStorage.PutObjectPart(multipart, part)
+	err = yig.MetaStorage.PutObjectPart(multipart, part, intent)
 	if err != nil {
-		RecycleQueue <- maybeObjectToRecycle
+		yig.abandonWriteIntents(intent)
 		return
 	}
-	// remove possible old object in Ceph
-	if part, ok := multipart.Parts[partId]; ok {
-		RecycleQueue <- objectToRecycle{
-			location: multipart.Metadata.Location,
-			pool:     multipart.Metadata.Pool,
-			objectId: part.ObjectId,
-		}
-	}
 
 	result.ETag = calculatedMd5
+	result.Checksum = part.Checksum
+	result.ChecksumAlgorithm = part.ChecksumAlgorithm
+	result.ChecksumValue = part.ChecksumValue
 	result.SseType = sseRequest.Type
 	result.SseAwsKmsKeyIdBase64 = base64.StdEncoding.EncodeToString([]byte(sseRequest.SseAwsKmsKeyId))
 	result.SseCustomerAlgorithm = sseRequest.SseCustomerAlgorithm
@@ -308,8 +344,12 @@ func (yig *YigStorage) CopyObjectPart(bucketName, objectName, uploadId string, p
 	if err != nil {
 		return
 	}
+	if cephCluster.IsFull(poolName) {
+		return result, ErrStorageFull
+	}
 	oid := cephCluster.GetUniqUploadName()
-	dataReader := io.TeeReader(limitedDataReader, md5Writer)
+	crcWriter := &checksumWriter{}
+	dataReader := io.TeeReader(limitedDataReader, io.MultiWriter(md5Writer, crcWriter))
 
 	var initializationVector []byte
 	if len(encryptionKey) != 0 {
@@ -318,25 +358,30 @@ func (yig *YigStorage) CopyObjectPart(bucketName, objectName, uploadId string, p
 			return
 		}
 	}
-	storageReader, err := wrapEncryptionReader(dataReader, encryptionKey,
-		initializationVector)
+	compressedReader, err := wrapCompressionReader(dataReader, multipart.Metadata.Compression)
 	if err != nil {
 		return
 	}
-	bytesWritten, err := cephCluster.Put(poolName, oid, storageReader)
+	storageReader, err := wrapEncryptionReader(compressedReader, encryptionKey,
+		initializationVector)
 	if err != nil {
 		return
 	}
-	// Should metadata update failed, add `maybeObjectToRecycle` to `RecycleQueue`,
+	// Should metadata update failed, the intent is put into gc,
 	// so the object in Ceph could be removed asynchronously
-	maybeObjectToRecycle := objectToRecycle{
-		location: cephCluster.Name,
-		pool:     poolName,
-		objectId: oid,
+	intent, err := yig.newWriteIntent(cephCluster, poolName, oid, bucketName, objectName)
+	if err != nil {
+		return
+	}
+	bytesWritten, err := cephCluster.Put(poolName, oid, storageReader)
+	if err != nil {
+		yig.abandonWriteIntents(intent)
+		return
 	}
+	bytesWritten, compressedSize := writtenSizes(compressedReader, bytesWritten)
 
 	if bytesWritten < size {
-		RecycleQueue <- maybeObjectToRecycle
+		yig.abandonWriteIntents(intent)
 		err = ErrIncompleteBody
 		return
 	}
@@ -345,7 +390,7 @@ func (yig *YigStorage) CopyObjectPart(bucketName, objectName, uploadId string, p
 
 	bucket, err := yig.MetaStorage.GetBucket(bucketName, true)
 	if err != nil {
-		RecycleQueue <- maybeObjectToRecycle
+		yig.abandonWriteIntents(intent)
 		return
 	}
 	switch bucket.ACL.CannedAcl {
@@ -353,7 +398,7 @@ func (yig *YigStorage) CopyObjectPart(bucketName, objectName, uploadId string, p
 		break
 	default:
 		if bucket.OwnerId != credential.UserId {
-			RecycleQueue <- maybeObjectToRecycle
+			yig.abandonWriteIntents(intent)
 			err = ErrBucketAccessForbidden
 			return
 		}
@@ -368,25 +413,20 @@ func (yig *YigStorage) CopyObjectPart(bucketName, objectName, uploadId string, p
 		Size:                 size,
 		ObjectId:             oid,
 		Etag:                 result.Md5,
+		Checksum:             crcWriter.Sum(),
+		CompressedSize:       compressedSize,
 		LastModified:         now.Format(meta.CREATE_TIME_LAYOUT),
 		InitializationVector: initializationVector,
 	}
 	result.LastModified = now
+	result.Checksum = part.Checksum
 
-	err = yig.MetaStorage.PutObjectPart(multipart, part)
+	err = yig.MetaStorage.PutObjectPart(multipart, part, intent)
 	if err != nil {
-		RecycleQueue <- maybeObjectToRecycle
+		yig.abandonWriteIntents(intent)
 		return
 	}
 
-	// remove possible old object in Ceph
-	if part, ok := multipart.Parts[partId]; ok {
-		RecycleQueue <- objectToRecycle{
-			location: multipart.Metadata.Location,
-			pool:     multipart.Metadata.Pool,
-			objectId: part.ObjectId,
-		}
-	}
 
 	return result, nil
 }
@@ -434,6 +474,7 @@ func (yig *YigStorage) ListObjectParts(credential common.Credential, bucketName,
 				LastModified: p.LastModified,
 				Size:         p.Size,
 			}
+			part.Checksums.Set(p.ChecksumAlgorithm, p.ChecksumValue)
 			result.Parts = append(result.Parts, part)
 
 			if len(result.Parts) > request.MaxParts {
@@ -496,27 +537,18 @@ func (yig *YigStorage) AbortMultipartUpload(credential common.Credential,
 		return err
 	}
 
+	// parts in Ceph are put into gc
 	err = yig.MetaStorage.DeleteMultipart(multipart)
 	if err != nil {
 		return err
 	}
-	// remove parts in Ceph
-	var removedSize int64 = 0
-	for _, p := range multipart.Parts {
-		RecycleQueue <- objectToRecycle{
-			location: multipart.Metadata.Location,
-			pool:     multipart.Metadata.Pool,
-			objectId: p.ObjectId,
-		}
-		removedSize += p.Size
-	}
 
 	return nil
 }
 
 func (yig *YigStorage) CompleteMultipartUpload(credential common.Credential, bucketName,
-	objectName, uploadId string, uploadedParts []meta.CompletePart) (result datatype.CompleteMultipartResult,
-	err error) {
+	objectName, uploadId string, uploadedParts []meta.CompletePart,
+	checksumRequest datatype.ChecksumRequest) (result datatype.CompleteMultipartResult, err error) {
 
 	bucket, err := yig.MetaStorage.GetBucket(bucketName, true)
 	if err != nil {
@@ -540,6 +572,7 @@ func (yig *YigStorage) CompleteMultipartUpload(credential common.Credential, buc
 
 	md5Writer := md5.New()
 	var totalSize int64 = 0
+	completedParts := make([]*meta.Part, 0, len(uploadedParts))
 	helper.Logger.Println(20, "Upload parts:", uploadedParts, "uploadId:", uploadId)
 	for i := 0; i < len(uploadedParts); i++ {
 		if uploadedParts[i].PartNumber != i+1 {
@@ -567,6 +600,14 @@ func (yig *YigStorage) CompleteMultipartUpload(credential common.Credential, buc
 			err = ErrInvalidPart
 			return
 		}
+		if algorithm, value := uploadedParts[i].Checksum(); algorithm != "" {
+			if algorithm != part.ChecksumAlgorithm || value != part.ChecksumValue {
+				helper.Logger.Println(20, "part checksum mismatch;", "i:", i, "algorithm:", algorithm,
+					"checksum:", part.ChecksumValue, "reqChecksum:", value, "uploadId:", uploadId)
+				err = ErrInvalidPart
+				return
+			}
+		}
 		var etagBytes []byte
 		etagBytes, err = hex.DecodeString(part.Etag)
 		if err != nil {
@@ -577,11 +618,31 @@ func (yig *YigStorage) CompleteMultipartUpload(credential common.Credential, buc
 		part.Offset = totalSize
 		totalSize += part.Size
 		md5Writer.Write(etagBytes)
+		completedParts = append(completedParts, part)
 	}
 	result.ETag = hex.EncodeToString(md5Writer.Sum(nil))
 	result.ETag += "-" + strconv.Itoa(len(uploadedParts))
 	// See http://stackoverflow.com/questions/12186993
 	// for how to calculate multipart Etag
+	result.Checksum = combinePartChecksums(completedParts)
+	if algorithm := multipart.Metadata.ChecksumAlgorithm; algorithm != "" {
+		checksumType := multipart.Metadata.ChecksumType
+		if checksumType == "" {
+			checksumType = datatype.ChecksumTypeComposite
+		}
+		result.ChecksumValue, err = multipartChecksum(algorithm, checksumType, completedParts)
+		if err != nil {
+			return
+		}
+		// clients could only send checksum of the whole object on completion
+		if checksumRequest.Value != "" && (checksumRequest.Algorithm != algorithm ||
+			checksumRequest.Value != result.ChecksumValue) {
+			err = ErrBadChecksum
+			return
+		}
+		result.ChecksumAlgorithm = algorithm
+		result.ChecksumType = checksumType
+	}
 
 	// Add to objects table
 	contentType := multipart.Metadata.ContentType
@@ -594,6 +655,7 @@ func (yig *YigStorage) CompleteMultipartUpload(credential common.Credential, buc
 		Size:             totalSize,
 		LastModifiedTime: time.Now().UTC(),
 		Etag:             result.ETag,
+		Checksum:         result.Checksum,
 		ContentType:      contentType,
 		Parts:            multipart.Parts,
 		ACL:              multipart.Metadata.Acl,
@@ -604,6 +666,11 @@ func (yig *YigStorage) CompleteMultipartUpload(credential common.Credential, buc
 		CustomAttributes: multipart.Metadata.Attrs,
 		Type:             meta.ObjectTypeMultipart,
 		StorageClass:     multipart.Metadata.StorageClass,
+		Compression:      multipart.Metadata.Compression,
+		Tagging:          multipart.Metadata.Tagging,
+
+		ChecksumAlgorithm: result.ChecksumAlgorithm,
+		ChecksumValue:     result.ChecksumValue,
 	}
 
 	var nullVerNum uint64
@@ -624,10 +691,11 @@ func (yig *YigStorage) CompleteMultipartUpload(credential common.Credential, buc
 		BucketName: bucketName,
 	}
 
+	replication := meta.NewReplicationTask(bucket, object)
 	if nullVerNum != 0 {
-		err = yig.MetaStorage.PutObject(object, &multipart, objMap, false)
+		err = yig.MetaStorage.PutObject(object, &multipart, objMap, false, replication)
 	} else {
-		err = yig.MetaStorage.PutObject(object, &multipart, nil, false)
+		err = yig.MetaStorage.PutObject(object, &multipart, nil, false, replication)
 	}
 
 	//// Remove from multiparts table
@@ -645,7 +713,6 @@ func (yig *YigStorage) CompleteMultipartUpload(credential common.Credential, buc
 
 	if err == nil {
 		yig.MetaStorage.Cache.Remove(redis.ObjectTable, bucketName+":"+objectName+":")
-		yig.DataCache.Remove(bucketName + ":" + objectName + ":" + object.GetVersionId())
 	}
 
 	return
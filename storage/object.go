@@ -1,11 +1,14 @@
 package storage
 
 import (
+	"bytes"
 	"crypto/md5"
 	"encoding/hex"
 	"errors"
 	"io"
+	"io/ioutil"
 	"math/rand"
+	"strconv"
 	"time"
 
 	"path"
@@ -22,62 +25,85 @@ import (
 	"github.com/journeymidnight/yig/signature"
 )
 
-var latestQueryTime [2]time.Time // 0 is for SMALL_FILE_POOLNAME, 1 is for BIG_FILE_POOLNAME
-const CLUSTER_MAX_USED_SPACE_PERCENT = 85
-
-func (yig *YigStorage) PickOneClusterAndPool(bucket string, object string, size int64, isAppend bool) (cluster *CephStorage,
-	poolName string) {
-
-	var idx int
-	if isAppend {
+// Pick a cluster randomly by effective weights, i.e. weights configured in
+// cluster table scaled by free space. Clusters over soft threshold are used
+// only if no other cluster is available, and clusters over hard threshold
+// are never used. Data of archive storage classes goes to `archive_pool`
+// if configured.
+func (yig *YigStorage) PickOneClusterAndPool(bucket string, object string, size int64, isAppend bool,
+	storageClass meta.StorageClass) (cluster *CephStorage, poolName string, err error) {
+
+	if storageClass.IsArchive() && helper.CONFIG.ArchivePool != "" {
+		poolName = helper.CONFIG.ArchivePool
+	} else if isAppend {
 		poolName = BIG_FILE_POOLNAME
-		idx = 1
 	} else if size < 0 { // request.ContentLength is -1 if length is unknown
 		poolName = BIG_FILE_POOLNAME
-		idx = 1
 	} else if size < BIG_FILE_THRESHOLD {
 		poolName = SMALL_FILE_POOLNAME
-		idx = 0
 	} else {
 		poolName = BIG_FILE_POOLNAME
-		idx = 1
 	}
-	var needCheck bool
-	queryTime := latestQueryTime[idx]
-	if time.Since(queryTime).Hours() > 24 { // check used space every 24 hours
-		latestQueryTime[idx] = time.Now()
-		needCheck = true
-	}
-	var totalWeight int
+
+	// weights of clusters under soft threshold, and those between soft and hard
+	// threshold or slow to respond
+	var totalWeight, totalSoftFencedWeight int
 	clusterWeights := make(map[string]int, len(yig.DataStorage))
-	for fsid, _ := range yig.DataStorage {
+	softFencedWeights := make(map[string]int, len(yig.DataStorage))
+	// clusters taken out of service are never written, even in the fallback
+	excluded := make(map[string]bool)
+	for fsid, c := range yig.DataStorage {
+		if yig.isDraining(fsid) {
+			excluded[fsid] = true
+			continue
+		}
 		cluster, err := yig.MetaStorage.GetCluster(fsid, poolName)
 		if err != nil {
 			helper.Debugln("Error getting cluster: ", err)
 			continue
 		}
 		if cluster.Weight == 0 {
+			excluded[fsid] = true
 			continue
 		}
-		if needCheck {
-			pct, err := yig.DataStorage[fsid].GetUsedSpacePercent()
-			if err != nil {
-				helper.Logger.Println(0, "Error getting used space: ", err, "fsid: ", fsid)
+		if !c.IsHealthy(poolName) {
+			helper.Logger.Println(5, "Circuit of cluster", fsid, "pool", poolName, "is open, skip it")
+			continue
+		}
+		status, ok := c.GetPoolStatus(poolName)
+		if !ok { // status unknown yet
+			totalWeight += cluster.Weight * 100
+			clusterWeights[fsid] = cluster.Weight * 100
+			continue
+		}
+		weight := status.EffectiveWeight(cluster.Weight)
+		if weight == 0 {
+			continue
+		}
+		if status.IsSoftFenced() || status.IsSlow() {
+			totalSoftFencedWeight += weight
+			softFencedWeights[fsid] = weight
+		} else {
+			totalWeight += weight
+			clusterWeights[fsid] = weight
+		}
+	}
+	if totalWeight == 0 {
+		clusterWeights, totalWeight = softFencedWeights, totalSoftFencedWeight
+	}
+	if len(clusterWeights) == 0 || totalWeight == 0 {
+		helper.Logger.Println(5, "Error picking cluster from table cluster in DB! Use first writable cluster in config.")
+		for fsid, c := range yig.DataStorage {
+			if excluded[fsid] || c.IsFull(poolName) {
 				continue
 			}
-			if pct > CLUSTER_MAX_USED_SPACE_PERCENT {
-				helper.Logger.Println(0, "Cluster used space exceed ", CLUSTER_MAX_USED_SPACE_PERCENT, fsid)
-				continue
+			if cluster == nil || (!cluster.IsHealthy(poolName) && c.IsHealthy(poolName)) {
+				cluster = c
 			}
 		}
-		totalWeight += cluster.Weight
-		clusterWeights[fsid] = cluster.Weight
-	}
-	if len(clusterWeights) == 0 || totalWeight == 0 {
-		helper.Logger.Println(5, "Error picking cluster from table cluster in DB! Use first cluster in config to write.")
-		for _, c := range yig.DataStorage {
-			cluster = c
-			break
+		if cluster == nil {
+			helper.Logger.Println(0, "All clusters are full for pool", poolName)
+			return nil, poolName, ErrStorageFull
 		}
 		return
 	}
@@ -113,55 +139,57 @@ func init() {
 	}
 }
 
-func generateTransWholeObjectFunc(cephCluster *CephStorage, object *meta.Object) func(io.Writer) error {
-	getWholeObject := func(w io.Writer) error {
-		reader, err := cephCluster.getReader(object.Pool, object.ObjectId, 0, object.Size)
-		if err != nil {
-			return nil
-		}
-		defer reader.Close()
+// Get a reader of stored data of rados object `oid` through data cache.
+// If `aligned`, the range is extended to AES_BLOCK_SIZE boundary to work
+// together with `wrapAlignedEncryptionReader`.
+func (yig *YigStorage) getCachedReader(cephCluster *CephStorage, object *meta.Object,
+	oid string, size int64, offset int64, length int64, aligned bool) (io.ReadCloser, error) {
 
-		buf := downloadBufPool.Get().([]byte)
-		_, err = io.CopyBuffer(w, reader, buf)
-		downloadBufPool.Put(buf)
-		return err
+	if aligned {
+		alignedOffset := offset / AES_BLOCK_SIZE * AES_BLOCK_SIZE
+		length += offset - alignedOffset
+		offset = alignedOffset
+	}
+	// PackOffset is 0 unless the object is packed, parts are never packed
+	readThrough := func(offset int64, length int64) (io.ReadCloser, error) {
+		return cephCluster.getReader(object.Pool, oid, object.PackOffset+offset, length)
 	}
-	return getWholeObject
+	cacheKey := dataCacheKey(object.Location, object.Pool, oid, object.GetVersionId())
+	if object.Packed {
+		cacheKey += ":" + strconv.FormatInt(object.PackOffset, 10)
+	}
+	return yig.DataCache.GetReader(cacheKey, size, offset, length, readThrough)
 }
 
-func generateTransPartObjectFunc(cephCluster *CephStorage, object *meta.Object, part *meta.Part, offset, length int64) func(io.Writer) error {
-	getNormalObject := func(w io.Writer) error {
-		var oid string
-		/* the transfered part could be Part or Object */
-		if part != nil {
-			oid = part.ObjectId
-		} else {
-			oid = object.ObjectId
-		}
-		reader, err := cephCluster.getReader(object.Pool, oid, offset, length)
-		if err != nil {
-			return nil
-		}
-		defer reader.Close()
-		buf := downloadBufPool.Get().([]byte)
-		_, err = io.CopyBuffer(w, reader, buf)
-		downloadBufPool.Put(buf)
+func (yig *YigStorage) copyPart(cephCluster *CephStorage, object *meta.Object, part *meta.Part,
+	readOffset int64, length int64, writer io.Writer) error {
+
+	/* the transfered part could be Part or Object */
+	oid, size := object.ObjectId, object.Size
+	if part != nil {
+		oid, size = part.ObjectId, part.Size
+	}
+	reader, err := yig.getCachedReader(cephCluster, object, oid, size, readOffset, length, false)
+	if err != nil {
 		return err
 	}
-	return getNormalObject
+	defer reader.Close()
+	buf := downloadBufPool.Get().([]byte)
+	_, err = io.CopyBuffer(writer, reader, buf)
+	downloadBufPool.Put(buf)
+	return err
 }
 
-func (yig *YigStorage) GetObject(object *meta.Object, startOffset int64,
-	length int64, writer io.Writer, sseRequest datatype.SseRequest) (err error) {
-	var encryptionKey []byte
+// Key to decrypt data of `object`
+func (yig *YigStorage) decryptionKey(object *meta.Object, sseRequest datatype.SseRequest) (encryptionKey []byte, err error) {
 	if object.SseType == crypto.S3.String() {
 		if yig.KMS == nil {
-			return ErrKMSNotConfigured
+			return nil, ErrKMSNotConfigured
 		}
 		key, err := yig.KMS.UnsealKey(yig.KMS.GetKeyID(), object.EncryptionKey,
 			crypto.Context{object.BucketName: path.Join(object.BucketName, object.Name)})
 		if err != nil {
-			return err
+			return nil, err
 		}
 		encryptionKey = key[:]
 	} else { // SSE-C
@@ -171,6 +199,27 @@ func (yig *YigStorage) GetObject(object *meta.Object, startOffset int64,
 			encryptionKey = sseRequest.SseCustomerKey
 		}
 	}
+	return encryptionKey, nil
+}
+
+func (yig *YigStorage) GetObject(object *meta.Object, startOffset int64,
+	length int64, writer io.Writer, sseRequest datatype.SseRequest) (err error) {
+	if !object.IsReadable() {
+		return ErrInvalidObjectState
+	}
+	if object.IsArchived() {
+		object = object.RestoredCopy()
+	}
+	encryptionKey, err := yig.decryptionKey(object, sseRequest)
+	if err != nil {
+		return err
+	}
+
+	if object.IsInline() {
+		return verifiedCopy(object, nil, startOffset, length, writer, func(writer io.Writer) error {
+			return copyInline(object, startOffset, length, encryptionKey, writer)
+		})
+	}
 
 	if len(object.Parts) == 0 { // this object has only one part
 		cephCluster, ok := yig.DataStorage[object.Location]
@@ -178,22 +227,21 @@ func (yig *YigStorage) GetObject(object *meta.Object, startOffset int64,
 			return errors.New("Cannot find specified ceph cluster: " + object.Location)
 		}
 
-		transWholeObjectWriter := generateTransWholeObjectFunc(cephCluster, object)
+		if object.Compression != "" {
+			return verifiedCopy(object, nil, startOffset, length, writer, func(writer io.Writer) error {
+				return yig.copyCompressedPart(cephCluster, object, nil, startOffset, length, encryptionKey, writer)
+			})
+		}
 
 		if object.SseType == "" { // unencrypted object
-			transPartObjectWriter := generateTransPartObjectFunc(cephCluster, object, nil, startOffset, length)
-
-			return yig.DataCache.WriteFromCache(object, startOffset, length, writer,
-				transPartObjectWriter, transWholeObjectWriter)
+			return verifiedCopy(object, nil, startOffset, length, writer, func(writer io.Writer) error {
+				return yig.copyPart(cephCluster, object, nil, startOffset, length, writer)
+			})
 		}
 
 		// encrypted object
-		normalAligenedGet := func() (io.ReadCloser, error) {
-			return cephCluster.getAlignedReader(object.Pool, object.ObjectId,
-				startOffset, length)
-		}
-		reader, err := yig.DataCache.GetAlignedReader(object, startOffset, length, normalAligenedGet,
-			transWholeObjectWriter)
+		reader, err := yig.getCachedReader(cephCluster, object, object.ObjectId, object.Size,
+			startOffset, length, true)
 		if err != nil {
 			return err
 		}
@@ -204,9 +252,11 @@ func (yig *YigStorage) GetObject(object *meta.Object, startOffset int64,
 		if err != nil {
 			return err
 		}
-		buffer := make([]byte, MAX_CHUNK_SIZE)
-		_, err = io.CopyBuffer(writer, decryptedReader, buffer)
-		return err
+		return verifiedCopy(object, nil, startOffset, length, writer, func(writer io.Writer) error {
+			buffer := make([]byte, MAX_CHUNK_SIZE)
+			_, err := io.CopyBuffer(writer, decryptedReader, buffer)
+			return err
+		})
 	}
 
 	// multipart uploaded object
@@ -242,31 +292,69 @@ func (yig *YigStorage) GetObject(object *meta.Object, startOffset int64,
 				return errors.New("Cannot find specified ceph cluster: " +
 					object.Location)
 			}
+			if p.CompressedSize != 0 {
+				err = verifiedCopy(object, p, readOffset, readLength, writer, func(writer io.Writer) error {
+					return yig.copyCompressedPart(cephCluster, object, p, readOffset, readLength, encryptionKey, writer)
+				})
+				if err != nil {
+					return err
+				}
+				continue
+			}
 			if object.SseType == "" { // unencrypted object
-
-				transPartFunc := generateTransPartObjectFunc(cephCluster, object, p, readOffset, readLength)
-				err := transPartFunc(writer)
+				err = verifiedCopy(object, p, readOffset, readLength, writer, func(writer io.Writer) error {
+					return yig.copyPart(cephCluster, object, p, readOffset, readLength, writer)
+				})
 				if err != nil {
-					return nil
+					return err
 				}
 				continue
 			}
 
 			// encrypted object
-			err = copyEncryptedPart(object.Pool, p, cephCluster, readOffset, readLength, encryptionKey, writer)
+			err = verifiedCopy(object, p, readOffset, readLength, writer, func(writer io.Writer) error {
+				return yig.copyEncryptedPart(cephCluster, object, p, readOffset, readLength, encryptionKey, writer)
+			})
 			if err != nil {
 				helper.Debugln("Multipart uploaded object write error:", err)
+				return err
 			}
 		}
 	}
 	return
 }
 
-func copyEncryptedPart(pool string, part *meta.Part, cephCluster *CephStorage, readOffset int64, length int64,
-	encryptionKey []byte, targetWriter io.Writer) (err error) {
+// Copy plain data of a compressed part, or the object if `part` is nil
+func (yig *YigStorage) copyCompressedPart(cephCluster *CephStorage, object *meta.Object, part *meta.Part,
+	readOffset int64, length int64, encryptionKey []byte, writer io.Writer) error {
+
+	oid, size, storedSize, iv := object.ObjectId, object.Size, object.CompressedSize, object.InitializationVector
+	if part != nil {
+		oid, size, storedSize, iv = part.ObjectId, part.Size, part.CompressedSize, part.InitializationVector
+	}
+	readStored := func(offset, length int64) (io.ReadCloser, error) {
+		if object.SseType == "" {
+			return yig.getCachedReader(cephCluster, object, oid, storedSize, offset, length, false)
+		}
+		reader, err := yig.getCachedReader(cephCluster, object, oid, storedSize, offset, length, true)
+		if err != nil {
+			return nil, err
+		}
+		decryptedReader, err := wrapAlignedEncryptionReader(reader, offset, encryptionKey, iv)
+		if err != nil {
+			reader.Close()
+			return nil, err
+		}
+		return &readCloser{Reader: decryptedReader, Closer: reader}, nil
+	}
+	return copyDecompressed(object.Compression, readStored, size, storedSize, readOffset, length, writer)
+}
+
+func (yig *YigStorage) copyEncryptedPart(cephCluster *CephStorage, object *meta.Object, part *meta.Part,
+	readOffset int64, length int64, encryptionKey []byte, targetWriter io.Writer) (err error) {
 
-	reader, err := cephCluster.getAlignedReader(pool, part.ObjectId,
-		readOffset, length)
+	reader, err := yig.getCachedReader(cephCluster, object, part.ObjectId, part.Size,
+		readOffset, length, true)
 	if err != nil {
 		return err
 	}
@@ -430,19 +518,21 @@ func (yig *YigStorage) SetObjectAcl(bucketName string, objectName string, versio
 //}
 
 // Write path:
-//                                           +-----------+
-// PUT object/part                           |           |   Ceph
-//         +---------+------------+----------+ Encryptor +----->
-//                   |            |          |           |
-//                   |            |          +-----------+
+//                                           +------------+     +-----------+
+// PUT object/part                           |            |     |           |   Ceph
+//         +---------+------------+----------+ Compressor +-----+ Encryptor +----->
+//                   |            |          |            |     |           |
+//                   |            |          +------------+     +-----------+
 //                   v            v
 //                  SHA256      MD5(ETag)
 //
 // SHA256 is calculated only for v4 signed authentication
+// Compressor is enabled when the bucket has compression set
 // Encryptor is enabled when user set SSE headers
 func (yig *YigStorage) PutObject(bucketName string, objectName string, credential common.Credential,
 	size int64, data io.Reader, metadata map[string]string, acl datatype.Acl,
-	sseRequest datatype.SseRequest, storageClass meta.StorageClass) (result datatype.PutObjectResult, err error) {
+	sseRequest datatype.SseRequest, storageClass meta.StorageClass,
+	checksumRequest datatype.ChecksumRequest, tagging datatype.Tagging) (result datatype.PutObjectResult, err error) {
 
 	encryptionKey, cipherKey, err := yig.encryptionKeyFromSseRequest(sseRequest, bucketName, objectName)
 	helper.Debugln("get encryptionKey:", encryptionKey, "cipherKey:", cipherKey, "err:", err)
@@ -465,6 +555,10 @@ func (yig *YigStorage) PutObject(bucketName string, objectName string, credentia
 		}
 	}
 
+	// replicas put by replication workers are not replicated again
+	replicationStatus := metadata[replicaMetadataKey]
+	delete(metadata, replicaMetadataKey)
+
 	md5Writer := md5.New()
 
 	// Limit the reader to its provided size if specified.
@@ -475,14 +569,28 @@ func (yig *YigStorage) PutObject(bucketName string, objectName string, credentia
 		limitedDataReader = data
 	}
 
-	cephCluster, poolName := yig.PickOneClusterAndPool(bucketName, objectName, size, false)
-	if cephCluster == nil {
-		return result, ErrInternalError
+	// archived data is always stored in Ceph
+	inline := isInlinable(size) && !storageClass.IsArchive()
+	var cephCluster *CephStorage
+	var location, poolName, oid, compression string
+	if !inline {
+		cephCluster, poolName, err = yig.PickOneClusterAndPool(bucketName, objectName, size, false,
+			storageClass)
+		if err != nil {
+			return
+		}
+		location = cephCluster.Name
+		compression = bucket.Compression
+		// Mapping a shorter name for the object
+		oid = cephCluster.GetUniqUploadName()
 	}
-
-	// Mapping a shorter name for the object
-	oid := cephCluster.GetUniqUploadName()
-	dataReader := io.TeeReader(limitedDataReader, md5Writer)
+	crcWriter := &checksumWriter{}
+	hashWriter := io.MultiWriter(md5Writer, crcWriter)
+	requestedHash := newChecksumHash(checksumRequest.Algorithm)
+	if requestedHash != nil {
+		hashWriter = io.MultiWriter(hashWriter, requestedHash)
+	}
+	dataReader := io.TeeReader(limitedDataReader, hashWriter)
 
 	var initializationVector []byte
 	if len(encryptionKey) != 0 {
@@ -491,25 +599,50 @@ func (yig *YigStorage) PutObject(bucketName string, objectName string, credentia
 			return
 		}
 	}
-	// Not support now
-	storageReader, err := wrapEncryptionReader(dataReader, encryptionKey, initializationVector)
+	compressedReader, err := wrapCompressionReader(dataReader, compression)
 	if err != nil {
 		return
 	}
-	bytesWritten, err := cephCluster.Put(poolName, oid, storageReader)
+	// Not support now
+	storageReader, err := wrapEncryptionReader(compressedReader, encryptionKey, initializationVector)
 	if err != nil {
 		return
 	}
-	// Should metadata update failed, add `maybeObjectToRecycle` to `RecycleQueue`,
-	// so the object in Ceph could be removed asynchronously
-	maybeObjectToRecycle := objectToRecycle{
-		location: cephCluster.Name,
-		pool:     poolName,
-		objectId: oid,
+	var intents []meta.WriteIntent
+	var bytesWritten, packOffset int64
+	var inlineData []byte
+	packed := !inline && isPackable(poolName, size)
+	if inline {
+		inlineData, err = ioutil.ReadAll(storageReader)
+		if err != nil {
+			return
+		}
+		bytesWritten = int64(len(inlineData))
+	} else if packed {
+		// space of packed objects failed to commit is left as holes in their containers
+		oid, packOffset, bytesWritten, err = yig.packObject(cephCluster, poolName, storageReader)
+		if err != nil {
+			return
+		}
+	} else {
+		// Should metadata update failed, the intent is put into gc,
+		// so the object in Ceph could be removed asynchronously
+		var intent meta.WriteIntent
+		intent, err = yig.newWriteIntent(cephCluster, poolName, oid, bucketName, objectName)
+		if err != nil {
+			return
+		}
+		intents = append(intents, intent)
+		bytesWritten, err = cephCluster.Put(poolName, oid, storageReader)
+		if err != nil {
+			yig.abandonWriteIntents(intents...)
+			return
+		}
 	}
-	if bytesWritten < size {
-		RecycleQueue <- maybeObjectToRecycle
-		helper.Logger.Printf(2, "failed to write objects, already written(%d), total size(%d)", bytesWritten, size)
+	plainSize, compressedSize := writtenSizes(compressedReader, bytesWritten)
+	if plainSize < size {
+		yig.abandonWriteIntents(intents...)
+		helper.Logger.Printf(2, "failed to write objects, already written(%d), total size(%d)", plainSize, size)
 		return result, ErrIncompleteBody
 	}
 
@@ -517,17 +650,24 @@ func (yig *YigStorage) PutObject(bucketName string, objectName string, credentia
 	helper.Logger.Println(20, "### calculatedMd5:", calculatedMd5, "userMd5:", metadata["md5Sum"])
 	if userMd5, ok := metadata["md5Sum"]; ok {
 		if userMd5 != "" && userMd5 != calculatedMd5 {
-			RecycleQueue <- maybeObjectToRecycle
+			yig.abandonWriteIntents(intents...)
 			return result, ErrBadDigest
 		}
 	}
 
 	result.Md5 = calculatedMd5
+	result.Checksum = crcWriter.Sum()
+	result.ChecksumValue, err = checkRequestedChecksum(checksumRequest, requestedHash, data)
+	if err != nil {
+		yig.abandonWriteIntents(intents...)
+		return
+	}
+	result.ChecksumAlgorithm = checksumRequest.Algorithm
 
 	if signVerifyReader, ok := data.(*signature.SignVerifyReader); ok {
 		credential, err = signVerifyReader.Verify()
 		if err != nil {
-			RecycleQueue <- maybeObjectToRecycle
+			yig.abandonWriteIntents(intents...)
 			return
 		}
 	}
@@ -535,13 +675,14 @@ func (yig *YigStorage) PutObject(bucketName string, objectName string, credentia
 	object := &meta.Object{
 		Name:             objectName,
 		BucketName:       bucketName,
-		Location:         cephCluster.Name,
+		Location:         location,
 		Pool:             poolName,
 		OwnerId:          credential.UserId,
-		Size:             bytesWritten,
+		Size:             plainSize,
 		ObjectId:         oid,
 		LastModifiedTime: time.Now().UTC(),
 		Etag:             calculatedMd5,
+		Checksum:         result.Checksum,
 		ContentType:      metadata["Content-Type"],
 		ACL:              acl,
 		NullVersion:      helper.Ternary(bucket.Versioning == "Enabled", false, true).(bool),
@@ -553,13 +694,22 @@ func (yig *YigStorage) PutObject(bucketName string, objectName string, credentia
 		CustomAttributes:     metadata,
 		Type:                 meta.ObjectTypeNormal,
 		StorageClass:         storageClass,
+		ChecksumAlgorithm:    result.ChecksumAlgorithm,
+		ChecksumValue:        result.ChecksumValue,
+		Compression:          compression,
+		CompressedSize:       compressedSize,
+		Packed:               packed,
+		PackOffset:           packOffset,
+		InlineData:           inlineData,
+		Tagging:              tagging,
+		ReplicationStatus:    replicationStatus,
 	}
 
 	result.LastModified = object.LastModifiedTime
 	var nullVerNum uint64
 	nullVerNum, err = yig.checkOldObject(bucketName, objectName, bucket.Versioning)
 	if err != nil {
-		RecycleQueue <- maybeObjectToRecycle
+		yig.abandonWriteIntents(intents...)
 		return
 	}
 	if bucket.Versioning == "Enabled" {
@@ -570,24 +720,24 @@ func (yig *YigStorage) PutObject(bucketName string, objectName string, credentia
 		nullVerNum = uint64(object.LastModifiedTime.UnixNano())
 	}
 
+	replication := meta.NewReplicationTask(bucket, object)
 	if nullVerNum != 0 {
 		objMap := &meta.ObjMap{
 			Name:       objectName,
 			BucketName: bucketName,
 		}
-		err = yig.MetaStorage.PutObject(object, nil, objMap, true)
+		err = yig.MetaStorage.PutObject(object, nil, objMap, true, replication, intents...)
 	} else {
-		err = yig.MetaStorage.PutObject(object, nil, nil, true)
+		err = yig.MetaStorage.PutObject(object, nil, nil, true, replication, intents...)
 	}
 
 	if err != nil {
-		RecycleQueue <- maybeObjectToRecycle
+		yig.abandonWriteIntents(intents...)
 		return
 	}
 
 	if err == nil {
 		yig.MetaStorage.Cache.Remove(redis.ObjectTable, bucketName+":"+objectName+":")
-		yig.DataCache.Remove(bucketName + ":" + objectName + ":" + object.GetVersionId())
 	}
 	return result, nil
 }
@@ -606,6 +756,11 @@ func (yig *YigStorage) AppendObject(bucketName string, objectName string, creden
 	//TODO: Append Support Encryption
 	encryptionKey = nil
 
+	// archived objects could not be changed in place
+	if storageClass.IsArchive() {
+		return result, ErrInvalidStorageClass
+	}
+
 	md5Writer := md5.New()
 
 	// Limit the reader to its provided size if specified.
@@ -620,6 +775,9 @@ func (yig *YigStorage) AppendObject(bucketName string, objectName string, creden
 	var poolName, oid string
 	var initializationVector []byte
 	var objSize int64
+	// continues from checksum of data appended before, objects appended
+	// before checksums were introduced have none
+	var crcWriter *checksumWriter
 	if isObjectExist(objInfo) {
 		cephCluster, err = yig.GetClusterByFsName(objInfo.Location)
 		if err != nil {
@@ -627,17 +785,27 @@ func (yig *YigStorage) AppendObject(bucketName string, objectName string, creden
 		}
 		// Every appendable file must be treated as a big file
 		poolName = BIG_FILE_POOLNAME
+		if cephCluster.IsFull(poolName) {
+			return result, ErrStorageFull
+		}
 		oid = objInfo.ObjectId
 		initializationVector = objInfo.InitializationVector
 		objSize = objInfo.Size
 		storageClass = objInfo.StorageClass
+		if objInfo.Checksum != "" {
+			crcWriter, err = newChecksumWriter(objInfo.Checksum)
+			if err != nil {
+				return
+			}
+		}
 		helper.Logger.Println(20, "request append oid:", oid, "iv:", initializationVector, "size:", objSize)
 	} else {
 		// New appendable object
-		cephCluster, poolName = yig.PickOneClusterAndPool(bucketName, objectName, size, true)
-		if cephCluster == nil || poolName != BIG_FILE_POOLNAME {
-			helper.Debugln("PickOneClusterAndPool error")
-			return result, ErrInternalError
+		cephCluster, poolName, err = yig.PickOneClusterAndPool(bucketName, objectName, size, true,
+			storageClass)
+		if err != nil {
+			helper.Debugln("PickOneClusterAndPool error:", err)
+			return
 		}
 		// Mapping a shorter name for the object
 		oid = cephCluster.GetUniqUploadName()
@@ -647,37 +815,60 @@ func (yig *YigStorage) AppendObject(bucketName string, objectName string, creden
 				return
 			}
 		}
+		crcWriter = &checksumWriter{}
 		helper.Logger.Println(20, "request first append oid:", oid, "iv:", initializationVector, "size:", objSize)
 	}
 
-	dataReader := io.TeeReader(limitedDataReader, md5Writer)
+	var dataReader io.Reader
+	if crcWriter != nil {
+		dataReader = io.TeeReader(limitedDataReader, io.MultiWriter(md5Writer, crcWriter))
+	} else {
+		dataReader = io.TeeReader(limitedDataReader, md5Writer)
+	}
 
 	storageReader, err := wrapEncryptionReader(dataReader, encryptionKey, initializationVector)
 	if err != nil {
 		return
 	}
+	// appending to an existing object writes no new RADOS object
+	var intents []meta.WriteIntent
+	if !isObjectExist(objInfo) {
+		var intent meta.WriteIntent
+		intent, err = yig.newWriteIntent(cephCluster, poolName, oid, bucketName, objectName)
+		if err != nil {
+			return
+		}
+		intents = append(intents, intent)
+	}
 	bytesWritten, err := cephCluster.Append(poolName, oid, storageReader, offset, isObjectExist(objInfo))
 	if err != nil {
 		helper.Debugln("cephCluster.Append err:", err, poolName, oid, offset)
+		yig.abandonWriteIntents(intents...)
 		return
 	}
 
 	if bytesWritten < size {
+		yig.abandonWriteIntents(intents...)
 		return result, ErrIncompleteBody
 	}
 
 	calculatedMd5 := hex.EncodeToString(md5Writer.Sum(nil))
 	if userMd5, ok := metadata["md5Sum"]; ok {
 		if userMd5 != "" && userMd5 != calculatedMd5 {
+			yig.abandonWriteIntents(intents...)
 			return result, ErrBadDigest
 		}
 	}
 
 	result.Md5 = calculatedMd5
+	if crcWriter != nil {
+		result.Checksum = crcWriter.Sum()
+	}
 
 	if signVerifyReader, ok := data.(*signature.SignVerifyReader); ok {
 		credential, err = signVerifyReader.Verify()
 		if err != nil {
+			yig.abandonWriteIntents(intents...)
 			return
 		}
 	}
@@ -693,6 +884,7 @@ func (yig *YigStorage) AppendObject(bucketName string, objectName string, creden
 		ObjectId:             oid,
 		LastModifiedTime:     time.Now().UTC(),
 		Etag:                 calculatedMd5,
+		Checksum:             result.Checksum,
 		ContentType:          metadata["Content-Type"],
 		ACL:                  acl,
 		NullVersion:          true,
@@ -709,14 +901,14 @@ func (yig *YigStorage) AppendObject(bucketName string, objectName string, creden
 	result.NextPosition = object.Size
 	helper.Logger.Println(20, "Append info.", "bucket:", bucketName, "objName:", objectName, "oid:", oid,
 		"objSize:", object.Size, "bytesWritten:", bytesWritten, "storageClass:", storageClass)
-	err = yig.MetaStorage.AppendObject(object, isObjectExist(objInfo))
+	err = yig.MetaStorage.AppendObject(object, isObjectExist(objInfo), intents...)
 	if err != nil {
+		yig.abandonWriteIntents(intents...)
 		return
 	}
 
 	if err == nil {
 		yig.MetaStorage.Cache.Remove(redis.ObjectTable, bucketName+":"+objectName+":")
-		yig.DataCache.Remove(bucketName + ":" + objectName + ":" + object.GetVersionId())
 	}
 	return result, nil
 }
@@ -743,10 +935,10 @@ func (yig *YigStorage) UpdateObjectAttrs(targetObject *meta.Object, credential c
 	}
 	result.LastModified = targetObject.LastModifiedTime
 	result.Md5 = targetObject.Etag
+	result.Checksum = targetObject.Checksum
 	result.VersionId = targetObject.GetVersionId()
 
 	yig.MetaStorage.Cache.Remove(redis.ObjectTable, targetObject.BucketName+":"+targetObject.Name+":")
-	yig.DataCache.Remove(targetObject.BucketName + ":" + targetObject.Name + ":" + targetObject.GetVersionId())
 
 	return result, nil
 }
@@ -755,7 +947,8 @@ func (yig *YigStorage) CopyObject(targetObject *meta.Object, source io.Reader, c
 	sseRequest datatype.SseRequest) (result datatype.PutObjectResult, err error) {
 
 	var oid string
-	var maybeObjectToRecycle objectToRecycle
+	// intents of all RADOS objects written
+	var intents []meta.WriteIntent
 	var encryptionKey []byte
 	encryptionKey, cipherKey, err := yig.encryptionKeyFromSseRequest(sseRequest, targetObject.BucketName, targetObject.Name)
 	if err != nil {
@@ -780,8 +973,21 @@ func (yig *YigStorage) CopyObject(targetObject *meta.Object, source io.Reader, c
 	var limitedDataReader io.Reader
 	limitedDataReader = io.LimitReader(source, targetObject.Size)
 
-	cephCluster, poolName := yig.PickOneClusterAndPool(targetObject.BucketName,
-		targetObject.Name, targetObject.Size, false)
+	inline := len(targetObject.Parts) == 0 && isInlinable(targetObject.Size) &&
+		!targetObject.StorageClass.IsArchive()
+	var cephCluster *CephStorage
+	var location, poolName, compression string
+	if !inline {
+		cephCluster, poolName, err = yig.PickOneClusterAndPool(targetObject.BucketName,
+			targetObject.Name, targetObject.Size, false, targetObject.StorageClass)
+		if err != nil {
+			return
+		}
+		location = cephCluster.Name
+		compression = bucket.Compression
+	}
+	// the target might be copied from an inline object
+	targetObject.InlineData = nil
 
 	if len(targetObject.Parts) != 0 {
 		var targetParts map[int]*meta.Part = make(map[int]*meta.Part, len(targetObject.Parts))
@@ -799,7 +1005,8 @@ func (yig *YigStorage) CopyObject(targetObject *meta.Object, source io.Reader, c
 				pw.Close()
 			}()
 			md5Writer := md5.New()
-			dataReader := io.TeeReader(pr, md5Writer)
+			crcWriter := &checksumWriter{}
+			dataReader := io.TeeReader(pr, io.MultiWriter(md5Writer, crcWriter))
 			oid = cephCluster.GetUniqUploadName()
 			var bytesW int64
 			var storageReader io.Reader
@@ -810,41 +1017,59 @@ func (yig *YigStorage) CopyObject(targetObject *meta.Object, source io.Reader, c
 					return
 				}
 			}
-			storageReader, err = wrapEncryptionReader(dataReader, encryptionKey, initializationVector)
-			bytesW, err = cephCluster.Put(poolName, oid, storageReader)
-			maybeObjectToRecycle = objectToRecycle{
-				location: cephCluster.Name,
-				pool:     poolName,
-				objectId: oid,
+			var compressedReader io.Reader
+			compressedReader, err = wrapCompressionReader(dataReader, bucket.Compression)
+			if err != nil {
+				yig.abandonWriteIntents(intents...)
+				return
+			}
+			storageReader, err = wrapEncryptionReader(compressedReader, encryptionKey, initializationVector)
+			var intent meta.WriteIntent
+			intent, err = yig.newWriteIntent(cephCluster, poolName, oid, targetObject.BucketName, targetObject.Name)
+			if err != nil {
+				yig.abandonWriteIntents(intents...)
+				return
 			}
-			if bytesW < part.Size {
-				RecycleQueue <- maybeObjectToRecycle
+			intents = append(intents, intent)
+			bytesW, err = cephCluster.Put(poolName, oid, storageReader)
+			var plainSize int64
+			plainSize, part.CompressedSize = writtenSizes(compressedReader, bytesW)
+			if plainSize < part.Size {
+				yig.abandonWriteIntents(intents...)
 				return result, ErrIncompleteBody
 			}
 			if err != nil {
+				yig.abandonWriteIntents(intents...)
 				return result, err
 			}
 			calculatedMd5 := hex.EncodeToString(md5Writer.Sum(nil))
 			//we will only chack part etag,overall etag will be same if each part of etag is same
 			if calculatedMd5 != part.Etag {
 				err = ErrInternalError
-				RecycleQueue <- maybeObjectToRecycle
+				yig.abandonWriteIntents(intents...)
 				return
 			}
+			part.Checksum = crcWriter.Sum()
 			part.LastModified = time.Now().UTC().Format(meta.CREATE_TIME_LAYOUT)
 			part.ObjectId = oid
 
 			part.InitializationVector = initializationVector
 		}
 		targetObject.ObjectId = ""
+		targetObject.Packed = false
+		targetObject.PackOffset = 0
 		targetObject.Parts = targetParts
+		sortedParts := make([]*meta.Part, 0, len(targetParts))
+		for i := 1; i <= len(targetParts); i++ {
+			sortedParts = append(sortedParts, targetParts[i])
+		}
+		targetObject.Checksum = combinePartChecksums(sortedParts)
 		result.Md5 = targetObject.Etag
 	} else {
 		md5Writer := md5.New()
+		crcWriter := &checksumWriter{}
 
-		// Mapping a shorter name for the object
-		oid = cephCluster.GetUniqUploadName()
-		dataReader := io.TeeReader(limitedDataReader, md5Writer)
+		dataReader := io.TeeReader(limitedDataReader, io.MultiWriter(md5Writer, crcWriter))
 		var storageReader io.Reader
 		var initializationVector []byte
 		if len(encryptionKey) != 0 {
@@ -853,41 +1078,69 @@ func (yig *YigStorage) CopyObject(targetObject *meta.Object, source io.Reader, c
 				return
 			}
 		}
-		storageReader, err = wrapEncryptionReader(dataReader, encryptionKey, initializationVector)
+		var compressedReader io.Reader
+		compressedReader, err = wrapCompressionReader(dataReader, compression)
 		if err != nil {
 			return
 		}
-		var bytesWritten int64
-		bytesWritten, err = cephCluster.Put(poolName, oid, storageReader)
+		storageReader, err = wrapEncryptionReader(compressedReader, encryptionKey, initializationVector)
 		if err != nil {
 			return
 		}
-		// Should metadata update failed, add `maybeObjectToRecycle` to `RecycleQueue`,
-		// so the object in Ceph could be removed asynchronously
-		maybeObjectToRecycle = objectToRecycle{
-			location: cephCluster.Name,
-			pool:     poolName,
-			objectId: oid,
+		if !inline {
+			// Mapping a shorter name for the object
+			oid = cephCluster.GetUniqUploadName()
+		}
+		var bytesWritten, plainSize int64
+		targetObject.Packed = !inline && isPackable(poolName, targetObject.Size)
+		targetObject.PackOffset = 0
+		if inline {
+			targetObject.InlineData, err = ioutil.ReadAll(storageReader)
+			if err != nil {
+				return
+			}
+			bytesWritten = int64(len(targetObject.InlineData))
+		} else if targetObject.Packed {
+			oid, targetObject.PackOffset, bytesWritten, err = yig.packObject(cephCluster, poolName, storageReader)
+			if err != nil {
+				return
+			}
+		} else {
+			// Should metadata update failed, the intent is put into gc,
+			// so the object in Ceph could be removed asynchronously
+			var intent meta.WriteIntent
+			intent, err = yig.newWriteIntent(cephCluster, poolName, oid, targetObject.BucketName, targetObject.Name)
+			if err != nil {
+				return
+			}
+			intents = append(intents, intent)
+			bytesWritten, err = cephCluster.Put(poolName, oid, storageReader)
+			if err != nil {
+				yig.abandonWriteIntents(intents...)
+				return
+			}
 		}
-		if bytesWritten < targetObject.Size {
-			RecycleQueue <- maybeObjectToRecycle
+		plainSize, targetObject.CompressedSize = writtenSizes(compressedReader, bytesWritten)
+		if plainSize < targetObject.Size {
+			yig.abandonWriteIntents(intents...)
 			return result, ErrIncompleteBody
 		}
 
 		calculatedMd5 := hex.EncodeToString(md5Writer.Sum(nil))
 		if calculatedMd5 != targetObject.Etag {
-			RecycleQueue <- maybeObjectToRecycle
+			yig.abandonWriteIntents(intents...)
 			return result, ErrBadDigest
 		}
 		result.Md5 = calculatedMd5
 		targetObject.ObjectId = oid
 		targetObject.InitializationVector = initializationVector
+		targetObject.Checksum = crcWriter.Sum()
 	}
 	// TODO validate bucket policy and fancy ACL
 
 	targetObject.Rowkey = nil   // clear the rowkey cache
 	targetObject.VersionId = "" // clear the versionId cache
-	targetObject.Location = cephCluster.Name
+	targetObject.Location = location
 	targetObject.Pool = poolName
 	targetObject.OwnerId = credential.UserId
 	targetObject.LastModifiedTime = time.Now().UTC()
@@ -896,13 +1149,14 @@ func (yig *YigStorage) CopyObject(targetObject *meta.Object, source io.Reader, c
 	targetObject.SseType = sseRequest.Type
 	targetObject.EncryptionKey = helper.Ternary(sseRequest.Type == crypto.S3.String(),
 		cipherKey, []byte("")).([]byte)
+	targetObject.Compression = compression
 
 	result.LastModified = targetObject.LastModifiedTime
 
 	var nullVerNum uint64
 	nullVerNum, err = yig.checkOldObject(targetObject.BucketName, targetObject.Name, bucket.Versioning)
 	if err != nil {
-		RecycleQueue <- maybeObjectToRecycle
+		yig.abandonWriteIntents(intents...)
 		return
 	}
 	if bucket.Versioning == "Enabled" {
@@ -918,24 +1172,127 @@ func (yig *YigStorage) CopyObject(targetObject *meta.Object, source io.Reader, c
 		BucketName: targetObject.BucketName,
 	}
 
+	replication := meta.NewReplicationTask(bucket, targetObject)
 	if nullVerNum != 0 {
 		objMap.NullVerNum = nullVerNum
-		err = yig.MetaStorage.PutObject(targetObject, nil, objMap, true)
+		err = yig.MetaStorage.PutObject(targetObject, nil, objMap, true, replication, intents...)
 	} else {
-		err = yig.MetaStorage.PutObject(targetObject, nil, nil, true)
+		err = yig.MetaStorage.PutObject(targetObject, nil, nil, true, replication, intents...)
 	}
 
 	if err != nil {
-		RecycleQueue <- maybeObjectToRecycle
+		yig.abandonWriteIntents(intents...)
 		return
 	}
 
 	yig.MetaStorage.Cache.Remove(redis.ObjectTable, targetObject.BucketName+":"+targetObject.Name+":")
-	yig.DataCache.Remove(targetObject.BucketName + ":" + targetObject.Name + ":" + targetObject.GetVersionId())
 
+	result.Checksum = targetObject.Checksum
 	return result, nil
 }
 
+// Returns true if `targetObject` copied from `sourceObject` could share its
+// data in Ceph. Data is stored as encrypted by the source, so the copy must
+// be encrypted the same way. SSE-S3 keys are sealed with object names, so
+// they could not be shared. Inline and packed objects are cheap to copy,
+// and appendable objects are changed in place.
+func isDataShareable(sourceObject *meta.Object, sseRequest datatype.SseRequest) bool {
+	if sourceObject.IsInline() || sourceObject.Packed || sourceObject.Type == meta.ObjectTypeAppendable {
+		return false
+	}
+	switch sourceObject.SseType {
+	case "":
+		return sseRequest.Type == ""
+	case crypto.SSEC.String():
+		if sseRequest.Type != crypto.SSEC.String() {
+			return false
+		}
+		return len(sseRequest.CopySourceSseCustomerKey) == 0 ||
+			bytes.Equal(sseRequest.CopySourceSseCustomerKey, sseRequest.SseCustomerKey)
+	default:
+		return false
+	}
+}
+
+// Copy `sourceObject` as `targetObject` by referencing data of the source,
+// instead of copying it. Returns false if the data could not be shared, and
+// should be copied by `CopyObject`.
+func (yig *YigStorage) CopyObjectByReference(targetObject *meta.Object, sourceObject *meta.Object,
+	credential common.Credential, sseRequest datatype.SseRequest) (result datatype.PutObjectResult,
+	shared bool, err error) {
+
+	// archived data is stored apart from others
+	if !isDataShareable(sourceObject, sseRequest) ||
+		sourceObject.IsArchived() != targetObject.StorageClass.IsArchive() {
+		return result, false, nil
+	}
+	bucket, err := yig.MetaStorage.GetBucket(targetObject.BucketName, true)
+	if err != nil {
+		return
+	}
+
+	switch bucket.ACL.CannedAcl {
+	case "public-read-write":
+		break
+	default:
+		if bucket.OwnerId != credential.UserId {
+			return result, false, ErrBucketAccessForbidden
+		}
+	}
+
+	targetObject.Rowkey = nil   // clear the rowkey cache
+	targetObject.VersionId = "" // clear the versionId cache
+	targetObject.Location = sourceObject.Location
+	targetObject.Pool = sourceObject.Pool
+	targetObject.ObjectId = sourceObject.ObjectId
+	targetObject.Parts = sourceObject.Parts
+	targetObject.PartsIndex = sourceObject.PartsIndex
+	targetObject.Type = sourceObject.Type
+	targetObject.Compression = sourceObject.Compression
+	targetObject.CompressedSize = sourceObject.CompressedSize
+	targetObject.SseType = sourceObject.SseType
+	targetObject.EncryptionKey = sourceObject.EncryptionKey
+	targetObject.InitializationVector = sourceObject.InitializationVector
+	targetObject.OwnerId = credential.UserId
+	targetObject.LastModifiedTime = time.Now().UTC()
+	targetObject.NullVersion = helper.Ternary(bucket.Versioning == "Enabled", false, true).(bool)
+	targetObject.DeleteMarker = false
+
+	result.LastModified = targetObject.LastModifiedTime
+	result.Md5 = targetObject.Etag
+	result.Checksum = targetObject.Checksum
+
+	nullVerNum, err := yig.checkOldObject(targetObject.BucketName, targetObject.Name, bucket.Versioning)
+	if err != nil {
+		return
+	}
+	if bucket.Versioning == "Enabled" {
+		result.VersionId = targetObject.GetVersionId()
+	}
+	// update null version number
+	if bucket.Versioning == "Suspended" {
+		nullVerNum = uint64(targetObject.LastModifiedTime.UnixNano())
+	}
+
+	replication := meta.NewReplicationTask(bucket, targetObject)
+	if nullVerNum != 0 {
+		objMap := &meta.ObjMap{
+			Name:       targetObject.Name,
+			BucketName: targetObject.BucketName,
+			NullVerNum: nullVerNum,
+		}
+		err = yig.MetaStorage.PutObjectReference(targetObject, sourceObject, objMap, true, replication)
+	} else {
+		err = yig.MetaStorage.PutObjectReference(targetObject, sourceObject, nil, true, replication)
+	}
+	if err != nil {
+		return
+	}
+
+	yig.MetaStorage.Cache.Remove(redis.ObjectTable, targetObject.BucketName+":"+targetObject.Name+":")
+	return result, true, nil
+}
+
 func (yig *YigStorage) removeByObject(object *meta.Object, objMap *meta.ObjMap) (err error) {
 
 	err = yig.MetaStorage.DeleteObject(object, object.DeleteMarker, objMap)
@@ -1086,10 +1443,11 @@ func (yig *YigStorage) addDeleteMarker(bucket meta.Bucket, objectName string,
 		BucketName: bucket.Name,
 	}
 
+	replication := meta.NewReplicationTask(&bucket, deleteMarker)
 	if nullVersion {
-		err = yig.MetaStorage.PutObject(deleteMarker, nil, objMap, false)
+		err = yig.MetaStorage.PutObject(deleteMarker, nil, objMap, false, replication)
 	} else {
-		err = yig.MetaStorage.PutObject(deleteMarker, nil, nil, false)
+		err = yig.MetaStorage.PutObject(deleteMarker, nil, nil, false, replication)
 	}
 
 	return
@@ -1169,12 +1527,9 @@ func (yig *YigStorage) DeleteObject(bucketName string, objectName string, versio
 
 	if err == nil {
 		yig.MetaStorage.Cache.Remove(redis.ObjectTable, bucketName+":"+objectName+":")
-		yig.DataCache.Remove(bucketName + ":" + objectName + ":")
-		yig.DataCache.Remove(bucketName + ":" + objectName + ":" + "null")
 		if version != "" {
 			yig.MetaStorage.Cache.Remove(redis.ObjectTable,
 				bucketName+":"+objectName+":"+version)
-			yig.DataCache.Remove(bucketName + ":" + objectName + ":" + version)
 		}
 	}
 	return result, nil
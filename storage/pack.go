@@ -0,0 +1,227 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/journeymidnight/yig/helper"
+	meta "github.com/journeymidnight/yig/meta/types"
+	"github.com/journeymidnight/yig/redis"
+)
+
+// Small objects are packed into containers, i.e. large RADOS objects in
+// SMALL_FILE_POOLNAME, one after another:
+//
+//   | object A | object B | (hole of deleted C) | object D | ...
+//
+// Each process appends to containers of its own, so offsets are reserved in
+// memory. A container is recorded in metadata before written, and objects
+// are recorded in it when committed, so ranges of failed writes are holes
+// too. Containers are sealed when full or old, then compacted when their
+// live data is under `pack_compaction_ratio`, by rewriting live objects
+// into new containers and putting the old ones into gc.
+
+const (
+	PACK_CONTAINER_MAX_AGE = 1 * time.Hour
+	// containers not sealed by their writers, e.g. crashed, are compacted after it
+	PACK_CONTAINER_ABANDON_AGE = 24 * time.Hour
+	PACK_COMPACTION_SCAN_LIMIT = 100
+)
+
+type openContainer struct {
+	container meta.Container
+	end       int64 // end of the last range reserved
+}
+
+type packer struct {
+	mutex      sync.Mutex
+	containers map[string]*openContainer // location + pool -> container appended
+}
+
+func newPacker() *packer {
+	return &packer{
+		containers: make(map[string]*openContainer),
+	}
+}
+
+// Returns true if objects of `size` written to `poolName` should be packed
+func isPackable(poolName string, size int64) bool {
+	return helper.CONFIG.PackSmallObjects && poolName == SMALL_FILE_POOLNAME &&
+		size >= 0 && size < BIG_FILE_THRESHOLD
+}
+
+// Reserve `length` bytes in the container of `cluster`, a new container is
+// started if the current one is full or too old.
+func (yig *YigStorage) reservePacking(cluster *CephStorage, poolName string,
+	length int64) (oid string, offset int64, err error) {
+
+	p := yig.packer
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	key := cluster.Name + "/" + poolName
+	c := p.containers[key]
+	if c != nil && (c.end+length > helper.CONFIG.PackContainerSize ||
+		time.Since(c.container.CreateTime) > PACK_CONTAINER_MAX_AGE) {
+		yig.sealContainer(&c.container)
+		delete(p.containers, key)
+		c = nil
+	}
+	if c == nil {
+		c = &openContainer{
+			container: meta.Container{
+				Location:   cluster.Name,
+				Pool:       poolName,
+				ObjectId:   cluster.GetUniqUploadName(),
+				CreateTime: time.Now().UTC(),
+			},
+		}
+		err = yig.MetaStorage.PutContainer(&c.container)
+		if err != nil {
+			helper.ErrorIf(err, "Failed to put container %s/%s", cluster.Name, c.container.ObjectId)
+			return
+		}
+		p.containers[key] = c
+	}
+	offset = c.end
+	c.end += length
+	return c.container.ObjectId, offset, nil
+}
+
+// Containers failed to be sealed here are compacted after PACK_CONTAINER_ABANDON_AGE
+func (yig *YigStorage) sealContainer(container *meta.Container) {
+	err := yig.MetaStorage.SealContainer(container)
+	if err != nil {
+		helper.Logger.Println(5, "Failed to seal container", container.Location, container.Pool,
+			container.ObjectId, "err:", err)
+	}
+}
+
+// Seal all containers being appended, so they could be compacted
+func (yig *YigStorage) sealContainers() {
+	p := yig.packer
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for key, c := range p.containers {
+		yig.sealContainer(&c.container)
+		delete(p.containers, key)
+	}
+}
+
+// Append all of `data` to a container in `cluster`.
+// Returns the container and where the data starts in it.
+func (yig *YigStorage) packObject(cluster *CephStorage, poolName string,
+	data io.Reader) (oid string, offset int64, size int64, err error) {
+
+	buf, err := ioutil.ReadAll(data)
+	if err != nil {
+		return
+	}
+	oid, offset, err = yig.reservePacking(cluster, poolName, int64(len(buf)))
+	if err != nil {
+		return
+	}
+	err = cluster.WriteAt(poolName, oid, buf, offset)
+	if err != nil {
+		return
+	}
+	return oid, offset, int64(len(buf)), nil
+}
+
+// Rewrite containers whose ratio of live data is under `liveRatio`.
+// Returns the number of containers put into gc.
+func (yig *YigStorage) CompactContainers(liveRatio float64) (compacted int, err error) {
+	var marker meta.Container
+	for !yig.Stopping {
+		var containers []meta.Container
+		containers, err = yig.MetaStorage.ScanContainers(liveRatio,
+			time.Now().Add(-PACK_CONTAINER_ABANDON_AGE), marker, PACK_COMPACTION_SCAN_LIMIT)
+		if err != nil {
+			return
+		}
+		for i := range containers {
+			if yig.Stopping {
+				break
+			}
+			if yig.compactContainer(&containers[i]) {
+				compacted++
+			}
+		}
+		if len(containers) < PACK_COMPACTION_SCAN_LIMIT {
+			break
+		}
+		marker = containers[len(containers)-1]
+	}
+	return
+}
+
+// Move live objects of `container` to other containers and put it into gc.
+// Returns true if the container is put into gc.
+func (yig *YigStorage) compactContainer(container *meta.Container) bool {
+	cluster, ok := yig.DataStorage[container.Location]
+	if !ok {
+		helper.Logger.Println(5, "Cannot find ceph cluster of container", container.Location,
+			container.Pool, container.ObjectId)
+		return false
+	}
+	objects, err := yig.MetaStorage.GetPackedObjects(container)
+	if err != nil {
+		helper.Logger.Println(5, "Failed to get objects of container", container.Location,
+			container.Pool, container.ObjectId, "err:", err)
+		return false
+	}
+	for _, object := range objects {
+		if !object.Packed || object.Location != container.Location || object.ObjectId != container.ObjectId {
+			continue // changed since recorded
+		}
+		err = yig.repackObject(cluster, object)
+		if err != nil {
+			helper.Logger.Println(5, "Failed to move object", object.BucketName, object.Name,
+				"out of container", container.Location, container.Pool, container.ObjectId, "err:", err)
+			return false
+		}
+	}
+	removed, err := yig.MetaStorage.RemoveContainer(container)
+	if err != nil {
+		helper.Logger.Println(5, "Failed to remove container", container.Location, container.Pool,
+			container.ObjectId, "err:", err)
+		return false
+	}
+	if removed {
+		helper.Logger.Println(10, "Compacted container", container.Location, container.Pool,
+			container.ObjectId, "objects moved:", len(objects))
+	}
+	return removed
+}
+
+// Copy data of packed `object` to the end of the current container
+func (yig *YigStorage) repackObject(cluster *CephStorage, object *meta.Object) error {
+	reader, err := cluster.getReader(object.Pool, object.ObjectId, object.PackOffset, object.StoredSize())
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	oid, offset, size, err := yig.packObject(cluster, object.Pool, reader)
+	if err != nil {
+		return err
+	}
+	if size != object.StoredSize() {
+		return fmt.Errorf("copied %d bytes of packed object, expected %d", size, object.StoredSize())
+	}
+	moved := *object
+	moved.ObjectId = oid
+	moved.PackOffset = offset
+	migrated, err := yig.MetaStorage.MigrateObject(object, &moved, nil)
+	if err != nil || !migrated {
+		// the range written becomes a hole of the new container
+		return err
+	}
+	version := strconv.FormatUint(math.MaxUint64-uint64(object.LastModifiedTime.UnixNano()), 10)
+	yig.MetaStorage.Cache.Remove(redis.ObjectTable, object.BucketName+":"+object.Name+":")
+	yig.MetaStorage.Cache.Remove(redis.ObjectTable, object.BucketName+":"+object.Name+":"+version)
+	return nil
+}
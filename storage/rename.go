@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/journeymidnight/yig/error"
+	"github.com/journeymidnight/yig/helper"
+	"github.com/journeymidnight/yig/iam/common"
+	meta "github.com/journeymidnight/yig/meta/types"
+	"github.com/journeymidnight/yig/redis"
+)
+
+// Rename `sourceName` to `targetName` in `bucketName` with all its versions,
+// or every key under `sourceName` if both are prefixes ending with "/".
+// Only metadata rows are rewritten, in one transaction.
+//
+// In buckets without versioning, objects at targets are replaced. In
+// versioned buckets, renaming onto any existing version fails, so version
+// histories and null versions are never mixed.
+// Returns keys renamed and their new names.
+func (yig *YigStorage) RenameObject(bucketName, sourceName, targetName string,
+	credential common.Credential) (sourceNames, targetNames []string, err error) {
+
+	bucket, err := yig.MetaStorage.GetBucket(bucketName, true)
+	if err != nil {
+		return
+	}
+	switch bucket.ACL.CannedAcl {
+	case "public-read-write":
+		break
+	default:
+		if bucket.OwnerId != credential.UserId {
+			return nil, nil, ErrBucketAccessForbidden
+		}
+	}
+
+	sourceNames, targetNames, err = yig.renameTargets(bucket, sourceName, targetName)
+	if err != nil {
+		return
+	}
+
+	versions, removed, err := yig.MetaStorage.RenameObjects(bucket, sourceNames, targetNames,
+		bucket.Versioning == "Disabled")
+	if err != nil {
+		return nil, nil, err
+	}
+	// versions moved might be cached as not found at targets
+	for i, name := range sourceNames {
+		yig.removeObjectVersionsCache(bucketName, name, versions[i])
+		yig.removeObjectVersionsCache(bucketName, targetNames[i], versions[i])
+	}
+	for _, object := range removed {
+		yig.removeObjectCache(object)
+	}
+	helper.Logger.Println(10, "Renamed", len(sourceNames), "objects in", bucketName,
+		"from", sourceName, "to", targetName)
+	return sourceNames, targetNames, nil
+}
+
+// Remove cached current version and `versions` of `objectName`, by both row
+// versions and version ids
+func (yig *YigStorage) removeObjectVersionsCache(bucketName, objectName string, versions []string) {
+	prefix := bucketName + ":" + objectName + ":"
+	yig.MetaStorage.Cache.Remove(redis.ObjectTable, prefix)
+	for _, version := range versions {
+		yig.MetaStorage.Cache.Remove(redis.ObjectTable, prefix+version)
+		rowVersion, err := strconv.ParseUint(version, 10, 64)
+		if err != nil {
+			continue
+		}
+		object := meta.Object{LastModifiedTime: time.Unix(0, int64(math.MaxUint64-rowVersion))}
+		yig.MetaStorage.Cache.Remove(redis.ObjectTable, prefix+object.GetVersionId())
+	}
+}
+
+// Keys to rename and their new names
+func (yig *YigStorage) renameTargets(bucket *meta.Bucket, sourceName, targetName string) (sourceNames,
+	targetNames []string, err error) {
+
+	if sourceName == targetName {
+		return nil, nil, ErrInvalidRenameSource
+	}
+	isPrefix := strings.HasSuffix(sourceName, "/")
+	if isPrefix != strings.HasSuffix(targetName, "/") {
+		return nil, nil, ErrInvalidRenameSource
+	}
+	if !isPrefix {
+		// the current version should exist, renaming deleted objects is not allowed
+		_, err = yig.MetaStorage.GetObject(bucket.Name, sourceName, false)
+		if err != nil {
+			return nil, nil, err
+		}
+		return []string{sourceName}, []string{targetName}, nil
+	}
+
+	// renamed keys would be renamed again, or overwrite each other
+	if strings.HasPrefix(targetName, sourceName) || strings.HasPrefix(sourceName, targetName) {
+		return nil, nil, ErrInvalidRenameSource
+	}
+	sourceNames, err = yig.MetaStorage.ListObjectNames(bucket.Name, sourceName,
+		helper.CONFIG.RenameMaxKeys+1)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(sourceNames) == 0 {
+		return nil, nil, ErrNoSuchKey
+	}
+	if len(sourceNames) > helper.CONFIG.RenameMaxKeys {
+		return nil, nil, ErrTooManyKeysToRename
+	}
+	for _, name := range sourceNames {
+		targetNames = append(targetNames, targetName+strings.TrimPrefix(name, sourceName))
+	}
+	return sourceNames, targetNames, nil
+}
@@ -0,0 +1,424 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/journeymidnight/aws-sdk-go/aws"
+	"github.com/journeymidnight/aws-sdk-go/aws/credentials"
+	"github.com/journeymidnight/aws-sdk-go/aws/session"
+	"github.com/journeymidnight/aws-sdk-go/service/s3"
+	"github.com/journeymidnight/aws-sdk-go/service/s3/s3manager"
+	"github.com/journeymidnight/yig/api/datatype"
+	"github.com/journeymidnight/yig/crypto"
+	. "github.com/journeymidnight/yig/error"
+	"github.com/journeymidnight/yig/helper"
+	"github.com/journeymidnight/yig/iam/common"
+	meta "github.com/journeymidnight/yig/meta/types"
+	"github.com/journeymidnight/yig/redis"
+)
+
+// Objects of buckets with replication rules are replicated asynchronously.
+// When an object version or a delete marker matching the rules is put, the
+// version is marked PENDING and a task is queued into the `replication`
+// table, in the same transaction as the object metadata. Workers of every
+// instance scan tasks due, claim them for `replicationLease`, and copy data
+// and metadata into destination buckets of this YIG, or of remote S3
+// endpoints in `replication_targets`.
+// Failed tasks are retried with exponential backoff, and the object version
+// is marked FAILED once given up after `replication_max_retries`.
+
+const (
+	// tasks claimed by crashed instances are processed again after the lease
+	replicationLease     = 30 * time.Minute
+	replicationScanLimit = 100
+	maxReplicationError  = 1024
+	// metadata passed to PutObject to put replicas, which are not replicated again
+	replicaMetadataKey = "replicationStatus"
+)
+
+var errReplicationUnsupported = errors.New("objects encrypted with customer keys could not be replicated")
+
+type ReplicationStats struct {
+	Replicating int64 // tasks being processed
+	Completed   int64
+	Failed      int64 // failed tries, including ones retried later
+	GivenUp     int64
+}
+
+// Tasks queued and statistics of workers on this instance
+type ReplicationProgress struct {
+	Backlog meta.ReplicationBacklog
+	Failing []meta.ReplicationTask // tasks to retry, earliest first
+	Stats   ReplicationStats
+}
+
+type replicator struct {
+	stats   ReplicationStats // updated atomically
+	mutex   sync.Mutex
+	clients map[string]*s3.S3 // target name -> client
+}
+
+func newReplicator() *replicator {
+	return &replicator{
+		clients: make(map[string]*s3.S3),
+	}
+}
+
+// Empty `replication` removes the replication configuration
+func (yig *YigStorage) SetBucketReplication(bucketName string, replication datatype.ReplicationConfiguration,
+	credential common.Credential) error {
+
+	bucket, err := yig.MetaStorage.GetBucket(bucketName, false)
+	if err != nil {
+		return err
+	}
+	if bucket.OwnerId != credential.UserId {
+		return ErrBucketAccessForbidden
+	}
+	if len(replication.Rules) != 0 && bucket.Versioning != "Enabled" {
+		return ErrReplicationRequiresVersioning
+	}
+	for _, rule := range replication.Rules {
+		if rule.Destination.StorageClass != "" {
+			if _, err := meta.MatchStorageClassIndex(rule.Destination.StorageClass); err != nil {
+				return ErrInvalidReplicationConfiguration
+			}
+		}
+		target, destination, _ := datatype.ParseReplicationDestination(rule.Destination.Bucket)
+		if target != "" {
+			if _, ok := helper.CONFIG.ReplicationTargets[target]; !ok {
+				return ErrInvalidReplicationDestination
+			}
+			continue
+		}
+		if destination == bucketName {
+			return ErrInvalidReplicationDestination
+		}
+		destinationBucket, err := yig.MetaStorage.GetBucket(destination, true)
+		if err != nil || destinationBucket.OwnerId != bucket.OwnerId {
+			return ErrInvalidReplicationDestination
+		}
+	}
+	bucket.Replication = replication
+	err = yig.MetaStorage.Client.PutBucket(*bucket)
+	if err != nil {
+		return err
+	}
+	yig.MetaStorage.Cache.Remove(redis.BucketTable, bucketName)
+	return nil
+}
+
+func (yig *YigStorage) GetBucketReplication(bucketName string,
+	credential common.Credential) (replication datatype.ReplicationConfiguration, err error) {
+
+	bucket, err := yig.MetaStorage.GetBucket(bucketName, true)
+	if err != nil {
+		return replication, err
+	}
+	if bucket.OwnerId != credential.UserId {
+		err = ErrBucketAccessForbidden
+		return
+	}
+	if len(bucket.Replication.Rules) == 0 {
+		err = ErrReplicationConfigurationNotFound
+		return
+	}
+	return bucket.Replication, nil
+}
+
+func (yig *YigStorage) setReplicationStatus(object *meta.Object, status string) {
+	object.ReplicationStatus = status
+	err := yig.MetaStorage.UpdateObjectReplicationStatus(object)
+	if err != nil {
+		helper.Logger.Printf(2, "failed to set replication status of %s/%s to %s, err: %v",
+			object.BucketName, object.Name, status, err)
+		return
+	}
+	prefix := object.BucketName + ":" + object.Name + ":"
+	rowVersion := strconv.FormatUint(math.MaxUint64-uint64(object.LastModifiedTime.UnixNano()), 10)
+	yig.MetaStorage.Cache.Remove(redis.ObjectTable, prefix)
+	yig.MetaStorage.Cache.Remove(redis.ObjectTable, prefix+rowVersion)
+	yig.MetaStorage.Cache.Remove(redis.ObjectTable, prefix+object.GetVersionId())
+}
+
+func (yig *YigStorage) GetReplicationProgress(limit int) (progress ReplicationProgress, err error) {
+	progress.Backlog, err = yig.MetaStorage.GetReplicationBacklog()
+	if err != nil {
+		return
+	}
+	progress.Failing, err = yig.MetaStorage.ScanFailedReplicationTasks(limit)
+	if err != nil {
+		return
+	}
+	stats := &yig.replicator.stats
+	progress.Stats = ReplicationStats{
+		Replicating: atomic.LoadInt64(&stats.Replicating),
+		Completed:   atomic.LoadInt64(&stats.Completed),
+		Failed:      atomic.LoadInt64(&stats.Failed),
+		GivenUp:     atomic.LoadInt64(&stats.GivenUp),
+	}
+	return
+}
+
+// Scan and claim tasks due, and process them by `replication_concurrency` workers
+func (yig *YigStorage) replicate() {
+	yig.WaitGroup.Add(1)
+	defer yig.WaitGroup.Done()
+	tasks := make(chan meta.ReplicationTask)
+	defer close(tasks)
+	for i := 0; i < helper.CONFIG.ReplicationConcurrency; i++ {
+		yig.WaitGroup.Add(1)
+		go func() {
+			defer yig.WaitGroup.Done()
+			for task := range tasks {
+				yig.processReplicationTask(task)
+			}
+		}()
+	}
+	for !yig.Stopping {
+		scanned, err := yig.MetaStorage.ScanDueReplicationTasks(replicationScanLimit)
+		if err != nil {
+			helper.Logger.Println(2, "failed to scan replication tasks, err:", err)
+		}
+		var claimed int
+		for i := range scanned {
+			if yig.Stopping {
+				return
+			}
+			ok, err := yig.MetaStorage.ClaimReplicationTask(&scanned[i], replicationLease)
+			if err != nil {
+				helper.Logger.Println(2, "failed to claim replication task, err:", err)
+				continue
+			}
+			if ok {
+				tasks <- scanned[i]
+				claimed++
+			}
+		}
+		if claimed == 0 || len(scanned) < replicationScanLimit {
+			time.Sleep(1 * time.Second)
+		}
+	}
+}
+
+func (yig *YigStorage) processReplicationTask(task meta.ReplicationTask) {
+	stats := &yig.replicator.stats
+	atomic.AddInt64(&stats.Replicating, 1)
+	defer atomic.AddInt64(&stats.Replicating, -1)
+
+	object, err := yig.runReplicationTask(&task)
+	if err == nil {
+		if object != nil {
+			yig.setReplicationStatus(object, meta.ReplicationStatusCompleted)
+		}
+		atomic.AddInt64(&stats.Completed, 1)
+		err = yig.MetaStorage.RemoveReplicationTask(&task)
+		if err != nil {
+			helper.Logger.Printf(2, "failed to remove replication task of %s/%s, err: %v",
+				task.BucketName, task.ObjectName, err)
+		}
+		return
+	}
+
+	atomic.AddInt64(&stats.Failed, 1)
+	task.Retries++
+	task.LastError = err.Error()
+	if len(task.LastError) > maxReplicationError {
+		task.LastError = task.LastError[:maxReplicationError]
+	}
+	if err == errReplicationUnsupported || err == ErrInvalidObjectState ||
+		task.Retries >= helper.CONFIG.ReplicationMaxRetries {
+		helper.Logger.Printf(2, "give up replication %s of %s/%s after %d tries, err: %v",
+			task.Operation, task.BucketName, task.ObjectName, task.Retries, err)
+		if object != nil {
+			yig.setReplicationStatus(object, meta.ReplicationStatusFailed)
+		}
+		atomic.AddInt64(&stats.GivenUp, 1)
+		err = yig.MetaStorage.RemoveReplicationTask(&task)
+		if err != nil {
+			helper.Logger.Printf(2, "failed to remove replication task of %s/%s, err: %v",
+				task.BucketName, task.ObjectName, err)
+		}
+		return
+	}
+	helper.Logger.Printf(5, "failed to replicate %s of %s/%s, retry %d, err: %v",
+		task.Operation, task.BucketName, task.ObjectName, task.Retries, err)
+	backoff := time.Duration(helper.CONFIG.ReplicationRetryMaxInterval) * time.Second
+	if task.Retries < 32 && time.Second<<uint(task.Retries-1) < backoff {
+		backoff = time.Second << uint(task.Retries-1)
+	}
+	task.NextTime = time.Now().Add(backoff)
+	err = yig.MetaStorage.UpdateReplicationTask(&task)
+	if err != nil {
+		helper.Logger.Printf(2, "failed to update replication task of %s/%s, err: %v",
+			task.BucketName, task.ObjectName, err)
+	}
+}
+
+// Replicate the object version or delete marker of `task`. Returns the
+// object version replicated, nil for delete markers or tasks no longer
+// needed, e.g. the version is removed or rules are changed.
+func (yig *YigStorage) runReplicationTask(task *meta.ReplicationTask) (object *meta.Object, err error) {
+	bucket, err := yig.MetaStorage.GetBucket(task.BucketName, true)
+	if err == ErrNoSuchBucket {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	version, err := yig.MetaStorage.Client.GetObject(task.BucketName, task.ObjectName,
+		strconv.FormatUint(task.Version, 10))
+	if err == ErrNoSuchKey {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if task.Operation == meta.ReplicationOperationDelete {
+		rule := bucket.Replication.RuleFor(task.ObjectName, nil)
+		if rule == nil || !rule.ReplicatesDeleteMarkers() {
+			return nil, nil
+		}
+		target, destination, _ := datatype.ParseReplicationDestination(rule.Destination.Bucket)
+		if target == "" {
+			_, err = yig.DeleteObject(destination, task.ObjectName, "",
+				common.Credential{UserId: bucket.OwnerId})
+			return nil, err
+		}
+		client, err := yig.replicationClient(target)
+		if err != nil {
+			return nil, err
+		}
+		_, err = client.DeleteObject(&s3.DeleteObjectInput{
+			Bucket: aws.String(destination),
+			Key:    aws.String(task.ObjectName),
+		})
+		return nil, err
+	}
+
+	object = version
+	rule := bucket.Replication.RuleFor(task.ObjectName, &object.Tagging)
+	if rule == nil {
+		yig.setReplicationStatus(object, "")
+		return nil, nil
+	}
+	if object.SseType == crypto.SSEC.String() {
+		return object, errReplicationUnsupported
+	}
+	if !object.IsReadable() {
+		return object, ErrInvalidObjectState
+	}
+	var reader io.Reader = bytes.NewReader(nil)
+	if object.Size > 0 {
+		pipeReader, pipeWriter := io.Pipe()
+		go func() {
+			pipeWriter.CloseWithError(yig.GetObject(object, 0, object.Size, pipeWriter,
+				datatype.SseRequest{}))
+		}()
+		// stop reading if writing fails
+		defer pipeReader.Close()
+		reader = pipeReader
+	}
+	target, destination, _ := datatype.ParseReplicationDestination(rule.Destination.Bucket)
+	if target == "" {
+		return object, yig.replicateLocally(bucket, object, destination, rule, reader)
+	}
+	return object, yig.replicateRemotely(target, object, destination, rule, reader)
+}
+
+func (yig *YigStorage) replicateLocally(bucket *meta.Bucket, object *meta.Object, destination string,
+	rule *datatype.ReplicationRule, data io.Reader) error {
+
+	metadata, sseRequest := copiedObjectMetadata(object)
+	metadata[replicaMetadataKey] = meta.ReplicationStatusReplica
+	storageClass := object.StorageClass
+	if rule.Destination.StorageClass != "" {
+		storageClass, _ = meta.MatchStorageClassIndex(rule.Destination.StorageClass)
+	}
+	_, err := yig.PutObject(destination, object.Name, common.Credential{UserId: bucket.OwnerId},
+		object.Size, data, metadata, object.ACL, sseRequest, storageClass, datatype.ChecksumRequest{},
+		object.Tagging)
+	return err
+}
+
+func (yig *YigStorage) replicateRemotely(target string, object *meta.Object, destination string,
+	rule *datatype.ReplicationRule, data io.Reader) error {
+
+	client, err := yig.replicationClient(target)
+	if err != nil {
+		return err
+	}
+	input := &s3manager.UploadInput{
+		Bucket:   aws.String(destination),
+		Key:      aws.String(object.Name),
+		Body:     data,
+		Metadata: make(map[string]*string),
+	}
+	if object.ContentType != "" {
+		input.ContentType = aws.String(object.ContentType)
+	}
+	for k, v := range object.CustomAttributes {
+		key := strings.ToLower(k)
+		switch {
+		case strings.HasPrefix(key, "x-amz-meta-"):
+			input.Metadata[k[len("x-amz-meta-"):]] = aws.String(v)
+		case key == "cache-control":
+			input.CacheControl = aws.String(v)
+		case key == "content-disposition":
+			input.ContentDisposition = aws.String(v)
+		case key == "content-encoding":
+			input.ContentEncoding = aws.String(v)
+		case key == "content-language":
+			input.ContentLanguage = aws.String(v)
+		case key == "x-amz-website-redirect-location":
+			input.WebsiteRedirectLocation = aws.String(v)
+		}
+	}
+	if len(object.Tagging.TagSet) != 0 {
+		input.Tagging = aws.String(object.Tagging.Encode())
+	}
+	if rule.Destination.StorageClass != "" {
+		input.StorageClass = aws.String(rule.Destination.StorageClass)
+	}
+	if object.SseType == crypto.S3.String() {
+		input.ServerSideEncryption = aws.String(crypto.SSEAlgorithmAES256)
+	}
+	_, err = s3manager.NewUploaderWithClient(client).Upload(input)
+	return err
+}
+
+func (yig *YigStorage) replicationClient(target string) (*s3.S3, error) {
+	r := yig.replicator
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if client, ok := r.clients[target]; ok {
+		return client, nil
+	}
+	config, ok := helper.CONFIG.ReplicationTargets[target]
+	if !ok {
+		return nil, ErrInvalidReplicationDestination
+	}
+	region := config.Region
+	if region == "" {
+		region = helper.CONFIG.Region
+	}
+	sess, err := session.NewSession(&aws.Config{
+		Endpoint:         aws.String(config.Endpoint),
+		Region:           aws.String(region),
+		Credentials:      credentials.NewStaticCredentials(config.AccessKey, config.SecretKey, ""),
+		S3ForcePathStyle: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	client := s3.New(sess)
+	r.clients[target] = client
+	return client, nil
+}
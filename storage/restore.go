@@ -0,0 +1,303 @@
+package storage
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/journeymidnight/yig/api/datatype"
+	. "github.com/journeymidnight/yig/error"
+	"github.com/journeymidnight/yig/helper"
+	"github.com/journeymidnight/yig/iam/common"
+	meta "github.com/journeymidnight/yig/meta/types"
+	"github.com/journeymidnight/yig/redis"
+)
+
+// Objects of GLACIER and DEEP_ARCHIVE storage classes are archived, their
+// data is stored in `archive_pool` and reads fail with InvalidObjectState.
+// RestoreObject marks the object version as restoring and queues a task into
+// the `restore` table, which is claimed by workers of every instance like
+// replication tasks. Workers copy the stored data as is into a standard
+// pool, and the copy is read instead until it expires `Days` after restored.
+// The same task is scheduled again at the expiry date to put the copy into gc.
+// Restored copies are not moved by cluster drains.
+
+const (
+	// tasks claimed by crashed instances are processed again after the lease
+	restoreLease            = 30 * time.Minute
+	restoreScanLimit        = 100
+	restoreRetryMaxInterval = time.Hour
+	maxRestoreError         = 1024
+)
+
+// Restore archived object version for `request.Days`, the expiry date is
+// extended if already restored. Returns true if the object could be read now,
+// or false if a restore is initiated.
+func (yig *YigStorage) RestoreObject(bucketName, objectName, version string, request datatype.RestoreRequest,
+	credential common.Credential) (restored bool, err error) {
+
+	bucket, err := yig.MetaStorage.GetBucket(bucketName, true)
+	if err != nil {
+		return
+	}
+	var object *meta.Object
+	if version == "" {
+		object, err = yig.MetaStorage.GetObject(bucketName, objectName, false)
+	} else {
+		object, err = yig.getObjWithVersion(bucketName, objectName, version)
+	}
+	if err != nil {
+		return
+	}
+	if bucket.OwnerId != credential.UserId && object.OwnerId != credential.UserId &&
+		!credential.AllowOtherUserAccess {
+		return false, ErrAccessDenied
+	}
+	if object.DeleteMarker {
+		return false, ErrMethodNotAllowed
+	}
+	if !object.IsArchived() {
+		return false, ErrInvalidObjectState
+	}
+
+	restore := object.Restore
+	switch {
+	case restore == nil:
+		object.Restore = &meta.ObjectRestore{
+			Ongoing: true,
+			Days:    request.Days,
+			Tier:    request.GlacierJobParameters.Tier,
+		}
+		err = yig.MetaStorage.RequestObjectRestore(object, true)
+	case restore.Ongoing:
+		return false, ErrRestoreAlreadyInProgress
+	default:
+		// the task already scheduled at the old expiry date reschedules itself
+		restore.Days = request.Days
+		restore.ExpiryDate = restoreExpiryDate(time.Now(), request.Days)
+		err = yig.MetaStorage.RequestObjectRestore(object, false)
+		restored = true
+	}
+	if err != nil {
+		return false, err
+	}
+	yig.removeObjectCache(object)
+	return restored, nil
+}
+
+// Restored copies are kept for `days` since `from`
+func restoreExpiryDate(from time.Time, days int) time.Time {
+	return from.UTC().Add(time.Duration(days) * 24 * time.Hour)
+}
+
+func (yig *YigStorage) removeObjectCache(object *meta.Object) {
+	prefix := object.BucketName + ":" + object.Name + ":"
+	rowVersion := strconv.FormatUint(math.MaxUint64-uint64(object.LastModifiedTime.UnixNano()), 10)
+	yig.MetaStorage.Cache.Remove(redis.ObjectTable, prefix)
+	yig.MetaStorage.Cache.Remove(redis.ObjectTable, prefix+rowVersion)
+	yig.MetaStorage.Cache.Remove(redis.ObjectTable, prefix+object.GetVersionId())
+}
+
+// Scan and claim tasks due, and process them by `restore_concurrency` workers
+func (yig *YigStorage) restore() {
+	yig.WaitGroup.Add(1)
+	defer yig.WaitGroup.Done()
+	tasks := make(chan meta.RestoreTask)
+	defer close(tasks)
+	for i := 0; i < helper.CONFIG.RestoreConcurrency; i++ {
+		yig.WaitGroup.Add(1)
+		go func() {
+			defer yig.WaitGroup.Done()
+			for task := range tasks {
+				yig.processRestoreTask(task)
+			}
+		}()
+	}
+	for !yig.Stopping {
+		scanned, err := yig.MetaStorage.ScanDueRestoreTasks(restoreScanLimit)
+		if err != nil {
+			helper.Logger.Println(2, "failed to scan restore tasks, err:", err)
+		}
+		var claimed int
+		for i := range scanned {
+			if yig.Stopping {
+				return
+			}
+			ok, err := yig.MetaStorage.ClaimRestoreTask(&scanned[i], restoreLease)
+			if err != nil {
+				helper.Logger.Println(2, "failed to claim restore task, err:", err)
+				continue
+			}
+			if ok {
+				tasks <- scanned[i]
+				claimed++
+			}
+		}
+		if claimed == 0 || len(scanned) < restoreScanLimit {
+			time.Sleep(1 * time.Second)
+		}
+	}
+}
+
+func (yig *YigStorage) processRestoreTask(task meta.RestoreTask) {
+	nextTime, err := yig.runRestoreTask(&task)
+	if err == nil {
+		if nextTime.IsZero() {
+			err = yig.MetaStorage.RemoveRestoreTask(&task)
+		} else {
+			task.Retries = 0
+			task.NextTime = nextTime
+			task.LastError = ""
+			err = yig.MetaStorage.UpdateRestoreTask(&task)
+		}
+		if err != nil {
+			helper.Logger.Printf(2, "failed to update restore task of %s/%s, err: %v",
+				task.BucketName, task.ObjectName, err)
+		}
+		return
+	}
+
+	// restores are never given up, archived objects could not be read otherwise
+	task.Retries++
+	task.LastError = err.Error()
+	if len(task.LastError) > maxRestoreError {
+		task.LastError = task.LastError[:maxRestoreError]
+	}
+	helper.Logger.Printf(5, "failed to restore %s/%s, retry %d, err: %v",
+		task.BucketName, task.ObjectName, task.Retries, err)
+	backoff := restoreRetryMaxInterval
+	if task.Retries < 32 && time.Second<<uint(task.Retries-1) < backoff {
+		backoff = time.Second << uint(task.Retries-1)
+	}
+	task.NextTime = time.Now().Add(backoff)
+	err = yig.MetaStorage.UpdateRestoreTask(&task)
+	if err != nil {
+		helper.Logger.Printf(2, "failed to update restore task of %s/%s, err: %v",
+			task.BucketName, task.ObjectName, err)
+	}
+}
+
+// Restore the object version of `task`, or expire its restored copy.
+// Returns when the task should be processed again, zero if no longer
+// needed, e.g. the version is removed or its copy is expired.
+func (yig *YigStorage) runRestoreTask(task *meta.RestoreTask) (nextTime time.Time, err error) {
+	object, err := yig.MetaStorage.Client.GetObject(task.BucketName, task.ObjectName,
+		strconv.FormatUint(task.Version, 10))
+	if err == ErrNoSuchKey {
+		return nextTime, nil
+	} else if err != nil {
+		return nextTime, err
+	}
+	restore := object.Restore
+	if restore == nil {
+		return nextTime, nil
+	}
+	if restore.Ongoing {
+		return yig.restoreObjectData(object)
+	}
+	if time.Now().Before(restore.ExpiryDate) {
+		return restore.ExpiryDate, nil
+	}
+	expired, err := yig.MetaStorage.ExpireObjectRestore(object)
+	if err != nil {
+		return nextTime, err
+	}
+	yig.removeObjectCache(object)
+	if !expired {
+		// extended meanwhile, check the new expiry date
+		return time.Now(), nil
+	}
+	helper.Logger.Println(20, "Expired restored copy of", object.BucketName, object.Name)
+	return nextTime, nil
+}
+
+// Copy stored data of `object` into a standard pool, and point the object to
+// the copy. Returns the expiry date of the copy.
+func (yig *YigStorage) restoreObjectData(object *meta.Object) (expiryDate time.Time, err error) {
+	restore := *object.Restore
+	restore.Ongoing = false
+	var intents []meta.WriteIntent
+	// data of inline objects is in the metadata row, nothing to copy
+	if !object.IsInline() {
+		intents, err = yig.copyRestoredData(object, &restore)
+		if err != nil {
+			return
+		}
+	}
+	restore.ExpiryDate = restoreExpiryDate(time.Now(), restore.Days)
+	completed, err := yig.MetaStorage.CompleteObjectRestore(object, &restore, intents)
+	if err != nil || !completed {
+		yig.abandonWriteIntents(intents...)
+		return
+	}
+	yig.removeObjectCache(object)
+	helper.Logger.Println(20, "Restored object", object.BucketName, object.Name, "to",
+		restore.Location, restore.Pool, "until", restore.ExpiryDate)
+	return restore.ExpiryDate, nil
+}
+
+// Copy data of `object` as its restored copy, which is recorded in `restore`
+func (yig *YigStorage) copyRestoredData(object *meta.Object,
+	restore *meta.ObjectRestore) (intents []meta.WriteIntent, err error) {
+
+	source, ok := yig.DataStorage[object.Location]
+	if !ok {
+		return nil, fmt.Errorf("cannot find specified ceph cluster: %s", object.Location)
+	}
+	size := object.StoredSize()
+	if len(object.Parts) != 0 {
+		size = -1 // parts are stored as big files
+	}
+	target, poolName, err := yig.PickOneClusterAndPool(object.BucketName, object.Name, size, false,
+		meta.ObjectStorageClassStandard)
+	if err != nil {
+		return nil, err
+	}
+	restore.Location = target.Name
+	restore.Pool = poolName
+	copyData := func(sourceOid string, sourceOffset int64, oid string, size int64) error {
+		intent, err := yig.newWriteIntent(target, poolName, oid, object.BucketName, object.Name)
+		if err != nil {
+			return err
+		}
+		intents = append(intents, intent)
+		return copyStoredData(source, object.Pool, sourceOid, sourceOffset, target, poolName, oid, size)
+	}
+	if len(object.Parts) == 0 {
+		restore.ObjectId = target.GetUniqUploadName()
+		err = copyData(object.ObjectId, object.PackOffset, restore.ObjectId, object.StoredSize())
+	} else {
+		restore.PartObjectIds = make(map[int]string, len(object.Parts))
+		for n, p := range object.Parts {
+			restore.PartObjectIds[n] = target.GetUniqUploadName()
+			err = copyData(p.ObjectId, 0, restore.PartObjectIds[n], p.StoredSize())
+			if err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		yig.abandonWriteIntents(intents...)
+		return nil, err
+	}
+	return intents, nil
+}
+
+func copyStoredData(source *CephStorage, sourcePool, sourceOid string, sourceOffset int64,
+	target *CephStorage, poolName, oid string, size int64) error {
+
+	reader, err := source.getReader(sourcePool, sourceOid, sourceOffset, size)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	written, err := target.Put(poolName, oid, reader)
+	if err != nil {
+		return err
+	}
+	if written != size {
+		return fmt.Errorf("copied %d bytes of %s/%s, expected %d", written, sourcePool, sourceOid, size)
+	}
+	return nil
+}
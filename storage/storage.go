@@ -34,25 +34,35 @@ var (
 
 // *YigStorage implements api.ObjectLayer
 type YigStorage struct {
-	DataStorage map[string]*CephStorage
-	DataCache   DataCache
-	MetaStorage *meta.Meta
-	KMS         crypto.KMS
-	Logger      *log.Logger
-	Stopping    bool
-	WaitGroup   *sync.WaitGroup
+	DataStorage  map[string]*CephStorage
+	DataCache    DataCache
+	MetaStorage  *meta.Meta
+	KMS          crypto.KMS
+	Logger       *log.Logger
+	Stopping     bool
+	WaitGroup    *sync.WaitGroup
+	DrainMutex   *sync.Mutex
+	Drains       map[string]*DrainJob // fsid -> drain job
+	packer       *packer
+	bucketLogger *bucketLogger
+	replicator   *replicator
 }
 
 func New(logger *log.Logger, metaCacheType int, enableDataCache bool, CephConfigPattern string) *YigStorage {
 	kms := crypto.NewKMS()
 	yig := YigStorage{
-		DataStorage: make(map[string]*CephStorage),
-		DataCache:   newDataCache(enableDataCache),
-		MetaStorage: meta.New(logger, meta.CacheType(metaCacheType)),
-		KMS:         kms,
-		Logger:      logger,
-		Stopping:    false,
-		WaitGroup:   new(sync.WaitGroup),
+		DataStorage:  make(map[string]*CephStorage),
+		DataCache:    newDataCache(enableDataCache),
+		MetaStorage:  meta.New(logger, meta.CacheType(metaCacheType)),
+		KMS:          kms,
+		Logger:       logger,
+		Stopping:     false,
+		WaitGroup:    new(sync.WaitGroup),
+		DrainMutex:   new(sync.Mutex),
+		Drains:       make(map[string]*DrainJob),
+		packer:       newPacker(),
+		bucketLogger: newBucketLogger(),
+		replicator:   newReplicator(),
 	}
 	if CephConfigPattern == "" {
 		CephConfigPattern = DEFAULT_CEPHCONFIG_PATTERN
@@ -75,7 +85,19 @@ func New(logger *log.Logger, metaCacheType int, enableDataCache bool, CephConfig
 		helper.Logger.Panic(0, "PANIC: No data storage can be used!")
 	}
 
-	initializeRecycler(&yig)
+	yig.refreshClusterStatus()
+	go yig.monitorCapacity()
+	go yig.flushBucketLogs()
+	if helper.CONFIG.ReplicationConcurrency > 0 {
+		go yig.replicate()
+	}
+	if helper.CONFIG.BatchJobConcurrency > 0 {
+		go yig.runBatchJobs()
+	}
+	if helper.CONFIG.RestoreConcurrency > 0 {
+		go yig.restore()
+	}
+
 	return &yig
 }
 
@@ -83,6 +105,7 @@ func (y *YigStorage) Stop() {
 	y.Stopping = true
 	helper.Logger.Print(5, "Stopping storage...")
 	y.WaitGroup.Wait()
+	y.sealContainers()
 	helper.Logger.Println(5, "done")
 }
 
@@ -95,13 +118,7 @@ func (y *YigStorage) PingCache(interval time.Duration) {
 			redis.CacheCircuit.Execute(
 				context.Background(),
 				func(ctx context.Context) (err error) {
-					c, err := redis.GetClient(ctx)
-					if err != nil {
-						return err
-					}
-					defer c.Close()
-					// Use table.String() + key as Redis key
-					_, err = c.Do("PING")
+					err = redis.Ping(ctx)
 					helper.ErrorIf(err, "Cmd: %s.", "PING")
 					return err
 				},
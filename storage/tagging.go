@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/journeymidnight/yig/api/datatype"
+	. "github.com/journeymidnight/yig/error"
+	"github.com/journeymidnight/yig/iam/common"
+	meta "github.com/journeymidnight/yig/meta/types"
+	"github.com/journeymidnight/yig/redis"
+)
+
+// Tags of objects could be accessed by the bucket owner, the object owner,
+// or users allowed by bucket policy.
+func (yig *YigStorage) getObjectForTagging(bucketName, objectName, version string,
+	credential common.Credential) (object *meta.Object, err error) {
+
+	bucket, err := yig.MetaStorage.GetBucket(bucketName, true)
+	if err != nil {
+		return
+	}
+	if version == "" {
+		object, err = yig.MetaStorage.GetObject(bucketName, objectName, false)
+	} else {
+		object, err = yig.getObjWithVersion(bucketName, objectName, version)
+	}
+	if err != nil {
+		return
+	}
+	if bucket.OwnerId != credential.UserId && object.OwnerId != credential.UserId &&
+		!credential.AllowOtherUserAccess {
+		return nil, ErrAccessDenied
+	}
+	if object.DeleteMarker {
+		return nil, ErrMethodNotAllowed
+	}
+	return object, nil
+}
+
+// Replace tags of the object, empty `tagging` removes all of them.
+// Returns the version tagged.
+func (yig *YigStorage) SetObjectTagging(bucketName, objectName, version string, tagging datatype.Tagging,
+	credential common.Credential) (versionId string, err error) {
+
+	object, err := yig.getObjectForTagging(bucketName, objectName, version, credential)
+	if err != nil {
+		return
+	}
+	object.Tagging = datatype.Tagging{TagSet: tagging.TagSet}
+	err = yig.MetaStorage.UpdateObjectTagging(object)
+	if err != nil {
+		return
+	}
+	yig.MetaStorage.Cache.Remove(redis.ObjectTable, bucketName+":"+objectName+":")
+	if version != "" {
+		yig.MetaStorage.Cache.Remove(redis.ObjectTable, bucketName+":"+objectName+":"+version)
+	}
+	rowVersion := strconv.FormatUint(math.MaxUint64-uint64(object.LastModifiedTime.UnixNano()), 10)
+	yig.MetaStorage.Cache.Remove(redis.ObjectTable, bucketName+":"+objectName+":"+rowVersion)
+	return object.GetVersionId(), nil
+}
+
+func (yig *YigStorage) GetObjectTagging(bucketName, objectName, version string,
+	credential common.Credential) (tagging datatype.Tagging, versionId string, err error) {
+
+	object, err := yig.getObjectForTagging(bucketName, objectName, version, credential)
+	if err != nil {
+		return
+	}
+	return object.Tagging, object.GetVersionId(), nil
+}
+
+func (yig *YigStorage) SetBucketTagging(bucketName string, tagging datatype.Tagging,
+	credential common.Credential) error {
+
+	bucket, err := yig.MetaStorage.GetBucket(bucketName, false)
+	if err != nil {
+		return err
+	}
+	if bucket.OwnerId != credential.UserId {
+		return ErrBucketAccessForbidden
+	}
+	bucket.Tagging = datatype.Tagging{TagSet: tagging.TagSet}
+	err = yig.MetaStorage.Client.PutBucket(*bucket)
+	if err != nil {
+		return err
+	}
+	yig.MetaStorage.Cache.Remove(redis.BucketTable, bucketName)
+	return nil
+}
+
+func (yig *YigStorage) GetBucketTagging(bucketName string,
+	credential common.Credential) (tagging datatype.Tagging, err error) {
+
+	bucket, err := yig.MetaStorage.GetBucket(bucketName, true)
+	if err != nil {
+		return
+	}
+	if bucket.OwnerId != credential.UserId {
+		err = ErrBucketAccessForbidden
+		return
+	}
+	if len(bucket.Tagging.TagSet) == 0 {
+		err = ErrNoSuchTagSet
+		return
+	}
+	return bucket.Tagging, nil
+}
@@ -21,11 +21,19 @@ var config Config
 
 func printHelp() {
 	fmt.Println("Usage: admin <commands> [options...] ")
-	fmt.Println("Commands: usage|bucket|object|user|cachehit")
+	fmt.Println("Commands: usage|bucket|object|user|cachehit|drain|compression|batchjob")
 	fmt.Println("Options:")
 	fmt.Println(" -b, --bucket   Specify bucket to operate")
 	fmt.Println(" -u, --uid      Specify user name to operate")
 	fmt.Println(" -o, --object   Specify object to operate")
+	fmt.Println(" -f, --fsid     Specify Ceph cluster to drain")
+	fmt.Println(" -a, --action   Drain action: start|status|pause|resume|ratelimit, default status")
+	fmt.Println("                Batch job action: create|status|pause|resume|cancel, default status")
+	fmt.Println(" -r, --rate     Drain rate limit in bytes per second, 0 for unlimited")
+	fmt.Println(" -c, --concurrency  Number of objects drained, or keys of a batch job processed, concurrently")
+	fmt.Println(" -i, --id       Specify batch job to operate, all jobs for status if empty")
+	fmt.Println(" -j, --job      JSON file of the batch job to create, with Operation, Manifest and Report")
+	fmt.Println(" -z, --compression  Compression of bucket: snappy or zstd, empty to disable")
 }
 
 func isParaEmpty(p string) bool {
@@ -212,6 +220,153 @@ func getCacheHit() {
 
 }
 
+func drain(action string, fsid string, rateLimit int64, concurrency int) {
+	claims := jwt.MapClaims{
+		"fsid": fsid,
+	}
+	method, path := "PUT", "/admin/drain"
+	switch action {
+	case "start":
+		if isParaEmpty(fsid) {
+			return
+		}
+		if rateLimit >= 0 {
+			claims["rate_limit"] = rateLimit
+		}
+		if concurrency > 0 {
+			claims["concurrency"] = concurrency
+		}
+	case "status":
+		method = "GET"
+	case "pause", "resume":
+		if isParaEmpty(fsid) {
+			return
+		}
+		path += "/" + action
+	case "ratelimit":
+		if isParaEmpty(fsid) || rateLimit < 0 {
+			fmt.Println("Bad usage, rate limit should be specified")
+			return
+		}
+		claims["rate_limit"] = rateLimit
+		path += "/" + action
+	default:
+		printHelp()
+		return
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenString, err := token.SignedString([]byte(config.AdminKey))
+	if err != nil {
+		fmt.Println("internal error", err)
+		return
+	}
+
+	request, _ := http.NewRequest(method, config.RequestUrl+path, nil)
+	request.Header.Set("Authorization", "Bearer "+tokenString)
+	response, err := client.Do(request)
+	if err != nil {
+		fmt.Println("drain failed error:", err.Error())
+		return
+	}
+	defer response.Body.Close()
+	body, _ := ioutil.ReadAll(response.Body)
+	if response.StatusCode != 200 {
+		fmt.Println("drain failed as status != 200", response.StatusCode, string(body))
+		return
+	}
+	fmt.Println(string(body))
+}
+
+func setCompression(bucket string, compression string) {
+	if isParaEmpty(bucket) {
+		return
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"bucket":      bucket,
+		"compression": compression,
+	})
+	tokenString, err := token.SignedString([]byte(config.AdminKey))
+	if err != nil {
+		fmt.Println("internal error", err)
+		return
+	}
+
+	request, _ := http.NewRequest("PUT", config.RequestUrl+"/admin/bucket/compression", nil)
+	request.Header.Set("Authorization", "Bearer "+tokenString)
+	response, err := client.Do(request)
+	if err != nil {
+		fmt.Println("setCompression failed error:", err.Error())
+		return
+	}
+	defer response.Body.Close()
+	body, _ := ioutil.ReadAll(response.Body)
+	if response.StatusCode != 200 {
+		fmt.Println("setCompression failed as status != 200", response.StatusCode, string(body))
+		return
+	}
+	fmt.Println(string(body))
+}
+
+func batchJob(action string, id string, jobFile string, concurrency int) {
+	claims := jwt.MapClaims{
+		"id": id,
+	}
+	method, path := "PUT", "/admin/batchjob"
+	switch action {
+	case "create":
+		if isParaEmpty(jobFile) {
+			return
+		}
+		data, err := ioutil.ReadFile(jobFile)
+		if err != nil {
+			fmt.Println("Cannot read job file:", err)
+			return
+		}
+		var job map[string]interface{}
+		if err = json.Unmarshal(data, &job); err != nil {
+			fmt.Println("Bad job file:", err)
+			return
+		}
+		if concurrency > 0 {
+			job["Concurrency"] = concurrency
+		}
+		claims["job"] = job
+	case "status":
+		method = "GET"
+	case "pause", "resume", "cancel":
+		if isParaEmpty(id) {
+			return
+		}
+		path += "/" + action
+	default:
+		printHelp()
+		return
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenString, err := token.SignedString([]byte(config.AdminKey))
+	if err != nil {
+		fmt.Println("internal error", err)
+		return
+	}
+
+	request, _ := http.NewRequest(method, config.RequestUrl+path, nil)
+	request.Header.Set("Authorization", "Bearer "+tokenString)
+	response, err := client.Do(request)
+	if err != nil {
+		fmt.Println("batchjob failed error:", err.Error())
+		return
+	}
+	defer response.Body.Close()
+	body, _ := ioutil.ReadAll(response.Body)
+	if response.StatusCode != 200 {
+		fmt.Println("batchjob failed as status != 200", response.StatusCode, string(body))
+		return
+	}
+	fmt.Println(string(body))
+}
+
 func main() {
 	f, err := os.Open("./admin.json")
 	if err != nil {
@@ -231,6 +386,13 @@ func main() {
 	bucket := mySet.String("b", "", "bucket name")
 	uid := mySet.String("u", "", "user name")
 	object := mySet.String("o", "", "object name")
+	fsid := mySet.String("f", "", "fsid of Ceph cluster")
+	action := mySet.String("a", "status", "drain or batch job action")
+	rateLimit := mySet.Int64("r", -1, "drain rate limit in bytes per second")
+	concurrency := mySet.Int("c", 0, "drain or batch job concurrency")
+	jobId := mySet.String("i", "", "batch job id")
+	jobFile := mySet.String("j", "", "JSON file of batch job")
+	compression := mySet.String("z", "", "compression of bucket")
 	mySet.Parse(os.Args[2:])
 	fmt.Println("command:", os.Args[1], "bucket:", *bucket, "user:", *uid, "object:", *object)
 	switch os.Args[1] {
@@ -244,6 +406,12 @@ func main() {
 		getObjectInfo(*bucket, *object)
 	case "cachehit":
 		getCacheHit()
+	case "drain":
+		drain(*action, *fsid, *rateLimit, *concurrency)
+	case "compression":
+		setCompression(*bucket, *compression)
+	case "batchjob":
+		batchJob(*action, *jobId, *jobFile, *concurrency)
 	default:
 		printHelp()
 		return
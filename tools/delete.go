@@ -6,6 +6,7 @@ import (
 	"github.com/journeymidnight/yig/log"
 	"github.com/journeymidnight/yig/meta"
 	"github.com/journeymidnight/yig/meta/types"
+	"github.com/journeymidnight/yig/redis"
 	"github.com/journeymidnight/yig/storage"
 	"os"
 	"os/signal"
@@ -26,6 +27,7 @@ var (
 	logger      *log.Logger
 	RootContext = context.Background()
 	yigs        []*storage.YigStorage
+	compactor   *storage.YigStorage
 	gcTaskQ     chan types.GarbageCollection
 	gcWaitgroup sync.WaitGroup
 	gcStop      bool
@@ -121,6 +123,49 @@ func removeDeleted() {
 	}
 }
 
+// Put RADOS objects of write intents never committed into gc
+func sweepWriteIntents() {
+	timeout := time.Duration(helper.CONFIG.WriteIntentTimeout) * time.Second
+	for {
+		if gcStop {
+			helper.Logger.Print(5, ".")
+			return
+		}
+		intents, err := yigs[0].MetaStorage.ScanExpiredWriteIntents(timeout, SCAN_HBASE_LIMIT)
+		if err != nil {
+			helper.Logger.Println(5, "failed to scan write intents, error:", err)
+		} else if len(intents) > 0 {
+			err = yigs[0].MetaStorage.AbandonWriteIntents(intents)
+			if err != nil {
+				helper.Logger.Println(5, "failed to sweep write intents, error:", err)
+			} else {
+				helper.Logger.Println(5, "swept write intents into gc:", len(intents))
+			}
+		}
+		if err != nil || len(intents) < SCAN_HBASE_LIMIT {
+			time.Sleep(time.Duration(10000) * time.Millisecond)
+		}
+	}
+}
+
+// Rewrite containers of packed objects with too many holes
+func compactContainers() {
+	interval := time.Duration(helper.CONFIG.PackCompactionInterval) * time.Second
+	for {
+		if gcStop {
+			helper.Logger.Print(5, ".")
+			return
+		}
+		compacted, err := compactor.CompactContainers(helper.CONFIG.PackCompactionRatio)
+		if err != nil {
+			helper.Logger.Println(5, "failed to compact containers, error:", err)
+		} else if compacted > 0 {
+			helper.Logger.Println(5, "compacted containers:", compacted)
+		}
+		time.Sleep(interval)
+	}
+}
+
 func main() {
 	helper.SetupConfig()
 
@@ -145,6 +190,14 @@ func main() {
 		go deleteFromCeph(i + 1)
 	}
 	go removeDeleted()
+	go sweepWriteIntents()
+	// objects moved by compaction should be invalidated in meta cache
+	if helper.CONFIG.MetaCacheType > 0 {
+		redis.Initialize()
+		defer redis.Close()
+	}
+	compactor = storage.New(logger, helper.CONFIG.MetaCacheType, false, helper.CONFIG.CephConfigPattern)
+	go compactContainers()
 	signal.Notify(signalQueue, syscall.SIGINT, syscall.SIGTERM,
 		syscall.SIGQUIT, syscall.SIGHUP)
 	for {
@@ -157,6 +210,7 @@ func main() {
 			// gcStop YIG server, order matters
 			gcStop = true
 			gcWaitgroup.Wait()
+			compactor.Stop()
 			return
 		}
 	}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"math"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/journeymidnight/yig/api/datatype"
+	"github.com/journeymidnight/yig/crypto"
+	"github.com/journeymidnight/yig/helper"
+	"github.com/journeymidnight/yig/iam/common"
+	"github.com/journeymidnight/yig/log"
+	"github.com/journeymidnight/yig/meta/types"
+	"github.com/journeymidnight/yig/redis"
+	"github.com/journeymidnight/yig/storage"
+)
+
+// Generate reports of bucket inventory configurations, should be run
+// periodically, e.g. hourly by cron. A report is generated once for each
+// schedule of a configuration: objects are listed into gzipped CSV data
+// files in the destination bucket, followed by manifest.json listing them.
+// The progress is saved after each data file, so a report interrupted is
+// resumed by the next run.
+
+const (
+	DEFAULT_INVENTORY_LOG_PATH = "/var/log/yig/inventory.log"
+	INVENTORY_SCAN_LIMIT       = 1000
+	INVENTORY_FILE_VERSION     = "2016-11-30"
+	INVENTORY_TIME_FORMAT      = "2006-01-02T15:04:05.000Z"
+)
+
+var (
+	logger *log.Logger
+	yig    *storage.YigStorage
+	stop   bool
+
+	bucketOnly = flag.String("bucket", "", "only generate reports of this bucket")
+	workers    = flag.Int("workers", 4, "number of reports generated concurrently")
+	rows       = flag.Int("rows", 1000000, "max number of objects in each data file")
+)
+
+type inventoryTask struct {
+	bucket    types.Bucket
+	inventory datatype.InventoryConfiguration
+}
+
+type inventoryManifest struct {
+	SourceBucket      string                `json:"sourceBucket"`
+	DestinationBucket string                `json:"destinationBucket"`
+	Version           string                `json:"version"`
+	CreationTimestamp string                `json:"creationTimestamp"`
+	FileFormat        string                `json:"fileFormat"`
+	FileSchema        string                `json:"fileSchema"`
+	Files             []types.InventoryFile `json:"files"`
+}
+
+func columnsOf(inventory datatype.InventoryConfiguration) []string {
+	columns := []string{"Bucket", "Key"}
+	if inventory.IncludedObjectVersions == datatype.InventoryVersionsAll {
+		columns = append(columns, "VersionId", "IsLatest", "IsDeleteMarker")
+	}
+	return append(columns, inventory.Fields()...)
+}
+
+func encryptionStatusOf(object *types.Object) string {
+	if object.SseType == "" {
+		return "NOT-SSE"
+	}
+	return object.SseType
+}
+
+func recordOf(inventory datatype.InventoryConfiguration, object *types.Object, isLatest bool) []string {
+	record := []string{object.BucketName, url.QueryEscape(object.Name)}
+	if inventory.IncludedObjectVersions == datatype.InventoryVersionsAll {
+		record = append(record, object.GetVersionId(),
+			strconv.FormatBool(isLatest), strconv.FormatBool(object.DeleteMarker))
+	}
+	for _, field := range inventory.Fields() {
+		var value string
+		switch field {
+		case datatype.InventoryFieldSize:
+			value = strconv.FormatInt(object.Size, 10)
+		case datatype.InventoryFieldLastModifiedDate:
+			value = object.LastModifiedTime.UTC().Format(INVENTORY_TIME_FORMAT)
+		case datatype.InventoryFieldStorageClass:
+			value = object.StorageClass.ToString()
+		case datatype.InventoryFieldETag:
+			value = object.Etag
+		case datatype.InventoryFieldIsMultipartUploaded:
+			value = strconv.FormatBool(object.Type == types.ObjectTypeMultipart)
+		case datatype.InventoryFieldReplicationStatus:
+			value = object.ReplicationStatus
+		case datatype.InventoryFieldEncryptionStatus:
+			value = encryptionStatusOf(object)
+		}
+		record = append(record, value)
+	}
+	return record
+}
+
+// Objects of reports are put as the owner of the source bucket, who also
+// owns the destination bucket.
+func putReportObject(task inventoryTask, key string, data []byte,
+	contentType string) (file types.InventoryFile, err error) {
+
+	var sseRequest datatype.SseRequest
+	encryption := task.inventory.Destination.S3BucketDestination.Encryption
+	if encryption != nil && encryption.SSES3 != nil {
+		sseRequest.Type = crypto.S3.String()
+	}
+	metadata := map[string]string{"Content-Type": contentType}
+	_, err = yig.PutObject(task.inventory.Destination.S3BucketDestination.DestinationBucket(), key,
+		common.Credential{UserId: task.bucket.OwnerId}, int64(len(data)), bytes.NewReader(data),
+		metadata, datatype.Acl{CannedAcl: "private"}, sseRequest, types.ObjectStorageClassStandard,
+		datatype.ChecksumRequest{}, datatype.Tagging{})
+	if err != nil {
+		return
+	}
+	sum := md5.Sum(data)
+	return types.InventoryFile{
+		Key:         key,
+		Size:        int64(len(data)),
+		MD5checksum: hex.EncodeToString(sum[:]),
+	}, nil
+}
+
+func reportPrefix(task inventoryTask) string {
+	prefix := task.inventory.Destination.S3BucketDestination.Prefix
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return prefix + task.bucket.Name + "/" + task.inventory.Id + "/"
+}
+
+func writeDataFile(task inventoryTask, records [][]string) (file types.InventoryFile, err error) {
+	var buffer bytes.Buffer
+	compressor := gzip.NewWriter(&buffer)
+	writer := csv.NewWriter(compressor)
+	err = writer.WriteAll(records)
+	if err != nil {
+		return
+	}
+	err = compressor.Close()
+	if err != nil {
+		return
+	}
+	key := reportPrefix(task) + "data/" + string(helper.GenerateRandomId()) + ".csv.gz"
+	return putReportObject(task, key, buffer.Bytes(), "application/x-gzip")
+}
+
+func writeManifest(task inventoryTask, checkpoint *types.InventoryCheckpoint) error {
+	manifest := inventoryManifest{
+		SourceBucket:      task.bucket.Name,
+		DestinationBucket: task.inventory.Destination.S3BucketDestination.Bucket,
+		Version:           INVENTORY_FILE_VERSION,
+		CreationTimestamp: strconv.FormatInt(checkpoint.ReportTime.UnixNano()/int64(time.Millisecond), 10),
+		FileFormat:        datatype.InventoryFormatCSV,
+		FileSchema:        strings.Join(columnsOf(task.inventory), ", "),
+		Files:             checkpoint.Files,
+	}
+	if manifest.Files == nil {
+		manifest.Files = []types.InventoryFile{}
+	}
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	prefix := reportPrefix(task) + checkpoint.ReportTime.Format("2006-01-02T15-04Z") + "/"
+	file, err := putReportObject(task, prefix+"manifest.json", data, "application/json")
+	if err != nil {
+		return err
+	}
+	_, err = putReportObject(task, prefix+"manifest.checksum", []byte(file.MD5checksum), "text/plain")
+	return err
+}
+
+func generateReport(task inventoryTask) error {
+	reportTime := task.inventory.ReportTime(time.Now())
+	checkpoint, err := yig.MetaStorage.GetInventoryCheckpoint(task.bucket.Name, task.inventory.Id)
+	if err != nil {
+		return err
+	}
+	if checkpoint != nil && checkpoint.Done && !checkpoint.ReportTime.Before(reportTime) {
+		return nil
+	}
+	if checkpoint == nil || checkpoint.Done || checkpoint.ReportTime.Before(reportTime) {
+		checkpoint = &types.InventoryCheckpoint{
+			BucketName: task.bucket.Name,
+			ConfigId:   task.inventory.Id,
+			ReportTime: reportTime,
+		}
+		err = yig.MetaStorage.PutInventoryCheckpoint(checkpoint)
+		if err != nil {
+			return err
+		}
+	}
+	helper.Logger.Println(5, "Generating inventory report", task.bucket.Name, task.inventory.Id,
+		"of", reportTime, "from", checkpoint.KeyMarker, checkpoint.VersionMarker)
+
+	currentOnly := task.inventory.IncludedObjectVersions == datatype.InventoryVersionsCurrent
+	var records [][]string
+	// position of the last object in `records`, saved once they are written
+	keyMarker, versionMarker := checkpoint.KeyMarker, checkpoint.VersionMarker
+	flush := func() error {
+		if len(records) > 0 {
+			file, err := writeDataFile(task, records)
+			if err != nil {
+				return err
+			}
+			checkpoint.Files = append(checkpoint.Files, file)
+			checkpoint.Objects += int64(len(records))
+			records = records[:0]
+		}
+		checkpoint.KeyMarker, checkpoint.VersionMarker = keyMarker, versionMarker
+		return yig.MetaStorage.PutInventoryCheckpoint(checkpoint)
+	}
+
+	// versions of an object are listed newest first
+	lastName := checkpoint.KeyMarker
+	scanKey, scanVersion := checkpoint.KeyMarker, checkpoint.VersionMarker
+	for {
+		if stop {
+			return flush()
+		}
+		objects, err := yig.MetaStorage.ScanObjectVersions(task.bucket.Name, task.inventory.KeyPrefix(),
+			scanKey, scanVersion, INVENTORY_SCAN_LIMIT)
+		if err != nil {
+			return err
+		}
+		for _, object := range objects {
+			isLatest := object.Name != lastName
+			lastName = object.Name
+			scanKey = object.Name
+			scanVersion = math.MaxUint64 - uint64(object.LastModifiedTime.UnixNano())
+			if currentOnly && (!isLatest || object.DeleteMarker) {
+				continue
+			}
+			records = append(records, recordOf(task.inventory, object, isLatest))
+			keyMarker, versionMarker = scanKey, scanVersion
+			if len(records) >= *rows {
+				if err = flush(); err != nil {
+					return err
+				}
+			}
+		}
+		if len(objects) < INVENTORY_SCAN_LIMIT {
+			break
+		}
+	}
+	keyMarker, versionMarker = scanKey, scanVersion
+	if err = flush(); err != nil {
+		return err
+	}
+	if err = writeManifest(task, checkpoint); err != nil {
+		return err
+	}
+	checkpoint.Done = true
+	err = yig.MetaStorage.PutInventoryCheckpoint(checkpoint)
+	if err != nil {
+		return err
+	}
+	helper.Logger.Println(5, "Generated inventory report", task.bucket.Name, task.inventory.Id,
+		"of", reportTime, "with", checkpoint.Objects, "objects")
+	return nil
+}
+
+func generateReports() {
+	buckets, err := yig.MetaStorage.GetBuckets()
+	if err != nil {
+		helper.Logger.Println(0, "Failed to get buckets:", err)
+		return
+	}
+	tasks := make(chan inventoryTask)
+	var wg sync.WaitGroup
+	for i := 0; i < *workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for task := range tasks {
+				err := generateReport(task)
+				if err != nil {
+					helper.Logger.Println(2, "Failed to generate inventory report",
+						task.bucket.Name, task.inventory.Id, "err:", err)
+				}
+			}
+		}()
+	}
+	for _, bucket := range buckets {
+		if *bucketOnly != "" && bucket.Name != *bucketOnly {
+			continue
+		}
+		for _, inventory := range bucket.Inventory {
+			if stop {
+				break
+			}
+			if inventory.IsEnabled {
+				tasks <- inventoryTask{bucket: bucket, inventory: inventory}
+			}
+		}
+	}
+	close(tasks)
+	wg.Wait()
+}
+
+func main() {
+	flag.Parse()
+	helper.SetupConfig()
+
+	f, err := os.OpenFile(DEFAULT_INVENTORY_LOG_PATH, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		panic("Failed to open log file in current dir")
+	}
+	defer f.Close()
+	logger = log.New(f, "[yig]", log.LstdFlags, helper.CONFIG.LogLevel)
+	helper.Logger = logger
+	if helper.CONFIG.MetaCacheType > 0 || helper.CONFIG.EnableDataCache {
+		redis.Initialize()
+		defer redis.Close()
+	}
+	yig = storage.New(logger, helper.CONFIG.MetaCacheType, helper.CONFIG.EnableDataCache, helper.CONFIG.CephConfigPattern)
+	defer yig.Stop()
+
+	// reports in progress are saved and resumed by the next run
+	signalQueue := make(chan os.Signal, 1)
+	signal.Notify(signalQueue, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	go func() {
+		<-signalQueue
+		helper.Logger.Println(5, "Stopping, reports in progress are saved")
+		stop = true
+	}()
+
+	generateReports()
+}
@@ -12,7 +12,6 @@ import (
 	"os"
 	"os/signal"
 	"strconv"
-	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -94,7 +93,7 @@ func retrieveBucket(lc types.LifeCycle) error {
 	}
 	rules := bucket.LC.Rule
 	for _, rule := range rules {
-		if rule.Prefix == "" {
+		if rule.IsDefault() {
 			defaultConfig = true
 			defaultDays, err = strconv.Atoi(rule.Expiration)
 			if err != nil {
@@ -116,10 +115,10 @@ func retrieveBucket(lc types.LifeCycle) error {
 				prefixMatch := false
 				matchDays := 0
 				for _, rule := range rules {
-					if rule.Prefix == "" {
+					if rule.IsDefault() {
 						continue
 					}
-					if strings.HasPrefix(object.Name, rule.Prefix) == false {
+					if rule.Matches(object.Name, object.Tagging) == false {
 						continue
 					}
 					prefixMatch = true
@@ -159,14 +158,14 @@ func retrieveBucket(lc types.LifeCycle) error {
 		}
 	} else {
 		for _, rule := range rules {
-			if rule.Prefix == "" {
+			if rule.IsDefault() {
 				continue
 			}
 			days, _ := strconv.Atoi(rule.Expiration)
 			if err != nil {
 				return err
 			}
-			request.Prefix = rule.Prefix
+			request.Prefix = rule.KeyPrefix()
 			for {
 
 				retObjects, _, truncated, nextMarker, nextVerIdMarker, err := yig.ListObjectsInternal(bucket.Name, request)
@@ -174,6 +173,9 @@ func retrieveBucket(lc types.LifeCycle) error {
 					return err
 				}
 				for _, object := range retObjects {
+					if rule.Matches(object.Name, object.Tagging) == false {
+						continue
+					}
 					if checkIfExpiration(object.LastModifiedTime, days) {
 						_, err = yig.DeleteObject(object.BucketName, object.Name, object.VersionId, common.Credential{})
 						if err != nil {
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/journeymidnight/yig/helper"
+	"github.com/journeymidnight/yig/log"
+	"github.com/journeymidnight/yig/meta"
+	"github.com/journeymidnight/yig/meta/types"
+	"github.com/journeymidnight/yig/storage"
+)
+
+// Cross-check metadata against Ceph:
+// 1. objects and parts whose RADOS objects are missing or have wrong sizes
+// 2. RADOS objects not referenced by any object, multipart upload, write
+//    intent or gc entry, i.e. orphans leaked by crashes or failed recycling
+//
+// Orphans are found by listing pools with `rados ls` and then removing ids
+// found in metadata, twice, with `grace` between the listing and the last
+// scan, so data of uploads in progress is not reported. Uploads slower than
+// `grace` are still protected by their write intents.
+
+const (
+	DEFAULT_SCRUB_LOG_PATH = "/var/log/yig/scrub.log"
+	SCRUB_SCAN_LIMIT       = 1000
+	// libradosstriper stores an object as pieces named "<oid>.%016x"
+	STRIPE_SUFFIX_LENGTH = 17
+)
+
+const (
+	REPORT_MISSING         = "MISSING"
+	REPORT_SIZE_MISMATCH   = "SIZE_MISMATCH"
+	REPORT_UNKNOWN_CLUSTER = "UNKNOWN_CLUSTER"
+	REPORT_ERROR           = "ERROR"
+	REPORT_ORPHAN          = "ORPHAN"
+)
+
+var (
+	logger *log.Logger
+	yig    *storage.YigStorage
+
+	reportMutex sync.Mutex
+	report      *bufio.Writer
+	counts      = make(map[string]int64)
+
+	checkData    = flag.Bool("check", true, "check data of objects and parts exist with right sizes")
+	findOrphans  = flag.Bool("orphan", true, "find RADOS objects not referenced by metadata")
+	enqueue      = flag.Bool("enqueue", false, "put orphans found into gc")
+	fsid         = flag.String("cluster", "", "only scrub the Ceph cluster with this fsid")
+	grace        = flag.Duration("grace", time.Hour, "only report orphans older than this")
+	workers      = flag.Int("workers", 8, "number of objects checked concurrently")
+	reportPath   = flag.String("report", "", "path of report file, default to stdout")
+	radosCommand = flag.String("rados", "rados", "path of rados command")
+)
+
+type radosObject struct {
+	location string
+	pool     string
+}
+
+func writeReport(kind string, fields ...interface{}) {
+	reportMutex.Lock()
+	defer reportMutex.Unlock()
+	counts[kind]++
+	line := kind
+	for _, f := range fields {
+		line += fmt.Sprintf("\t%v", f)
+	}
+	report.WriteString(line + "\n")
+}
+
+func checkRadosObject(object *types.Object, partNumber int, objectId string, size int64) {
+	version := strconv.FormatUint(math.MaxUint64-uint64(object.LastModifiedTime.UnixNano()), 10)
+	cluster, ok := yig.DataStorage[object.Location]
+	if !ok {
+		writeReport(REPORT_UNKNOWN_CLUSTER, object.Location, object.Pool, objectId,
+			object.BucketName, object.Name, version, partNumber)
+		return
+	}
+	actual, err := cluster.StatObject(object.Pool, objectId, size)
+	if err == storage.ErrNoSuchRadosObject {
+		writeReport(REPORT_MISSING, object.Location, object.Pool, objectId,
+			object.BucketName, object.Name, version, partNumber)
+		return
+	}
+	if err != nil {
+		helper.Logger.Println(5, "Failed to stat", object.Location, object.Pool, objectId, "err:", err)
+		writeReport(REPORT_ERROR, object.Location, object.Pool, objectId,
+			object.BucketName, object.Name, version, partNumber, err)
+		return
+	}
+	// failed appends might leave data beyond the size,
+	// and containers are longer than objects packed in them
+	if actual == size || (object.Type == types.ObjectTypeAppendable && actual > size) || object.Packed {
+		return
+	}
+	writeReport(REPORT_SIZE_MISMATCH, object.Location, object.Pool, objectId,
+		object.BucketName, object.Name, version, partNumber, size, actual)
+}
+
+func checkObject(object *types.Object) {
+	if object.DeleteMarker {
+		return
+	}
+	if len(object.Parts) == 0 {
+		if object.ObjectId != "" {
+			checkRadosObject(object, 0, object.ObjectId, object.PackOffset+object.StoredSize())
+		}
+		return
+	}
+	for _, p := range object.Parts {
+		checkRadosObject(object, p.PartNumber, p.ObjectId, p.StoredSize())
+	}
+}
+
+func checkObjects() error {
+	objects := make(chan *types.Object, SCRUB_SCAN_LIMIT)
+	var wg sync.WaitGroup
+	for i := 0; i < *workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for object := range objects {
+				checkObject(object)
+			}
+		}()
+	}
+	defer wg.Wait()
+	defer close(objects)
+
+	var marker string
+	var scanned int64
+	for {
+		batch, nextMarker, err := yig.MetaStorage.ScanObjectsByLocation(*fsid, SCRUB_SCAN_LIMIT, marker)
+		if err != nil {
+			return err
+		}
+		for _, object := range batch {
+			objects <- object
+		}
+		scanned += int64(len(batch))
+		helper.Logger.Println(10, "Objects checked:", scanned)
+		if nextMarker == "" {
+			return nil
+		}
+		marker = nextMarker
+	}
+}
+
+// Returns the striper object id of a piece in big file pool
+func stripedObjectId(name string) string {
+	i := len(name) - STRIPE_SUFFIX_LENGTH
+	if i <= 0 || name[i] != '.' {
+		return name
+	}
+	if _, err := strconv.ParseUint(name[i+1:], 16, 64); err != nil {
+		return name
+	}
+	return name[:i]
+}
+
+func listRadosObjects(cluster *storage.CephStorage, pool string, walk func(oid string)) error {
+	cmd := exec.Command(*radosCommand, "-c", cluster.ConfigFile, "--id", "admin", "-p", pool, "ls")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	cmd.Stderr = os.Stderr
+	err = cmd.Start()
+	if err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		walk(scanner.Text())
+	}
+	if err = scanner.Err(); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return err
+	}
+	return cmd.Wait()
+}
+
+func findOrphanObjects() error {
+	start := time.Now()
+	candidates := make(map[string][]radosObject)
+	for name, cluster := range yig.DataStorage {
+		if *fsid != "" && name != *fsid {
+			continue
+		}
+		for _, pool := range []string{storage.SMALL_FILE_POOLNAME, storage.BIG_FILE_POOLNAME} {
+			location := radosObject{location: name, pool: pool}
+			err := listRadosObjects(cluster, pool, func(oid string) {
+				if pool == storage.BIG_FILE_POOLNAME {
+					oid = stripedObjectId(oid)
+				}
+				if oid == storage.CAPACITY_PROBE_OID {
+					return
+				}
+				for _, l := range candidates[oid] {
+					if l == location {
+						return
+					}
+				}
+				candidates[oid] = append(candidates[oid], location)
+			})
+			if err != nil {
+				return fmt.Errorf("list pool %s of cluster %s: %v", pool, name, err)
+			}
+			helper.Logger.Println(5, "Listed pool", pool, "of cluster", name)
+		}
+	}
+
+	// ids are unique in one cluster, an id referenced in another cluster
+	// is not reported, which is safe.
+	removeReferenced := func(objectId string) error {
+		delete(candidates, objectId)
+		return nil
+	}
+	err := yig.MetaStorage.ScanReferencedObjectIds(removeReferenced)
+	if err != nil {
+		return err
+	}
+	if len(candidates) > 0 {
+		if wait := *grace - time.Since(start); wait > 0 {
+			helper.Logger.Println(5, len(candidates), "orphan candidates found, check them again after", wait)
+			time.Sleep(wait)
+		}
+		err = yig.MetaStorage.ScanReferencedObjectIds(removeReferenced)
+		if err != nil {
+			return err
+		}
+	}
+
+	for oid, locations := range candidates {
+		for _, l := range locations {
+			writeReport(REPORT_ORPHAN, l.location, l.pool, oid)
+			if !*enqueue {
+				continue
+			}
+			garbage := &types.Object{
+				Name:             oid,
+				Location:         l.location,
+				Pool:             l.pool,
+				ObjectId:         oid,
+				LastModifiedTime: time.Now().UTC(),
+			}
+			err = yig.MetaStorage.PutObjectToGarbageCollection(garbage)
+			if err != nil {
+				helper.Logger.Println(5, "Failed to put orphan into gc", l.location, l.pool, oid, "err:", err)
+			}
+		}
+	}
+	return nil
+}
+
+func main() {
+	flag.Parse()
+	helper.SetupConfig()
+
+	f, err := os.OpenFile(DEFAULT_SCRUB_LOG_PATH, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		panic("Failed to open log file in current dir")
+	}
+	defer f.Close()
+	logger = log.New(f, "[yig]", log.LstdFlags, helper.CONFIG.LogLevel)
+	helper.Logger = logger
+
+	var out io.Writer = os.Stdout
+	if *reportPath != "" {
+		file, err := os.Create(*reportPath)
+		if err != nil {
+			panic("Failed to create report file: " + err.Error())
+		}
+		defer file.Close()
+		out = file
+	}
+	report = bufio.NewWriter(out)
+	defer report.Flush()
+
+	yig = storage.New(logger, int(meta.NoCache), false, helper.CONFIG.CephConfigPattern)
+	defer yig.Stop()
+
+	if *checkData {
+		helper.Logger.Println(5, "Start checking data of objects")
+		err = checkObjects()
+		if err != nil {
+			helper.Logger.Println(0, "Failed to check objects:", err)
+			writeReport(REPORT_ERROR, err)
+		}
+	}
+	if *findOrphans {
+		helper.Logger.Println(5, "Start finding orphan RADOS objects")
+		err = findOrphanObjects()
+		if err != nil {
+			helper.Logger.Println(0, "Failed to find orphans:", err)
+			writeReport(REPORT_ERROR, err)
+		}
+	}
+
+	var summary []string
+	for kind, count := range counts {
+		summary = append(summary, fmt.Sprintf("%s: %d", kind, count))
+	}
+	helper.Logger.Println(5, "Scrub done.", strings.Join(summary, ", "))
+	fmt.Fprintln(os.Stderr, "Scrub done.", strings.Join(summary, ", "))
+}
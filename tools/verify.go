@@ -0,0 +1,162 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/journeymidnight/yig/helper"
+	"github.com/journeymidnight/yig/log"
+	"github.com/journeymidnight/yig/meta"
+	"github.com/journeymidnight/yig/meta/types"
+	"github.com/journeymidnight/yig/storage"
+)
+
+// Re-read cold data from Ceph periodically and compare it against checksums
+// recorded at write time, to find silent corruption in RADOS. Hot data is
+// verified on read if `verify_checksum_on_read` is set.
+
+const (
+	DEFAULT_VERIFY_LOG_PATH = "/var/log/yig/verify.log"
+	VERIFY_SCAN_LIMIT       = 1000
+)
+
+var (
+	logger   *log.Logger
+	yig      *storage.YigStorage
+	verifier *storage.Verifier
+	stop     bool
+
+	reportMutex sync.Mutex
+	report      *os.File
+
+	coldAge    = flag.Duration("cold", 30*24*time.Hour, "only verify objects not modified for this long")
+	rateLimit  = flag.Int64("rate", 50<<20, "bytes read per second, 0 for unlimited")
+	interval   = flag.Duration("interval", 24*time.Hour, "wait between passes over all objects")
+	workers    = flag.Int("workers", 2, "number of objects verified concurrently")
+	reportPath = flag.String("report", "", "file to append mismatches found, default to log only")
+)
+
+func writeReport(object *types.Object, part int) {
+	version := strconv.FormatUint(math.MaxUint64-uint64(object.LastModifiedTime.UnixNano()), 10)
+	helper.Logger.Println(0, "Checksum mismatch of object", object.BucketName, object.Name,
+		"version:", version, "part:", part, "location:", object.Location, object.Pool)
+	if report == nil {
+		return
+	}
+	reportMutex.Lock()
+	defer reportMutex.Unlock()
+	fmt.Fprintf(report, "MISMATCH\t%s\t%s\t%s\t%s\t%s\t%d\t%s\n", object.Location, object.Pool,
+		object.BucketName, object.Name, version, part, time.Now().UTC().Format(time.RFC3339))
+}
+
+func verifyObject(object *types.Object) {
+	verified, mismatched, err := verifier.Verify(object)
+	if err != nil {
+		helper.Logger.Println(5, "Failed to verify object", object.BucketName, object.Name,
+			"location:", object.Location, "err:", err)
+		return
+	}
+	if !verified {
+		return
+	}
+	for _, part := range mismatched {
+		writeReport(object, part)
+	}
+}
+
+func verifyPass() error {
+	objects := make(chan *types.Object, VERIFY_SCAN_LIMIT)
+	var wg sync.WaitGroup
+	for i := 0; i < *workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for object := range objects {
+				verifyObject(object)
+			}
+		}()
+	}
+	defer wg.Wait()
+	defer close(objects)
+
+	var marker string
+	var scanned int64
+	for !stop {
+		batch, nextMarker, err := yig.MetaStorage.ScanObjectsByLocation("", VERIFY_SCAN_LIMIT, marker)
+		if err != nil {
+			return err
+		}
+		coldBefore := time.Now().Add(-*coldAge)
+		for _, object := range batch {
+			if object.LastModifiedTime.Before(coldBefore) {
+				objects <- object
+			}
+		}
+		scanned += int64(len(batch))
+		helper.Logger.Println(10, "Objects scanned:", scanned)
+		if nextMarker == "" {
+			return nil
+		}
+		marker = nextMarker
+	}
+	return nil
+}
+
+func verifyCold() {
+	for !stop {
+		start := time.Now()
+		helper.Logger.Println(5, "Start verifying cold objects")
+		err := verifyPass()
+		if err != nil {
+			helper.Logger.Println(0, "Failed to verify objects:", err)
+		} else {
+			helper.Logger.Println(5, "Verified cold objects in", time.Since(start))
+		}
+		for !stop && time.Since(start) < *interval {
+			time.Sleep(time.Second)
+		}
+	}
+}
+
+func main() {
+	flag.Parse()
+	helper.SetupConfig()
+
+	f, err := os.OpenFile(DEFAULT_VERIFY_LOG_PATH, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		panic("Failed to open log file in current dir")
+	}
+	defer f.Close()
+	logger = log.New(f, "[yig]", log.LstdFlags, helper.CONFIG.LogLevel)
+	helper.Logger = logger
+
+	if *reportPath != "" {
+		report, err = os.OpenFile(*reportPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+		if err != nil {
+			panic("Failed to open report file: " + err.Error())
+		}
+		defer report.Close()
+	}
+
+	yig = storage.New(logger, int(meta.NoCache), false, helper.CONFIG.CephConfigPattern)
+	verifier = yig.NewVerifier(*rateLimit)
+	done := make(chan struct{})
+	go func() {
+		verifyCold()
+		close(done)
+	}()
+
+	signalQueue := make(chan os.Signal, 1)
+	signal.Notify(signalQueue, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	<-signalQueue
+	stop = true
+	<-done
+	yig.Stop()
+}